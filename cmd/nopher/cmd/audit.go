@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/anthr76/nopher/internal/fetch"
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditFix     bool
+	auditJobs    int
+	auditVerbose bool
+)
+
+var auditAvailabilityCmd = &cobra.Command{
+	Use:   "audit-availability [directory]",
+	Short: "Check that every locked download URL still exists",
+	Long: `Send a HEAD request to every download URL in the lockfile, including the
+alternative sources in urls, and report dead links: deleted tags,
+force-pushed commits, removed proxy entries, and deleted repositories.
+
+A link is dead when the server answers 404 or 410. Other failures are
+reported as unreachable but do not fail the command, since they say
+nothing about the file. Modules with no live source at all are listed as
+unavailable: the next build that does not already have them will fail.
+Nothing is downloaded. Exits non-zero if any link is dead.
+
+With --fix, modules whose locked URL is dead are moved to the module proxy
+(proxy.golang.org unless GOPROXY or mirrors say otherwise) when it still
+serves the version: the zip is downloaded, checked against the go.sum hash
+recorded in the lockfile (or the locked hash, if there is none), and the
+entry's URL and hash are rewritten to the proxy source. Private modules are
+never moved.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runAuditAvailability,
+}
+
+func init() {
+	rootCmd.AddCommand(auditAvailabilityCmd)
+	auditAvailabilityCmd.Flags().BoolVar(&auditFix, "fix", false, "move modules with a dead URL to the module proxy")
+	auditAvailabilityCmd.Flags().IntVarP(&auditJobs, "jobs", "j", 8, "number of URLs to check concurrently")
+	auditAvailabilityCmd.Flags().BoolVarP(&auditVerbose, "verbose", "v", false, "also list live URLs")
+}
+
+// urlCheck is the availability of one locked download URL.
+type urlCheck struct {
+	path   string // module path the URL is fetched for
+	url    string
+	status int
+	err    error
+}
+
+func runAuditAvailability(cmd *cobra.Command, args []string) error {
+	dir := projectDir(args, 0)
+	if auditJobs < 1 {
+		return fmt.Errorf("--jobs must be at least 1")
+	}
+
+	if auditFix {
+		unlock, err := lockfile.Lock(dir)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	lfPath := lockfile.Path(dir, lockProfile)
+	lf, err := lockfile.Load(lfPath)
+	if err != nil {
+		return fmt.Errorf("loading lockfile: %w", err)
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return err
+	}
+	fetcher, err := newFetcher(cfg)
+	if err != nil {
+		return err
+	}
+	defer fetcher.Close()
+
+	targets := lockedTargets(lf)
+	checks := checkAvailability(fetcher, targets, auditJobs)
+
+	out := cmd.OutOrStdout()
+	var urls, dead, unreachable, unavailable, fixedDead int
+	var fixed []string
+	for i, t := range targets {
+		name := fmt.Sprintf("%s@%s", t.dest, t.locked.Version)
+		live := false
+		for _, c := range checks[i] {
+			urls++
+			switch {
+			case c.err != nil:
+				unreachable++
+				fmt.Fprintf(out, "unreachable  %s: %s (%v)\n", name, c.url, c.err)
+			case fetch.Gone(c.status):
+				dead++
+				fmt.Fprintf(out, "dead         %s: %s (%d %s)\n", name, c.url, c.status, http.StatusText(c.status))
+			case c.status >= 400:
+				unreachable++
+				fmt.Fprintf(out, "unreachable  %s: %s (%d %s)\n", name, c.url, c.status, http.StatusText(c.status))
+			default:
+				live = true
+				if auditVerbose {
+					fmt.Fprintf(out, "ok           %s: %s\n", name, c.url)
+				}
+			}
+		}
+
+		if auditFix && len(checks[i]) > 0 && fetch.Gone(checks[i][0].status) {
+			newURL, err := rehome(fetcher, lf, t, checks[i])
+			if err != nil {
+				fmt.Fprintf(out, "not fixed    %s: %v\n", name, err)
+			} else {
+				fmt.Fprintf(out, "fixed        %s: now %s\n", name, newURL)
+				fixed = append(fixed, name)
+				for _, c := range checks[i] {
+					if fetch.Gone(c.status) {
+						fixedDead++
+					}
+				}
+				continue
+			}
+		}
+		if !live {
+			unavailable++
+			fmt.Fprintf(out, "unavailable  %s: no live source\n", name)
+		}
+	}
+
+	if len(fixed) > 0 {
+		if err := lf.SaveAs(lfPath); err != nil {
+			return fmt.Errorf("saving lockfile: %w", err)
+		}
+	}
+
+	fmt.Fprintf(out, "Checked %d URLs for %d modules: %d dead, %d unreachable, %d modules unavailable",
+		urls, len(targets), dead, unreachable, unavailable)
+	if auditFix {
+		fmt.Fprintf(out, ", %d modules fixed", len(fixed))
+	}
+	fmt.Fprintln(out)
+	if dead > fixedDead {
+		return fmt.Errorf("%d dead links", dead-fixedDead)
+	}
+	return nil
+}
+
+// rehome moves t, whose locked URL is dead, to the module proxy and updates
+// its lockfile entry. Other live sources are kept only if the proxy zip has
+// the locked hash, since they serve the locked bytes.
+func rehome(fetcher *fetch.Fetcher, lf *lockfile.Lockfile, t fetchTarget, checks []urlCheck) (string, error) {
+	sum := lf.Modules[t.dest].Sum
+	if t.replaced {
+		sum = lf.Replace[t.dest].Sum
+	}
+	newURL, newHash, err := fetcher.Rehome(t.locked, sum)
+	if err != nil {
+		return "", err
+	}
+
+	var urls []string
+	if newHash == t.locked.Hash {
+		urls = []string{newURL}
+		for _, c := range checks {
+			if c.err == nil && c.status < 400 && c.url != newURL {
+				urls = append(urls, c.url)
+			}
+		}
+		if len(urls) < 2 {
+			urls = nil
+		}
+	}
+
+	if t.replaced {
+		r := lf.Replace[t.dest]
+		r.URL, r.URLs, r.Hash = newURL, urls, newHash
+		lf.Replace[t.dest] = r
+	} else {
+		m := lf.Modules[t.dest]
+		m.URL, m.URLs, m.Hash = newURL, urls, newHash
+		lf.Modules[t.dest] = m
+	}
+	return newURL, nil
+}
+
+// checkAvailability checks every source of targets, at most jobs at a time.
+// The results for targets[i] are in checks[i], in source order.
+func checkAvailability(fetcher *fetch.Fetcher, targets []fetchTarget, jobs int) [][]urlCheck {
+	checks := make([][]urlCheck, len(targets))
+	for i, t := range targets {
+		for _, u := range fetcher.Sources(t.locked) {
+			checks[i] = append(checks[i], urlCheck{path: t.locked.Path, url: u})
+		}
+	}
+
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i := range checks {
+		for j := range checks[i] {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(c *urlCheck) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				c.status, c.err = fetcher.CheckURL(c.path, c.url)
+			}(&checks[i][j])
+		}
+	}
+	wg.Wait()
+	return checks
+}
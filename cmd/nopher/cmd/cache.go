@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/anthr76/nopher/internal/fetch"
+)
+
+// Cache flags, shared by every command that downloads.
+var (
+	cacheDir      string
+	cachePerm     string
+	cacheKeyFile  string
+	cacheReadOnly bool
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "cache downloaded modules in `dir` instead of the user cache directory")
+	rootCmd.PersistentFlags().StringVar(&cachePerm, "cache-perm", "", "octal `mode` of cache files, such as 0640 for a group-shared cache; directories also get search permission (default 0600)")
+	rootCmd.PersistentFlags().StringVar(&cacheKeyFile, "cache-key-file", "", "authenticate cache entries with the HMAC key in this `file`, fetching entries that fail the check again")
+	rootCmd.PersistentFlags().BoolVar(&cacheReadOnly, "cache-readonly", false, "use only modules already in the cache and write nothing to it, failing with the list of modules that would need fetching")
+}
+
+// cacheOptions returns the cache file permission and integrity key from
+// the global flags. A zero permission selects the fetcher's default.
+func cacheOptions() (os.FileMode, []byte, error) {
+	var perm os.FileMode
+	if cachePerm != "" {
+		var err error
+		if perm, err = fetch.ParseCachePerm(cachePerm); err != nil {
+			return 0, nil, err
+		}
+	}
+	if cacheKeyFile == "" {
+		return perm, nil, nil
+	}
+	key, err := os.ReadFile(cacheKeyFile)
+	if err != nil {
+		return 0, nil, fmt.Errorf("reading cache key: %w", err)
+	}
+	key = bytes.TrimSpace(key)
+	if len(key) == 0 {
+		return 0, nil, fmt.Errorf("cache key file %s is empty", cacheKeyFile)
+	}
+	return perm, key, nil
+}
@@ -1,12 +1,45 @@
 package cmd
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/anthr76/nopher/internal/depsdev"
+	"github.com/anthr76/nopher/internal/fetch"
+	"github.com/anthr76/nopher/internal/hash"
+	"github.com/anthr76/nopher/internal/mod"
+	"github.com/anthr76/nopher/pkg/daemonapi"
+	"github.com/anthr76/nopher/pkg/generator"
+	"github.com/anthr76/nopher/pkg/lockfile"
 	"github.com/spf13/cobra"
+	"golang.org/x/mod/sumdb/dirhash"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
 func TestRootCommand(t *testing.T) {
@@ -98,6 +131,26 @@ golang.org/x/mod v0.32.0/go.mod h1:xyz9876
 	}
 }
 
+func TestGenerateTidyWithoutGo(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/test\n\ngo 1.22\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", t.TempDir())
+
+	rootCmd.SetArgs([]string{"generate", "--tidy", dir})
+	defer func() {
+		generateTidy = false
+		rootCmd.SetArgs(nil)
+	}()
+	if err := rootCmd.Execute(); !errors.Is(err, mod.ErrNoGo) {
+		t.Errorf("generate --tidy without go error = %v, want %v", err, mod.ErrNoGo)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "nopher.lock.yaml")); err == nil {
+		t.Error("generate --tidy should not write a lockfile when tidying fails")
+	}
+}
+
 func TestVerifyCommand(t *testing.T) {
 	// Create test directory
 	tmpDir := t.TempDir()
@@ -160,6 +213,208 @@ func TestUpdateCommandValidation(t *testing.T) {
 	}
 }
 
+func TestGenerationMeta(t *testing.T) {
+	t.Setenv("GOPROXY", "direct")
+
+	meta := generationMeta(true)
+	if meta.Generator != "nopher "+Version {
+		t.Errorf("Generator = %q, want %q", meta.Generator, "nopher "+Version)
+	}
+	if meta.Proxy != "direct" {
+		t.Errorf("Proxy = %q, want %q", meta.Proxy, "direct")
+	}
+	if meta.GeneratedAt != "" {
+		t.Errorf("GeneratedAt = %q, want empty in reproducible mode", meta.GeneratedAt)
+	}
+
+	if meta := generationMeta(false); meta.GeneratedAt == "" {
+		t.Error("GeneratedAt is empty, want timestamp")
+	}
+}
+
+func TestListCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	lockfile := `schema: 1
+go: "1.21"
+modules:
+  example.com/small:
+    version: v1.0.0
+    hash: sha256-a
+    size: 100
+    files: 2
+  example.com/big:
+    version: v1.0.0
+    hash: sha256-b
+    size: 2048
+    files: 10
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "nopher.lock.yaml"), []byte(lockfile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &cobra.Command{
+		Use:  "list",
+		RunE: runList,
+	}
+	cmd.Flags().StringVar(&listSort, "sort", "name", "")
+	cmd.SetArgs([]string{"--sort", "size", tmpDir})
+
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+
+	output := buf.String()
+	big := bytes.Index([]byte(output), []byte("example.com/big"))
+	small := bytes.Index([]byte(output), []byte("example.com/small"))
+	if big < 0 || small < 0 || big > small {
+		t.Errorf("expected example.com/big listed before example.com/small, got:\n%s", output)
+	}
+	if !contains(output, "2.1KiB") {
+		t.Errorf("expected total size 2.1KiB in output, got:\n%s", output)
+	}
+}
+
+func TestExportBuilderJSON(t *testing.T) {
+	tmpDir := t.TempDir()
+	lockfile := `schema: 1
+go: "1.21"
+modules:
+  example.com/dep:
+    version: v1.0.0
+    hash: sha256-a
+    hashType: nar
+    url: https://github.com/example/dep/archive/refs/tags/v1.0.0.zip
+    subdir: dep
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "nopher.lock.yaml"), []byte(lockfile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(tmpDir, "nopher.builder.json")
+
+	cmd := &cobra.Command{Use: "export", RunE: runExport}
+	cmd.Flags().StringVar(&exportTo, "to", "", "")
+	cmd.Flags().StringVar(&exportOut, "out", "", "")
+	cmd.SetArgs([]string{"--to", "builder-json", "--out", out, tmpDir})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b struct {
+		BuilderSchema int `json:"builderSchema"`
+		Modules       []struct {
+			Path, Version, Fetcher, Hash, Subdir string
+		}
+	}
+	if err := json.Unmarshal(data, &b); err != nil {
+		t.Fatalf("parsing export: %v\n%s", err, data)
+	}
+	if b.BuilderSchema != 1 || len(b.Modules) != 1 {
+		t.Fatalf("export = %s", data)
+	}
+	if m := b.Modules[0]; m.Path != "example.com/dep" || m.Fetcher != "fetchzip" || m.Hash != "sha256-a" || m.Subdir != "dep" {
+		t.Errorf("exported module = %+v", m)
+	}
+
+	cmd.SetArgs([]string{"--to", "yaml", tmpDir})
+	if err := cmd.Execute(); err == nil {
+		t.Error("export --to yaml should fail")
+	}
+}
+
+func TestBuildTree(t *testing.T) {
+	lf := &lockfile.Lockfile{
+		Modules: map[string]lockfile.Module{
+			"example.com/a": {Version: "v1.1.0", Hash: "sha256-a", Size: 10},
+			"example.com/b": {Version: "v1.0.0", Hash: "sha256-b"},
+		},
+	}
+	graph := map[string][]string{
+		"example.com/main":     {"example.com/a@v1.1.0", "example.com/b@v1.0.0"},
+		"example.com/a@v1.0.0": {"example.com/stale@v0.1.0"},
+		"example.com/a@v1.1.0": {"example.com/b@v0.9.0"},
+		"example.com/b@v1.0.0": {"example.com/c@v1.0.0"},
+		"example.com/b@v0.9.0": {"example.com/stale@v0.1.0"},
+	}
+
+	root := buildTree("example.com/main", graph, lf, 0)
+
+	if len(root.Requires) != 2 {
+		t.Fatalf("len(root.Requires) = %d, want 2", len(root.Requires))
+	}
+	a := root.Requires[0]
+	if a.Path != "example.com/a" || a.Version != "v1.1.0" {
+		t.Errorf("first child = %s@%s, want example.com/a@v1.1.0", a.Path, a.Version)
+	}
+	if len(a.Requires) != 1 || a.Requires[0].Version != "v1.0.0" {
+		t.Fatalf("a should require b resolved to locked v1.0.0, got %+v", a.Requires)
+	}
+	if a.Requires[0].Repeated {
+		t.Error("first occurrence of b should not be marked repeated")
+	}
+	if b := root.Requires[1]; !b.Repeated || len(b.Requires) != 0 {
+		t.Errorf("second occurrence of b should be repeated and unexpanded, got %+v", b)
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	score := 7.2
+	summaries := []*depsdev.Summary{
+		{Module: "example.com/a", Version: "v1.0.0", Project: "github.com/example/a", Stars: 42, Scorecard: &score, Licenses: []string{"MIT"}},
+		{Module: "example.com/b", Version: "v0.3.0", Advisories: []string{"GHSA-1234-5678-9abc"}},
+	}
+
+	buf := new(bytes.Buffer)
+	printReport(buf, summaries)
+	output := buf.String()
+
+	for _, want := range []string{"7.2", "42", "MIT", "example.com/a@v1.0.0", "Advisories (1 modules)", "example.com/b@v0.3.0: GHSA-1234-5678-9abc"} {
+		if !contains(output, want) {
+			t.Errorf("expected %q in output, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestSummarizeLockedPrivate(t *testing.T) {
+	t.Setenv("GOPRIVATE", "")
+	t.Setenv("GONOPROXY", "git.corp.example.com")
+	lf := lockfile.New("1.22")
+	lf.Modules["github.com/pkg/errors"] = lockfile.Module{Version: "v0.9.1", Hash: "sha256-e"}
+	lf.Modules["git.corp.example.com/team/secret"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-s"}
+	lf.Modules["example.com/internal/tool"] = lockfile.Module{Version: "v0.2.0", Hash: "sha256-t"}
+
+	var mu sync.Mutex
+	var looked []string
+	summarize := func(modulePath, version string) (*depsdev.Summary, error) {
+		mu.Lock()
+		looked = append(looked, modulePath)
+		mu.Unlock()
+		return &depsdev.Summary{Module: modulePath, Version: version, Licenses: []string{"MIT"}}, nil
+	}
+
+	cfg := &config.Config{Private: []string{"example.com/internal"}}
+	summaries := summarizeLocked(lf, privateModules(cfg), summarize)
+	if !reflect.DeepEqual(looked, []string{"github.com/pkg/errors"}) {
+		t.Errorf("looked up %v, want only the public module", looked)
+	}
+	for _, s := range summaries {
+		if s.Module != "github.com/pkg/errors" && len(s.Licenses) > 0 {
+			t.Errorf("private module %s has data: %+v", s.Module, s)
+		}
+	}
+	if len(summaries) != 3 {
+		t.Errorf("got %d summaries, want private modules reported too", len(summaries))
+	}
+}
+
 func contains(s, substr string) bool {
 	if len(s) == 0 || len(substr) == 0 {
 		return false
@@ -171,3 +426,1669 @@ func contains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestStoreModules(t *testing.T) {
+	lf := lockfile.New("1.22")
+	lf.Modules["github.com/sirupsen/logrus"] = lockfile.Module{
+		Version: "v1.9.3",
+		Hash:    "sha256-E5GnOMrWPCJLof4UFRJ9sLQKLpALbstsrqHmnWpnn5w=",
+		URL:     "https://github.com/sirupsen/logrus/archive/refs/tags/v1.9.3.zip",
+		Rev:     "d40e25cd45ed9c6b2b66e6b97573a0413e4c23bd",
+	}
+	lf.Modules["golang.org/x/mod"] = lockfile.Module{
+		Version: "v0.32.0",
+		Hash:    "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+	}
+	lf.Modules["github.com/pkg/errors"] = lockfile.Module{
+		Version:  "v0.9.1",
+		Hash:     "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+		HashType: lockfile.HashNAR,
+		URL:      "https://github.com/pkg/errors/archive/refs/tags/v0.9.1.zip",
+	}
+	// Locked by an older nopher, by the archive's bytes.
+	lf.Modules["github.com/pkg/legacy"] = lockfile.Module{
+		Version: "v1.0.0",
+		Hash:    "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+		URL:     "https://github.com/pkg/legacy/archive/refs/tags/v1.0.0.zip",
+	}
+	lf.Replace["example.com/old"] = lockfile.Replace{
+		New:     "example.com/new",
+		Version: "v1.0.0",
+		Hash:    "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+		URL:     "https://proxy.example.com/example.com/new/@v/v1.0.0.zip",
+	}
+
+	modules, err := storeModules(lf)
+	if err != nil {
+		t.Fatalf("storeModules() error = %v", err)
+	}
+	if len(modules) != 5 {
+		t.Fatalf("len(modules) = %d, want 5", len(modules))
+	}
+
+	replaced, pkgerrors, legacy, logrus, xmod := modules[0], modules[1], modules[2], modules[3], modules[4]
+	if replaced.name != "example.com/old" || replaced.fodName != "v1.0.0.zip" {
+		t.Errorf("replacement = %+v", replaced)
+	}
+	if !logrus.fetchGit {
+		t.Error("module with full rev and GitHub archive URL should use fetchGit")
+	}
+	if pkgerrors.fetchGit || !pkgerrors.recursive || pkgerrors.fodName != "source" {
+		t.Errorf("GitHub archive without a rev should use fetchzip: %+v", pkgerrors)
+	}
+	if legacy.fetchGit || legacy.recursive || legacy.fodName != "v1.0.0.zip" {
+		t.Errorf("GitHub archive locked by its bytes should use fetchurl: %+v", legacy)
+	}
+	want := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if xmod.fetchGit || xmod.recursive || xmod.fodName != "v0.32.0.zip" || xmod.hexHash != want {
+		t.Errorf("golang.org/x/mod = %+v", xmod)
+	}
+}
+
+func TestNarinfoCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	source := filepath.Join(tmpDir, "source")
+	out := filepath.Join(tmpDir, "cache")
+
+	zipDir := filepath.Join(source, "example.com", "foo", "@v")
+	if err := os.MkdirAll(zipDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	zipData := []byte("PK\x05\x06" + string(make([]byte, 18))) // empty zip archive
+	if err := os.WriteFile(filepath.Join(zipDir, "v1.0.0.zip"), zipData, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(zipData)
+
+	lockfile := "schema: 1\ngo: \"1.21\"\nmodules:\n  example.com/foo:\n    version: v1.0.0\n    hash: " +
+		"sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "nopher.lock.yaml"), []byte(lockfile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &cobra.Command{Use: "narinfo", RunE: runNarinfo}
+	cmd.Flags().StringVar(&narinfoOut, "out", "", "")
+	cmd.Flags().StringVar(&narinfoSource, "source", "", "")
+	cmd.Flags().StringVar(&narinfoStoreDir, "store-dir", "/nix/store", "")
+	cmd.SetArgs([]string{"--out", out, "--source", source, tmpDir})
+	cmd.SetOut(new(bytes.Buffer))
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("narinfo failed: %v", err)
+	}
+
+	infos, _ := filepath.Glob(filepath.Join(out, "*.narinfo"))
+	if len(infos) != 1 {
+		t.Fatalf("expected 1 narinfo, got %v", infos)
+	}
+	data, err := os.ReadFile(infos[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := string(data)
+	for _, want := range []string{"StorePath: /nix/store/", "-v1.0.0.zip\n", "NarHash: sha256:", "Compression: none", "References: \n"} {
+		if !contains(info, want) {
+			t.Errorf("narinfo missing %q:\n%s", want, info)
+		}
+	}
+	nars, _ := filepath.Glob(filepath.Join(out, "nar", "*.nar"))
+	if len(nars) != 1 {
+		t.Errorf("expected 1 NAR file, got %v", nars)
+	}
+}
+
+func TestSyncFlake(t *testing.T) {
+	src := `{
+  outputs = { self, nixpkgs }:
+    let
+      goVersion = "1.21";
+    in {
+      packages.default = nopher.buildNopherGoApp {
+        go = pkgs.go;
+        modules = ./nix/nopher.lock.yaml;
+      };
+      devShells.default = pkgs.mkShell { packages = [ pkgs.go ]; };
+    };
+}
+`
+	target := flakeSyncTarget{goVersion: "1.22.3", lockfile: "nopher.lock.yaml.gz", pinGo: true}
+
+	got, changes := syncFlake(src, target)
+	if len(changes) != 3 {
+		t.Errorf("changes = %v, want 3", changes)
+	}
+	for _, want := range []string{`goVersion = "1.22.3";`, "go = pkgs.go_1_22;", "modules = ./nix/nopher.lock.yaml.gz;", "packages = [ pkgs.go ]"} {
+		if !contains(got, want) {
+			t.Errorf("expected %q in synced flake:\n%s", want, got)
+		}
+	}
+
+	again, changes := syncFlake(got, target)
+	if len(changes) != 0 || again != got {
+		t.Errorf("second sync should be a no-op, got changes %v", changes)
+	}
+}
+
+func TestFlakeInitCommand(t *testing.T) {
+	dir := t.TempDir()
+	gomod := "module example.com/foo/v2\n\ngo 1.22.1\n\ntoolchain go1.22.5\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	flakeInitForce = false
+	if err := runFlakeInit(flakeInitCmd, []string{dir}); err != nil {
+		t.Fatalf("runFlakeInit: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "flake.nix"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{`goVersion = "1.22.5";`, "go = pkgs.go_1_22;", `pname = "foo";`, "modules = ./nopher.lock.yaml;", "devShells.default", `GOTOOLCHAIN = "local";`} {
+		if !contains(got, want) {
+			t.Errorf("expected %q in generated flake:\n%s", want, got)
+		}
+	}
+
+	if _, changes := syncFlake(got, flakeSyncTarget{goVersion: "1.22.5", lockfile: "nopher.lock.yaml", pinGo: true}); len(changes) != 0 {
+		t.Errorf("sync on a fresh flake should be a no-op, got %v", changes)
+	}
+
+	if err := runFlakeInit(flakeInitCmd, []string{dir}); err == nil {
+		t.Error("expected error when flake.nix already exists")
+	}
+}
+
+func TestFlakeInitWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.work":                "go 1.23.2\n\nuse (\n\t./cmd/api\n\t./lib\n)\n",
+		"cmd/api/go.mod":         "module example.com/api\n\ngo 1.23\n",
+		"cmd/api/main.go":        "package main\n\nfunc main() {}\n",
+		"lib/go.mod":             "module example.com/lib\n\ngo 1.23\n",
+		"lib/lib.go":             "package lib\n",
+		"lib/testdata/x/main.go": "package main\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := loadFlakeData(dir)
+	if err != nil {
+		t.Fatalf("loadFlakeData: %v", err)
+	}
+	if data.GoVersion != "1.23.2" || data.GoAttr != "go_1_23" {
+		t.Errorf("go = %s (%s), want 1.23.2 (go_1_23)", data.GoVersion, data.GoAttr)
+	}
+	if len(data.Packages) != 1 {
+		t.Fatalf("Packages = %+v, want only api", data.Packages)
+	}
+	if p := data.Packages[0]; p.Attr != "api" || p.SubPackages != "./cmd/api/..." {
+		t.Errorf("package = %+v", p)
+	}
+
+	data.Lockfile = "nopher.lock.yaml"
+	var buf bytes.Buffer
+	if err := flakeTemplate.Execute(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"packages.api = ", `subPackages = [ "./cmd/api/..." ];`, "modules = ./nopher.lock.yaml;"} {
+		if !contains(buf.String(), want) {
+			t.Errorf("expected %q in generated flake:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestFetchSigners(t *testing.T) {
+	signers, err := fetchSigners(map[string]config.Signing{
+		"goproxy.internal.example.com": {SigV4: &config.SigV4{Region: "us-east-1"}},
+		"goproxy.corp.example":         {IAP: &config.IAP{Audience: "client-id"}},
+	})
+	if err != nil {
+		t.Fatalf("fetchSigners: %v", err)
+	}
+	if _, ok := signers["goproxy.internal.example.com"].(*fetch.SigV4Signer); !ok {
+		t.Errorf("sigv4 host signer = %T", signers["goproxy.internal.example.com"])
+	}
+	if _, ok := signers["goproxy.corp.example"].(*fetch.IAPSigner); !ok {
+		t.Errorf("iap host signer = %T", signers["goproxy.corp.example"])
+	}
+
+	for _, bad := range []config.Signing{
+		{},
+		{SigV4: &config.SigV4{}},
+		{IAP: &config.IAP{}},
+		{SigV4: &config.SigV4{Region: "us-east-1"}, IAP: &config.IAP{Audience: "x"}},
+	} {
+		if _, err := fetchSigners(map[string]config.Signing{"h": bad}); err == nil {
+			t.Errorf("fetchSigners(%+v) should fail", bad)
+		}
+	}
+}
+
+func TestConfigOptionsGitHubApp(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "app.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyFile, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := configOptions(&config.Config{GitHubApp: &config.GitHubApp{AppID: 42, Owner: "acme", PrivateKeyFile: keyFile}})
+	if err != nil {
+		t.Fatalf("configOptions: %v", err)
+	}
+	if app := opts.GitHubApp; app == nil || app.AppID != 42 || app.Owner != "acme" || !app.PrivateKey.Equal(key) {
+		t.Errorf("GitHubApp = %+v", opts.GitHubApp)
+	}
+
+	for _, bad := range []config.GitHubApp{
+		{PrivateKeyFile: keyFile},
+		{AppID: 42},
+		{AppID: 42, PrivateKeyFile: filepath.Join(t.TempDir(), "missing.pem")},
+	} {
+		if _, err := configOptions(&config.Config{GitHubApp: &bad}); err == nil {
+			t.Errorf("configOptions(%+v) should fail", bad)
+		}
+	}
+}
+
+func TestChangedSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("go.mod", "module example.com/app\n\ngo 1.22\n\nrequire (\n\tgithub.com/a/a v1.0.0\n\tgithub.com/b/b v1.0.0\n\tgithub.com/c/c v1.0.0\n)\n")
+	write("go.sum", "github.com/a/a v1.0.0 h1:a=\ngithub.com/b/b v1.0.0 h1:b=\ngithub.com/c/c v1.0.0 h1:c=\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "base")
+
+	write("go.mod", "module example.com/app\n\ngo 1.22\n\nrequire (\n\tgithub.com/a/a v1.1.0\n\tgithub.com/c/c v1.0.0\n\tgithub.com/d/d v1.0.0\n)\n\nreplace github.com/c/c => github.com/fork/c v1.0.1\n")
+	write("go.sum", "github.com/a/a v1.1.0 h1:a2=\ngithub.com/c/c v1.0.0 h1:c=\ngithub.com/d/d v1.0.0 h1:d=\ngithub.com/fork/c v1.0.1 h1:fc=\n")
+
+	changed, all, err := changedSince(dir, "HEAD")
+	if err != nil {
+		t.Fatalf("changedSince() error = %v", err)
+	}
+	if all {
+		t.Fatal("changedSince() all = true, want scoped result")
+	}
+	for _, p := range []string{"github.com/a/a", "github.com/b/b", "github.com/c/c", "github.com/d/d", "github.com/fork/c"} {
+		if !changed[p] {
+			t.Errorf("%s should be changed; got %v", p, changed)
+		}
+	}
+	if len(changed) != 5 {
+		t.Errorf("changed = %v, want 5 modules", changed)
+	}
+
+	if _, _, err := changedSince(dir, "no-such-ref"); err == nil {
+		t.Error("changedSince() with unknown ref should fail")
+	}
+}
+
+func TestFindUpdates(t *testing.T) {
+	modInfo := &mod.ModInfo{
+		Requires: []mod.Require{
+			{Path: "github.com/z/z", Version: "v1.0.0"},
+			{Path: "github.com/a/a", Version: "v1.0.0"},
+			{Path: "github.com/current/c", Version: "v1.2.0"},
+			{Path: "github.com/indirect/i", Version: "v1.0.0", Indirect: true},
+			{Path: "github.com/replaced/r", Version: "v1.0.0"},
+			{Path: "github.com/denied/d", Version: "v1.0.0"},
+			{Path: "github.com/missing/m", Version: "v1.0.0"},
+			{Path: "git.internal.example.com/sdk", Version: "v0.0.0-20240101000000-aaaaaaaaaaaa", Indirect: true},
+		},
+		Replaces: []mod.Replace{{Old: "github.com/replaced/r", New: "../r", IsLocal: true}},
+	}
+	latest := func(path, branch string) (*fetch.ModuleInfo, error) {
+		if branch != "" {
+			if path != "git.internal.example.com/sdk" || branch != "main" {
+				t.Errorf("latest(%s, %s) for untracked module", path, branch)
+			}
+			return &fetch.ModuleInfo{Version: "v0.0.0-20240105000000-bbbbbbbbbbbb"}, nil
+		}
+		switch path {
+		case "github.com/current/c":
+			return &fetch.ModuleInfo{Version: "v1.2.0"}, nil
+		case "github.com/missing/m":
+			return nil, fetch.ErrNoVersions
+		}
+		return &fetch.ModuleInfo{Version: "v1.1.0", Time: "2024-01-02T03:04:05Z"}, nil
+	}
+	permit := func(path, version string) bool { return path != "github.com/denied/d" }
+
+	updates := findUpdates(modInfo, upgradeScope{}, latest, permit)
+	if len(updates) != 2 || updates[0].Path != "github.com/a/a" || updates[1].Path != "github.com/z/z" {
+		t.Fatalf("findUpdates() = %+v, want a/a and z/z", updates)
+	}
+	if updates[0].Latest != "v1.1.0" || updates[0].Published.IsZero() {
+		t.Errorf("update = %+v, want v1.1.0 with publish time", updates[0])
+	}
+
+	if updates := findUpdates(modInfo, upgradeScope{Indirect: true}, latest, permit); len(updates) != 4 {
+		t.Errorf("findUpdates(indirect) = %+v, want 4 updates", updates)
+	}
+
+	track := map[string]string{"git.internal.example.com/sdk": "main"}
+	updates = findUpdates(modInfo, upgradeScope{TrackedOnly: true, Track: track}, latest, permit)
+	if len(updates) != 1 || updates[0].Latest != "v0.0.0-20240105000000-bbbbbbbbbbbb" || updates[0].Branch != "main" {
+		t.Errorf("findUpdates(tracked) = %+v, want the branch head of the tracked module", updates)
+	}
+	if updates := findUpdates(modInfo, upgradeScope{Track: track}, latest, permit); len(updates) != 3 {
+		t.Errorf("findUpdates(track) = %+v, want 3 updates", updates)
+	}
+}
+
+func TestTargetUpdates(t *testing.T) {
+	modInfo := &mod.ModInfo{
+		Requires: []mod.Require{
+			{Path: "github.com/z/z", Version: "v1.0.0"},
+			{Path: "github.com/a/a", Version: "v1.0.0"},
+			{Path: "github.com/replaced/r", Version: "v1.0.0"},
+		},
+		Replaces: []mod.Replace{{Old: "github.com/replaced/r", New: "../r", IsLocal: true}},
+	}
+
+	updates, err := targetUpdates(modInfo, []string{"github.com/z/z@1.2.0", "github.com/a/a@v1.0.0"})
+	if err != nil {
+		t.Fatalf("targetUpdates() error = %v", err)
+	}
+	if len(updates) != 1 || updates[0].Path != "github.com/z/z" || updates[0].Current != "v1.0.0" || updates[0].Latest != "v1.2.0" {
+		t.Errorf("targetUpdates() = %+v, want z/z v1.0.0 -> v1.2.0", updates)
+	}
+
+	for target, want := range map[string]string{
+		"github.com/a/a":                "want module@version",
+		"github.com/a/a@v1.2":           "use a full version such as v1.2.0",
+		"github.com/a/a@v2.0.0":         "should be v0 or v1",
+		"github.com/other/o@v1.0.0":     "not required in go.mod",
+		"github.com/replaced/r@v1.1.0":  "replaced in go.mod",
+		"github.com/a/a@v1.1.0+build.1": "build metadata",
+	} {
+		if _, err := targetUpdates(modInfo, []string{target}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("targetUpdates(%s) error = %v, want %q", target, err, want)
+		}
+	}
+}
+
+func TestPinCommit(t *testing.T) {
+	tests := []struct {
+		rev     string
+		want    string
+		wantErr string
+	}{
+		{"3d8f5e7", "3d8f5e7", ""},
+		{"v0.0.0-20240102150405-abcdef123456", "abcdef123456", ""},
+		{"0.0.0-20240102150405-abcdef123456", "abcdef123456", ""},
+		{"main", "", "not a git commit hash or pseudo-version"},
+		{"v1.2.3", "", "use nopher update example.com/lib@v1.2.3"},
+		{"v0.0.0-20240102150405-ABCDEF123456", "", "12-character lowercase hash"},
+	}
+	for _, tt := range tests {
+		got, err := pinCommit("example.com/lib", tt.rev)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("pinCommit(%s) error = %v, want %q", tt.rev, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("pinCommit(%s) = %q, %v, want %q", tt.rev, got, err, tt.want)
+		}
+	}
+}
+
+func TestSelectUpdates(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	updates := []moduleUpdate{
+		{Path: "github.com/a/a", Current: "v1.0.0", Latest: "v1.1.0", Published: now.Add(-72 * time.Hour)},
+		{Path: "github.com/b/b", Current: "v1.0.0", Latest: "v2.0.0"},
+		{Path: "github.com/c/c", Current: "v0.1.0", Latest: "v0.2.0", Branch: "main"},
+	}
+
+	var out bytes.Buffer
+	selected, err := selectUpdates(strings.NewReader("3, 1\n"), &out, updates, now)
+	if err != nil {
+		t.Fatalf("selectUpdates() error = %v", err)
+	}
+	if len(selected) != 2 || selected[0].Path != "github.com/a/a" || selected[1].Path != "github.com/c/c" {
+		t.Errorf("selectUpdates() = %+v, want a/a and c/c", selected)
+	}
+	for _, want := range []string{"[1] github.com/a/a v1.0.0 -> v1.1.0 (3d0h old)", "[2] github.com/b/b v1.0.0 -> v2.0.0 (unknown age)", "(unknown age, tracking main)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	if selected, _ := selectUpdates(strings.NewReader(""), io.Discard, updates, now); len(selected) != 0 {
+		t.Errorf("empty selection = %+v, want none", selected)
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []int
+		wantErr bool
+	}{
+		{in: "all", want: []int{0, 1, 2, 3}},
+		{in: "2-3 1,2", want: []int{0, 1, 2}},
+		{in: "4", want: []int{3}},
+		{in: "", want: []int{}},
+		{in: "5", wantErr: true},
+		{in: "3-2", wantErr: true},
+		{in: "x", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSelection(tt.in, 4)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSelection(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSelection(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSelftestCommand(t *testing.T) {
+	dir := t.TempDir()
+	goMod := "module example.com/app\n\ngo 1.22\n\nrequire example.com/lib v1.0.0\n\nreplace example.com/lib => ../lib\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.sum"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	selftestCold = true
+	defer func() { selftestCold = false }()
+
+	buf := new(bytes.Buffer)
+	cmd := &cobra.Command{RunE: runSelftest}
+	cmd.SetOut(buf)
+	if err := runSelftest(cmd, []string{dir}); err != nil {
+		t.Fatalf("runSelftest() error = %v\n%s", err, buf.String())
+	}
+	if !strings.Contains(buf.String(), "is reproducible (0 modules, 1 replacements)") {
+		t.Errorf("output = %q", buf.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, lockfile.DefaultLockfile)); !os.IsNotExist(err) {
+		t.Error("selftest should not write the project lockfile")
+	}
+}
+
+func TestDiffLockfiles(t *testing.T) {
+	a := lockfile.New("1.22")
+	a.Modules["example.com/a"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-a", URL: "https://proxy/a.zip"}
+	a.Modules["example.com/b"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-b"}
+	a.Modules["example.com/gone"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-g"}
+	a.Replace["example.com/old"] = lockfile.Replace{New: "example.com/new", Version: "v1.0.0", Hash: "sha256-n"}
+
+	b := lockfile.New("1.22")
+	b.Modules["example.com/a"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-a", URL: "https://github.com/a.tar.gz"}
+	b.Modules["example.com/b"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-B", Annotations: lockfile.Annotations{Notes: "x"}}
+	b.Replace["example.com/old"] = lockfile.Replace{New: "example.com/new", Version: "v1.0.0", Hash: "sha256-n"}
+
+	diffs := diffLockfiles(a, b, false)
+	if len(diffs) != 3 || !strings.HasPrefix(diffs[0], "example.com/a: ") || diffs[2] != "example.com/gone: only in run 1" {
+		t.Errorf("diffLockfiles() = %q", diffs)
+	}
+
+	diffs = diffLockfiles(a, b, true)
+	if len(diffs) != 2 || diffs[0] != "example.com/b: v1.0.0 sha256-b (run 1) != v1.0.0 sha256-B (run 2)" {
+		t.Errorf("diffLockfiles(hashesOnly) = %q", diffs)
+	}
+
+	if diffs := diffLockfiles(a, a, false); len(diffs) != 0 {
+		t.Errorf("diffLockfiles(a, a) = %q, want none", diffs)
+	}
+}
+
+func TestHashCheckCommand(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", "")
+
+	buf := new(bytes.Buffer)
+	cmd := &cobra.Command{RunE: runHashCheck}
+	cmd.SetOut(buf)
+	if err := runHashCheck(cmd, []string{dir}); err != nil {
+		t.Fatalf("runHashCheck() error = %v\n%s", err, buf.String())
+	}
+	if !strings.Contains(buf.String(), "nix: not available") || !strings.Contains(buf.String(), "No known divergent structures") {
+		t.Errorf("output = %q", buf.String())
+	}
+
+	odd := filepath.Join(dir, "odd")
+	if err := os.WriteFile(odd, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(odd, 0o645); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := runHashCheck(cmd, []string{dir}); err == nil {
+		t.Errorf("runHashCheck() should fail on divergent tree:\n%s", buf.String())
+	}
+}
+
+func TestModulePaths(t *testing.T) {
+	dir := t.TempDir()
+	goMod := `module example.com/app
+
+go 1.21
+
+require (
+	example.com/direct v1.0.0
+	golang.org/x/mod v0.1.0 // indirect
+)
+`
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lf := lockfile.New("1.21")
+	lf.Modules["example.com/locked"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-a"}
+	lf.Modules["example.com/direct"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-b"}
+	if err := lf.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	got := modulePaths(dir, "example.com/")
+	want := []string{"example.com/direct", "example.com/locked"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("modulePaths() = %v, want %v", got, want)
+	}
+	if got := modulePaths(t.TempDir(), ""); got != nil {
+		t.Errorf("modulePaths() without go.mod = %v, want nil", got)
+	}
+}
+
+func TestChdirFlag(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := t.TempDir()
+	lf := lockfile.New("1.21")
+	lf.Modules["example.com/mod"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-a"}
+	if err := lf.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetArgs([]string{"-C", dir, "list"})
+	defer func() {
+		chdir = ""
+		rootCmd.SetArgs(nil)
+	}()
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("nopher -C %s list: %v", dir, err)
+	}
+	if !contains(buf.String(), "example.com/mod@v1.0.0") {
+		t.Errorf("output = %q, want example.com/mod listed", buf.String())
+	}
+}
+
+func TestApplyEnvFlags(t *testing.T) {
+	cmd := &cobra.Command{Use: "test"}
+	verbose := cmd.Flags().BoolP("verbose", "v", false, "")
+	minAge := cmd.Flags().String("min-age", "", "")
+	sort := cmd.Flags().String("sort", "name", "")
+
+	t.Setenv("NOPHER_VERBOSE", "true")
+	t.Setenv("NOPHER_MIN_AGE", "7d")
+	t.Setenv("NOPHER_SORT", "size")
+	if err := cmd.Flags().Parse([]string{"--sort", "files"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyEnvFlags(cmd); err != nil {
+		t.Fatalf("applyEnvFlags() error = %v", err)
+	}
+	if !*verbose || *minAge != "7d" {
+		t.Errorf("verbose, min-age = %v, %q; want true, %q", *verbose, *minAge, "7d")
+	}
+	if *sort != "files" {
+		t.Errorf("sort = %q, want command-line value %q", *sort, "files")
+	}
+
+	t.Setenv("NOPHER_VERBOSE", "maybe")
+	cmd = &cobra.Command{Use: "test"}
+	cmd.Flags().BoolP("verbose", "v", false, "")
+	if err := applyEnvFlags(cmd); err == nil || !contains(err.Error(), "NOPHER_VERBOSE") {
+		t.Errorf("applyEnvFlags() error = %v, want invalid NOPHER_VERBOSE", err)
+	}
+}
+
+func TestApplyConfigDefaults(t *testing.T) {
+	dir := t.TempDir()
+	content := `proxy: https://goproxy.corp.example.com,direct
+private: [git.corp.example.com, git.example.org/team]
+cacheDir: .cache
+concurrency: 3
+format: toml
+include: [example.com/a, example.com/b]
+exclude: [example.com/b/legacy]
+`
+	if err := os.WriteFile(filepath.Join(dir, config.Filename), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	for _, name := range []string{"GOPROXY", "GOPRIVATE", "GONOPROXY"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	t.Setenv("NOPHER_SKIP", "example.com/c")
+
+	cmd := &cobra.Command{Use: "test"}
+	format := cmd.Flags().String("format", "", "")
+	only := cmd.Flags().String("only", "", "")
+	skip := cmd.Flags().String("skip", "", "")
+	jobs := cmd.Flags().Int("jobs", 8, "")
+	cache := cmd.Flags().String("cache-dir", "", "")
+	if err := cmd.Flags().Parse([]string{"--format", "yaml"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyEnvFlags(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfigDefaults(cmd); err != nil {
+		t.Fatalf("applyConfigDefaults() error = %v", err)
+	}
+
+	if *format != "yaml" || *skip != "example.com/c" {
+		t.Errorf("format, skip = %q, %q; want the command line and environment to win", *format, *skip)
+	}
+	if *only != "example.com/a,example.com/b" || *jobs != 3 || *cache != ".cache" {
+		t.Errorf("only, jobs, cache-dir = %q, %d, %q; want the config values", *only, *jobs, *cache)
+	}
+	if got := os.Getenv("GOPROXY"); got != "https://goproxy.corp.example.com,direct" {
+		t.Errorf("GOPROXY = %q", got)
+	}
+	if got := os.Getenv("GOPRIVATE"); got != "git.corp.example.com,git.example.org/team" {
+		t.Errorf("GOPRIVATE = %q", got)
+	}
+
+	// The environment takes precedence over proxy and private.
+	t.Setenv("GOPROXY", "off")
+	t.Setenv("GONOPROXY", "example.com")
+	os.Unsetenv("GOPRIVATE")
+	if err := applyConfigDefaults(&cobra.Command{Use: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("GOPROXY") != "off" || os.Getenv("GOPRIVATE") != "" {
+		t.Errorf("GOPROXY, GOPRIVATE = %q, %q; want the environment kept", os.Getenv("GOPROXY"), os.Getenv("GOPRIVATE"))
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, config.Filename), []byte("concurrency: -x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfigDefaults(cmd); err == nil {
+		t.Error("applyConfigDefaults() with an invalid config should fail")
+	}
+}
+
+func TestFindProjectDir(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(root, "internal", "pkg")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := findProjectDir(sub); got != root {
+		t.Errorf("findProjectDir(subdir) = %q, want %q", got, root)
+	}
+	if got := findProjectDir(root); got != root {
+		t.Errorf("findProjectDir(root) = %q, want %q", got, root)
+	}
+
+	t.Chdir(sub)
+	if got := projectDir(nil, 0); got != root {
+		t.Errorf("projectDir() = %q, want %q", got, root)
+	}
+	if got := projectDir([]string{"other"}, 0); got != "other" {
+		t.Errorf("projectDir(other) = %q, want %q", got, "other")
+	}
+	t.Chdir(t.TempDir())
+	if got := projectDir(nil, 0); got != "." {
+		t.Errorf("projectDir() outside a project = %q, want %q", got, ".")
+	}
+}
+
+func TestExplainURLCommand(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	config := "urlOverrides:\n  git.example.com: https://dl.example.com/{module}/{version}.zip\n"
+	if err := os.WriteFile(filepath.Join(dir, ".nopher.yaml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetArgs([]string{"explain-url", "git.example.com/lib@v1.0.0"})
+	defer rootCmd.SetArgs(nil)
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("nopher explain-url: %v", err)
+	}
+	for _, want := range []string{
+		"URL override: git.example.com matches",
+		"URL: https://dl.example.com/git.example.com/lib/v1.0.0.zip\n",
+	} {
+		if !contains(buf.String(), want) {
+			t.Errorf("output = %q, want %q", buf.String(), want)
+		}
+	}
+
+	rootCmd.SetArgs([]string{"explain-url", "git.example.com/lib"})
+	if err := rootCmd.Execute(); err == nil {
+		t.Error("explain-url without a version succeeded")
+	}
+}
+
+func TestPrintPlan(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GOPROXY", "https://proxy.example.com")
+	t.Setenv("GOPRIVATE", "")
+	t.Setenv("GONOPROXY", "")
+
+	dir := t.TempDir()
+	goMod := `module example.com/app
+
+go 1.21
+
+require (
+	example.com/a v1.0.0
+	example.com/cached v1.0.0
+	example.com/old v1.0.0
+	git.example.com/lib v1.2.0
+)
+
+replace example.com/old => example.com/fork v1.0.1
+`
+	goSum := `example.com/a v1.0.0 h1:a
+example.com/cached v1.0.0 h1:c
+example.com/fork v1.0.1 h1:f
+git.example.com/lib v1.2.0 h1:l
+`
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.sum"), []byte(goSum), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cacheDir := t.TempDir()
+	cached := filepath.Join(cacheDir, "example.com", "cached@v1.0.0")
+	if err := os.MkdirAll(cached, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for ext, data := range map[string]string{".hash": "sha256-c", ".url": "https://proxy.example.com/example.com/cached/@v/v1.0.0.zip"} {
+		if err := os.WriteFile(cached+ext, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := generator.Options{
+		CacheDir:     cacheDir,
+		URLOverrides: map[string]string{"git.example.com": "https://dl.example.com/{module}/{version}.zip"},
+		Signers:      map[string]func(*http.Request) error{"proxy.example.com": func(*http.Request) error { return nil }},
+	}
+	buf := new(bytes.Buffer)
+	if err := printPlan(buf, dir, opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"example.com/fork@v1.0.1  proxy  https://proxy.example.com/example.com/fork/@v/v1.0.1.zip  (replaces example.com/old, auth: signed)\n",
+		"example.com/a@v1.0.0  proxy  https://proxy.example.com/example.com/a/@v/v1.0.0.zip  (auth: signed)\n",
+		"example.com/cached@v1.0.0  proxy  https://proxy.example.com/example.com/cached/@v/v1.0.0.zip  (cached)\n",
+		"git.example.com/lib@v1.2.0  override  https://dl.example.com/git.example.com/lib/v1.2.0.zip\n",
+		"4 module(s) to fetch: 1 cached, 3 to download\n",
+	} {
+		if !contains(buf.String(), want) {
+			t.Errorf("output = %q, want %q", buf.String(), want)
+		}
+	}
+	if contains(buf.String(), "example.com/old@") {
+		t.Errorf("output = %q, lists the replaced module", buf.String())
+	}
+
+	buf.Reset()
+	opts.Only = "example.com/a"
+	if err := printPlan(buf, dir, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !contains(buf.String(), "1 module(s) to fetch") {
+		t.Errorf("output with --only = %q, want 1 module", buf.String())
+	}
+}
+
+func TestAuditAvailability(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/gone/") {
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	lf := lockfile.New("1.21")
+	lf.Modules["example.com/live"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-a", URL: srv.URL + "/live.zip"}
+	lf.Modules["example.com/mirrored"] = lockfile.Module{
+		Version: "v1.0.0",
+		Hash:    "sha256-b",
+		URL:     srv.URL + "/gone/mirrored.zip",
+		URLs:    []string{srv.URL + "/gone/mirrored.zip", srv.URL + "/mirrored.zip"},
+	}
+	lf.Modules["example.com/dead"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-c", URL: srv.URL + "/gone/dead.zip"}
+	if err := lf.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetArgs([]string{"audit-availability", dir})
+	defer rootCmd.SetArgs(nil)
+	err := rootCmd.Execute()
+	if err == nil || !contains(err.Error(), "2 dead links") {
+		t.Errorf("audit-availability error = %v, want 2 dead links", err)
+	}
+	for _, want := range []string{
+		"dead         example.com/dead@v1.0.0",
+		"dead         example.com/mirrored@v1.0.0",
+		"unavailable  example.com/dead@v1.0.0",
+		"Checked 4 URLs for 3 modules: 2 dead, 0 unreachable, 1 modules unavailable",
+	} {
+		if !contains(buf.String(), want) {
+			t.Errorf("output = %q, want %q", buf.String(), want)
+		}
+	}
+	if contains(buf.String(), "unavailable  example.com/mirrored") {
+		t.Errorf("output = %q, mirrored module has a live source", buf.String())
+	}
+}
+
+func TestAuditAvailabilityFix(t *testing.T) {
+	zipPath := filepath.Join(t.TempDir(), "v1.0.0.zip")
+	zf, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(zf)
+	w, err := zw.Create("example.com/moved@v1.0.0/go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("module example.com/moved\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zf.Close()
+	h1, err := dirhash.HashZip(zipPath, dirhash.Hash1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	proxyHash := "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/proxy/example.com/moved/@v/v1.0.0.zip", "/proxy/example.com/tampered/@v/v1.0.0.zip":
+			http.ServeFile(w, r, zipPath)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	config := "mirrors:\n  example.com:\n    - " + srv.URL + "/proxy\n"
+	if err := os.WriteFile(filepath.Join(dir, ".nopher.yaml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lf := lockfile.New("1.21")
+	lf.Modules["example.com/moved"] = lockfile.Module{
+		Version: "v1.0.0",
+		Hash:    "sha256-archive",
+		URL:     srv.URL + "/archive/moved.zip",
+		Sum:     h1,
+	}
+	lf.Modules["example.com/tampered"] = lockfile.Module{
+		Version: "v1.0.0",
+		Hash:    "sha256-archive",
+		URL:     srv.URL + "/archive/tampered.zip",
+		Sum:     "h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+	}
+	if err := lf.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetArgs([]string{"audit-availability", "--fix", dir})
+	defer func() {
+		auditFix = false
+		rootCmd.SetArgs(nil)
+	}()
+	err = rootCmd.Execute()
+	if err == nil || !contains(err.Error(), "1 dead links") {
+		t.Errorf("audit-availability --fix error = %v, want 1 dead link left", err)
+	}
+	for _, want := range []string{
+		"fixed        example.com/moved@v1.0.0: now " + srv.URL + "/proxy/example.com/moved/@v/v1.0.0.zip",
+		"not fixed    example.com/tampered@v1.0.0",
+		"1 modules fixed",
+	} {
+		if !contains(buf.String(), want) {
+			t.Errorf("output = %q, want %q", buf.String(), want)
+		}
+	}
+
+	got, err := lockfile.Load(lockfile.Path(dir, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := got.Modules["example.com/moved"]; m.Hash != proxyHash || m.URL != srv.URL+"/proxy/example.com/moved/@v/v1.0.0.zip" {
+		t.Errorf("moved module = %+v, want the proxy URL and hash", m)
+	}
+	if m := got.Modules["example.com/tampered"]; m.Hash != "sha256-archive" {
+		t.Errorf("tampered module = %+v, want it left alone", m)
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	goMod := "module example.com/app\n\ngo 1.21\n\nrequire example.com/dep v1.0.0\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lf := lockfile.New("1.21")
+	lf.Modules["example.com/dep"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-dep"}
+	if err := lf.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) error {
+		t.Helper()
+		rootCmd.SetOut(new(bytes.Buffer))
+		rootCmd.SetArgs(args)
+		defer func() {
+			rootCmd.SetArgs(nil)
+			quarantineReason, quarantineRelease, verifyAllowQuarantined = "", false, false
+		}()
+		return rootCmd.Execute()
+	}
+
+	if err := run("quarantine", "example.com/missing", dir); err == nil {
+		t.Error("quarantining a module that is not locked succeeded")
+	}
+	if err := run("quarantine", "--reason", "new dependency", "example.com/dep", dir); err != nil {
+		t.Fatalf("quarantine: %v", err)
+	}
+	got, err := lockfile.Load(lockfile.Path(dir, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := got.Quarantine["example.com/dep"]; q.Version != "v1.0.0" || q.Reason != "new dependency" {
+		t.Errorf("quarantine entry = %+v", q)
+	}
+
+	if err := run("verify", dir); err == nil || !contains(err.Error(), "1 quarantined modules") {
+		t.Errorf("verify error = %v, want quarantined modules reported", err)
+	}
+	if err := run("verify", "--allow-quarantined", dir); err != nil {
+		t.Errorf("verify --allow-quarantined: %v", err)
+	}
+
+	if err := run("quarantine", "--release", "example.com/dep", dir); err != nil {
+		t.Fatalf("quarantine --release: %v", err)
+	}
+	if err := run("verify", dir); err != nil {
+		t.Errorf("verify after release: %v", err)
+	}
+	if err := run("quarantine", "--release", "example.com/dep", dir); err == nil {
+		t.Error("releasing a module that is not quarantined succeeded")
+	}
+}
+
+func TestDiffCommand(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	base := lockfile.New("1.22")
+	base.Modules["example.com/up"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-up"}
+	base.Modules["example.com/down"] = lockfile.Module{Version: "v1.2.0", Hash: "sha256-down"}
+	base.Modules["example.com/gone"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-gone"}
+	base.Modules["example.com/same"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-same"}
+	base.Modules["example.com/tampered"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-a"}
+	if err := base.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "base")
+
+	head := lockfile.New("1.22")
+	head.Modules["example.com/up"] = lockfile.Module{Version: "v1.1.0", Hash: "sha256-up2"}
+	head.Modules["example.com/down"] = lockfile.Module{Version: "v1.1.0", Hash: "sha256-down2"}
+	head.Modules["example.com/new"] = lockfile.Module{Version: "v0.1.0", Hash: "sha256-new"}
+	head.Modules["github.com/stretchr/testfiy"] = lockfile.Module{Version: "v1.9.0", Hash: "sha256-typo"}
+	head.Modules["example.com/same"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-same"}
+	head.Modules["example.com/tampered"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-b"}
+	head.Replace["example.com/old"] = lockfile.Replace{Path: "./old"}
+	if err := head.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetArgs([]string{"diff", "--base", "HEAD", dir})
+	defer func() {
+		diffBase = ""
+		rootCmd.SetArgs(nil)
+	}()
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	for _, want := range []string{
+		"3 added, 1 removed, 1 upgraded, 1 downgraded, 1 rehashed.",
+		"| `example.com/down` | downgraded | `v1.2.0` | `v1.1.0` |",
+		"| `example.com/gone` | removed | `v1.0.0` |  |",
+		"| `example.com/new` | added |  | `v0.1.0` |",
+		"| `replace example.com/old` | added |  | `./old` |",
+		"| `example.com/old` | not built | `./old` (local) |",
+		"> - `example.com/tampered` `v1.0.0`",
+		"> - github.com/stretchr/testfiy@v1.9.0 looks like a near-miss of github.com/stretchr/testify",
+	} {
+		if !contains(buf.String(), want) {
+			t.Errorf("output = %q, want %q", buf.String(), want)
+		}
+	}
+	if contains(buf.String(), "example.com/same") {
+		t.Errorf("output lists an unchanged module: %q", buf.String())
+	}
+
+	rootCmd.SetArgs([]string{"diff", "--base", "no-such-ref", dir})
+	if err := rootCmd.Execute(); err == nil {
+		t.Error("diff with unknown ref should fail")
+	}
+}
+
+func TestReplaceEffects(t *testing.T) {
+	base := lockfile.New("1.22")
+	base.Modules["example.com/forked"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-orig"}
+	base.Modules["example.com/pinned"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-p1"}
+	base.Replace["example.com/pinned"] = lockfile.Replace{OldVersion: "v1.0.0", Match: "v1.0.0", New: "example.com/patched", Version: "v1.0.0", Hash: "sha256-patched"}
+	base.Replace["example.com/same"] = lockfile.Replace{OldVersion: "v1.0.0", New: "example.com/fork", Version: "v1.0.0", Hash: "sha256-same"}
+
+	head := lockfile.New("1.22")
+	head.Replace["example.com/forked"] = lockfile.Replace{OldVersion: "v1.0.0", New: "example.com/fork", Version: "v1.0.1", Hash: "sha256-fork"}
+	// The version-specific replacement no longer applies after an upgrade.
+	head.Modules["example.com/pinned"] = lockfile.Module{Version: "v1.1.0", Hash: "sha256-p2"}
+	head.Replace["example.com/pinned"] = lockfile.Replace{Match: "v1.0.0", New: "example.com/patched", Version: "v1.0.0", Hash: "sha256-patched"}
+	head.Replace["example.com/same"] = base.Replace["example.com/same"]
+
+	effects := replaceEffects(base, head)
+	want := []replaceEffect{
+		{Path: "example.com/forked", Before: builtSource{"example.com/forked@v1.0.0", "sha256-orig"}, After: builtSource{"example.com/fork@v1.0.1", "sha256-fork"}},
+		{Path: "example.com/pinned", Before: builtSource{"example.com/patched@v1.0.0", "sha256-patched"}, After: builtSource{"example.com/pinned@v1.1.0", "sha256-p2"}},
+	}
+	if !reflect.DeepEqual(effects, want) {
+		t.Errorf("replaceEffects() = %+v, want %+v", effects, want)
+	}
+
+	buf := new(bytes.Buffer)
+	printReplaceEffects(buf, effects)
+	if want := "  example.com/forked: example.com/forked@v1.0.0 sha256-orig -> example.com/fork@v1.0.1 sha256-fork\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("printReplaceEffects() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestDuplicatesCommand(t *testing.T) {
+	dir := t.TempDir()
+	lf := lockfile.New("1.22")
+	lf.Modules["gopkg.in/yaml.v2"] = lockfile.Module{Version: "v2.4.0", Hash: "sha256-a"}
+	lf.Modules["gopkg.in/yaml.v3"] = lockfile.Module{Version: "v3.0.1", Hash: "sha256-b"}
+	if err := lf.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetArgs([]string{"duplicates", dir})
+	defer rootCmd.SetArgs(nil)
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("duplicates: %v", err)
+	}
+	want := "majors  2 major versions are locked: gopkg.in/yaml.v2@v2.4.0, gopkg.in/yaml.v3@v3.0.1"
+	if !contains(buf.String(), want) {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestDaemonAPI(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(newDaemon([]string{dir}).handler())
+	defer srv.Close()
+
+	post := func(endpoint, body string, v any) int {
+		t.Helper()
+		resp, err := http.Post(srv.URL+endpoint, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if v != nil {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n\ngo 1.22\n\nrequire example.com/dep v1.0.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lf := lockfile.New("1.22")
+	lf.Modules["example.com/dep"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-dep"}
+	lf.Modules["example.com/extra"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-extra"}
+	if err := lf.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	var verified struct {
+		InSync bool     `json:"inSync"`
+		Extra  []string `json:"extra"`
+	}
+	if status := post("/v1/verify", `{"dir": "`+dir+`"}`, &verified); status != http.StatusOK {
+		t.Fatalf("verify status = %d", status)
+	}
+	if verified.InSync || !reflect.DeepEqual(verified.Extra, []string{"example.com/extra"}) {
+		t.Errorf("verify = %+v, want example.com/extra reported", verified)
+	}
+
+	want, err := hash.GoNARHash(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hashed map[string]string
+	if status := post("/v1/hash", `{"path": "`+dir+`"}`, &hashed); status != http.StatusOK || hashed["hash"] != want {
+		t.Errorf("hash = %d %v, want %s", status, hashed, want)
+	}
+
+	// Requests may not reach outside the daemon's projects, where a
+	// .nopher.yaml could run arbitrary hooks.
+	outside := t.TempDir()
+	if status := post("/v1/generate", `{"dir": "`+outside+`"}`, nil); status != http.StatusForbidden {
+		t.Errorf("generate outside the projects status = %d, want 403", status)
+	}
+	for _, path := range []string{outside, "/", dir + "/../" + filepath.Base(dir)} {
+		if status := post("/v1/hash", `{"path": "`+path+`"}`, nil); status != http.StatusForbidden {
+			t.Errorf("hash of %s status = %d, want 403", path, status)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if status := post("/v1/hash", `{"path": "`+filepath.Join(dir, "link")+`"}`, nil); status != http.StatusForbidden {
+		t.Errorf("hash through a symlink out of the project status = %d, want 403", status)
+	}
+
+	var failed map[string]string
+	if status := post("/v1/fetch", `{"path": "example.com/dep"}`, &failed); status != http.StatusBadRequest || failed["error"] == "" {
+		t.Errorf("fetch without version = %d %v, want a 400 error", status, failed)
+	}
+	if status := post("/v1/verify", `{`, nil); status != http.StatusBadRequest {
+		t.Errorf("malformed request status = %d, want 400", status)
+	}
+
+	resp, err := http.Get(srv.URL + "/v1/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("health status = %d", resp.StatusCode)
+	}
+
+	// A page can POST text/plain cross-origin without a preflight.
+	resp, err = http.Post(srv.URL+"/v1/generate", "text/plain", strings.NewReader(`{"dir": "`+dir+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("text/plain generate status = %d, want 400", resp.StatusCode)
+	}
+	if status := post("/v1/hash", `{"path": "`+dir+`"}`, nil); status != http.StatusOK {
+		t.Errorf("hash with application/json status = %d", status)
+	}
+
+	// A DNS-rebound name reaches the loopback listener with its own Host.
+	for host, want := range map[string]int{
+		"evil.example:7800": http.StatusForbidden,
+		"localhost:7800":    http.StatusOK,
+		"[::1]:7800":        http.StatusOK,
+	} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/v1/health", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = host
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("health with Host %s status = %d, want %d", host, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestDaemonGRPC(t *testing.T) {
+	srv := httptest.NewUnstartedServer(nil)
+	dir := t.TempDir()
+	srv.Config = newDaemonServer(newDaemon([]string{dir}))
+	srv.Start()
+	defer srv.Close()
+
+	conn, err := grpc.NewClient(strings.TrimPrefix(srv.URL, "http://"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := daemonapi.NewDaemonClient(conn)
+	ctx := context.Background()
+
+	if _, err := client.Health(ctx, &daemonapi.HealthRequest{}); err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+
+	want, err := hash.GoNARHash(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed, err := client.Hash(ctx, &daemonapi.HashRequest{Path: dir})
+	if err != nil || hashed.GetHash() != want {
+		t.Errorf("Hash = %v, %v, want %s", hashed, err, want)
+	}
+	if _, err := client.Hash(ctx, &daemonapi.HashRequest{Path: t.TempDir()}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Hash outside the projects = %v, want PermissionDenied", err)
+	}
+
+	_, err = client.Fetch(ctx, &daemonapi.FetchRequest{Path: "example.com/dep"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Fetch without version = %v, want InvalidArgument", err)
+	}
+
+	// The JSON API is served on the same listener.
+	resp, err := http.Get(srv.URL + "/v1/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("health status = %d", resp.StatusCode)
+	}
+}
+
+func TestListenDaemon(t *testing.T) {
+	dir := t.TempDir()
+
+	// Something that isn't a socket is never deleted.
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if ln, err := listenDaemon("unix:" + file); err == nil {
+		ln.Close()
+		t.Fatal("listenDaemon() on a regular file succeeded")
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Fatalf("regular file removed: %v", err)
+	}
+
+	// A socket left behind by a previous daemon is replaced.
+	sock := filepath.Join(dir, "daemon.sock")
+	stale, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	ln, err := listenDaemon("unix:" + sock)
+	if err != nil {
+		t.Fatalf("listenDaemon() over a stale socket: %v", err)
+	}
+	defer ln.Close()
+	info, err := os.Stat(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("socket mode = %o, want 600", perm)
+	}
+}
+
+func TestJSONProgress(t *testing.T) {
+	buf := new(bytes.Buffer)
+	progress := jsonProgress(buf)
+	progress(generator.Event{Kind: generator.EventDownloaded, Path: "example.com/a", Version: "v1.0.0", Total: 2, URL: "https://proxy.example/a.zip", Size: 42})
+	progress(generator.Event{Kind: generator.EventFailed, Path: "example.com/b", Version: "v1.0.0", Total: 2, Err: errors.New("boom")})
+
+	var lines []progressLine
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var l progressLine
+		if err := json.Unmarshal([]byte(line), &l); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		lines = append(lines, l)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), buf.String())
+	}
+	if l := lines[0]; l.Event != "downloaded" || l.Module != "example.com/a" || l.Total != 2 || l.Size != 42 || l.URL == "" || l.Time.IsZero() {
+		t.Errorf("downloaded line = %+v", l)
+	}
+	if l := lines[1]; l.Event != "failed" || l.Error != "boom" {
+		t.Errorf("failed line = %+v", l)
+	}
+}
+
+func TestDashboardFrame(t *testing.T) {
+	t.Setenv("COLUMNS", "100")
+	d := newDashboard(io.Discard, "nopher generate .")
+	for _, e := range []generator.Event{
+		{Kind: generator.EventStarted, Path: "example.com/a", Version: "v1.0.0"},
+		{Kind: generator.EventDownloaded, Path: "example.com/a", Version: "v1.0.0", Size: 2048},
+		{Kind: generator.EventHashed, Path: "example.com/a", Version: "v1.0.0", Retries: 2},
+		{Kind: generator.EventStarted, Path: "example.com/b", Version: "v1.0.0"},
+		{Kind: generator.EventHashed, Path: "example.com/b", Version: "v1.0.0", CacheHit: true},
+		{Kind: generator.EventStarted, Path: "example.com/c", Version: "v1.0.0"},
+		{Kind: generator.EventFailed, Path: "example.com/c", Version: "v1.0.0", Err: errors.New("not\nfound")},
+		{Kind: generator.EventStarted, Path: "example.com/d", Version: "v1.0.0"},
+	} {
+		e.Total = 4
+		d.event(e)
+	}
+
+	frame := d.frame(time.Now())
+	for _, want := range []string{
+		"3/4 modules  75%",
+		"hashed 2  cache hits 1  retries 2  failed 1  downloaded 2.0KiB",
+		"  example.com/d@v1.0.0  downloading",
+		"  ✓ example.com/a@v1.0.0  2 retries",
+		"  ✓ example.com/b@v1.0.0  cached",
+		"  ✗ example.com/c@v1.0.0: not found",
+	} {
+		if !contains(frame, want) {
+			t.Errorf("frame missing %q:\n%s", want, frame)
+		}
+	}
+}
+
+func TestReleaseCheck(t *testing.T) {
+	dir := t.TempDir()
+	gomod := "module example.com/lib\n\ngo 1.22\n\nrequire example.com/dep v1.0.0\n\nreplace example.com/dep => ../dep\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetArgs([]string{"release", "check", dir})
+	defer rootCmd.SetArgs(nil)
+	if err := rootCmd.Execute(); err == nil {
+		t.Error("release check with a local replacement should fail")
+	}
+	want := "error: replace example.com/dep => ../dep: consumers ignore replace directives"
+	if !contains(buf.String(), want) {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestPrintInfo(t *testing.T) {
+	lf := lockfile.New("1.22")
+	lf.Modules["example.com/lib"] = lockfile.Module{
+		Version:      "v1.2.0",
+		Hash:         "sha256-abc",
+		URL:          "https://proxy.golang.org/example.com/lib/@v/v1.2.0.zip",
+		Sum:          "h1:xyz",
+		Size:         2048,
+		Files:        3,
+		GoModReplace: []string{"example.com/dep => example.com/fork/dep@v1.0.1"},
+		Annotations:  lockfile.Annotations{Notes: "vendored fork"},
+	}
+	lf.Replace["example.com/old"] = lockfile.Replace{New: "example.com/new", Version: "v2.0.0", Hash: "sha256-def"}
+
+	buf := new(bytes.Buffer)
+	if err := printInfo(buf, lf, "example.com/lib"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"example.com/lib@v1.2.0\n",
+		"  url:        https://proxy.golang.org/example.com/lib/@v/v1.2.0.zip\n",
+		"  size:       2.0KiB, 3 files\n",
+		"  notes:      vendored fork\n",
+		"  go.mod replaces (ignored by go outside the main module):\n    example.com/dep => example.com/fork/dep@v1.0.1\n",
+	} {
+		if !contains(buf.String(), want) {
+			t.Errorf("info missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := printInfo(buf, lf, "example.com/old"); err != nil {
+		t.Fatal(err)
+	}
+	if !contains(buf.String(), "example.com/old => example.com/new@v2.0.0\n") {
+		t.Errorf("replacement info = %q", buf.String())
+	}
+
+	if err := printInfo(buf, lf, "example.com/missing"); err == nil {
+		t.Error("printInfo() of an unlocked module should fail")
+	}
+}
+
+func TestWarnGoModReplaces(t *testing.T) {
+	replaces := []string{"example.com/dep => ../dep"}
+	prev := lockfile.New("1.22")
+	prev.Modules["example.com/a"] = lockfile.Module{Version: "v1.0.0", GoModReplace: replaces}
+	prev.Modules["example.com/b"] = lockfile.Module{Version: "v1.0.0", GoModReplace: replaces}
+
+	lf := lockfile.New("1.22")
+	lf.Modules["example.com/a"] = lockfile.Module{Version: "v1.0.0", GoModReplace: replaces}
+	lf.Modules["example.com/b"] = lockfile.Module{Version: "v1.1.0", GoModReplace: replaces}
+	lf.Modules["example.com/c"] = lockfile.Module{Version: "v1.0.0"}
+	lf.Replace["example.com/d"] = lockfile.Replace{New: "example.com/e", Version: "v1.0.0", GoModReplace: replaces}
+
+	buf := new(bytes.Buffer)
+	warnGoModReplaces(buf, lf, prev)
+	want := "warning: example.com/b@v1.1.0 has replace directives in its go.mod, which go ignores outside the main module:\n" +
+		"  example.com/dep => ../dep\n" +
+		"warning: example.com/e@v1.0.0 has replace directives in its go.mod, which go ignores outside the main module:\n" +
+		"  example.com/dep => ../dep\n"
+	if buf.String() != want {
+		t.Errorf("warnings = %q, want %q", buf.String(), want)
+	}
+	if n := countGoModReplaces(lf); n != 3 {
+		t.Errorf("countGoModReplaces() = %d, want 3", n)
+	}
+}
+
+func TestDiffOwners(t *testing.T) {
+	base := lockfile.New("1.22")
+	base.Modules["github.com/aws/aws-sdk-go-v2"] = lockfile.Module{Version: "v1.30.0", Hash: "sha256-a"}
+	base.Modules["golang.org/x/crypto"] = lockfile.Module{Version: "v0.30.0", Hash: "sha256-c"}
+
+	head := lockfile.New("1.22")
+	head.Modules["github.com/aws/aws-sdk-go-v2"] = lockfile.Module{Version: "v1.31.0", Hash: "sha256-a2"}
+	head.Modules["golang.org/x/crypto"] = lockfile.Module{Version: "v0.31.0", Hash: "sha256-c2"}
+	head.Modules["example.com/new"] = lockfile.Module{Version: "v0.1.0", Hash: "sha256-n"}
+	head.Replace["github.com/aws/smithy-go"] = lockfile.Replace{Path: "./smithy"}
+
+	cfg := &config.Config{Owners: map[string][]string{
+		"github.com/aws/*":    {"@acme/cloud"},
+		"golang.org/x/crypto": {"@acme/security", "@acme/cloud"},
+	}}
+	buf := new(bytes.Buffer)
+	printDiffMarkdown(buf, "main", base, head, cfg.OwnersOf)
+	for _, want := range []string{
+		"| Module | Change | `main` | This change | Owners |\n",
+		"| `golang.org/x/crypto` | upgraded | `v0.30.0` | `v0.31.0` | @acme/security, @acme/cloud |\n",
+		"| `example.com/new` | added |  | `v0.1.0` | unowned |\n",
+		"#### Owners\n\n" +
+			"- @acme/cloud: `github.com/aws/aws-sdk-go-v2`, `golang.org/x/crypto`, `replace github.com/aws/smithy-go`\n" +
+			"- @acme/security: `golang.org/x/crypto`\n" +
+			"- Unowned: `example.com/new`\n",
+	} {
+		if !contains(buf.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	printDiffMarkdown(buf, "main", base, head, nil)
+	if contains(buf.String(), "Owners") {
+		t.Errorf("output without owners config mentions owners:\n%s", buf.String())
+	}
+}
+
+func TestListOwners(t *testing.T) {
+	dir := t.TempDir()
+	lf := lockfile.New("1.22")
+	lf.Modules["example.com/team/lib"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-a"}
+	lf.Modules["example.com/other"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-b"}
+	if err := lf.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, config.Filename), []byte("owners:\n  example.com/team: [\"@acme/team\"]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetArgs([]string{"list", "--owners", dir})
+	defer func() {
+		listOwners = false
+		rootCmd.SetArgs(nil)
+	}()
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("list --owners: %v", err)
+	}
+	for _, want := range []string{
+		"example.com/team/lib@v1.0.0  @acme/team\n",
+		"example.com/other@v1.0.0  unowned\n",
+	} {
+		if !contains(buf.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestUpgradeReport(t *testing.T) {
+	prev := lockfile.New("1.22")
+	prev.Modules["golang.org/x/net"] = lockfile.Module{Version: "v0.30.0", Hash: "sha256-n1", Size: 4096}
+	prev.Modules["example.com/relicensed"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-r1", Size: 1024}
+	prev.Modules["example.com/gone"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-g", Size: 2048}
+	prev.Modules["example.com/same"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-s", Size: 100}
+
+	next := lockfile.New("1.22")
+	next.Modules["golang.org/x/net"] = lockfile.Module{Version: "v0.31.0", Hash: "sha256-n2", Size: 5120}
+	next.Modules["example.com/relicensed"] = lockfile.Module{Version: "v1.1.0", Hash: "sha256-r2", Size: 1024}
+	next.Modules["example.com/private"] = lockfile.Module{Version: "v0.1.0", Hash: "sha256-p", Size: 512}
+	next.Modules["example.com/same"] = lockfile.Module{Version: "v1.0.0", Hash: "sha256-s", Size: 100}
+
+	data := map[string]*depsdev.Summary{
+		"golang.org/x/net@v0.30.0":      {Licenses: []string{"BSD-3-Clause"}, Advisories: []string{"GO-2024-3333", "GO-2025-0001"}},
+		"golang.org/x/net@v0.31.0":      {Licenses: []string{"BSD-3-Clause"}, Advisories: []string{"GO-2025-0001"}},
+		"example.com/relicensed@v1.0.0": {Licenses: []string{"MIT"}},
+		"example.com/relicensed@v1.1.0": {Licenses: []string{"BUSL-1.1"}, Advisories: []string{"GHSA-xxxx"}},
+		"example.com/gone@v1.0.0":       {Licenses: []string{"MIT"}, Advisories: []string{"GO-2023-1111"}},
+	}
+	var mu sync.Mutex
+	var looked []string
+	summarize := func(modulePath, version string) (*depsdev.Summary, error) {
+		mu.Lock()
+		looked = append(looked, modulePath+"@"+version)
+		mu.Unlock()
+		if s, ok := data[modulePath+"@"+version]; ok {
+			return s, nil
+		}
+		return nil, depsdev.ErrNotFound
+	}
+
+	buf := new(bytes.Buffer)
+	private := func(modulePath string) bool { return modulePath == "example.com/private" }
+	printUpgradeMarkdown(buf, buildUpgradeReport(prev, next, private, summarize))
+	for _, want := range []string{
+		"1 added, 1 removed, 2 upgraded. Vendored size: 7.1KiB → 6.6KiB (-512B).\n",
+		"| `golang.org/x/net` | upgraded | `v0.30.0` | `v0.31.0` | +1.0KiB | BSD-3-Clause |\n",
+		"| `example.com/private` | added |  | `v0.1.0` | +512B | unknown |\n",
+		"| `example.com/gone` | removed | `v1.0.0` |  | -2.0KiB | MIT |\n",
+		"#### Advisories fixed\n\n- `example.com/gone` `v1.0.0`: GO-2023-1111\n- `golang.org/x/net` `v0.30.0`: GO-2024-3333\n",
+		"> - `example.com/relicensed` `v1.1.0`: GHSA-xxxx\n",
+		"#### License changes\n\n- `example.com/relicensed`: MIT → BUSL-1.1\n",
+		"1 of these modules are private or unknown to deps.dev",
+	} {
+		if !contains(buf.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, buf.String())
+		}
+	}
+	if contains(buf.String(), "example.com/same") || slices.Contains(looked, "example.com/same@v1.0.0") {
+		t.Errorf("report covers an unchanged module:\n%s", buf.String())
+	}
+	if slices.Contains(looked, "example.com/private@v0.1.0") {
+		t.Error("private module sent to deps.dev")
+	}
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/anthr76/nopher/internal/mod"
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+)
+
+// completeModulePath completes the <module-path> argument of commands taking
+// `<module-path> [directory]` with the modules required by go.mod or locked in
+// the lockfile of the project containing the current (or -C) directory, and
+// the directory argument with directories.
+func completeModulePath(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		dir := "."
+		if chdir != "" {
+			dir = chdir
+		}
+		return modulePaths(findProjectDir(dir), toComplete), cobra.ShellCompDirectiveNoFileComp
+	case 1:
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+// modulePaths returns the sorted module paths starting with prefix that are
+// required by dir's go.mod or present in its lockfile. Missing or invalid
+// files contribute nothing.
+func modulePaths(dir, prefix string) []string {
+	seen := make(map[string]bool)
+	if info, err := mod.ParseGoMod(filepath.Join(dir, "go.mod")); err == nil {
+		for _, req := range info.Requires {
+			seen[req.Path] = true
+		}
+	}
+	if lf, err := lockfile.Load(lockfile.Path(dir, lockProfile)); err == nil {
+		for path := range lf.Modules {
+			seen[path] = true
+		}
+	}
+
+	var paths []string
+	for path := range seen {
+		if strings.HasPrefix(path, prefix) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,597 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/anthr76/nopher/internal/hash"
+	"github.com/anthr76/nopher/internal/suspect"
+	"github.com/anthr76/nopher/pkg/daemonapi"
+	"github.com/anthr76/nopher/pkg/generator"
+	"github.com/anthr76/nopher/pkg/lockcheck"
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	daemonListen   string
+	daemonProjects []string
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Serve generate, verify, fetch and hash over a local gRPC and HTTP API",
+	Long: `Run nopher as a long-lived process answering API requests, so IDE plugins,
+Nix shell hooks and other tooling get fast responses from a warm process.
+Fetch results are kept in memory, per project directory, for the life of
+the daemon, so regenerating after a small go.mod change only fetches what
+changed.
+
+The API is defined in pkg/daemonapi/daemon.proto (service
+nopher.daemon.v1.Daemon). It is served over gRPC, with HTTP/2 without TLS,
+and as JSON over HTTP on the same address. JSON bodies are the protobuf
+messages in their canonical JSON form:
+
+  GET  /v1/health    HealthResponse
+  POST /v1/generate  GenerateRequest -> GenerateResult
+  POST /v1/verify    VerifyRequest -> VerifyResponse
+  POST /v1/fetch     FetchRequest -> FetchResponse
+  POST /v1/hash      HashRequest -> HashResponse
+
+A /v1/generate request sent with "Accept: application/x-ndjson" is answered
+with one GenerateEvent per line as modules are fetched, the result last.
+Failures are answered with a non-2xx status and {"error": "..."}, or, once
+a stream has started, with a final {"error": "..."} line.
+
+--listen takes host:port, or unix:<path> for a Unix socket, which is
+created readable and writable only by its owner. The API has no
+authentication; keep it on a loopback address or a private socket. So that
+web pages can't reach it, requests over TCP must name a loopback Host, and
+POST requests must be sent as Content-Type: application/json.
+
+Since generating runs a project's post-fetch hooks, requests may only name
+the directories given with --project and their subdirectories, by default
+the project containing the working directory. Hash requests are limited to
+paths inside them too.`,
+	Args: cobra.NoArgs,
+	RunE: runDaemon,
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+	daemonCmd.Flags().StringVar(&daemonListen, "listen", "127.0.0.1:7800", "address to listen on: host:port, or unix:<path>")
+	daemonCmd.Flags().StringArrayVar(&daemonProjects, "project", nil, "project `directory` requests may name, with its subdirectories (repeatable; default: the project containing the working directory)")
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	projects := daemonProjects
+	if len(projects) == 0 {
+		projects = []string{findProjectDir(".")}
+	}
+	var roots []string
+	for _, p := range projects {
+		root, err := resolvePath(p)
+		if err != nil {
+			return fmt.Errorf("--project: %w", err)
+		}
+		roots = append(roots, root)
+	}
+
+	ln, err := listenDaemon(daemonListen)
+	if err != nil {
+		return err
+	}
+
+	srv := newDaemonServer(newDaemon(roots))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(cmd.OutOrStdout(), "nopher daemon listening on %s\n", daemonListen)
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// listenDaemon listens on a --listen address. A Unix socket left behind by
+// a previous daemon is replaced, but nothing else at the path is, and the
+// new socket is only accessible by its owner.
+func listenDaemon(listen string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(listen, "unix:")
+	if !ok {
+		return net.Listen("tcp", listen)
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("removing stale socket: %w", err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("restricting socket: %w", err)
+	}
+	return ln, nil
+}
+
+// newDaemonServer returns an HTTP server answering gRPC requests (over
+// HTTP/2 without TLS) and JSON requests for d.
+func newDaemonServer(d *daemon) *http.Server {
+	grpcServer := grpc.NewServer()
+	daemonapi.RegisterDaemonServer(grpcServer, d)
+	jsonAPI := d.handler()
+
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	return &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+				grpcServer.ServeHTTP(w, r)
+				return
+			}
+			jsonAPI.ServeHTTP(w, r)
+		}),
+		Protocols:         &protocols,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// daemon implements the Daemon service, holding the state shared between
+// requests.
+type daemon struct {
+	daemonapi.UnimplementedDaemonServer
+
+	// roots are the directories, absolute and without symlinks, that
+	// requests may name, with their subdirectories.
+	roots []string
+
+	mu     sync.Mutex
+	caches map[string]*generator.Cache // by absolute project directory
+}
+
+// newDaemon returns a daemon serving the projects in roots, which must be
+// absolute and free of symlinks.
+func newDaemon(roots []string) *daemon {
+	return &daemon{roots: roots, caches: make(map[string]*generator.Cache)}
+}
+
+// cache returns the fetch cache for the project in dir. Projects get
+// separate caches since their .nopher.yaml can fetch modules differently.
+func (d *daemon) cache(dir string) *generator.Cache {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, ok := d.caches[dir]
+	if !ok {
+		c = generator.NewCache()
+		d.caches[dir] = c
+	}
+	return c
+}
+
+// project resolves a request's project directory and lockfile profile:
+// dir relative to the working directory, defaulting to it, and profile
+// defaulting to --profile. The directory must be within d's roots.
+func (d *daemon) project(dir, profile string) (string, string, error) {
+	if dir == "" {
+		dir = "."
+	}
+	abs, err := d.allowed(dir)
+	if err != nil {
+		return "", "", err
+	}
+	if profile == "" {
+		profile = lockProfile
+	}
+	return abs, profile, nil
+}
+
+// allowed resolves path, relative to the working directory, to an absolute
+// path without symlinks, and checks that it lies within one of d's roots.
+// Paths with .. elements are rejected outright.
+func (d *daemon) allowed(path string) (string, error) {
+	if slices.Contains(strings.Split(filepath.ToSlash(path), "/"), "..") {
+		return "", status.Errorf(codes.PermissionDenied, "%s: paths may not contain ..", path)
+	}
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	for _, root := range d.roots {
+		if rel, err := filepath.Rel(root, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", status.Errorf(codes.PermissionDenied, "%s is outside the daemon's projects (see --project)", path)
+}
+
+// resolveDir returns path as an absolute path without symlinks.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// projectOptions returns generator options for the project in dir, with
+// the daemon's cache for it.
+func (d *daemon) projectOptions(dir string) (*config.Config, generator.Options, error) {
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return nil, generator.Options{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+	opts, err := configOptions(cfg)
+	if err != nil {
+		return nil, generator.Options{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+	opts.Cache = d.cache(dir)
+	return cfg, opts, nil
+}
+
+func (d *daemon) Health(ctx context.Context, req *daemonapi.HealthRequest) (*daemonapi.HealthResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cached := 0
+	for _, c := range d.caches {
+		cached += c.Len()
+	}
+	return &daemonapi.HealthResponse{Version: Version, Cached: int32(cached)}, nil
+}
+
+func (d *daemon) Generate(req *daemonapi.GenerateRequest, stream daemonapi.Daemon_GenerateServer) error {
+	return d.generate(req, stream.Send)
+}
+
+// generate runs a generation for req, passing send an event for every
+// module as it is fetched, and the result last.
+func (d *daemon) generate(req *daemonapi.GenerateRequest, send func(*daemonapi.GenerateEvent) error) error {
+	dir, profile, err := d.project(req.Dir, req.Profile)
+	if err != nil {
+		return err
+	}
+	cfg, opts, err := d.projectOptions(dir)
+	if err != nil {
+		return err
+	}
+	opts.Profile = profile
+	opts.Meta = generationMeta(false)
+
+	// Progress can be reported concurrently, but a stream takes one
+	// message at a time. Once a send fails, later events are dropped and
+	// the request fails with the send error.
+	var mu sync.Mutex
+	var sendErr error
+	opts.Progress = func(e generator.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if sendErr == nil {
+			sendErr = send(&daemonapi.GenerateEvent{Event: &daemonapi.GenerateEvent_Module{Module: moduleEvent(e)}})
+		}
+	}
+
+	unlock, err := lockfile.Lock(dir)
+	if err != nil {
+		if errors.Is(err, lockfile.ErrLocked) {
+			return status.Error(codes.Aborted, err.Error())
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+	defer unlock()
+
+	prev, _ := lockfile.Load(lockfile.Path(dir, profile))
+	rule, err := minAgeRule(cfg.Policy)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if rule != nil {
+		opts.Check = func(lf *lockfile.Lockfile) error {
+			var violations strings.Builder
+			if err := checkMinAge(&violations, cfg, lf, prev, *rule); err != nil {
+				return fmt.Errorf("%w\n%s", err, strings.TrimSpace(violations.String()))
+			}
+			return nil
+		}
+	}
+
+	lf, err := generator.GenerateAndSave(dir, opts)
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	result := &daemonapi.GenerateResult{Modules: int32(len(lf.Modules)), Replacements: int32(len(lf.Replace))}
+	for _, f := range suspect.Check(lf, prev, cfg.Suspicious.Allow) {
+		result.Warnings = append(result.Warnings, "suspicious module: "+f.String())
+	}
+	return send(&daemonapi.GenerateEvent{Event: &daemonapi.GenerateEvent_Result{Result: result}})
+}
+
+// moduleEvent converts a generator progress event.
+func moduleEvent(e generator.Event) *daemonapi.ModuleEvent {
+	m := &daemonapi.ModuleEvent{Path: e.Path, Version: e.Version, Total: int32(e.Total), Hash: e.Hash, CacheHit: e.CacheHit, Url: e.URL, Size: e.Size}
+	switch e.Kind {
+	case generator.EventStarted:
+		m.Kind = daemonapi.ModuleEvent_KIND_STARTED
+	case generator.EventDownloaded:
+		m.Kind = daemonapi.ModuleEvent_KIND_DOWNLOADED
+	case generator.EventHashed:
+		m.Kind = daemonapi.ModuleEvent_KIND_HASHED
+	case generator.EventFailed:
+		m.Kind = daemonapi.ModuleEvent_KIND_FAILED
+	}
+	if e.Err != nil {
+		m.Error = e.Err.Error()
+	}
+	return m
+}
+
+func (d *daemon) Verify(ctx context.Context, req *daemonapi.VerifyRequest) (*daemonapi.VerifyResponse, error) {
+	dir, profile, err := d.project(req.Dir, req.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	findings, err := lockcheck.CheckWith(dir, lockcheck.Options{Profile: profile})
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	resp := &daemonapi.VerifyResponse{
+		Missing:     lockcheck.Messages(findings, lockcheck.Missing),
+		Extra:       lockcheck.Messages(findings, lockcheck.Extra),
+		Mismatch:    lockcheck.Messages(findings, lockcheck.Mismatch),
+		GoSum:       lockcheck.Messages(findings, lockcheck.GoSum),
+		Quarantined: lockcheck.Messages(findings, lockcheck.Quarantined),
+	}
+	if goVersion := lockcheck.Messages(findings, lockcheck.GoVersion); len(goVersion) > 0 {
+		resp.GoVersion = goVersion[0]
+	}
+	resp.InSync = lockcheck.Fresh(findings)
+	return resp, nil
+}
+
+func (d *daemon) Fetch(ctx context.Context, req *daemonapi.FetchRequest) (*daemonapi.FetchResponse, error) {
+	if req.Path == "" || req.Version == "" {
+		return nil, status.Error(codes.InvalidArgument, "path and version are required")
+	}
+	dir, _, err := d.project(req.Dir, "")
+	if err != nil {
+		return nil, err
+	}
+	_, opts, err := d.projectOptions(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := generator.FetchModule(opts, req.Path, req.Version)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &daemonapi.FetchResponse{
+		Hash:     result.Hash,
+		Url:      result.URL,
+		Urls:     result.URLs,
+		Rev:      result.Rev,
+		Subdir:   result.Subdir,
+		CacheHit: result.CacheHit,
+	}, nil
+}
+
+func (d *daemon) Hash(ctx context.Context, req *daemonapi.HashRequest) (*daemonapi.HashResponse, error) {
+	if req.Path == "" {
+		return nil, status.Error(codes.InvalidArgument, "path is required")
+	}
+	path, err := d.allowed(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := hash.GoNARHash(path)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &daemonapi.HashResponse{Hash: h}, nil
+}
+
+// handler returns the JSON over HTTP form of the API.
+func (d *daemon) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/health", func(w http.ResponseWriter, r *http.Request) {
+		resp, err := d.Health(r.Context(), &daemonapi.HealthRequest{})
+		respond(w, resp, err)
+	})
+	mux.HandleFunc("POST /v1/generate", d.generateJSON)
+	mux.Handle("POST /v1/verify", unaryJSON(d.Verify))
+	mux.Handle("POST /v1/fetch", unaryJSON(d.Fetch))
+	mux.Handle("POST /v1/hash", unaryJSON(d.Hash))
+	return sameOrigin(mux)
+}
+
+// sameOrigin guards the JSON API against web pages. The API can run
+// commands and rewrite files, so a browser must not be able to reach it:
+// POST requests must be application/json, which a page can't send
+// cross-origin without a CORS preflight the daemon never answers, and over
+// TCP the Host must be loopback, so a DNS-rebound name can't read
+// responses.
+func sameOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok && addr.Network() == "tcp" && !loopbackHost(r.Host) {
+			respond[*daemonapi.Error](w, nil, status.Errorf(codes.PermissionDenied, "host %q is not a loopback address", r.Host))
+			return
+		}
+		if r.Method == http.MethodPost {
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+				respond[*daemonapi.Error](w, nil, status.Error(codes.InvalidArgument, "requests must be sent as Content-Type: application/json"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// loopbackHost reports whether the Host header host names this machine:
+// localhost or a loopback IP, with or without a port.
+func loopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// jsonOptions marshals API messages for HTTP clients. Default values are
+// included so clients need not know the protobuf defaults.
+var jsonOptions = protojson.MarshalOptions{EmitDefaultValues: true}
+
+// unaryJSON adapts a unary method of the service to a JSON handler.
+func unaryJSON[Req any, Resp proto.Message, PReq interface {
+	*Req
+	proto.Message
+}](call func(context.Context, PReq) (Resp, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := PReq(new(Req))
+		if err := decodeJSON(r, req); err != nil {
+			var none Resp
+			respond(w, none, err)
+			return
+		}
+		resp, err := call(r.Context(), req)
+		respond(w, resp, err)
+	})
+}
+
+// decodeJSON reads an API request message from r. An empty body is an
+// empty request.
+func decodeJSON(r *http.Request, req proto.Message) error {
+	data, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "reading request: %v", err)
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil
+	}
+	if err := protojson.Unmarshal(data, req); err != nil {
+		return status.Errorf(codes.InvalidArgument, "decoding request: %v", err)
+	}
+	return nil
+}
+
+// respond writes resp, or err with a matching HTTP status.
+func respond[Resp proto.Message](w http.ResponseWriter, resp Resp, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(httpStatus(err))
+		w.Write(errorJSON(err))
+		return
+	}
+	data, err := jsonOptions.Marshal(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(errorJSON(err))
+		return
+	}
+	w.Write(append(data, '\n'))
+}
+
+// errorJSON renders err as {"error": "..."}.
+func errorJSON(err error) []byte {
+	msg := err.Error()
+	if s, ok := status.FromError(err); ok {
+		msg = s.Message()
+	}
+	data, _ := protojson.Marshal(&daemonapi.Error{Error: msg})
+	return append(data, '\n')
+}
+
+// httpStatus maps a service error to an HTTP status.
+func httpStatus(err error) int {
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Aborted:
+		return http.StatusConflict
+	case codes.FailedPrecondition:
+		return http.StatusUnprocessableEntity
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// generateJSON answers /v1/generate with the result, or streams every
+// event as a line of JSON when the client accepts application/x-ndjson.
+func (d *daemon) generateJSON(w http.ResponseWriter, r *http.Request) {
+	req := &daemonapi.GenerateRequest{}
+	if err := decodeJSON(r, req); err != nil {
+		respond[*daemonapi.GenerateResult](w, nil, err)
+		return
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		var result *daemonapi.GenerateResult
+		err := d.generate(req, func(e *daemonapi.GenerateEvent) error {
+			if res := e.GetResult(); res != nil {
+				result = res
+			}
+			return nil
+		})
+		respond(w, result, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	err := d.generate(req, func(e *daemonapi.GenerateEvent) error {
+		data, err := protojson.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(data, '\n')); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		w.Write(errorJSON(err))
+	}
+}
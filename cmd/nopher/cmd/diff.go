@@ -0,0 +1,368 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/anthr76/nopher/internal/suspect"
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/semver"
+)
+
+var diffBase string
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [directory] --base <git-ref>",
+	Short: "Summarize lockfile changes against a git ref as markdown",
+	Long: `Compare the lockfile in the working tree with the lockfile at a git ref,
+typically the pull request's base branch, and print a markdown summary of
+dependency changes ready to post as a review comment (requires git).
+
+Added, removed, upgraded, and downgraded modules and changed replacements
+are listed in a table. Modules whose hash changed without a version change
+are called out separately, since a published version's contents should
+never change. For every module whose replacement was added, removed, or
+changed, the source the build fetches and its hash are shown before and
+after, since a replace directive can swap a module for any code. Added
+modules that look like typosquats or forks of well-known modules are
+flagged, as generate does. If the ref has no lockfile, every module is
+reported as added.
+
+When .nopher.yaml assigns owners to modules, each change is attributed to
+its owning teams, and the changes are grouped by team so each knows what to
+review.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runDiff,
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().StringVar(&diffBase, "base", "", "git ref to compare against, e.g. origin/main (required)")
+	diffCmd.MarkFlagRequired("base")
+}
+
+// depChange is a difference in one lockfile entry between two lockfiles.
+type depChange struct {
+	Path   string // Module path, prefixed with "replace " for replacements
+	Kind   string // added, removed, upgraded, downgraded, changed, or rehashed
+	Before string
+	After  string
+}
+
+// changeKinds orders change kinds in summaries.
+var changeKinds = []string{"added", "removed", "upgraded", "downgraded", "changed", "rehashed"}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	dir := projectDir(args, 0)
+
+	if err := checkRef(dir, diffBase); err != nil {
+		return err
+	}
+	base, err := loadLockfileAt(dir, diffBase)
+	if err != nil {
+		return err
+	}
+	head, err := lockfile.Load(lockfile.Path(dir, lockProfile))
+	if err != nil {
+		return fmt.Errorf("loading lockfile: %w", err)
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return err
+	}
+	var owners func(string) []string
+	if len(cfg.Owners) > 0 {
+		owners = cfg.OwnersOf
+	}
+
+	out := cmd.OutOrStdout()
+	printDiffMarkdown(out, diffBase, base, head, owners)
+	printSuspectMarkdown(out, suspect.Check(head, base, cfg.Suspicious.Allow))
+	return nil
+}
+
+// loadLockfileAt reads the lockfile for the selected profile, plain,
+// compressed, or TOML, from git ref. A ref without a lockfile yields an
+// empty one.
+func loadLockfileAt(dir, ref string) (*lockfile.Lockfile, error) {
+	name := lockfile.Filename(lockProfile)
+	for _, n := range []string{name, name + lockfile.CompressedSuffix, lockfile.TOMLFilename(lockProfile)} {
+		data, ok, err := gitShow(dir, ref, n)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		lf, err := lockfile.Parse(n, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s at %s: %w", n, ref, err)
+		}
+		return lf, nil
+	}
+	return lockfile.New(""), nil
+}
+
+// lockfileChanges lists the modules and replacements that differ between
+// base and head, sorted by path.
+func lockfileChanges(base, head *lockfile.Lockfile) []depChange {
+	var changes []depChange
+	for path, m := range head.Modules {
+		old, ok := base.Modules[path]
+		switch {
+		case !ok:
+			changes = append(changes, depChange{Path: path, Kind: "added", After: m.Version})
+		case old.Version != m.Version:
+			kind := "upgraded"
+			if semver.Compare(m.Version, old.Version) < 0 {
+				kind = "downgraded"
+			}
+			changes = append(changes, depChange{Path: path, Kind: kind, Before: old.Version, After: m.Version})
+		case old.Hash != m.Hash:
+			changes = append(changes, depChange{Path: path, Kind: "rehashed", Before: old.Version, After: m.Version})
+		}
+	}
+	for path, m := range base.Modules {
+		if _, ok := head.Modules[path]; !ok {
+			changes = append(changes, depChange{Path: path, Kind: "removed", Before: m.Version})
+		}
+	}
+
+	for old, r := range head.Replace {
+		prev, ok := base.Replace[old]
+		switch {
+		case !ok:
+			changes = append(changes, depChange{Path: "replace " + old, Kind: "added", After: r.Target()})
+		case prev.Target() != r.Target():
+			changes = append(changes, depChange{Path: "replace " + old, Kind: "changed", Before: prev.Target(), After: r.Target()})
+		case prev.Hash != r.Hash:
+			changes = append(changes, depChange{Path: "replace " + old, Kind: "rehashed", Before: prev.Target(), After: r.Target()})
+		}
+	}
+	for old, r := range base.Replace {
+		if _, ok := head.Replace[old]; !ok {
+			changes = append(changes, depChange{Path: "replace " + old, Kind: "removed", Before: r.Target()})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// builtSource is what the build vendors for a module path: the module
+// itself, the target of a replacement, or a local directory.
+type builtSource struct {
+	Source string // path@version, or a local replacement's directory
+	Hash   string // Empty for local replacements
+}
+
+func (s builtSource) String() string {
+	if s.Source == "" {
+		return "nothing"
+	}
+	if s.Hash == "" {
+		return s.Source + " (local)"
+	}
+	return s.Source + " " + s.Hash
+}
+
+// effectiveSource returns what lf builds for path: its replacement when one
+// applies to the required version, or else its locked module. The zero
+// builtSource means path is not built at all.
+func effectiveSource(lf *lockfile.Lockfile, path string) builtSource {
+	if r, ok := lf.Replace[path]; ok {
+		switch {
+		case r.Path != "":
+			return builtSource{Source: r.Path}
+		case r.OldVersion != "":
+			return builtSource{Source: r.Target(), Hash: r.Hash}
+		}
+	}
+	if m, ok := lf.Modules[path]; ok {
+		return builtSource{Source: path + "@" + m.Version, Hash: m.Hash}
+	}
+	return builtSource{}
+}
+
+// replaceEffect is the change in what is built for a module whose
+// replacement changed.
+type replaceEffect struct {
+	Path          string
+	Before, After builtSource
+}
+
+// replaceEffects lists, sorted by path, the modules with a replacement in
+// base or head whose effective source differs between them. A replacement
+// becoming used or unused when the required version moves counts as well
+// as one being added, removed, or retargeted.
+func replaceEffects(base, head *lockfile.Lockfile) []replaceEffect {
+	paths := make(map[string]bool)
+	for path := range base.Replace {
+		paths[path] = true
+	}
+	for path := range head.Replace {
+		paths[path] = true
+	}
+
+	var effects []replaceEffect
+	for path := range paths {
+		before, after := effectiveSource(base, path), effectiveSource(head, path)
+		if before != after {
+			effects = append(effects, replaceEffect{Path: path, Before: before, After: after})
+		}
+	}
+	sort.Slice(effects, func(i, j int) bool { return effects[i].Path < effects[j].Path })
+	return effects
+}
+
+// printDiffMarkdown writes a markdown summary of the changes from base
+// (the lockfile at ref) to head. When owners is non-nil, every change is
+// attributed to the owners it returns for the module path.
+func printDiffMarkdown(w io.Writer, ref string, base, head *lockfile.Lockfile, owners func(modulePath string) []string) {
+	changes := lockfileChanges(base, head)
+
+	fmt.Fprintf(w, "### Dependency changes against `%s`\n\n", ref)
+	if base.Go != "" && base.Go != head.Go {
+		fmt.Fprintf(w, "Go version: `%s` → `%s`\n\n", base.Go, head.Go)
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "No dependency changes.")
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, c := range changes {
+		counts[c.Kind]++
+	}
+	var parts []string
+	for _, kind := range changeKinds {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+	fmt.Fprintf(w, "%s.\n\n", strings.Join(parts, ", "))
+
+	if owners == nil {
+		fmt.Fprintf(w, "| Module | Change | `%s` | This change |\n", ref)
+		fmt.Fprintln(w, "|--------|--------|------|-------------|")
+		for _, c := range changes {
+			fmt.Fprintf(w, "| `%s` | %s | %s | %s |\n", c.Path, c.Kind, markdownCode(c.Before), markdownCode(c.After))
+		}
+	} else {
+		fmt.Fprintf(w, "| Module | Change | `%s` | This change | Owners |\n", ref)
+		fmt.Fprintln(w, "|--------|--------|------|-------------|--------|")
+		for _, c := range changes {
+			fmt.Fprintf(w, "| `%s` | %s | %s | %s | %s |\n", c.Path, c.Kind, markdownCode(c.Before), markdownCode(c.After), formatOwners(owners(c.modulePath())))
+		}
+		printOwnedChanges(w, changes, owners)
+	}
+
+	if effects := replaceEffects(base, head); len(effects) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "#### What replacements build")
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "| Module | `%s` | This change |\n", ref)
+		fmt.Fprintln(w, "|--------|------|-------------|")
+		for _, e := range effects {
+			fmt.Fprintf(w, "| `%s` | %s | %s |\n", e.Path, markdownSource(e.Before), markdownSource(e.After))
+		}
+	}
+
+	if counts["rehashed"] > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "> [!WARNING]")
+		fmt.Fprintln(w, "> The hash of these modules changed without a version change. A published")
+		fmt.Fprintln(w, "> version's contents should never change; check where they are fetched from.")
+		for _, c := range changes {
+			if c.Kind == "rehashed" {
+				fmt.Fprintf(w, "> - `%s` %s\n", c.Path, markdownCode(c.After))
+			}
+		}
+	}
+}
+
+// printSuspectMarkdown warns about suspicious modules a change adds.
+func printSuspectMarkdown(w io.Writer, findings []suspect.Finding) {
+	if len(findings) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "> [!WARNING]")
+	fmt.Fprintln(w, "> These added modules look like typosquats or forks of well-known modules;")
+	fmt.Fprintln(w, "> check they are the ones you meant to depend on.")
+	for _, f := range findings {
+		fmt.Fprintf(w, "> - %s\n", f)
+	}
+}
+
+// modulePath returns the module path a change is to, without the replace
+// prefix.
+func (c depChange) modulePath() string {
+	return strings.TrimPrefix(c.Path, "replace ")
+}
+
+// printOwnedChanges writes the changed module paths grouped by owner, with
+// unowned changes last, so each team can find what it has to review.
+func printOwnedChanges(w io.Writer, changes []depChange, owners func(string) []string) {
+	byOwner := make(map[string][]string)
+	var unowned []string
+	for _, c := range changes {
+		path := markdownCode(c.Path)
+		teams := owners(c.modulePath())
+		if len(teams) == 0 {
+			unowned = append(unowned, path)
+		}
+		for _, team := range teams {
+			byOwner[team] = append(byOwner[team], path)
+		}
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "#### Owners")
+	fmt.Fprintln(w)
+	teams := make([]string, 0, len(byOwner))
+	for team := range byOwner {
+		teams = append(teams, team)
+	}
+	sort.Strings(teams)
+	for _, team := range teams {
+		fmt.Fprintf(w, "- %s: %s\n", team, strings.Join(byOwner[team], ", "))
+	}
+	if len(unowned) > 0 {
+		fmt.Fprintf(w, "- Unowned: %s\n", strings.Join(unowned, ", "))
+	}
+}
+
+// formatOwners joins owners for display, or returns "unowned".
+func formatOwners(owners []string) string {
+	if len(owners) == 0 {
+		return "unowned"
+	}
+	return strings.Join(owners, ", ")
+}
+
+// markdownSource formats s for a markdown table cell.
+func markdownSource(s builtSource) string {
+	switch {
+	case s.Source == "":
+		return "not built"
+	case s.Hash == "":
+		return markdownCode(s.Source) + " (local)"
+	}
+	return markdownCode(s.Source) + " " + markdownCode(s.Hash)
+}
+
+// markdownCode formats s as inline code, or returns an empty string.
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + s + "`"
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/anthr76/nopher/internal/suspect"
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var duplicatesCmd = &cobra.Command{
+	Use:   "duplicates [directory]",
+	Short: "Report modules locked more than once under different paths",
+	Long: `Report locked modules that likely duplicate each other, which commonly
+indicates accidental bloat:
+
+- several major versions of the same module, such as gopkg.in/yaml.v2 and
+  gopkg.in/yaml.v3, or example.com/lib and example.com/lib/v2
+- a popular module next to a repository with the same name under another
+  owner, such as github.com/spf13/cobra and github.com/someone/cobra
+
+These are heuristics for reviewers. Modules matching suspicious.allow in
+.nopher.yaml are ignored. Nothing is fetched.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runDuplicates,
+}
+
+func init() {
+	rootCmd.AddCommand(duplicatesCmd)
+}
+
+func runDuplicates(cmd *cobra.Command, args []string) error {
+	dir := projectDir(args, 0)
+
+	lf, err := lockfile.Load(lockfile.Path(dir, lockProfile))
+	if err != nil {
+		return fmt.Errorf("loading lockfile: %w", err)
+	}
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	dups := suspect.Duplicates(lf, cfg.Suspicious.Allow)
+	if len(dups) == 0 {
+		fmt.Fprintln(out, "No duplicate modules found")
+		return nil
+	}
+	for _, d := range dups {
+		fmt.Fprintf(out, "%-7s %s\n", d.Kind, d)
+	}
+	fmt.Fprintf(out, "\n%d possible duplicates\n", len(dups))
+	return nil
+}
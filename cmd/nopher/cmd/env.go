@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// envPrefix prefixes the environment variables that set flags.
+const envPrefix = "NOPHER_"
+
+// flagEnvName returns the environment variable for a flag: --min-age is
+// NOPHER_MIN_AGE.
+func flagEnvName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyEnvFlags sets every flag of cmd, including inherited global flags,
+// that was not given on the command line from its NOPHER_ environment
+// variable, so command-line flags take precedence over the environment.
+func applyEnvFlags(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == "help" {
+			return
+		}
+		value, ok := os.LookupEnv(flagEnvName(f.Name))
+		if !ok {
+			return
+		}
+		if serr := cmd.Flags().Set(f.Name, value); serr != nil {
+			err = fmt.Errorf("invalid %s: %w", flagEnvName(f.Name), serr)
+		}
+	})
+	return err
+}
+
+// applyConfigDefaults applies the project settings of .nopher.yaml, from
+// the project containing the working directory: flags of cmd that neither
+// the command line nor the environment set take their value from it, and
+// GOPROXY and GOPRIVATE are set from its proxy and private settings unless
+// the environment already sets them. Setting the variables also passes
+// them to the go commands nopher runs.
+func applyConfigDefaults(cmd *cobra.Command) error {
+	cfg, err := config.Load(findProjectDir("."))
+	if err != nil {
+		return err
+	}
+
+	defaults := []struct{ flag, key, value string }{
+		{"cache-dir", "cacheDir", cfg.CacheDir},
+		{"format", "format", cfg.Format},
+		{"only", "include", strings.Join(cfg.Include, ",")},
+		{"skip", "exclude", strings.Join(cfg.Exclude, ",")},
+	}
+	if cfg.Concurrency != 0 {
+		defaults = append(defaults, struct{ flag, key, value string }{"jobs", "concurrency", strconv.Itoa(cfg.Concurrency)})
+	}
+	for _, d := range defaults {
+		f := cmd.Flags().Lookup(d.flag)
+		if d.value == "" || f == nil || f.Changed {
+			continue
+		}
+		if err := cmd.Flags().Set(d.flag, d.value); err != nil {
+			return fmt.Errorf("invalid %s in %s: %w", d.key, config.Filename, err)
+		}
+	}
+
+	if _, ok := os.LookupEnv("GOPROXY"); !ok && cfg.Proxy != "" {
+		os.Setenv("GOPROXY", cfg.Proxy)
+	}
+	_, private := os.LookupEnv("GOPRIVATE")
+	_, noproxy := os.LookupEnv("GONOPROXY")
+	if !private && !noproxy && len(cfg.Private) > 0 {
+		os.Setenv("GOPRIVATE", strings.Join(cfg.Private, ","))
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var explainURLStrict bool
+
+var explainURLCmd = &cobra.Command{
+	Use:   "explain-url <module>@<version>",
+	Short: "Explain how a module's download URL is chosen",
+	Long: `Print the decisions nopher takes when choosing the download URL of a
+module, and the URLs that would be tried.
+
+URL overrides are checked first. Otherwise private modules (GONOPROXY or
+GOPRIVATE) are fetched directly, and everything else through the proxy chain
+(mirrors from .nopher.yaml, or GOPROXY). Direct GitHub URLs come from origin
+metadata when available, with a tag archive URL as the fallback. Nothing is
+downloaded, but origin metadata is looked up, so network access may be needed.
+Configuration is read from the project containing the current directory.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExplainURL,
+}
+
+func init() {
+	rootCmd.AddCommand(explainURLCmd)
+	explainURLCmd.Flags().BoolVar(&explainURLStrict, "strict", false, "show whether --strict would reject the URL")
+}
+
+func runExplainURL(cmd *cobra.Command, args []string) error {
+	modulePath, version, ok := strings.Cut(args[0], "@")
+	if !ok || modulePath == "" || version == "" {
+		return fmt.Errorf("%q is not of the form <module>@<version>", args[0])
+	}
+
+	cfg, err := config.Load(findProjectDir("."))
+	if err != nil {
+		return err
+	}
+	fetcher, err := newFetcher(cfg)
+	if err != nil {
+		return err
+	}
+	defer fetcher.Close()
+	fetcher.Strict = explainURLStrict
+
+	e := fetcher.ExplainURL(modulePath, version)
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "%s@%s\n", modulePath, version)
+	for _, s := range e.Steps {
+		fmt.Fprintf(out, "  %s\n", s)
+	}
+	if e.URL == "" {
+		fmt.Fprintln(out, "URL: none")
+		return nil
+	}
+	fmt.Fprintf(out, "URL: %s", e.URL)
+	if e.Guessed {
+		fmt.Fprint(out, " (guessed)")
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Tried in order:")
+	for _, u := range e.Tried {
+		fmt.Fprintf(out, "  %s\n", u)
+	}
+	return nil
+}
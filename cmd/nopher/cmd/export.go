@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportTo  string
+	exportOut string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export --to builder-json [directory]",
+	Short: "Export the lockfile for machine consumers",
+	Long: `Export the lockfile in a machine-oriented format.
+
+--to builder-json writes exactly the structure buildNopherGoApp consumes: a
+flat list of the modules to vendor, replacements included, each with the Nix
+fetcher that fetches it (fetchGit, fetchzip, or fetchurl), its hash, and its
+subdirectory. Pass the file as the builder's modules argument to build
+without parsing YAML. The builder JSON has its own schema version
+(builderSchema), so tools reading it are unaffected by changes to the
+lockfile's schema.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runExport,
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVar(&exportTo, "to", "", "export `format`: builder-json (required)")
+	exportCmd.Flags().StringVar(&exportOut, "out", "", "write to `file` instead of stdout")
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	if exportTo != "builder-json" {
+		return fmt.Errorf("unknown export format %q (want builder-json)", exportTo)
+	}
+	dir := projectDir(args, 0)
+
+	lf, err := lockfile.Load(lockfile.Path(dir, lockProfile))
+	if err != nil {
+		return fmt.Errorf("loading lockfile: %w", err)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(lf.Builder()); err != nil {
+		return err
+	}
+	if exportOut == "" || exportOut == "-" {
+		_, err = cmd.OutOrStdout().Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(exportOut, buf.Bytes(), 0o644)
+}
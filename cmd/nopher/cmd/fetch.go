@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/anthr76/nopher/internal/fetch"
+	"github.com/anthr76/nopher/pkg/generator"
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fetchDest    string
+	fetchSource  string
+	fetchVerbose bool
+	fetchJSON    bool
+	fetchExplain bool
+)
+
+var fetchCmd = &cobra.Command{
+	Use:   "fetch [directory]",
+	Short: "Fetch and verify all locked modules",
+	Long: `Fetch every module in the lockfile into a destination directory, verifying
+each one against its locked hash.
+
+Modules are written to <dest>/<module path>, matching the vendor directory
+layout (replaced modules are written under their original path). With
+--source, zips are read from a directory in GOPROXY layout instead of the
+network, so the command can run inside an offline Nix build. Any hash
+mismatch fails the command; nothing is trusted from the local cache.
+
+--progress-json writes one JSON object per line to stdout as each module is
+started, downloaded, hashed (verified against the lockfile), or fails, in
+place of the summary.
+
+--explain-mismatch compares each artifact that fails verification with a
+cached copy of the locked artifact (from the Go module cache or nopher's
+cache), listing added, removed, and changed files and files that differ
+only in line endings, and names likely causes such as a re-tagged release,
+a switch between the module proxy and a forge archive, or an archive
+regenerated with new metadata.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runFetch,
+}
+
+func init() {
+	rootCmd.AddCommand(fetchCmd)
+	fetchCmd.Flags().StringVar(&fetchDest, "dest", "", "directory to write modules to (required)")
+	fetchCmd.Flags().StringVar(&fetchSource, "source", "", "read module zips from this GOPROXY-layout directory instead of downloading")
+	fetchCmd.Flags().BoolVarP(&fetchVerbose, "verbose", "v", false, "verbose output")
+	fetchCmd.Flags().BoolVar(&fetchJSON, "progress-json", false, "write newline-delimited JSON progress events to stdout")
+	fetchCmd.Flags().BoolVar(&fetchExplain, "explain-mismatch", false, "explain hash mismatches by comparing with cached copies")
+	fetchCmd.MarkFlagRequired("dest")
+}
+
+func runFetch(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	lf, err := lockfile.Load(lockfile.Path(dir, lockProfile))
+	if err != nil {
+		return fmt.Errorf("loading lockfile: %w", err)
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return err
+	}
+	fetcher, err := newFetcher(cfg)
+	if err != nil {
+		return err
+	}
+	defer fetcher.Close()
+	fetcher.Verbose = fetchVerbose
+	fetcher.ExplainMismatches = fetchExplain
+
+	targets := lockedTargets(lf)
+
+	progress := func(generator.Event) {}
+	if fetchJSON {
+		progress = jsonProgress(cmd.OutOrStdout())
+		fetcher.OnDownload = func(modulePath, version, url string, size int64) {
+			progress(generator.Event{Kind: generator.EventDownloaded, Path: modulePath, Version: version, Total: len(targets), URL: url, Size: size})
+		}
+	}
+
+	var failed []error
+	for _, t := range targets {
+		event := generator.Event{Path: t.locked.Path, Version: t.locked.Version, Total: len(targets)}
+		event.Kind = generator.EventStarted
+		progress(event)
+		err := fetcher.FetchVerified(t.locked, fetchSource, filepath.Join(fetchDest, t.dest))
+		if err != nil {
+			event.Kind, event.Err = generator.EventFailed, err
+			progress(event)
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			var hm *fetch.HashMismatchError
+			if errors.As(err, &hm) && hm.Report != nil {
+				fmt.Fprint(os.Stderr, hm.Report)
+			}
+			failed = append(failed, err)
+			continue
+		}
+		event.Kind, event.Hash = generator.EventHashed, t.locked.Hash
+		progress(event)
+		if fetchVerbose {
+			fmt.Fprintf(os.Stderr, "Verified %s@%s\n", t.locked.Path, t.locked.Version)
+		}
+	}
+
+	if len(failed) > 0 {
+		var mismatches int
+		for _, err := range failed {
+			var hm *fetch.HashMismatchError
+			if errors.As(err, &hm) {
+				mismatches++
+			}
+		}
+		return fmt.Errorf("%d of %d modules failed (%d hash mismatches)", len(failed), len(targets), mismatches)
+	}
+
+	if !fetchJSON {
+		fmt.Fprintf(cmd.OutOrStdout(), "Fetched and verified %d modules into %s\n", len(targets), fetchDest)
+	}
+	return nil
+}
+
+// fetchTarget is a locked module and the directory it is written to,
+// relative to the destination.
+type fetchTarget struct {
+	locked   fetch.Locked
+	dest     string
+	replaced bool // locked as the replacement Replace[dest]
+}
+
+// lockedTargets lists every remotely fetched module in lf in destination
+// order, so a module is extracted before any module nested inside it
+// (extraction replaces the target directory). Remote replacements are
+// fetched from their new path but written under the original one; local
+// replacements are skipped.
+func lockedTargets(lf *lockfile.Lockfile) []fetchTarget {
+	var targets []fetchTarget
+	for path, m := range lf.Modules {
+		if r, replaced := lf.Replace[path]; replaced && r.Applies(m.Version) {
+			continue
+		}
+		targets = append(targets, fetchTarget{
+			locked: fetch.Locked{Path: path, Version: m.Version, Hash: m.Hash, HashType: m.HashType, URL: m.URL, URLs: m.URLs, Subdir: m.Subdir},
+			dest:   path,
+		})
+	}
+	for old, r := range lf.Replace {
+		if r.Path != "" || r.New == "" {
+			continue
+		}
+		targets = append(targets, fetchTarget{
+			locked:   fetch.Locked{Path: r.New, Version: r.Version, Hash: r.Hash, HashType: r.HashType, URL: r.URL, URLs: r.URLs, Subdir: r.Subdir},
+			dest:     old,
+			replaced: true,
+		})
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].dest < targets[j].dest })
+	return targets
+}
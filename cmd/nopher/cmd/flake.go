@@ -0,0 +1,369 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/anthr76/nopher/internal/mod"
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/module"
+)
+
+var (
+	flakeSyncPinGo  bool
+	flakeSyncDryRun bool
+	flakeInitForce  bool
+)
+
+var flakeCmd = &cobra.Command{
+	Use:   "flake",
+	Short: "Maintain nopher references in flake.nix",
+}
+
+var flakeSyncCmd = &cobra.Command{
+	Use:   "sync [directory]",
+	Short: "Update flake.nix to match go.mod and the lockfile",
+	Long: `Update nopher-related references in an existing flake.nix in place:
+
+- goVersion = "...";            set to the toolchain (or go) directive from go.mod
+                                (go.work at a workspace root)
+- modules = ./nopher.lock.yaml; pointed at the current lockfile (profile or .gz)
+- go = pkgs.go...;              with --pin-go, pinned to pkgs.go_<major>_<minor>
+
+Only those assignments are edited; the rest of the file is left untouched,
+and running sync again makes no further changes.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runFlakeSync,
+}
+
+var flakeInitCmd = &cobra.Command{
+	Use:   "init [directory]",
+	Short: "Generate a flake.nix for the module",
+	Long: `Generate a flake.nix that builds the module with buildNopherGoApp and
+provides a devShell with the Go toolchain from go.mod plus nopher itself.
+
+The toolchain is pinned to the nixpkgs go_<major>_<minor> attribute for the
+go.mod toolchain (or go) directive; the devShell warns when nixpkgs provides a
+different patch release and sets GOTOOLCHAIN=local so go never downloads
+another toolchain. Keep the file current with nopher flake sync.
+
+At a go.work workspace root, one package is generated per member that has a
+main package, all built from the shared workspace lockfile.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runFlakeInit,
+}
+
+func init() {
+	rootCmd.AddCommand(flakeCmd)
+	flakeCmd.AddCommand(flakeInitCmd)
+	flakeCmd.AddCommand(flakeSyncCmd)
+	flakeInitCmd.Flags().BoolVar(&flakeInitForce, "force", false, "overwrite an existing flake.nix")
+	flakeSyncCmd.Flags().BoolVar(&flakeSyncPinGo, "pin-go", false, "pin go = pkgs.go_X_Y to the go.mod Go version")
+	flakeSyncCmd.Flags().BoolVar(&flakeSyncDryRun, "dry-run", false, "show changes without writing flake.nix")
+}
+
+func runFlakeSync(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	flakePath := filepath.Join(dir, "flake.nix")
+	src, err := os.ReadFile(flakePath)
+	if err != nil {
+		return fmt.Errorf("reading flake.nix: %w", err)
+	}
+
+	data, err := loadFlakeData(dir)
+	if err != nil {
+		return err
+	}
+
+	updated, changes := syncFlake(string(src), flakeSyncTarget{
+		goVersion: data.GoVersion,
+		lockfile:  filepath.Base(lockfile.Path(dir, lockProfile)),
+		pinGo:     flakeSyncPinGo,
+	})
+
+	out := cmd.OutOrStdout()
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "flake.nix is up to date")
+		return nil
+	}
+	for _, c := range changes {
+		fmt.Fprintf(out, "  %s\n", c)
+	}
+	if flakeSyncDryRun {
+		return nil
+	}
+
+	if err := os.WriteFile(flakePath, []byte(updated), 0o644); err != nil {
+		return fmt.Errorf("writing flake.nix: %w", err)
+	}
+	fmt.Fprintf(out, "Updated %s\n", flakePath)
+	return nil
+}
+
+// flakeSyncTarget is the state flake.nix should reflect.
+type flakeSyncTarget struct {
+	goVersion string // go directive from go.mod, e.g. "1.22.3"
+	lockfile  string // lockfile base name, e.g. "nopher.lock.yaml"
+	pinGo     bool
+}
+
+var (
+	flakeGoVersionRe = regexp.MustCompile(`(\bgoVersion\s*=\s*")([^"]*)(")`)
+	flakeModulesRe   = regexp.MustCompile(`(\bmodules\s*=\s*\./(?:[\w.-]+/)*)(nopher(?:\.[\w-]+)?\.lock\.yaml(?:\.gz)?)(\s*;)`)
+	flakeGoAttrRe    = regexp.MustCompile(`(\bgo\s*=\s*pkgs\.)(go(?:_\d+_\d+)?)(\s*;)`)
+)
+
+// syncFlake applies the edits needed for src to match t and describes each
+// one. Applying it to its own output yields no changes.
+func syncFlake(src string, t flakeSyncTarget) (string, []string) {
+	var changes []string
+
+	replace := func(re *regexp.Regexp, want, label string) {
+		src = re.ReplaceAllStringFunc(src, func(m string) string {
+			parts := re.FindStringSubmatch(m)
+			if parts[2] == want {
+				return m
+			}
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", label, parts[2], want))
+			return parts[1] + want + parts[3]
+		})
+	}
+
+	if t.goVersion != "" {
+		replace(flakeGoVersionRe, t.goVersion, "goVersion")
+	}
+	if t.lockfile != "" {
+		replace(flakeModulesRe, t.lockfile, "modules")
+	}
+	if t.pinGo && t.goVersion != "" {
+		if attr := goAttr(t.goVersion); attr != "" {
+			replace(flakeGoAttrRe, attr, "go")
+		}
+	}
+
+	return src, changes
+}
+
+// goAttr returns the nixpkgs Go attribute for a go directive, e.g.
+// "1.22.3" -> "go_1_22".
+func goAttr(goVersion string) string {
+	parts := strings.SplitN(goVersion, ".", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+	return "go_" + parts[0] + "_" + parts[1]
+}
+
+func runFlakeInit(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	flakePath := filepath.Join(dir, "flake.nix")
+	if _, err := os.Stat(flakePath); err == nil && !flakeInitForce {
+		return fmt.Errorf("%s already exists (use --force to overwrite, or nopher flake sync to update it)", flakePath)
+	}
+
+	data, err := loadFlakeData(dir)
+	if err != nil {
+		return err
+	}
+	if len(data.Packages) == 0 {
+		return fmt.Errorf("no workspace member in %s has a main package", dir)
+	}
+	data.Lockfile = filepath.Base(lockfile.Path(dir, lockProfile))
+
+	var buf bytes.Buffer
+	if err := flakeTemplate.Execute(&buf, data); err != nil {
+		return fmt.Errorf("rendering flake.nix: %w", err)
+	}
+
+	if err := os.WriteFile(flakePath, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("writing flake.nix: %w", err)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s\n", flakePath)
+	return nil
+}
+
+// flakeData fills flakeTemplate.
+type flakeData struct {
+	Description string
+	GoVersion   string
+	GoAttr      string
+	Lockfile    string
+	Packages    []flakePackage
+}
+
+// flakePackage is one buildNopherGoApp output.
+type flakePackage struct {
+	Attr        string
+	Pname       string
+	SubPackages string // Empty builds the module root
+}
+
+// loadFlakeData reads go.mod, or go.work for a workspace root. A workspace
+// gets one package per member with a main package, all sharing the root
+// lockfile.
+func loadFlakeData(dir string) (flakeData, error) {
+	workPath := filepath.Join(dir, "go.work")
+	if _, err := os.Stat(workPath); err != nil || os.Getenv("GOWORK") == "off" {
+		modInfo, err := mod.ParseGoMod(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			return flakeData{}, fmt.Errorf("parsing go.mod: %w", err)
+		}
+		data := newFlakeData(modInfo.ToolchainVersion())
+		data.Description = modInfo.ModulePath
+		data.Packages = []flakePackage{{Attr: "default", Pname: pnameOf(modInfo.ModulePath)}}
+		return data, nil
+	}
+
+	work, err := mod.ParseGoWork(workPath)
+	if err != nil {
+		return flakeData{}, err
+	}
+	data := newFlakeData(work.ToolchainVersion())
+	if abs, err := filepath.Abs(dir); err == nil {
+		data.Description = filepath.Base(abs)
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range work.Members {
+		hasMain, err := hasMainPackage(filepath.Join(dir, m.Dir))
+		if err != nil {
+			return flakeData{}, fmt.Errorf("workspace member %s: %w", m.Dir, err)
+		}
+		if !hasMain {
+			continue
+		}
+		pname := pnameOf(m.ModulePath)
+		attr := pname
+		if seen[attr] {
+			attr = strings.ReplaceAll(strings.TrimPrefix(m.Dir, "./"), "/", "-")
+		}
+		seen[attr] = true
+		subPackages := m.Dir + "/..."
+		if m.Dir == "." {
+			subPackages = "./..."
+		}
+		data.Packages = append(data.Packages, flakePackage{Attr: attr, Pname: pname, SubPackages: subPackages})
+	}
+	return data, nil
+}
+
+func newFlakeData(goVersion string) flakeData {
+	goAttrName := goAttr(goVersion)
+	if goAttrName == "" {
+		goAttrName = "go"
+	}
+	return flakeData{GoVersion: goVersion, GoAttr: goAttrName}
+}
+
+// pnameOf returns the last element of a module path, ignoring a /vN suffix.
+func pnameOf(modulePath string) string {
+	if prefix, _, ok := module.SplitPathVersion(modulePath); ok && prefix != "" {
+		modulePath = prefix
+	}
+	return path.Base(modulePath)
+}
+
+// hasMainPackage reports whether the module rooted at dir contains a main
+// package. Nested modules, vendor, testdata, and hidden directories are
+// skipped, as go build ./... would.
+func hasMainPackage(dir string) (bool, error) {
+	found := false
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if p != dir {
+				if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+					return filepath.SkipDir
+				}
+				if _, err := os.Stat(filepath.Join(p, "go.mod")); err == nil {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, ".go") || strings.HasSuffix(p, "_test.go") {
+			return nil
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), p, nil, parser.PackageClauseOnly)
+		if err != nil {
+			return nil // Not our problem; go build will report it
+		}
+		if f.Name.Name == "main" {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found, err
+}
+
+var flakeTemplate = template.Must(template.New("flake.nix").Parse(`{
+  description = "{{.Description}}";
+
+  inputs = {
+    nixpkgs.url = "github:NixOS/nixpkgs/nixpkgs-unstable";
+    flake-utils.url = "github:numtide/flake-utils";
+    nopher.url = "github:anthr76/nopher";
+    nopher.inputs.nixpkgs.follows = "nixpkgs";
+  };
+
+  outputs = { self, nixpkgs, flake-utils, nopher }:
+    flake-utils.lib.eachDefaultSystem (system:
+      let
+        pkgs = nixpkgs.legacyPackages.${system};
+
+        # Go toolchain from go.mod (kept current by nopher flake sync)
+        goVersion = "{{.GoVersion}}";
+        go = pkgs.{{.GoAttr}};
+      in
+      {
+{{- range .Packages}}
+        packages.{{.Attr}} = nopher.lib.${system}.buildNopherGoApp {
+          inherit go;
+          pname = "{{.Pname}}";
+          version = "0.1.0";
+          src = ./.;
+          modules = ./{{$.Lockfile}};
+{{- if .SubPackages}}
+          subPackages = [ "{{.SubPackages}}" ];
+{{- end}}
+        };
+{{end}}
+        devShells.default = pkgs.mkShell {
+          packages = [
+            go
+            nopher.packages.${system}.default
+          ];
+
+          GOTOOLCHAIN = "local";
+
+          shellHook = ''
+            if [ "${go.version}" != "${goVersion}" ]; then
+              echo "warning: go.mod wants Go ${goVersion}, nixpkgs provides ${go.version}" >&2
+            fi
+          '';
+        };
+      });
+}
+`))
@@ -1,15 +1,41 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/anthr76/nopher/internal/fetch"
+	"github.com/anthr76/nopher/internal/mod"
+	"github.com/anthr76/nopher/internal/suspect"
 	"github.com/anthr76/nopher/pkg/generator"
+	"github.com/anthr76/nopher/pkg/lockfile"
 	"github.com/spf13/cobra"
 )
 
 var (
 	generateVerbose bool
 	generateTidy    bool
+	generateMetrics string
+	generateNoMeta  bool
+	generateRepro   bool
+	generateGraph   bool
+	generateGzip    bool
+	generateFormat  string
+	generateTrust   bool
+	generateDump    string
+	generateStrict  bool
+	generateSkipSum bool
+	generateOnly    string
+	generateSkip    string
+	generatePlan    bool
+	generateJSON    bool
+	generateTUI     bool
 )
 
 var generateCmd = &cobra.Command{
@@ -18,7 +44,39 @@ var generateCmd = &cobra.Command{
 	Long: `Generate a nopher.lock.yaml file from go.mod and go.sum.
 
 The lockfile contains all module dependencies with their versions and hashes,
-enabling reproducible Nix builds.`,
+enabling reproducible Nix builds.
+
+--only and --skip take comma-separated module path patterns, as in
+GOPRIVATE, and limit which modules are fetched, for iterating on a few
+problematic modules. Every other module keeps its entry from the existing
+lockfile if it locks the same version, and is otherwise left out with a
+warning.
+
+--plan prints, for each module that would be fetched, the source and URL it
+would be downloaded from, how the request is authenticated, and whether it
+is already in the cache, then exits without downloading anything or
+writing the lockfile. Use it to check proxy and credential configuration
+before a long fetch. Origin metadata is still looked up for GitHub modules.
+
+--progress-json writes one JSON object per line to stdout as each module is
+started, downloaded, hashed, or fails, for wrappers that render their own
+progress, in place of the summary.
+
+--format toml writes nopher.lock.toml instead of nopher.lock.yaml, removing
+the YAML lockfile; Nix reads it with builtins.fromTOML, without import from
+derivation. Later runs keep the existing lockfile's format.
+
+--from-goproxy-dump takes every module from a GOPROXY storage dump in the
+Athens disk layout (<dir>/<module>/<version>/source.zip), such as one kept
+for disaster recovery, instead of the network. Each zip is checked against
+its go.sum h1 hash, and generation fails on any module missing from the
+dump or from go.sum. The lockfile records proxy URLs, since the dump holds
+what the proxy serves.
+
+--tui shows a full-screen dashboard of the module being fetched, recent
+results, failures, retries, and cache hits while generating, which helps on
+large projects. It needs a terminal on stderr; otherwise the usual output
+is printed.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runGenerate,
 }
@@ -27,6 +85,23 @@ func init() {
 	rootCmd.AddCommand(generateCmd)
 	generateCmd.Flags().BoolVarP(&generateVerbose, "verbose", "v", false, "verbose output")
 	generateCmd.Flags().BoolVar(&generateTidy, "tidy", false, "run go mod tidy before generating (requires go)")
+	generateCmd.Flags().StringVar(&generateMetrics, "metrics-out", "", "write a JSON fetch metrics report to this path")
+	generateCmd.Flags().BoolVar(&generateNoMeta, "no-meta", false, "omit the provenance meta block from the lockfile")
+	generateCmd.Flags().BoolVar(&generateGraph, "graph", false, "embed module dependency edges from go mod graph (requires go)")
+	generateCmd.Flags().BoolVar(&generateGzip, "gzip", false, "write a gzip-compressed lockfile (nopher.lock.yaml.gz)")
+	generateCmd.Flags().StringVar(&generateFormat, "format", "", "lockfile `format`: yaml or toml (nopher.lock.toml); default keeps the existing lockfile's")
+	generateCmd.Flags().BoolVar(&generateTrust, "trust-gosum", false, "take hashes from the Go module cache when zips match go.sum, skipping downloads")
+	generateCmd.Flags().StringVar(&generateDump, "from-goproxy-dump", "", "take modules from this Athens-layout GOPROXY dump `directory`, verifying go.sum hashes, instead of the network")
+	generateCmd.MarkFlagsMutuallyExclusive("from-goproxy-dump", "trust-gosum")
+	generateCmd.Flags().BoolVar(&generateSkipSum, "skip-missing-sums", false, "leave out requirements that have no go.sum entry instead of failing")
+	generateCmd.Flags().StringVar(&generateOnly, "only", "", "fetch only modules matching these comma-separated patterns, keeping other entries")
+	generateCmd.Flags().StringVar(&generateSkip, "skip", "", "don't fetch modules matching these comma-separated patterns, keeping their entries")
+	generateCmd.Flags().BoolVar(&generatePlan, "plan", false, "print where each module would be fetched from, without downloading")
+	generateCmd.Flags().BoolVar(&generateStrict, "strict", false, "fail instead of guessing download URLs for modules without a verified source")
+	generateCmd.Flags().BoolVar(&generateJSON, "progress-json", false, "write newline-delimited JSON progress events to stdout")
+	generateCmd.Flags().BoolVar(&generateTUI, "tui", false, "show a full-screen progress dashboard when stderr is a terminal")
+	generateCmd.MarkFlagsMutuallyExclusive("tui", "progress-json", "verbose")
+	generateCmd.Flags().BoolVar(&generateRepro, "reproducible", false, "omit the generation timestamp so output is byte-identical across machines")
 }
 
 func runGenerate(cmd *cobra.Command, args []string) error {
@@ -35,19 +110,243 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		dir = args[0]
 	}
 
-	_ = generateTidy // TODO: implement tidy support
+	// Tidying first means generation reads the go.mod and go.sum it
+	// leaves behind.
+	if generateTidy {
+		if err := mod.Tidy(dir); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	opts, err := configOptions(cfg)
+	if err != nil {
+		return err
+	}
+	opts.Verbose = generateVerbose
+	opts.Graph = generateGraph
+	opts.Profile = lockProfile
+	opts.Compress = generateGzip
+	if generateFormat != "" {
+		if opts.Format, err = lockfile.ParseFormat(generateFormat); err != nil {
+			return err
+		}
+	}
+	opts.TrustGoSum = generateTrust
+	opts.GoproxyDump = generateDump
+	opts.Strict = generateStrict
+	opts.SkipMissingSums = generateSkipSum
+	opts.Only = generateOnly
+	opts.Skip = generateSkip
+	if !generateNoMeta {
+		opts.Meta = generationMeta(generateRepro)
+	}
+	if generateMetrics != "" {
+		opts.Metrics = generator.NewMetrics()
+	}
+	if generateJSON {
+		opts.Progress = jsonProgress(cmd.OutOrStdout())
+	}
+	if generatePlan {
+		return printPlan(cmd.OutOrStdout(), dir, opts)
+	}
 
-	lf, err := generator.GenerateAndSave(dir, generator.Options{
-		Verbose: generateVerbose,
-	})
+	unlock, err := lockfile.Lock(dir)
 	if err != nil {
 		return err
 	}
+	defer unlock()
 
+	// The previous lockfile, if any, tells which modules are newly added.
+	prev, _ := lockfile.Load(lockfile.Path(dir, lockProfile))
+
+	// Warnings written while the dashboard is up would be drawn over, so
+	// they are held back until it stops.
+	var stderr io.Writer = os.Stderr
+	var held bytes.Buffer
+	var dash *dashboard
+	if generateTUI && isTerminal(os.Stderr) {
+		stderr = &held
+		dash = newDashboard(os.Stderr, "nopher generate "+dir)
+		opts.Progress = dash.event
+	}
+
+	rule, err := minAgeRule(cfg.Policy)
+	if err != nil {
+		return err
+	}
+	if rule != nil {
+		opts.Check = func(lf *lockfile.Lockfile) error {
+			return checkMinAge(stderr, cfg, lf, prev, *rule)
+		}
+	}
+
+	if dash != nil {
+		dash.run()
+	}
+	lf, err := generator.GenerateAndSave(dir, opts)
+	if dash != nil {
+		dash.stop()
+		os.Stderr.Write(held.Bytes())
+	}
+	if opts.Metrics != nil {
+		if merr := opts.Metrics.WriteFile(generateMetrics); merr != nil {
+			if err == nil {
+				return merr
+			}
+			fmt.Fprintf(os.Stderr, "warning: %v\n", merr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, f := range suspect.Check(lf, prev, cfg.Suspicious.Allow) {
+		fmt.Fprintf(os.Stderr, "warning: suspicious module: %s\n", f)
+	}
+	warnGoModReplaces(os.Stderr, lf, prev)
+
+	if generateJSON {
+		return nil
+	}
 	fmt.Printf("Generated lockfile with %d modules\n", len(lf.Modules))
 	if len(lf.Replace) > 0 {
 		fmt.Printf("  Replacements: %d\n", len(lf.Replace))
 	}
+	if n := countGoModReplaces(lf); n > 0 {
+		fmt.Printf("  With their own replace directives: %d (see nopher info)\n", n)
+	}
 
 	return nil
 }
+
+// configOptions returns generator options carrying the fetch settings and
+// module rules from cfg, and the network and cache flags.
+func configOptions(cfg *config.Config) (generator.Options, error) {
+	rules, err := moduleRules(cfg.Policy)
+	if err != nil {
+		return generator.Options{}, err
+	}
+
+	signers, err := fetchSigners(cfg.Signing)
+	if err != nil {
+		return generator.Options{}, err
+	}
+
+	app, err := githubApp(cfg.GitHubApp)
+	if err != nil {
+		return generator.Options{}, err
+	}
+
+	symlinks, err := fetch.ParseSymlinkPolicy(cfg.Symlinks)
+	if err != nil {
+		return generator.Options{}, err
+	}
+
+	network, err := networkOptions()
+	if err != nil {
+		return generator.Options{}, err
+	}
+
+	perm, key, err := cacheOptions()
+	if err != nil {
+		return generator.Options{}, err
+	}
+
+	opts := generator.Options{
+		URLOverrides:  cfg.URLOverrides,
+		Mirrors:       cfg.Mirrors,
+		Symlinks:      symlinks,
+		Network:       network,
+		FetchLog:      fetchLog,
+		GitHubApp:     app,
+		GitLabHosts:   cfg.GitLab,
+		CachePerm:     perm,
+		CacheKey:      key,
+		ReadOnlyCache: cacheReadOnly,
+		CacheDir:      cacheDir,
+	}
+	if len(signers) > 0 {
+		opts.Signers = make(map[string]func(*http.Request) error, len(signers))
+		for host, s := range signers {
+			opts.Signers[host] = s.Sign
+		}
+	}
+	if rules != nil {
+		opts.Permit = rules.Check
+	}
+	for _, command := range cfg.Hooks.PostFetch {
+		opts.PostFetch = append(opts.PostFetch, generator.CommandHook(command))
+	}
+	return opts, nil
+}
+
+// generationMeta builds the provenance block for a generated lockfile.
+// In reproducible mode the timestamp is omitted.
+func generationMeta(reproducible bool) *lockfile.Meta {
+	meta := &lockfile.Meta{
+		Generator: "nopher " + Version,
+		Proxy:     fetch.ProxyFromEnv(),
+	}
+	if meta.Proxy == "" {
+		meta.Proxy = "direct"
+	}
+	if !reproducible {
+		meta.GeneratedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	return meta
+}
+
+// printPlan prints the fetch plan of generator.Plan, one module per line.
+func printPlan(w io.Writer, dir string, opts generator.Options) error {
+	plan, err := generator.Plan(dir, opts)
+	if err != nil {
+		return err
+	}
+
+	cached, rejected := 0, 0
+	for _, p := range plan {
+		fmt.Fprintf(w, "%s@%s  %s", p.Path, p.Version, p.Source)
+		if p.URL != "" {
+			fmt.Fprintf(w, "  %s", p.URL)
+		}
+		var notes []string
+		if p.Replaces != "" {
+			notes = append(notes, "replaces "+p.Replaces)
+		}
+		if p.CacheHit {
+			notes = append(notes, "cached")
+			cached++
+		}
+		if p.Auth != "" {
+			notes = append(notes, "auth: "+p.Auth)
+		}
+		if p.Guessed {
+			if opts.Strict {
+				notes = append(notes, "guessed, rejected by --strict")
+				rejected++
+			} else {
+				notes = append(notes, "guessed")
+			}
+		}
+		if p.Source == fetch.SourceOff && !p.CacheHit {
+			notes = append(notes, "GOPROXY=off")
+			rejected++
+		}
+		if len(notes) > 0 {
+			fmt.Fprintf(w, "  (%s)", strings.Join(notes, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "%d module(s) to fetch: %d cached, %d to download", len(plan), cached, len(plan)-cached-rejected)
+	if rejected > 0 {
+		fmt.Fprintf(w, ", %d would fail", rejected)
+	}
+	fmt.Fprintln(w)
+	return nil
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/anthr76/nopher/internal/hash"
+	"github.com/spf13/cobra"
+)
+
+var hashCmd = &cobra.Command{
+	Use:   "hash",
+	Short: "Inspect NAR hashing",
+}
+
+var hashCheckCmd = &cobra.Command{
+	Use:   "check <path>",
+	Short: "Compare the pure-Go NAR hash of a path with nix",
+	Long: `Compute the NAR hash of a path with nopher's pure-Go serializer and, when
+nix is installed, with nix hash path, and report whether they agree.
+
+The tree is also scanned for structures the pure-Go serializer is known to
+hash differently from nix, such as files executable by group or others but
+not their owner. nopher refuses to emit a pure-Go NAR hash for such trees
+when nix is unavailable. Any divergence or mismatch fails the command.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHashCheck,
+}
+
+func init() {
+	rootCmd.AddCommand(hashCmd)
+	hashCmd.AddCommand(hashCheckCmd)
+}
+
+func runHashCheck(cmd *cobra.Command, args []string) error {
+	path := args[0]
+	out := cmd.OutOrStdout()
+
+	goHash, err := hash.GoNARHash(path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "go:  %s\n", goHash)
+
+	nixHash, err := hash.NixNARHash(path)
+	switch {
+	case errors.Is(err, hash.ErrNixUnavailable):
+		fmt.Fprintln(out, "nix: not available")
+	case err != nil:
+		return err
+	default:
+		fmt.Fprintf(out, "nix: %s\n", nixHash)
+	}
+
+	divergences, err := hash.FindDivergences(path)
+	if err != nil {
+		return err
+	}
+	if len(divergences) > 0 {
+		fmt.Fprintln(out, "Known divergent structures:")
+		for _, d := range divergences {
+			fmt.Fprintf(out, "  %s\n", d)
+		}
+	}
+
+	if nixHash != "" && nixHash != goHash {
+		return errors.New("pure-Go NAR hash differs from nix")
+	}
+	if len(divergences) > 0 {
+		return fmt.Errorf("%d known divergent structure(s)", len(divergences))
+	}
+	if nixHash != "" {
+		fmt.Fprintln(out, "Hashes match")
+	} else {
+		fmt.Fprintln(out, "No known divergent structures")
+	}
+	return nil
+}
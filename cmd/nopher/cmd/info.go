@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var infoCmd = &cobra.Command{
+	Use:   "info <module-path> [directory]",
+	Short: "Show a locked module's lockfile entry",
+	Long: `Show everything the lockfile records about a module: its version, source,
+hashes, size, review annotations, and quarantine status. A replaced module
+shows its replacement.
+
+Replace directives in the module's own go.mod are listed too. go ignores
+them outside the main module, so a dependency that replaces something with
+a fork is built against the module this lockfile selects instead.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runInfo,
+}
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+}
+
+func runInfo(cmd *cobra.Command, args []string) error {
+	modulePath := args[0]
+	dir := projectDir(args, 1)
+
+	lf, err := lockfile.Load(lockfile.Path(dir, lockProfile))
+	if err != nil {
+		return fmt.Errorf("loading lockfile: %w", err)
+	}
+	return printInfo(cmd.OutOrStdout(), lf, modulePath)
+}
+
+// printInfo writes the lockfile entry of modulePath, as a module or as a
+// replacement.
+func printInfo(w io.Writer, lf *lockfile.Lockfile, modulePath string) error {
+	var (
+		rows        [][2]string
+		replaces    []string
+		annotations lockfile.Annotations
+	)
+	if m, ok := lf.Modules[modulePath]; ok {
+		fmt.Fprintf(w, "%s@%s\n", modulePath, m.Version)
+		rows = sourceRows(m.URL, m.URLs, m.Rev, m.Subdir, m.Hash, m.HashType, m.Sum, m.Size, m.Files)
+		if m.Via != "" {
+			rows = append(rows, [2]string{"via", m.Via})
+		}
+		replaces, annotations = m.GoModReplace, m.Annotations
+	} else if r, ok := lf.ReplaceFor(modulePath); ok {
+		fmt.Fprintf(w, "%s => %s\n", modulePath, r.Target())
+		if r.Match != "" {
+			rows = append(rows, [2]string{"replaces", modulePath + "@" + r.Match})
+		}
+		if r.Path == "" {
+			rows = append(rows, sourceRows(r.URL, r.URLs, r.Rev, r.Subdir, r.Hash, r.HashType, r.Sum, r.Size, r.Files)...)
+		}
+		replaces, annotations = r.GoModReplace, r.Annotations
+	} else {
+		return fmt.Errorf("%s is not in the lockfile", modulePath)
+	}
+
+	if q, ok := lf.Quarantine[modulePath]; ok {
+		rows = append(rows, [2]string{"quarantine", strings.TrimSpace("yes " + q.Reason)})
+	}
+	if annotations.ReviewedBy != "" {
+		rows = append(rows, [2]string{"reviewedBy", annotations.ReviewedBy})
+	}
+	if annotations.Notes != "" {
+		rows = append(rows, [2]string{"notes", annotations.Notes})
+	}
+	for _, row := range rows {
+		fmt.Fprintf(w, "  %-11s %s\n", row[0]+":", row[1])
+	}
+
+	if len(replaces) > 0 {
+		fmt.Fprintln(w, "  go.mod replaces (ignored by go outside the main module):")
+		for _, r := range replaces {
+			fmt.Fprintf(w, "    %s\n", r)
+		}
+	}
+	return nil
+}
+
+// sourceRows describes where a locked module comes from and what it hashes
+// to.
+func sourceRows(url string, urls []string, rev, subdir, hash, hashType, sum string, size int64, files int) [][2]string {
+	var rows [][2]string
+	if url != "" {
+		rows = append(rows, [2]string{"url", url})
+	}
+	for _, u := range urls {
+		if u != url {
+			rows = append(rows, [2]string{"mirror", u})
+		}
+	}
+	if rev != "" {
+		rows = append(rows, [2]string{"rev", rev})
+	}
+	if subdir != "" {
+		rows = append(rows, [2]string{"subdir", subdir})
+	}
+	if hashType != "" {
+		hash += " (" + hashType + ")"
+	}
+	rows = append(rows, [2]string{"hash", hash})
+	if sum != "" {
+		rows = append(rows, [2]string{"sum", sum})
+	}
+	if size > 0 || files > 0 {
+		rows = append(rows, [2]string{"size", fmt.Sprintf("%s, %d files", formatSize(size), files)})
+	}
+	return rows
+}
+
+// warnGoModReplaces warns about locked modules whose own go.mod has replace
+// directives, unless prev already recorded the same ones: go ignores them
+// outside the main module, which changes what the build compiles from what
+// the module's authors tested.
+func warnGoModReplaces(w io.Writer, lf, prev *lockfile.Lockfile) {
+	type entry struct {
+		key      string
+		replaces []string
+	}
+	var entries []entry
+	for path, m := range lf.Modules {
+		var seen []string
+		if prev != nil {
+			if old, ok := prev.Modules[path]; ok && old.Version == m.Version {
+				seen = old.GoModReplace
+			}
+		}
+		if !slices.Equal(m.GoModReplace, seen) {
+			entries = append(entries, entry{path + "@" + m.Version, m.GoModReplace})
+		}
+	}
+	for path, r := range lf.Replace {
+		var seen []string
+		if prev != nil {
+			if old, ok := prev.Replace[path]; ok && old.Target() == r.Target() {
+				seen = old.GoModReplace
+			}
+		}
+		if !slices.Equal(r.GoModReplace, seen) {
+			entries = append(entries, entry{r.Target(), r.GoModReplace})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	for _, e := range entries {
+		printGoModReplaces(w, e.key, e.replaces)
+	}
+}
+
+// countGoModReplaces returns the number of locked modules and replacements
+// whose own go.mod has replace directives.
+func countGoModReplaces(lf *lockfile.Lockfile) int {
+	n := 0
+	for _, m := range lf.Modules {
+		if len(m.GoModReplace) > 0 {
+			n++
+		}
+	}
+	for _, r := range lf.Replace {
+		if len(r.GoModReplace) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// printGoModReplaces warns that the module at key (path@version) has the
+// given replace directives in its go.mod.
+func printGoModReplaces(w io.Writer, key string, replaces []string) {
+	if len(replaces) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "warning: %s has replace directives in its go.mod, which go ignores outside the main module:\n", key)
+	for _, r := range replaces {
+		fmt.Fprintf(w, "  %s\n", r)
+	}
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var (
+	listSort   string
+	listOwners bool
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list [directory]",
+	Short: "List locked modules",
+	Long: `List the modules recorded in the lockfile with their size and file count.
+
+Use --sort size or --sort files to find the dependencies contributing most
+to the Nix closure. With --owners, each module is followed by the teams that
+own it, from the owners rules in .nopher.yaml.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runList,
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().StringVar(&listSort, "sort", "name", "sort order: name, size, or files")
+	listCmd.Flags().BoolVar(&listOwners, "owners", false, "show the owners of each module")
+}
+
+type listEntry struct {
+	path    string
+	version string
+	size    int64
+	files   int
+	owners  []string
+}
+
+func runList(cmd *cobra.Command, args []string) error {
+	dir := projectDir(args, 0)
+
+	lf, err := lockfile.Load(lockfile.Path(dir, lockProfile))
+	if err != nil {
+		return fmt.Errorf("loading lockfile: %w", err)
+	}
+
+	ownersOf := func(string) []string { return nil }
+	if listOwners {
+		cfg, err := config.Load(dir)
+		if err != nil {
+			return err
+		}
+		ownersOf = cfg.OwnersOf
+	}
+
+	var entries []listEntry
+	for path, m := range lf.Modules {
+		entries = append(entries, listEntry{path: path, version: m.Version, size: m.Size, files: m.Files, owners: ownersOf(path)})
+	}
+	for path, r := range lf.Replace {
+		if r.Path != "" {
+			continue
+		}
+		entries = append(entries, listEntry{path: path + " => " + r.New, version: r.Version, size: r.Size, files: r.Files, owners: ownersOf(path)})
+	}
+
+	if err := sortListEntries(entries, listSort); err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	var totalSize int64
+	var totalFiles int
+	for _, e := range entries {
+		fmt.Fprintf(out, "%-10s %6d  %s@%s", formatSize(e.size), e.files, e.path, e.version)
+		if listOwners {
+			fmt.Fprintf(out, "  %s", formatOwners(e.owners))
+		}
+		fmt.Fprintln(out)
+		totalSize += e.size
+		totalFiles += e.files
+	}
+	fmt.Fprintf(out, "\nTotal: %d modules, %s, %d files\n", len(entries), formatSize(totalSize), totalFiles)
+
+	return nil
+}
+
+// sortListEntries orders entries by the given key. Size and file count sort
+// largest first; ties fall back to module path.
+func sortListEntries(entries []listEntry, key string) error {
+	var less func(a, b listEntry) bool
+	switch key {
+	case "name":
+		less = func(a, b listEntry) bool { return false }
+	case "size":
+		less = func(a, b listEntry) bool { return a.size > b.size }
+	case "files":
+		less = func(a, b listEntry) bool { return a.files > b.files }
+	default:
+		return fmt.Errorf("invalid sort key %q (want name, size, or files)", key)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if less(entries[i], entries[j]) {
+			return true
+		}
+		if less(entries[j], entries[i]) {
+			return false
+		}
+		return entries[i].path < entries[j].path
+	})
+	return nil
+}
+
+// formatSize renders a byte count in human-readable binary units.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/anthr76/nopher/internal/fetch"
+	"github.com/anthr76/nopher/internal/hash"
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var (
+	narinfoOut      string
+	narinfoSource   string
+	narinfoStoreDir string
+	narinfoVerbose  bool
+)
+
+var narinfoCmd = &cobra.Command{
+	Use:   "narinfo [directory]",
+	Short: "Export binary cache metadata for locked modules",
+	Long: `Write a .narinfo file and matching uncompressed NAR for every module
+fetched as a fixed-output download, in the layout of a Nix binary cache.
+
+Store paths are computed from the locked hashes (as recursive fetchzip
+outputs for GitHub archives without a full rev), and each download is
+verified before its NAR is written, so a cache can be pre-populated from the
+lockfile without running Nix. Modules fetched with builtins.fetchGit are
+skipped. The output is unsigned; sign it with nix store sign before use.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runNarinfo,
+}
+
+func init() {
+	rootCmd.AddCommand(narinfoCmd)
+	narinfoCmd.Flags().StringVar(&narinfoOut, "out", "", "binary cache directory to write to (required)")
+	narinfoCmd.Flags().StringVar(&narinfoSource, "source", "", "read module zips from this GOPROXY-layout directory instead of downloading")
+	narinfoCmd.Flags().StringVar(&narinfoStoreDir, "store-dir", hash.DefaultStoreDir, "Nix store directory")
+	narinfoCmd.Flags().BoolVarP(&narinfoVerbose, "verbose", "v", false, "verbose output")
+	narinfoCmd.MarkFlagRequired("out")
+}
+
+func runNarinfo(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	lf, err := lockfile.Load(lockfile.Path(dir, lockProfile))
+	if err != nil {
+		return fmt.Errorf("loading lockfile: %w", err)
+	}
+
+	modules, err := storeModules(lf)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Join(narinfoOut, "nar"), 0o755); err != nil {
+		return fmt.Errorf("creating output directory: %w", err)
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return err
+	}
+	fetcher, err := newFetcher(cfg)
+	if err != nil {
+		return err
+	}
+	defer fetcher.Close()
+	fetcher.Verbose = narinfoVerbose
+
+	written, skipped := 0, 0
+	for _, m := range modules {
+		if m.fetchGit {
+			skipped++
+			continue
+		}
+		storePath, err := exportNarinfo(fetcher, m)
+		if err != nil {
+			return err
+		}
+		if narinfoVerbose {
+			fmt.Fprintf(os.Stderr, "%s -> %s\n", m.name, storePath)
+		}
+		written++
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Wrote %d narinfo files to %s", written, narinfoOut)
+	if skipped > 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), " (%d fetchGit modules skipped)", skipped)
+	}
+	fmt.Fprintln(cmd.OutOrStdout())
+	return nil
+}
+
+// exportNarinfo writes the NAR and .narinfo for one fixed-output module and
+// returns its store path.
+func exportNarinfo(fetcher *fetch.Fetcher, m storeModule) (string, error) {
+	zipPath, cleanup, err := fetcher.VerifiedZip(m.locked, narinfoSource)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	flat, err := hex.DecodeString(m.hexHash)
+	if err != nil {
+		return "", err
+	}
+	storePath := hash.FixedOutputPath(narinfoStoreDir, m.fodName, flat)
+	if m.recursive {
+		storePath = hash.RecursiveOutputPath(narinfoStoreDir, m.fodName, flat)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(narinfoOut, "nar"), ".nar-*")
+	if err != nil {
+		return "", fmt.Errorf("creating NAR file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	cw := &countingWriter{w: io.MultiWriter(tmp, h)}
+	if m.recursive {
+		err = writeArchiveNAR(cw, zipPath)
+	} else {
+		err = hash.WriteNAR(cw, zipPath)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("writing NAR for %s: %w", m.name, err)
+	}
+
+	narHash := "sha256:" + hash.NixBase32(h.Sum(nil))
+	narFile := "nar/" + strings.TrimPrefix(narHash, "sha256:") + ".nar"
+	if err := os.Rename(tmp.Name(), filepath.Join(narinfoOut, narFile)); err != nil {
+		return "", fmt.Errorf("writing NAR for %s: %w", m.name, err)
+	}
+
+	info := narinfo(storePath, narFile, narHash, cw.n)
+	hashPart := strings.SplitN(filepath.Base(storePath), "-", 2)[0]
+	if err := os.WriteFile(filepath.Join(narinfoOut, hashPart+".narinfo"), []byte(info), 0o644); err != nil {
+		return "", fmt.Errorf("writing narinfo for %s: %w", m.name, err)
+	}
+	return storePath, nil
+}
+
+// writeArchiveNAR writes the NAR of the tree fetchzip unpacks from a
+// repository archive.
+func writeArchiveNAR(w io.Writer, archivePath string) error {
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	root, err := hash.ArchiveRoot(&r.Reader)
+	if err != nil {
+		return err
+	}
+	return hash.WriteZipNAR(w, &r.Reader, root)
+}
+
+// narinfo renders an uncompressed, unsigned narinfo. Fixed-output downloads
+// have no references.
+func narinfo(storePath, url, narHash string, narSize int64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "StorePath: %s\n", storePath)
+	fmt.Fprintf(&b, "URL: %s\n", url)
+	fmt.Fprintf(&b, "Compression: none\n")
+	fmt.Fprintf(&b, "FileHash: %s\n", narHash)
+	fmt.Fprintf(&b, "FileSize: %d\n", narSize)
+	fmt.Fprintf(&b, "NarHash: %s\n", narHash)
+	fmt.Fprintf(&b, "NarSize: %d\n", narSize)
+	fmt.Fprintf(&b, "References: \n")
+	return b.String()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/anthr76/nopher/internal/fetch"
+)
+
+// Network flags, shared by every command that downloads.
+var (
+	resolveHosts       []string
+	ipFamily           string
+	happyEyeballsDelay time.Duration
+	allProxy           string
+)
+
+// fetchLog is the path of the JSONL log of downloaded artifacts.
+var fetchLog string
+
+func init() {
+	rootCmd.PersistentFlags().StringSliceVar(&resolveHosts, "resolve", nil, "connect to `host:ip` instead of resolving host (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&ipFamily, "ip-family", "", "connect over IPv4 (4) or IPv6 (6) only; default is dual-stack")
+	rootCmd.PersistentFlags().StringVar(&allProxy, "all-proxy", "", "proxy `URL` (such as socks5://bastion:1080) for requests HTTPS_PROXY doesn't cover, also used by go and git; defaults to $ALL_PROXY")
+	rootCmd.PersistentFlags().StringVar(&fetchLog, "fetch-log", "", "append a JSON line for every downloaded artifact (URL, time, size, SHA-256, TLS peer) to this `path`")
+	rootCmd.PersistentFlags().DurationVar(&happyEyeballsDelay, "happy-eyeballs-delay", 0, "how long a dual-stack dial waits on the preferred address family before racing the other; 0 is Go's default (300ms), negative disables")
+}
+
+// networkOptions returns the fetcher network settings from the global flags.
+func networkOptions() (fetch.Network, error) {
+	resolve, err := fetch.ParseResolve(resolveHosts)
+	if err != nil {
+		return fetch.Network{}, err
+	}
+	family, err := fetch.ParseIPFamily(ipFamily)
+	if err != nil {
+		return fetch.Network{}, err
+	}
+	proxy := allProxy
+	if proxy == "" {
+		proxy = fetch.AllProxyFromEnv()
+	}
+	if _, err := fetch.ParseAllProxy(proxy); err != nil {
+		return fetch.Network{}, err
+	}
+	return fetch.Network{Resolve: resolve, IPFamily: family, FallbackDelay: happyEyeballsDelay, AllProxy: proxy}, nil
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/anthr76/nopher/internal/fetch"
+	"github.com/anthr76/nopher/internal/hash"
+	"github.com/anthr76/nopher/pkg/lockfile"
+)
+
+// storeModule is a locked module as fetched by buildNopherGoApp.
+type storeModule struct {
+	name      string // module path, or original path for replacements
+	fetchGit  bool   // fetched with builtins.fetchGit; no fixed-output path
+	recursive bool   // fetched with fetchzip; the hash covers the unpacked tree
+	fodName   string // store name of the fixed-output download
+	hexHash   string // flat sha256 of the download, or of its NAR when recursive, base16
+	locked    fetch.Locked
+}
+
+// storeModules lists the modules in lf the way fetchGoModule fetches them.
+// Modules with a GitHub archive URL use builtins.fetchGit when they have a
+// full rev, and otherwise, when locked by NAR hash, a recursive fixed-output
+// fetchzip named "source"; everything else is a fixed-output fetchurl of the
+// download, whose store name is the URL's base name.
+func storeModules(lf *lockfile.Lockfile) ([]storeModule, error) {
+	add := func(out []storeModule, name string, locked fetch.Locked, rev string) ([]storeModule, error) {
+		fetcher := lockfile.FetcherFor(locked.URL, rev, locked.HashType)
+		if fetcher == lockfile.FetchGit {
+			return append(out, storeModule{name: name, fetchGit: true}), nil
+		}
+		algo, sum, err := hash.ParseSRI(locked.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if algo != "sha256" {
+			return nil, fmt.Errorf("%s: unsupported hash algorithm %q", name, algo)
+		}
+		fodName := locked.Version + ".zip"
+		if fetcher == lockfile.FetchZip {
+			fodName = "source"
+		} else if locked.URL != "" {
+			fodName = path.Base(locked.URL)
+		}
+		return append(out, storeModule{name: name, recursive: fetcher == lockfile.FetchZip, fodName: fodName, hexHash: hex.EncodeToString(sum), locked: locked}), nil
+	}
+
+	var out []storeModule
+	var err error
+	for p, m := range lf.Modules {
+		if r, replaced := lf.Replace[p]; replaced && r.Applies(m.Version) {
+			continue
+		}
+		locked := fetch.Locked{Path: p, Version: m.Version, Hash: m.Hash, HashType: m.HashType, URL: m.URL, URLs: m.URLs, Subdir: m.Subdir}
+		if out, err = add(out, p, locked, m.Rev); err != nil {
+			return nil, err
+		}
+	}
+	for p, r := range lf.Replace {
+		if r.Path != "" || r.New == "" {
+			continue
+		}
+		locked := fetch.Locked{Path: r.New, Version: r.Version, Hash: r.Hash, HashType: r.HashType, URL: r.URL, URLs: r.URLs, Subdir: r.Subdir}
+		if out, err = add(out, p, locked, r.Rev); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out, nil
+}
+
+// checkNixStore asks Nix which locked modules' fixed-output paths are already
+// present and intact in the store, and reports the ones the next build will
+// need to download. Present paths are checked with nix-store --verify-path;
+// corrupted paths are an error.
+func checkNixStore(w io.Writer, lf *lockfile.Lockfile) error {
+	if _, err := exec.LookPath("nix-store"); err != nil {
+		return fmt.Errorf("--nix-store requires nix-store in PATH: %w", err)
+	}
+
+	modules, err := storeModules(lf)
+	if err != nil {
+		return err
+	}
+
+	var missing, corrupt, skipped []string
+	present := 0
+	for _, m := range modules {
+		if m.fetchGit {
+			skipped = append(skipped, m.name)
+			continue
+		}
+
+		fixedPath := []string{"--print-fixed-path", "sha256", m.hexHash, m.fodName}
+		if m.recursive {
+			fixedPath = []string{"--print-fixed-path", "--recursive", "sha256", m.hexHash, m.fodName}
+		}
+		out, err := exec.Command("nix-store", fixedPath...).Output()
+		if err != nil {
+			return fmt.Errorf("computing store path for %s: %w", m.name, err)
+		}
+		storePath := strings.TrimSpace(string(out))
+
+		invalid, err := exec.Command("nix-store", "--check-validity", "--print-invalid", storePath).Output()
+		if err != nil {
+			return fmt.Errorf("checking %s: %w", storePath, err)
+		}
+		if len(bytes.TrimSpace(invalid)) > 0 {
+			missing = append(missing, fmt.Sprintf("%s (%s)", m.name, storePath))
+			continue
+		}
+
+		if err := exec.Command("nix-store", "--verify-path", storePath).Run(); err != nil {
+			corrupt = append(corrupt, fmt.Sprintf("%s (%s)", m.name, storePath))
+			continue
+		}
+		present++
+	}
+
+	fmt.Fprintf(w, "%d of %d fixed-output modules present in the Nix store\n", present, len(modules)-len(skipped))
+	if len(missing) > 0 {
+		fmt.Fprintf(w, "\nWill be downloaded on the next build:\n")
+		for _, m := range missing {
+			fmt.Fprintf(w, "  + %s\n", m)
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(w, "\nFetched with builtins.fetchGit (not checked):\n")
+		for _, m := range skipped {
+			fmt.Fprintf(w, "  ? %s\n", m)
+		}
+	}
+	if len(corrupt) > 0 {
+		fmt.Fprintf(w, "\nStore paths failing verification:\n")
+		for _, m := range corrupt {
+			fmt.Fprintf(w, "  ! %s\n", m)
+		}
+		return fmt.Errorf("%d store paths failed verification", len(corrupt))
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/anthr76/nopher/internal/mod"
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/module"
+)
+
+var (
+	pinRev     string
+	pinVerbose bool
+)
+
+// commitHash matches a full or abbreviated git commit hash.
+var commitHash = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+var pinCmd = &cobra.Command{
+	Use:   "pin <module-path> [directory]",
+	Short: "Pin a module to a specific commit",
+	Long: `Pin a module to an arbitrary commit, ahead of any tagged release.
+
+The commit is resolved to its pseudo-version (or its tag, if the commit is
+tagged) through the module proxy, or go list for private modules. go.mod and
+go.sum are then updated with go get (requires go), and the lockfile is
+regenerated so that exact commit is fetched and hashed. If regeneration
+fails, go.mod and go.sum are restored.
+
+--rev also takes a pseudo-version, whose commit is pinned. Releases are
+rejected; use update <module-path>@<version> for those.`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeModulePath,
+	RunE:              runPin,
+}
+
+func init() {
+	rootCmd.AddCommand(pinCmd)
+	pinCmd.Flags().StringVar(&pinRev, "rev", "", "git commit hash or pseudo-version to pin to (required)")
+	pinCmd.Flags().BoolVarP(&pinVerbose, "verbose", "v", false, "verbose output")
+	pinCmd.MarkFlagRequired("rev")
+}
+
+func runPin(cmd *cobra.Command, args []string) error {
+	modulePath := args[0]
+	dir := "."
+	if len(args) > 1 {
+		dir = args[1]
+	}
+
+	rev, err := pinCommit(modulePath, pinRev)
+	if err != nil {
+		return err
+	}
+
+	modInfo, err := mod.ParseGoMod(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return fmt.Errorf("parsing go.mod: %w", err)
+	}
+	for _, rep := range modInfo.Replaces {
+		if rep.Old == modulePath {
+			return fmt.Errorf("module %s is replaced in go.mod; pin the replacement instead", modulePath)
+		}
+	}
+	var current string
+	for _, req := range modInfo.Requires {
+		if req.Path == modulePath {
+			current = req.Version
+			break
+		}
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	fetcher, err := newFetcher(cfg)
+	if err != nil {
+		return err
+	}
+	fetcher.Verbose = pinVerbose
+	info, err := fetcher.ResolveRev(modulePath, rev)
+	fetcher.Close()
+	if err != nil {
+		return err
+	}
+
+	rules, err := moduleRules(cfg.Policy)
+	if err != nil {
+		return err
+	}
+	if rules != nil {
+		if err := rules.Check(modulePath, info.Version); err != nil {
+			return fmt.Errorf("module policy: %w", err)
+		}
+	}
+
+	update := moduleUpdate{Path: modulePath, Current: current, Latest: info.Version}
+	if _, _, err := applyUpdates(dir, cfg, []moduleUpdate{update}, pinVerbose); err != nil {
+		return err
+	}
+
+	// go get raises the version instead if another requirement needs a newer one.
+	if modInfo, err := mod.ParseGoMod(filepath.Join(dir, "go.mod")); err == nil {
+		for _, req := range modInfo.Requires {
+			if req.Path == modulePath && req.Version != info.Version {
+				fmt.Fprintf(os.Stderr, "warning: %s resolved to %s, not %s, to satisfy other requirements\n", modulePath, req.Version, info.Version)
+			}
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Pinned %s@%s (commit %s)\n", modulePath, info.Version, rev)
+	if lf, err := lockfile.Load(lockfile.Path(dir, lockProfile)); err == nil {
+		if m, ok := lf.Modules[modulePath]; ok {
+			fmt.Fprintf(out, "  Hash: %s\n", trimHash(m.Hash))
+		}
+	}
+	return nil
+}
+
+// pinCommit returns the commit to pin modulePath to for a --rev of a commit
+// hash or a pseudo-version.
+func pinCommit(modulePath, rev string) (string, error) {
+	if commitHash.MatchString(rev) {
+		return rev, nil
+	}
+	// Every version has a dot; anything else was meant as a commit.
+	if !strings.Contains(rev, ".") {
+		return "", fmt.Errorf("--rev %q is not a git commit hash or pseudo-version", rev)
+	}
+	version, err := mod.CanonicalVersion(modulePath, rev)
+	if err != nil {
+		return "", fmt.Errorf("--rev: %w", err)
+	}
+	if !module.IsPseudoVersion(version) {
+		return "", fmt.Errorf("--rev %s is a release, not a commit; use nopher update %s@%s", version, modulePath, version)
+	}
+	commit, err := module.PseudoVersionRev(version)
+	if err != nil {
+		return "", fmt.Errorf("--rev: %w", err)
+	}
+	return commit, nil
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/anthr76/nopher/internal/policy"
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var policyMinAge string
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Enforce supply-chain policies on the lockfile",
+}
+
+var policyCheckCmd = &cobra.Command{
+	Use:   "check [directory]",
+	Short: "Check locked modules against the configured policy",
+	Long: `Check every locked module against the policy in .nopher.yaml.
+
+The minimum age rule rejects module versions published more recently than
+policy.minAge, using the module proxy's .info timestamps (or the commit time
+embedded in pseudo-versions). Modules matching policy.exceptions are exempt.
+
+The module rules reject locked modules matching policy.deny, or not matching
+policy.allow when it is set. generate and update enforce the same rules
+before fetching anything.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runPolicyCheck,
+}
+
+func init() {
+	rootCmd.AddCommand(policyCmd)
+	policyCmd.AddCommand(policyCheckCmd)
+	policyCheckCmd.Flags().StringVar(&policyMinAge, "min-age", "", "minimum age overriding policy.minAge (e.g. 7d)")
+}
+
+func runPolicyCheck(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return err
+	}
+	if policyMinAge != "" {
+		cfg.Policy.MinAge = policyMinAge
+	}
+
+	rule, err := minAgeRule(cfg.Policy)
+	if err != nil {
+		return err
+	}
+	rules, err := moduleRules(cfg.Policy)
+	if err != nil {
+		return err
+	}
+	if rule == nil && rules == nil {
+		fmt.Fprintln(cmd.OutOrStdout(), "No policy configured")
+		return nil
+	}
+
+	lf, err := lockfile.Load(lockfile.Path(dir, lockProfile))
+	if err != nil {
+		return fmt.Errorf("loading lockfile: %w", err)
+	}
+
+	if rules != nil {
+		if violations := rules.CheckLockfile(lf); len(violations) > 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "Modules blocked by the module rules:")
+			for _, v := range violations {
+				fmt.Fprintf(cmd.OutOrStdout(), "  %v\n", v)
+			}
+			return fmt.Errorf("%d module(s) violate the module rules", len(violations))
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "All modules pass the module rules")
+	}
+	if rule == nil {
+		return nil
+	}
+
+	if err := checkMinAge(cmd.OutOrStdout(), cfg, lf, nil, *rule); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "All modules are at least %s old\n", cfg.Policy.MinAge)
+	return nil
+}
+
+// moduleRules returns the allow/deny rules configured in p, or nil if there
+// are none.
+func moduleRules(p config.Policy) (*policy.ModuleRules, error) {
+	rules := policy.ModuleRules{Allow: p.Allow, Deny: p.Deny}
+	if rules.Empty() {
+		return nil, nil
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, fmt.Errorf("policy: %w", err)
+	}
+	return &rules, nil
+}
+
+// minAgeRule returns the minimum age rule configured in p, or nil if the
+// rule is disabled.
+func minAgeRule(p config.Policy) (*policy.MinAge, error) {
+	if p.MinAge == "" {
+		return nil, nil
+	}
+	age, err := policy.ParseAge(p.MinAge)
+	if err != nil {
+		return nil, err
+	}
+	return &policy.MinAge{Age: age, Exceptions: p.Exceptions}, nil
+}
+
+// checkMinAge enforces rule on lf (only modules new relative to prev, when
+// prev is non-nil), listing violations on w.
+func checkMinAge(w io.Writer, cfg *config.Config, lf, prev *lockfile.Lockfile, rule policy.MinAge) error {
+	fetcher, err := newFetcher(cfg)
+	if err != nil {
+		return err
+	}
+	defer fetcher.Close()
+
+	violations, err := policy.CheckMinAge(lf, prev, rule, fetcher.PublishTime)
+	if err != nil {
+		return fmt.Errorf("checking minimum age: %w", err)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w, "Modules younger than the minimum age:")
+	for _, v := range violations {
+		fmt.Fprintf(w, "  %s\n", v)
+	}
+	return fmt.Errorf("%d module(s) violate the minimum age policy", len(violations))
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+var (
+	cpuProfile string
+	memProfile string
+	traceOut   string
+
+	cpuProfileFile *os.File
+	traceFile      *os.File
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&cpuProfile, "cpuprofile", "", "write CPU profile to file")
+	rootCmd.PersistentFlags().StringVar(&memProfile, "memprofile", "", "write heap profile to file on exit")
+	rootCmd.PersistentFlags().StringVar(&traceOut, "trace", "", "write execution trace to file")
+	_ = rootCmd.PersistentFlags().MarkHidden("cpuprofile")
+	_ = rootCmd.PersistentFlags().MarkHidden("memprofile")
+	_ = rootCmd.PersistentFlags().MarkHidden("trace")
+}
+
+// startProfiling starts CPU profiling and execution tracing if requested.
+func startProfiling() error {
+	if cpuProfile != "" {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			return fmt.Errorf("creating CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return fmt.Errorf("starting CPU profile: %w", err)
+		}
+		cpuProfileFile = f
+	}
+
+	if traceOut != "" {
+		f, err := os.Create(traceOut)
+		if err != nil {
+			return fmt.Errorf("creating trace file: %w", err)
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			return fmt.Errorf("starting trace: %w", err)
+		}
+		traceFile = f
+	}
+
+	return nil
+}
+
+// stopProfiling flushes any active profiles and writes the heap profile.
+func stopProfiling() error {
+	if cpuProfileFile != nil {
+		pprof.StopCPUProfile()
+		cpuProfileFile.Close()
+		cpuProfileFile = nil
+	}
+
+	if traceFile != nil {
+		trace.Stop()
+		traceFile.Close()
+		traceFile = nil
+	}
+
+	if memProfile != "" {
+		f, err := os.Create(memProfile)
+		if err != nil {
+			return fmt.Errorf("creating heap profile: %w", err)
+		}
+		defer f.Close()
+
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			return fmt.Errorf("writing heap profile: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/anthr76/nopher/pkg/generator"
+)
+
+// progressLine is one line of --progress-json output.
+type progressLine struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	Module   string    `json:"module"`
+	Version  string    `json:"version"`
+	Total    int       `json:"total"`
+	URL      string    `json:"url,omitempty"`
+	Size     int64     `json:"size,omitempty"`
+	Hash     string    `json:"hash,omitempty"`
+	CacheHit bool      `json:"cacheHit,omitempty"`
+	Retries  int       `json:"retries,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// jsonProgress returns a progress callback writing each event to w as a
+// line of JSON. It is safe for concurrent use.
+func jsonProgress(w io.Writer) func(generator.Event) {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(e generator.Event) {
+		line := progressLine{
+			Time:     time.Now().UTC(),
+			Event:    string(e.Kind),
+			Module:   e.Path,
+			Version:  e.Version,
+			Total:    e.Total,
+			URL:      e.URL,
+			Size:     e.Size,
+			Hash:     e.Hash,
+			CacheHit: e.CacheHit,
+			Retries:  e.Retries,
+		}
+		if e.Err != nil {
+			line.Error = e.Err.Error()
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(line)
+	}
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/anthr76/nopher/pkg/lockfile"
+)
+
+// projectDir returns the directory given as args[i], or, when it is absent,
+// the nearest directory at or above the working directory containing go.mod
+// or the lockfile, like git finds its repository from a subdirectory. If
+// there is none, it returns ".".
+func projectDir(args []string, i int) string {
+	if len(args) > i {
+		return args[i]
+	}
+	return findProjectDir(".")
+}
+
+// findProjectDir walks up from start to the first directory containing
+// go.mod or the lockfile. start itself is returned unchanged when it
+// qualifies, or when no directory does.
+func findProjectDir(start string) string {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return start
+	}
+	for first := true; ; first = false {
+		if fileExists(filepath.Join(dir, "go.mod")) || fileExists(lockfile.Path(dir, lockProfile)) {
+			if first {
+				return start
+			}
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return start
+		}
+		dir = parent
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
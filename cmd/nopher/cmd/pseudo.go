@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var pseudoSubdir string
+
+var pseudoCmd = &cobra.Command{
+	Use:   "pseudo-version <repo-url> <rev>",
+	Short: "Compute the pseudo-version of a commit",
+	Long: `Print the canonical version of a commit, branch, or tag in a git repository,
+for hand-pinning commits in go.mod before locking (requires git).
+
+The pseudo-version is computed the way the go command computes it: from
+the highest semver tag for the module's major version (read from go.mod at
+the commit) that is an ancestor of the commit, the commit time, and the
+12-character commit hash. A commit with a semver tag of its own is
+printed as that version. Only commits are fetched, and no module proxy is
+involved, so this works for unpublished commits and forks.
+
+A repository URL without a scheme, such as github.com/owner/repo, is
+fetched over HTTPS. For a module in a subdirectory of the repository, pass
+--subdir; its tags are then prefixed with the subdirectory, as in
+sub/v1.2.3.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runPseudo,
+}
+
+func init() {
+	rootCmd.AddCommand(pseudoCmd)
+	pseudoCmd.Flags().StringVar(&pseudoSubdir, "subdir", "", "directory of the module within the repository")
+}
+
+func runPseudo(cmd *cobra.Command, args []string) error {
+	repoURL, rev := args[0], args[1]
+	if !strings.Contains(repoURL, "://") && !strings.Contains(repoURL, "@") {
+		repoURL = "https://" + repoURL
+	}
+
+	cfg, err := config.Load(".")
+	if err != nil {
+		return err
+	}
+	fetcher, err := newFetcher(cfg)
+	if err != nil {
+		return err
+	}
+	defer fetcher.Close()
+
+	cv, err := fetcher.ResolveCommit(repoURL, rev, pseudoSubdir)
+	if err != nil {
+		return err
+	}
+	if cv.Tagged {
+		fmt.Fprintf(os.Stderr, "%s is tagged %s\n", rev, cv.Version)
+	}
+	if cv.Module == "" {
+		fmt.Fprintf(os.Stderr, "warning: no go.mod at %s; assuming major version v0 or v1\n", cv.Rev)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), cv.Version)
+	return nil
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var (
+	quarantineReason  string
+	quarantineRelease bool
+)
+
+var quarantineCmd = &cobra.Command{
+	Use:   "quarantine <module-path> [directory]",
+	Short: "Mark a locked module as fetched but not yet approved",
+	Long: `Record a locked module in the lockfile's quarantine section, marking it
+as fetched but not yet approved for use. nopher verify fails while any
+module is quarantined, unless --allow-quarantined is given, so a new
+dependency can be locked and built on a branch while it waits for review.
+
+Quarantine entries survive regeneration for as long as the module stays
+locked, even across version changes. Once the module has been reviewed,
+release it with --release.`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeModulePath,
+	RunE:              runQuarantine,
+}
+
+func init() {
+	rootCmd.AddCommand(quarantineCmd)
+	quarantineCmd.Flags().StringVar(&quarantineReason, "reason", "", "why the module is quarantined")
+	quarantineCmd.Flags().BoolVar(&quarantineRelease, "release", false, "approve the module, removing it from quarantine")
+}
+
+func runQuarantine(cmd *cobra.Command, args []string) error {
+	modulePath := args[0]
+	dir := projectDir(args, 1)
+
+	unlock, err := lockfile.Lock(dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	lfPath := lockfile.Path(dir, lockProfile)
+	lf, err := lockfile.Load(lfPath)
+	if err != nil {
+		return fmt.Errorf("loading lockfile: %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+	if quarantineRelease {
+		if _, ok := lf.Quarantine[modulePath]; !ok {
+			return fmt.Errorf("module %s is not quarantined", modulePath)
+		}
+		delete(lf.Quarantine, modulePath)
+		if err := lf.SaveAs(lfPath); err != nil {
+			return fmt.Errorf("saving lockfile: %w", err)
+		}
+		fmt.Fprintf(out, "Released %s from quarantine\n", modulePath)
+		return nil
+	}
+
+	version, ok := lf.LockedVersion(modulePath)
+	if !ok {
+		return fmt.Errorf("module %s not found in lockfile", modulePath)
+	}
+	if lf.Quarantine == nil {
+		lf.Quarantine = make(map[string]lockfile.Quarantined)
+	}
+	lf.Quarantine[modulePath] = lockfile.Quarantined{Version: version, Reason: quarantineReason}
+	if err := lf.SaveAs(lfPath); err != nil {
+		return fmt.Errorf("saving lockfile: %w", err)
+	}
+	fmt.Fprintf(out, "Quarantined %s\n", quarantinedName(modulePath, version))
+	return nil
+}
+
+// quarantinedName describes a quarantined module for output.
+func quarantinedName(path, version string) string {
+	if version == "" {
+		return path
+	}
+	return path + "@" + version
+}
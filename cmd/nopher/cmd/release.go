@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/anthr76/nopher/internal/fetch"
+	"github.com/anthr76/nopher/internal/mod"
+	"github.com/anthr76/nopher/internal/release"
+	"github.com/spf13/cobra"
+)
+
+var (
+	releaseVersion string
+	releaseStrict  bool
+)
+
+var releaseCmd = &cobra.Command{
+	Use:   "release",
+	Short: "Check a module before tagging a release",
+}
+
+var releaseCheckCmd = &cobra.Command{
+	Use:   "check [directory]",
+	Short: "Report go.mod directives that would break downstream consumers",
+	Long: `Inspect the main module's go.mod for directives that behave differently for
+consumers, who lock the module with nopher or build it with go, than they
+do here, and warn before a release is tagged.
+
+Go ignores replace and exclude directives outside the main module, so
+consumers build against the required versions instead. Local path
+replacements are errors: the release was never built the way consumers
+will build it. Retractions are only read from the latest version, and
+should say why.
+
+With --version, the version about to be tagged is checked too: it must be
+a canonical semantic version matching the module path's major version,
+must not be retracted by this go.mod (other than by the documented
+self-retraction of a range ending in it), and must not already be
+published. Published versions are listed through the module proxy, or go
+list for private modules.
+
+Exits non-zero if any error is found, or, with --strict, any warning.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runReleaseCheck,
+}
+
+func init() {
+	rootCmd.AddCommand(releaseCmd)
+	releaseCmd.AddCommand(releaseCheckCmd)
+	releaseCheckCmd.Flags().StringVar(&releaseVersion, "version", "", "version about to be tagged, e.g. v1.4.0")
+	releaseCheckCmd.Flags().BoolVar(&releaseStrict, "strict", false, "fail on warnings too")
+}
+
+func runReleaseCheck(cmd *cobra.Command, args []string) error {
+	dir := projectDir(args, 0)
+
+	info, err := mod.ParseGoMod(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return err
+	}
+
+	var published []string
+	if releaseVersion != "" {
+		published, err = publishedVersions(dir, info.ModulePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: listing published versions of %s: %v\n", info.ModulePath, err)
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	var errs, warnings int
+	for _, f := range release.Check(info, releaseVersion, published) {
+		fmt.Fprintf(out, "%s: %s\n", f.Severity, f)
+		if f.Severity == release.Error {
+			errs++
+		} else {
+			warnings++
+		}
+	}
+
+	if errs == 0 && warnings == 0 {
+		fmt.Fprintf(out, "%s is ready to release\n", info.ModulePath)
+		return nil
+	}
+	if errs > 0 || releaseStrict {
+		return fmt.Errorf("release check failed: %d errors, %d warnings", errs, warnings)
+	}
+	return nil
+}
+
+// publishedVersions lists the published versions of modulePath, bypassing
+// the cached version list. A module never published has none.
+func publishedVersions(dir, modulePath string) ([]string, error) {
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	fetcher, err := newFetcher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer fetcher.Close()
+	fetcher.ListTTL = -1
+
+	versions, err := fetcher.Versions(modulePath)
+	if errors.Is(err, fetch.ErrNoVersions) {
+		return nil, nil
+	}
+	return versions, err
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/anthr76/nopher/internal/depsdev"
+	"github.com/anthr76/nopher/internal/fetch"
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/module"
+)
+
+var (
+	reportJSON    bool
+	reportVerbose bool
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report [directory]",
+	Short: "Summarize dependency health from deps.dev",
+	Long: `Query deps.dev for every locked module and summarize its OpenSSF Scorecard
+score, repository popularity, licenses, and known advisories.
+
+This helps reviewers assess new dependencies when a lockfile changes. It
+requires network access to api.deps.dev. Private modules, those matching
+GOPRIVATE, GONOPROXY, or the private patterns in .nopher.yaml, are never
+sent to deps.dev and are reported as unknown.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runReport,
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.Flags().BoolVar(&reportJSON, "json", false, "output the report as JSON")
+	reportCmd.Flags().BoolVarP(&reportVerbose, "verbose", "v", false, "verbose output")
+}
+
+// reportConcurrency bounds parallel deps.dev requests.
+const reportConcurrency = 8
+
+func runReport(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	lf, err := lockfile.Load(lockfile.Path(dir, lockProfile))
+	if err != nil {
+		return fmt.Errorf("loading lockfile: %w", err)
+	}
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	summaries := summarizeLocked(lf, privateModules(cfg), depsdev.New().Summarize)
+
+	out := cmd.OutOrStdout()
+	if reportJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(summaries)
+	}
+
+	printReport(out, summaries)
+	return nil
+}
+
+// privateModules returns a function reporting whether a module is private,
+// so nothing about it may be sent to public services: it matches GOPRIVATE,
+// GONOPROXY, or cfg's private patterns.
+func privateModules(cfg *config.Config) func(modulePath string) bool {
+	patterns := strings.Join(append([]string{os.Getenv("GOPRIVATE"), fetch.PrivateFromEnv()}, cfg.Private...), ",")
+	return func(modulePath string) bool {
+		return module.MatchPrefixPatterns(strings.ReplaceAll(patterns, " ", ""), modulePath)
+	}
+}
+
+// summarizeLocked looks up every module and remote replacement lf locks
+// with summarize, sorted by module path. Private modules, and those
+// summarize has no data for, get an empty summary.
+func summarizeLocked(lf *lockfile.Lockfile, private func(string) bool, summarize summarizeFunc) []*depsdev.Summary {
+	type target struct{ path, version string }
+	var targets []target
+	for path, m := range lf.Modules {
+		targets = append(targets, target{path, m.Version})
+	}
+	for _, r := range lf.Replace {
+		if r.Path == "" && r.New != "" {
+			targets = append(targets, target{r.New, r.Version})
+		}
+	}
+
+	summaries := make([]*depsdev.Summary, len(targets))
+	sem := make(chan struct{}, reportConcurrency)
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		if private(t.path) {
+			summaries[i] = &depsdev.Summary{Module: t.path, Version: t.version}
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			s, err := summarize(t.path, t.version)
+			if err != nil {
+				if reportVerbose && !errors.Is(err, depsdev.ErrNotFound) {
+					fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+				}
+				s = &depsdev.Summary{Module: t.path, Version: t.version}
+			}
+			summaries[i] = s
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Module < summaries[j].Module
+	})
+	return summaries
+}
+
+// printReport renders summaries as a table followed by an advisory summary.
+func printReport(w io.Writer, summaries []*depsdev.Summary) {
+	fmt.Fprintf(w, "%-9s %7s  %-12s %s\n", "SCORECARD", "STARS", "LICENSE", "MODULE")
+
+	var flagged []*depsdev.Summary
+	for _, s := range summaries {
+		score := "-"
+		if s.Scorecard != nil {
+			score = fmt.Sprintf("%.1f", *s.Scorecard)
+		}
+		stars := "-"
+		if s.Project != "" {
+			stars = fmt.Sprintf("%d", s.Stars)
+		}
+		license := "-"
+		if len(s.Licenses) > 0 {
+			license = strings.Join(s.Licenses, ",")
+		}
+		fmt.Fprintf(w, "%-9s %7s  %-12s %s@%s\n", score, stars, license, s.Module, s.Version)
+
+		if len(s.Advisories) > 0 {
+			flagged = append(flagged, s)
+		}
+	}
+
+	if len(flagged) == 0 {
+		fmt.Fprintf(w, "\nNo known advisories in %d modules\n", len(summaries))
+		return
+	}
+
+	fmt.Fprintf(w, "\nAdvisories (%d modules):\n", len(flagged))
+	for _, s := range flagged {
+		fmt.Fprintf(w, "  %s@%s: %s\n", s.Module, s.Version, strings.Join(s.Advisories, ", "))
+	}
+}
@@ -9,6 +9,12 @@ import (
 
 const Version = "0.1.0"
 
+// lockProfile selects an alternate lockfile (nopher.<profile>.lock.yaml).
+var lockProfile string
+
+// chdir is the directory to change to before running any command (-C).
+var chdir string
+
 var rootCmd = &cobra.Command{
 	Use:   "nopher",
 	Short: "Generate Nix-compatible lockfiles from Go modules",
@@ -16,15 +22,34 @@ var rootCmd = &cobra.Command{
 
 It parses go.mod and go.sum to create a nopher.lock.yaml file that can be
 used by Nix's buildNopherGoApp to build Go applications reproducibly.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyEnvFlags(cmd); err != nil {
+			return err
+		}
+		if chdir != "" {
+			if err := os.Chdir(chdir); err != nil {
+				return err
+			}
+		}
+		if err := applyConfigDefaults(cmd); err != nil {
+			return err
+		}
+		return startProfiling()
+	},
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	if perr := stopProfiling(); perr != nil {
+		fmt.Fprintln(os.Stderr, perr)
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
 func init() {
-	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.PersistentFlags().StringVarP(&chdir, "chdir", "C", "", "change to `dir` before doing anything else; directory arguments are relative to it")
+	rootCmd.PersistentFlags().StringVar(&lockProfile, "profile", "", "use the nopher.<profile>.lock.yaml lockfile instead of nopher.lock.yaml")
 }
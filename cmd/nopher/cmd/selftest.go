@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/anthr76/nopher/pkg/generator"
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selftestCold    bool
+	selftestDirect  bool
+	selftestVerbose bool
+)
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest [directory]",
+	Short: "Check that lockfile generation is reproducible here",
+	Long: `Generate the lockfile twice into temporary files and compare them, proving
+that generation is deterministic in the current environment before relying
+on it in CI. The project's lockfile is not modified.
+
+By default both runs share the usual module cache. With --cold, the first
+run starts from an empty cache and the second reuses it, so cached results
+must match freshly computed ones. With --direct, the first run fetches
+through GOPROXY and the second directly from each module's origin, each
+with its own empty cache; download URLs legitimately differ between the
+two, so only versions and hashes are compared.
+
+When the runs differ, the differing modules are listed and both lockfiles
+are kept for inspection.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runSelftest,
+}
+
+func init() {
+	rootCmd.AddCommand(selftestCmd)
+	selftestCmd.Flags().BoolVar(&selftestCold, "cold", false, "compare a cold-cache run against a warm-cache run")
+	selftestCmd.Flags().BoolVar(&selftestDirect, "direct", false, "compare a proxy run against a direct run")
+	selftestCmd.Flags().BoolVarP(&selftestVerbose, "verbose", "v", false, "verbose output")
+}
+
+func runSelftest(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return err
+	}
+	base, err := configOptions(cfg)
+	if err != nil {
+		return err
+	}
+	base.Verbose = selftestVerbose
+	base.Profile = lockProfile
+
+	tmp, err := os.MkdirTemp("", "nopher-selftest-")
+	if err != nil {
+		return err
+	}
+
+	runs := [2]generator.Options{base, base}
+	switch {
+	case selftestDirect:
+		runs[0].CacheDir = filepath.Join(tmp, "cache-proxy")
+		runs[1].CacheDir = filepath.Join(tmp, "cache-direct")
+		runs[1].Direct = true
+	case selftestCold:
+		runs[0].CacheDir = filepath.Join(tmp, "cache")
+		runs[1].CacheDir = runs[0].CacheDir
+	}
+
+	var lfs [2]*lockfile.Lockfile
+	var paths [2]string
+	for i, opts := range runs {
+		if opts.CacheDir != "" {
+			if err := os.MkdirAll(opts.CacheDir, 0o755); err != nil {
+				os.RemoveAll(tmp)
+				return err
+			}
+		}
+		lf, err := generator.Generate(dir, opts)
+		if err != nil {
+			os.RemoveAll(tmp)
+			return fmt.Errorf("run %d: %w", i+1, err)
+		}
+		paths[i] = filepath.Join(tmp, fmt.Sprintf("run%d.lock.yaml", i+1))
+		if err := lf.SaveYAML(paths[i]); err != nil {
+			os.RemoveAll(tmp)
+			return fmt.Errorf("run %d: saving lockfile: %w", i+1, err)
+		}
+		lfs[i] = lf
+	}
+
+	// The caches can be large; only the lockfiles are worth keeping. A
+	// --cache-dir shared by both runs is the user's and is kept.
+	for _, opts := range runs {
+		if opts.CacheDir != "" && opts.CacheDir != base.CacheDir {
+			os.RemoveAll(opts.CacheDir)
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	diffs := diffLockfiles(lfs[0], lfs[1], selftestDirect)
+	if len(diffs) == 0 && !selftestDirect {
+		a, aerr := os.ReadFile(paths[0])
+		b, berr := os.ReadFile(paths[1])
+		if aerr != nil || berr != nil || !bytes.Equal(a, b) {
+			diffs = append(diffs, "lockfiles are not byte-identical")
+		}
+	}
+	if len(diffs) > 0 {
+		fmt.Fprintln(out, "Lockfile generation is not reproducible:")
+		for _, d := range diffs {
+			fmt.Fprintf(out, "  %s\n", d)
+		}
+		fmt.Fprintf(out, "Lockfiles kept in %s\n", tmp)
+		return fmt.Errorf("%d difference(s) between runs", len(diffs))
+	}
+
+	os.RemoveAll(tmp)
+	fmt.Fprintf(out, "Lockfile generation is reproducible (%d modules, %d replacements)\n", len(lfs[0].Modules), len(lfs[0].Replace))
+	return nil
+}
+
+// diffLockfiles describes the modules and replacements that differ between
+// a and b, sorted. With hashesOnly, only versions and hashes are compared.
+func diffLockfiles(a, b *lockfile.Lockfile, hashesOnly bool) []string {
+	ea, eb := lockEntries(a, hashesOnly), lockEntries(b, hashesOnly)
+
+	keys := make(map[string]bool)
+	for k := range ea {
+		keys[k] = true
+	}
+	for k := range eb {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var diffs []string
+	for _, k := range sorted {
+		va, inA := ea[k]
+		vb, inB := eb[k]
+		switch {
+		case !inB:
+			diffs = append(diffs, fmt.Sprintf("%s: only in run 1", k))
+		case !inA:
+			diffs = append(diffs, fmt.Sprintf("%s: only in run 2", k))
+		case va != vb:
+			diffs = append(diffs, fmt.Sprintf("%s: %s (run 1) != %s (run 2)", k, va, vb))
+		}
+	}
+	if a.Go != b.Go {
+		diffs = append(diffs, fmt.Sprintf("go: %s (run 1) != %s (run 2)", a.Go, b.Go))
+	}
+	return diffs
+}
+
+// lockEntries flattens lf's modules and replacements into comparable
+// strings keyed by module path.
+func lockEntries(lf *lockfile.Lockfile, hashesOnly bool) map[string]string {
+	entries := make(map[string]string, len(lf.Modules)+len(lf.Replace))
+	for path, m := range lf.Modules {
+		m.Annotations = lockfile.Annotations{}
+		if hashesOnly {
+			entries[path] = m.Version + " " + m.Hash
+		} else {
+			entries[path] = fmt.Sprintf("%+v", m)
+		}
+	}
+	for old, r := range lf.Replace {
+		r.Annotations = lockfile.Annotations{}
+		if hashesOnly {
+			entries["replace "+old] = r.New + r.Path + "@" + r.Version + " " + r.Hash
+		} else {
+			entries["replace "+old] = fmt.Sprintf("%+v", r)
+		}
+	}
+	return entries
+}
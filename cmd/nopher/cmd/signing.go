@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/anthr76/nopher/internal/fetch"
+)
+
+// fetchSigners builds the request signers configured in .nopher.yaml.
+func fetchSigners(signing map[string]config.Signing) (map[string]fetch.Signer, error) {
+	if len(signing) == 0 {
+		return nil, nil
+	}
+	signers := make(map[string]fetch.Signer, len(signing))
+	for host, s := range signing {
+		switch {
+		case s.SigV4 != nil && s.IAP != nil:
+			return nil, fmt.Errorf("signing for %s: set only one of sigv4 and iap", host)
+		case s.SigV4 != nil:
+			if s.SigV4.Region == "" {
+				return nil, fmt.Errorf("signing for %s: sigv4 requires region", host)
+			}
+			signers[host] = &fetch.SigV4Signer{Region: s.SigV4.Region, Service: s.SigV4.Service, RoleARN: s.SigV4.RoleARN}
+		case s.IAP != nil:
+			if s.IAP.Audience == "" {
+				return nil, fmt.Errorf("signing for %s: iap requires audience", host)
+			}
+			signers[host] = &fetch.IAPSigner{Audience: s.IAP.Audience, ServiceAccount: s.IAP.ServiceAccount}
+		default:
+			return nil, fmt.Errorf("signing for %s: no method configured (sigv4 or iap)", host)
+		}
+	}
+	return signers, nil
+}
+
+// githubApp builds the GitHub App authenticator configured in .nopher.yaml.
+func githubApp(c *config.GitHubApp) (*fetch.GitHubApp, error) {
+	if c == nil {
+		return nil, nil
+	}
+	if c.AppID == 0 || c.PrivateKeyFile == "" {
+		return nil, errors.New("githubApp requires appId and privateKeyFile")
+	}
+	data, err := os.ReadFile(c.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("githubApp: %w", err)
+	}
+	key, err := fetch.ParseGitHubAppKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("githubApp: %s: %w", c.PrivateKeyFile, err)
+	}
+	return &fetch.GitHubApp{AppID: c.AppID, InstallationID: c.InstallationID, Owner: c.Owner, PrivateKey: key}, nil
+}
+
+// newFetcher creates a fetcher with the URL overrides, request signers,
+// GitHub App, proxy mirrors, and symlink policy from cfg and the network,
+// fetch log, and cache flags applied.
+func newFetcher(cfg *config.Config) (*fetch.Fetcher, error) {
+	signers, err := fetchSigners(cfg.Signing)
+	if err != nil {
+		return nil, err
+	}
+	network, err := networkOptions()
+	if err != nil {
+		return nil, err
+	}
+	symlinks, err := fetch.ParseSymlinkPolicy(cfg.Symlinks)
+	if err != nil {
+		return nil, err
+	}
+	app, err := githubApp(cfg.GitHubApp)
+	if err != nil {
+		return nil, err
+	}
+	perm, key, err := cacheOptions()
+	if err != nil {
+		return nil, err
+	}
+	fetcher, err := fetch.NewFetcher()
+	if err != nil {
+		return nil, fmt.Errorf("creating fetcher: %w", err)
+	}
+	fetcher.Symlinks = symlinks
+	fetcher.URLOverrides = cfg.URLOverrides
+	fetcher.Signers = signers
+	fetcher.Mirrors = cfg.Mirrors
+	fetcher.Network = network
+	fetcher.FetchLog = fetchLog
+	fetcher.GitHubApp = app
+	fetcher.GitLabHosts = cfg.GitLab
+	fetcher.CachePerm = perm
+	fetcher.CacheKey = key
+	fetcher.ReadOnlyCache = cacheReadOnly
+	if cacheDir != "" {
+		fetcher.CacheDir = cacheDir
+	}
+	return fetcher, nil
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/anthr76/nopher/internal/mod"
+)
+
+// changedSince returns the module paths whose go.mod requirement or
+// replacement, or go.sum lines, differ between git ref and the working tree
+// in dir. all is true when go.mod did not exist at ref, so every module must
+// be checked.
+func changedSince(dir, ref string) (changed map[string]bool, all bool, err error) {
+	if err := checkRef(dir, ref); err != nil {
+		return nil, false, err
+	}
+
+	oldMod, ok, err := gitShow(dir, ref, "go.mod")
+	if err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		return nil, true, nil
+	}
+	before, err := mod.ParseGoModData("go.mod@"+ref, oldMod)
+	if err != nil {
+		return nil, false, err
+	}
+	after, err := mod.ParseGoMod(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return nil, false, fmt.Errorf("parsing go.mod: %w", err)
+	}
+
+	changed = make(map[string]bool)
+	diffKeys := func(a, b map[string]string) {
+		for k, v := range a {
+			if b[k] != v {
+				changed[k] = true
+			}
+		}
+		for k := range b {
+			if _, ok := a[k]; !ok {
+				changed[k] = true
+			}
+		}
+	}
+	diffKeys(requireVersions(before), requireVersions(after))
+	diffKeys(replaceTargets(before), replaceTargets(after))
+
+	oldSum, _, err := gitShow(dir, ref, "go.sum")
+	if err != nil {
+		return nil, false, err
+	}
+	newSum, err := os.ReadFile(filepath.Join(dir, "go.sum"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, false, fmt.Errorf("reading go.sum: %w", err)
+	}
+	oldLines, newLines := sumLines(oldSum), sumLines(newSum)
+	for line := range oldLines {
+		if !newLines[line] {
+			changed[strings.Fields(line)[0]] = true
+		}
+	}
+	for line := range newLines {
+		if !oldLines[line] {
+			changed[strings.Fields(line)[0]] = true
+		}
+	}
+
+	// go.sum lines name replacement targets; check the replaced module too.
+	for _, rep := range after.Replaces {
+		if changed[rep.New] {
+			changed[rep.Old] = true
+		}
+	}
+	return changed, false, nil
+}
+
+func requireVersions(info *mod.ModInfo) map[string]string {
+	m := make(map[string]string, len(info.Requires))
+	for _, req := range info.Requires {
+		m[req.Path] = req.Version
+	}
+	return m
+}
+
+func replaceTargets(info *mod.ModInfo) map[string]string {
+	m := make(map[string]string, len(info.Replaces))
+	for _, rep := range info.Replaces {
+		m[rep.Old] = rep.OldVersion + " => " + rep.New + " " + rep.NewVersion
+	}
+	return m
+}
+
+// sumLines returns the set of well-formed go.sum lines in data.
+func sumLines(data []byte) map[string]bool {
+	lines := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 {
+			lines[strings.Join(fields, " ")] = true
+		}
+	}
+	return lines
+}
+
+// checkRef fails unless ref names a commit in the repository containing dir.
+func checkRef(dir, ref string) error {
+	if out, err := gitOutput(dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil || len(out) == 0 {
+		return fmt.Errorf("unknown git ref %q", ref)
+	}
+	return nil
+}
+
+// gitShow returns the contents of name (relative to dir) at ref. ok is false
+// when the file does not exist at ref.
+func gitShow(dir, ref, name string) (data []byte, ok bool, err error) {
+	out, err := gitOutput(dir, "show", ref+":./"+name)
+	if err != nil {
+		if exitErr, isExit := err.(*exec.ExitError); isExit {
+			stderr := string(exitErr.Stderr)
+			if strings.Contains(stderr, "does not exist") || strings.Contains(stderr, "exists on disk, but not in") {
+				return nil, false, nil
+			}
+			return nil, false, fmt.Errorf("git show %s:%s: %s", ref, name, strings.TrimSpace(stderr))
+		}
+		return nil, false, fmt.Errorf("git show %s:%s: %w", ref, name, err)
+	}
+	return out, true, nil
+}
+
+func gitOutput(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	return cmd.Output()
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/anthr76/nopher/internal/mod"
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var (
+	treeJSON  bool
+	treeDepth int
+)
+
+var treeCmd = &cobra.Command{
+	Use:   "tree [directory]",
+	Short: "Show the locked dependency tree",
+	Long: `Show the dependency tree resolved to the versions in the lockfile.
+
+Edges come from the lockfile's graph section when present (see generate --graph),
+otherwise from go mod graph. Each module is annotated with its locked hash and
+size. Modules already shown elsewhere in the tree are marked with (*) and not
+expanded again.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runTree,
+}
+
+func init() {
+	rootCmd.AddCommand(treeCmd)
+	treeCmd.Flags().BoolVar(&treeJSON, "json", false, "output the tree as JSON")
+	treeCmd.Flags().IntVar(&treeDepth, "depth", 0, "maximum depth to display (0 for unlimited)")
+}
+
+// treeNode is a module in the rendered dependency tree.
+type treeNode struct {
+	Path     string      `json:"path"`
+	Version  string      `json:"version,omitempty"`
+	Hash     string      `json:"hash,omitempty"`
+	Size     int64       `json:"size,omitempty"`
+	Repeated bool        `json:"repeated,omitempty"`
+	Requires []*treeNode `json:"requires,omitempty"`
+
+	// graphVersion is the version go mod graph uses for this module, which
+	// differs from Version for replaced modules.
+	graphVersion string
+}
+
+func runTree(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	lf, err := lockfile.Load(lockfile.Path(dir, lockProfile))
+	if err != nil {
+		return fmt.Errorf("loading lockfile: %w", err)
+	}
+
+	modInfo, err := mod.ParseGoMod(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return fmt.Errorf("parsing go.mod: %w", err)
+	}
+
+	graph := lf.Graph
+	if graph == nil {
+		g, err := mod.ModGraph(dir)
+		if err != nil {
+			return fmt.Errorf("building module graph: %w", err)
+		}
+		graph = g
+	}
+
+	root := buildTree(modInfo.ModulePath, graph, lf, treeDepth)
+
+	out := cmd.OutOrStdout()
+	if treeJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(root)
+	}
+
+	printTree(out, root, "")
+	return nil
+}
+
+// buildTree walks graph from the main module. Each module's requirements are
+// taken from the graph entry for its locked version, and every edge is
+// resolved to the version recorded in lf.
+func buildTree(mainModule string, graph map[string][]string, lf *lockfile.Lockfile, maxDepth int) *treeNode {
+	expanded := make(map[string]bool)
+
+	requires := func(node *treeNode) []string {
+		key := node.Path
+		if node.Path != mainModule {
+			key += "@" + node.graphVersion
+		}
+		var deps []string
+		for _, dep := range graph[key] {
+			depPath, _, _ := strings.Cut(dep, "@")
+			if depPath != node.Path {
+				deps = append(deps, depPath)
+			}
+		}
+		sort.Strings(deps)
+		return dedupSorted(deps)
+	}
+
+	var walk func(path string, depth int) *treeNode
+	walk = func(path string, depth int) *treeNode {
+		node := lockedNode(path, lf)
+		deps := requires(node)
+		if expanded[path] {
+			node.Repeated = len(deps) > 0
+			return node
+		}
+		expanded[path] = true
+		if maxDepth > 0 && depth >= maxDepth {
+			return node
+		}
+		for _, dep := range deps {
+			node.Requires = append(node.Requires, walk(dep, depth+1))
+		}
+		return node
+	}
+
+	return walk(mainModule, 0)
+}
+
+// lockedNode returns a tree node annotated with lockfile data for path.
+func lockedNode(path string, lf *lockfile.Lockfile) *treeNode {
+	node := &treeNode{Path: path}
+	if m, ok := lf.Modules[path]; ok {
+		node.Version = m.Version
+		node.Hash = m.Hash
+		node.Size = m.Size
+		node.graphVersion = m.Version
+	} else if r, ok := lf.Replace[path]; ok && r.Path == "" {
+		node.Version = r.Version
+		node.Hash = r.Hash
+		node.Size = r.Size
+		node.graphVersion = r.OldVersion
+	}
+	return node
+}
+
+func printTree(w io.Writer, node *treeNode, indent string) {
+	line := node.Path
+	if node.Version != "" {
+		line += "@" + node.Version
+	}
+	if node.Hash != "" {
+		line += " " + trimHash(node.Hash)
+	}
+	if node.Size > 0 {
+		line += " (" + formatSize(node.Size) + ")"
+	}
+	if node.Repeated {
+		line += " (*)"
+	}
+	fmt.Fprintln(w, indent+line)
+
+	for _, child := range node.Requires {
+		printTree(w, child, indent+"  ")
+	}
+}
+
+func dedupSorted(s []string) []string {
+	out := s[:0]
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthr76/nopher/pkg/generator"
+)
+
+// dashboardRecent is how many finished modules the dashboard lists.
+const dashboardRecent = 10
+
+// dashboardFailures is how many failures the dashboard lists.
+const dashboardFailures = 5
+
+// isTerminal reports whether f is an interactive terminal that can show
+// the dashboard.
+func isTerminal(f *os.File) bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// dashboard is the full-screen view of a running generation shown by
+// generate --tui. It is drawn on the terminal's alternate screen, so the
+// terminal is left as it was once the dashboard stops.
+type dashboard struct {
+	w     io.Writer
+	title string
+	width int
+	start time.Time
+
+	mu        sync.Mutex
+	total     int
+	active    map[string]*activeFetch // by path@version
+	hashed    int
+	cacheHits int
+	retries   int
+	bytes     int64
+	recent    []string // newest last
+	failures  []string
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// activeFetch is a module being fetched.
+type activeFetch struct {
+	start time.Time
+	size  int64 // Downloaded bytes, once downloaded
+}
+
+func newDashboard(w io.Writer, title string) *dashboard {
+	width := 80
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 20 {
+		width = n
+	}
+	return &dashboard{
+		w:       w,
+		title:   title,
+		width:   width,
+		start:   time.Now(),
+		active:  make(map[string]*activeFetch),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+// run switches to the alternate screen and redraws the dashboard until
+// stop is called.
+func (d *dashboard) run() {
+	fmt.Fprint(d.w, "\x1b[?1049h\x1b[?25l")
+	go func() {
+		defer close(d.stopped)
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			d.draw()
+			select {
+			case <-d.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// stop stops redrawing and restores the terminal's screen and cursor.
+func (d *dashboard) stop() {
+	close(d.done)
+	<-d.stopped
+	fmt.Fprint(d.w, "\x1b[?25h\x1b[?1049l")
+}
+
+// event records a generator progress event. It is safe for concurrent use.
+func (d *dashboard) event(e generator.Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := e.Path + "@" + e.Version
+	d.total = e.Total
+	switch e.Kind {
+	case generator.EventStarted:
+		d.active[key] = &activeFetch{start: time.Now()}
+	case generator.EventDownloaded:
+		d.bytes += e.Size
+		if a := d.active[key]; a != nil {
+			a.size = e.Size
+		}
+	case generator.EventHashed:
+		delete(d.active, key)
+		d.hashed++
+		d.retries += e.Retries
+		line := "✓ " + key
+		if e.CacheHit {
+			d.cacheHits++
+			line += "  cached"
+		}
+		if e.Retries > 0 {
+			line += fmt.Sprintf("  %d retries", e.Retries)
+		}
+		d.recent = append(d.recent, line)
+		if len(d.recent) > dashboardRecent {
+			d.recent = d.recent[1:]
+		}
+	case generator.EventFailed:
+		delete(d.active, key)
+		msg := strings.ReplaceAll(e.Err.Error(), "\n", " ")
+		d.failures = append(d.failures, fmt.Sprintf("✗ %s: %s", key, msg))
+	}
+}
+
+// draw redraws the whole screen.
+func (d *dashboard) draw() {
+	frame := d.frame(time.Now())
+	fmt.Fprint(d.w, "\x1b[H\x1b[2J"+frame)
+}
+
+// frame renders the dashboard as of now.
+func (d *dashboard) frame(now time.Time) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var b bytes.Buffer
+	line := func(format string, args ...any) {
+		s := fmt.Sprintf(format, args...)
+		if r := []rune(s); len(r) > d.width {
+			s = string(r[:d.width-1]) + "…"
+		}
+		b.WriteString(s + "\n")
+	}
+
+	line("%s  %s", d.title, now.Sub(d.start).Round(time.Second))
+	line("")
+
+	finished := d.hashed + len(d.failures)
+	percent := 0
+	if d.total > 0 {
+		percent = finished * 100 / d.total
+	}
+	barWidth := max(10, min(40, d.width-30))
+	filled := barWidth * percent / 100
+	line("[%s%s] %d/%d modules  %d%%", strings.Repeat("█", filled), strings.Repeat("░", barWidth-filled), finished, d.total, percent)
+	line("hashed %d  cache hits %d  retries %d  failed %d  downloaded %s", d.hashed, d.cacheHits, d.retries, len(d.failures), formatSize(d.bytes))
+	line("")
+
+	line("Fetching")
+	keys := make([]string, 0, len(d.active))
+	for k := range d.active {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) == 0 {
+		line("  -")
+	}
+	for _, k := range keys {
+		a := d.active[k]
+		status := "downloading"
+		if a.size > 0 {
+			status = "hashing " + formatSize(a.size)
+		}
+		line("  %s  %s  %s", k, status, now.Sub(a.start).Round(100*time.Millisecond))
+	}
+	line("")
+
+	line("Recent")
+	for i := len(d.recent) - 1; i >= 0; i-- {
+		line("  %s", d.recent[i])
+	}
+
+	if len(d.failures) > 0 {
+		line("")
+		line("Failures")
+		for _, f := range d.failures[:min(len(d.failures), dashboardFailures)] {
+			line("  %s", f)
+		}
+		if n := len(d.failures) - dashboardFailures; n > 0 {
+			line("  and %d more", n)
+		}
+	}
+	return b.String()
+}
@@ -4,40 +4,63 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/anthr76/nopher/internal/config"
 	"github.com/anthr76/nopher/internal/fetch"
 	"github.com/anthr76/nopher/internal/mod"
+	"github.com/anthr76/nopher/pkg/generator"
 	"github.com/anthr76/nopher/pkg/lockfile"
 	"github.com/spf13/cobra"
 )
 
-var updateVerbose bool
+var (
+	updateVerbose bool
+	updateStrict  bool
+)
 
 var updateCmd = &cobra.Command{
-	Use:   "update <module-path> [directory]",
+	Use:   "update <module-path>[@version] [directory]",
 	Short: "Update specific module in lockfile",
 	Long: `Update a specific module in the lockfile to match go.mod.
 
 This command re-fetches the module and updates its hash in the lockfile.
-Useful for refreshing a single dependency without regenerating the entire lockfile.`,
-	Args: cobra.RangeArgs(1, 2),
-	RunE: runUpdate,
+Useful for refreshing a single dependency without regenerating the entire lockfile.
+
+With @version, go.mod and go.sum are first moved to that version with go
+get (requires go) and the lockfile is regenerated, as upgrade does. The
+version is canonicalized (1.2.3 is read as v1.2.3), and incomplete
+versions, build metadata, and malformed pseudo-versions are rejected.`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeModulePath,
+	RunE:              runUpdate,
 }
 
 func init() {
 	rootCmd.AddCommand(updateCmd)
 	updateCmd.Flags().BoolVarP(&updateVerbose, "verbose", "v", false, "verbose output")
+	updateCmd.Flags().BoolVar(&updateStrict, "strict", false, "fail instead of guessing the download URL")
 }
 
 func runUpdate(cmd *cobra.Command, args []string) error {
-	modulePath := args[0]
-	dir := "."
-	if len(args) > 1 {
-		dir = args[1]
+	modulePath, version, err := parseModuleVersion(args[0])
+	if err != nil {
+		return err
+	}
+	dir := projectDir(args, 1)
+	if version != "" {
+		return updateTo(cmd, dir, modulePath, version)
 	}
 
+	unlock, err := lockfile.Lock(dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Load existing lockfile
-	lfPath := filepath.Join(dir, lockfile.DefaultLockfile)
+	lfPath := lockfile.Path(dir, lockProfile)
 	lf, err := lockfile.Load(lfPath)
 	if err != nil {
 		return fmt.Errorf("loading lockfile: %w", err)
@@ -79,28 +102,72 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	rules, err := moduleRules(cfg.Policy)
+	if err != nil {
+		return err
+	}
+	if rules != nil {
+		if err := rules.Check(modulePath, targetVersion); err != nil {
+			return fmt.Errorf("module policy: %w", err)
+		}
+	}
+
 	// Fetch the module
-	fetcher, err := fetch.NewFetcher()
+	fetcher, err := newFetcher(cfg)
 	if err != nil {
-		return fmt.Errorf("creating fetcher: %w", err)
+		return err
 	}
 	fetcher.Verbose = updateVerbose
+	fetcher.Strict = updateStrict
+	defer func() {
+		if err := fetcher.Close(); err != nil && updateVerbose {
+			fmt.Fprintf(os.Stderr, "warning: failed to export traces: %v\n", err)
+		}
+	}()
 
 	result, err := fetcher.Fetch(modulePath, targetVersion)
 	if err != nil {
 		return fmt.Errorf("fetching %s@%s: %w", modulePath, targetVersion, err)
 	}
+	if err := fetch.CheckModulePath(result.ModFile, modulePath); err != nil {
+		return fmt.Errorf("fetching %s@%s: %w", modulePath, targetVersion, err)
+	}
+	for _, command := range cfg.Hooks.PostFetch {
+		if err := generator.CommandHook(command)(modulePath, targetVersion, result.Dir); err != nil {
+			return fmt.Errorf("fetching %s@%s: %w", modulePath, targetVersion, err)
+		}
+	}
 
-	// Update lockfile
+	// Record the go.sum hash when available
+	var sum string
+	if entries, err := mod.ParseGoSum(filepath.Join(dir, "go.sum")); err == nil {
+		sum = mod.SumMap(entries)[modulePath+"@"+targetVersion]
+	}
+
+	// Update lockfile, keeping any review annotations
 	lf.Modules[modulePath] = lockfile.Module{
-		Version: targetVersion,
-		Hash:    result.Hash,
-		URL:     result.URL,
-		Rev:     result.Rev,
+		Version:     targetVersion,
+		Hash:        result.Hash,
+		HashType:    result.HashType,
+		URL:         result.URL,
+		URLs:        result.URLs,
+		Rev:         result.Rev,
+		Subdir:      result.Subdir,
+		Size:        result.Size,
+		Files:       result.Files,
+		Sum:         sum,
+		Annotations: current.Annotations,
+
+		GoModReplace: result.Replaces,
 	}
 
 	// Save
-	if err := lf.Save(dir); err != nil {
+	if err := lf.SaveAs(lfPath); err != nil {
 		return fmt.Errorf("saving lockfile: %w", err)
 	}
 
@@ -109,7 +176,71 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 	if updateVerbose && result.URL != "" {
 		fmt.Printf("  URL: %s\n", result.URL)
 	}
+	if !slices.Equal(result.Replaces, current.GoModReplace) {
+		printGoModReplaces(os.Stderr, modulePath+"@"+targetVersion, result.Replaces)
+	}
+
+	return nil
+}
 
+// parseModuleVersion splits a module path with an optional @version
+// suffix, canonicalizing the version.
+func parseModuleVersion(arg string) (modulePath, version string, err error) {
+	modulePath, version, ok := strings.Cut(arg, "@")
+	if !ok {
+		return modulePath, "", nil
+	}
+	version, err = mod.CanonicalVersion(modulePath, version)
+	return modulePath, version, err
+}
+
+// updateTo moves modulePath to version in go.mod and go.sum and regenerates
+// the lockfile.
+func updateTo(cmd *cobra.Command, dir, modulePath, version string) error {
+	modInfo, err := mod.ParseGoMod(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return fmt.Errorf("parsing go.mod: %w", err)
+	}
+	update := moduleUpdate{Path: modulePath, Latest: version}
+	for _, req := range modInfo.Requires {
+		if req.Path == modulePath {
+			update.Current = req.Version
+			break
+		}
+	}
+	for _, rep := range modInfo.Replaces {
+		if rep.Old == modulePath {
+			return fmt.Errorf("module %s is replaced in go.mod; update the replacement instead", modulePath)
+		}
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return err
+	}
+	rules, err := moduleRules(cfg.Policy)
+	if err != nil {
+		return err
+	}
+	if rules != nil {
+		if err := rules.Check(modulePath, version); err != nil {
+			return fmt.Errorf("module policy: %w", err)
+		}
+	}
+
+	_, next, err := applyUpdates(dir, cfg, []moduleUpdate{update}, updateVerbose)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Updated %s@%s\n", modulePath, version)
+	if m, ok := next.Modules[modulePath]; ok {
+		if m.Version != version {
+			fmt.Fprintf(os.Stderr, "warning: %s resolved to %s, not %s, to satisfy other requirements\n", modulePath, m.Version, version)
+		}
+		fmt.Fprintf(out, "  Hash: %s\n", trimHash(m.Hash))
+	}
 	return nil
 }
 
@@ -0,0 +1,436 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthr76/nopher/internal/config"
+	"github.com/anthr76/nopher/internal/depsdev"
+	"github.com/anthr76/nopher/internal/fetch"
+	"github.com/anthr76/nopher/internal/mod"
+	"github.com/anthr76/nopher/internal/policy"
+	"github.com/anthr76/nopher/pkg/generator"
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/semver"
+)
+
+var (
+	upgradeInteractive bool
+	upgradeIndirect    bool
+	upgradeTracked     bool
+	upgradeVerbose     bool
+	upgradeTo          []string
+	upgradeReportFmt   string
+)
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade [directory]",
+	Short: "Upgrade dependencies to their latest versions",
+	Long: `Upgrade required modules to the latest versions published by their proxy,
+then regenerate the lockfile.
+
+Only direct dependencies are considered unless --indirect is set; replaced
+modules and updates blocked by the module rules are skipped. Modules listed
+under track in .nopher.yaml follow a branch instead: they move to the
+pseudo-version of the branch's latest commit. --tracked limits the upgrade
+to those modules. --to module@version moves a module to that version
+instead of the latest; it can be repeated, and only the modules it names
+are upgraded. Versions are canonicalized (1.2.3 is read as v1.2.3), and
+invalid ones are rejected before anything is changed. With
+--interactive, the available updates are listed with their release age and
+only the selected ones are applied.
+
+go.mod and go.sum are updated with go get (requires go) and the lockfile is
+regenerated from them. If regeneration fails, go.mod and go.sum are restored
+so they never disagree with the lockfile. When the upgrade changes what a
+replace directive builds, for instance because a version-specific
+replacement no longer applies, the source and hash before and after are
+printed.
+
+With --report markdown, a summary ready to post as a pull request or issue
+body is printed instead: every changed lockfile entry with its size change
+and license, the advisories fixed or introduced, license changes, and what
+replacements build. Licenses and advisories come from deps.dev (requires
+network access); modules it has no data for are reported as unknown.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runUpgrade,
+}
+
+func init() {
+	rootCmd.AddCommand(upgradeCmd)
+	upgradeCmd.Flags().BoolVarP(&upgradeInteractive, "interactive", "i", false, "choose which updates to apply")
+	upgradeCmd.Flags().BoolVar(&upgradeIndirect, "indirect", false, "include indirect dependencies")
+	upgradeCmd.Flags().BoolVar(&upgradeTracked, "tracked", false, "only upgrade branch-tracked modules")
+	upgradeCmd.Flags().BoolVarP(&upgradeVerbose, "verbose", "v", false, "verbose output")
+	upgradeCmd.Flags().StringArrayVar(&upgradeTo, "to", nil, "upgrade `module@version` to that version instead of the latest (repeatable)")
+	upgradeCmd.Flags().StringVar(&upgradeReportFmt, "report", "", "print a summary of the upgrade in `format` (markdown)")
+	upgradeCmd.MarkFlagsMutuallyExclusive("to", "tracked")
+	upgradeCmd.MarkFlagsMutuallyExclusive("report", "interactive")
+}
+
+// moduleUpdate is an available upgrade for a required module.
+type moduleUpdate struct {
+	Path      string
+	Current   string
+	Latest    string
+	Published time.Time // zero if unknown
+	Branch    string    // tracked branch, if any
+}
+
+// upgradeScope selects which requirements findUpdates considers.
+type upgradeScope struct {
+	Indirect    bool
+	TrackedOnly bool
+	// Track maps module paths to the branch they follow.
+	Track map[string]string
+}
+
+// latestFunc returns the newest version of a module: the head of branch
+// when it is non-empty, otherwise the latest release.
+type latestFunc func(modulePath, branch string) (*fetch.ModuleInfo, error)
+
+func runUpgrade(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	if upgradeReportFmt != "" && upgradeReportFmt != "markdown" {
+		return fmt.Errorf("invalid report format %q (want markdown)", upgradeReportFmt)
+	}
+
+	modInfo, err := mod.ParseGoMod(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return fmt.Errorf("parsing go.mod: %w", err)
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return err
+	}
+	rules, err := moduleRules(cfg.Policy)
+	if err != nil {
+		return err
+	}
+
+	var updates []moduleUpdate
+	if len(upgradeTo) > 0 {
+		if updates, err = targetUpdates(modInfo, upgradeTo); err != nil {
+			return err
+		}
+		// Versions asked for by name fail the policy check rather than
+		// being skipped.
+		for _, u := range updates {
+			if rules != nil {
+				if err := rules.Check(u.Path, u.Latest); err != nil {
+					return fmt.Errorf("module policy: %w", err)
+				}
+			}
+		}
+	} else if updates, err = latestUpdates(cfg, modInfo, rules); err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if len(updates) == 0 {
+		fmt.Fprintln(out, "All modules are up to date")
+		return nil
+	}
+
+	if upgradeInteractive {
+		updates, err = selectUpdates(cmd.InOrStdin(), out, updates, time.Now())
+		if err != nil {
+			return err
+		}
+		if len(updates) == 0 {
+			fmt.Fprintln(out, "No updates selected")
+			return nil
+		}
+	}
+
+	prev, next, err := applyUpdates(dir, cfg, updates, upgradeVerbose)
+	if err != nil {
+		return err
+	}
+
+	if upgradeReportFmt != "" {
+		printUpgradeMarkdown(out, buildUpgradeReport(prev, next, privateModules(cfg), depsdev.New().Summarize))
+		return nil
+	}
+	for _, u := range updates {
+		fmt.Fprintf(out, "Upgraded %s %s -> %s\n", u.Path, u.Current, u.Latest)
+	}
+	printReplaceEffects(out, replaceEffects(prev, next))
+	return nil
+}
+
+// latestUpdates returns the available upgrades of the requirements in
+// modInfo to their latest versions, as selected by the upgrade flags.
+func latestUpdates(cfg *config.Config, modInfo *mod.ModInfo, rules *policy.ModuleRules) ([]moduleUpdate, error) {
+	fetcher, err := newFetcher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	fetcher.Verbose = upgradeVerbose
+	latest := func(modulePath, branch string) (*fetch.ModuleInfo, error) {
+		if branch != "" {
+			return fetcher.ResolveRev(modulePath, branch)
+		}
+		return fetcher.Latest(modulePath)
+	}
+	scope := upgradeScope{Indirect: upgradeIndirect, TrackedOnly: upgradeTracked, Track: cfg.Track}
+	updates := findUpdates(modInfo, scope, latest, func(path, version string) bool {
+		if rules == nil {
+			return true
+		}
+		if err := rules.Check(path, version); err != nil {
+			if upgradeVerbose {
+				fmt.Fprintf(os.Stderr, "Skipping %v\n", err)
+			}
+			return false
+		}
+		return true
+	})
+	fetcher.Close()
+	return updates, nil
+}
+
+// targetUpdates returns the updates named by --to arguments of the form
+// module@version. Every module must be a requirement in modInfo that is not
+// replaced.
+func targetUpdates(modInfo *mod.ModInfo, targets []string) ([]moduleUpdate, error) {
+	replaced := make(map[string]bool)
+	for _, rep := range modInfo.Replaces {
+		replaced[rep.Old] = true
+	}
+	required := make(map[string]string)
+	for _, req := range modInfo.Requires {
+		required[req.Path] = req.Version
+	}
+
+	var updates []moduleUpdate
+	for _, target := range targets {
+		modulePath, version, err := parseModuleVersion(target)
+		if err != nil {
+			return nil, fmt.Errorf("--to: %w", err)
+		}
+		current, ok := required[modulePath]
+		switch {
+		case version == "":
+			return nil, fmt.Errorf("--to %s: want module@version", target)
+		case !ok:
+			return nil, fmt.Errorf("--to %s: %s is not required in go.mod", target, modulePath)
+		case replaced[modulePath]:
+			return nil, fmt.Errorf("--to %s: %s is replaced in go.mod", target, modulePath)
+		case current == version:
+			continue
+		}
+		updates = append(updates, moduleUpdate{Path: modulePath, Current: current, Latest: version})
+	}
+
+	sort.Slice(updates, func(i, j int) bool { return updates[i].Path < updates[j].Path })
+	return updates, nil
+}
+
+// printReplaceEffects lists the changes in what replacements build.
+func printReplaceEffects(w io.Writer, effects []replaceEffect) {
+	if len(effects) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Replacements now build:")
+	for _, e := range effects {
+		fmt.Fprintf(w, "  %s: %s -> %s\n", e.Path, e.Before, e.After)
+	}
+}
+
+// findUpdates returns the requirements in modInfo in scope with a newer
+// latest version, sorted by module path. Replaced modules, indirect ones
+// unless scope.Indirect is set, and updates rejected by permit are skipped,
+// as are modules whose latest version cannot be resolved. Tracked modules
+// are always considered.
+func findUpdates(modInfo *mod.ModInfo, scope upgradeScope, latest latestFunc, permit func(path, version string) bool) []moduleUpdate {
+	replaced := make(map[string]bool)
+	for _, rep := range modInfo.Replaces {
+		replaced[rep.Old] = true
+	}
+
+	var updates []moduleUpdate
+	for _, req := range modInfo.Requires {
+		branch := scope.Track[req.Path]
+		if replaced[req.Path] || (branch == "" && (scope.TrackedOnly || (req.Indirect && !scope.Indirect))) {
+			continue
+		}
+		info, err := latest(req.Path, branch)
+		if err != nil || semver.Compare(info.Version, req.Version) <= 0 {
+			continue
+		}
+		if permit != nil && !permit(req.Path, info.Version) {
+			continue
+		}
+		u := moduleUpdate{Path: req.Path, Current: req.Version, Latest: info.Version, Branch: branch}
+		if t, err := time.Parse(time.RFC3339, info.Time); err == nil {
+			u.Published = t
+		}
+		updates = append(updates, u)
+	}
+
+	sort.Slice(updates, func(i, j int) bool { return updates[i].Path < updates[j].Path })
+	return updates
+}
+
+// selectUpdates lists updates on w and reads the user's selection from r:
+// space- or comma-separated numbers and ranges (1,3-5), "all", or an empty
+// line for none.
+func selectUpdates(r io.Reader, w io.Writer, updates []moduleUpdate, now time.Time) ([]moduleUpdate, error) {
+	fmt.Fprintln(w, "Available updates:")
+	for i, u := range updates {
+		age := "unknown age"
+		if !u.Published.IsZero() {
+			age = policy.FormatAge(now.Sub(u.Published)) + " old"
+		}
+		if u.Branch != "" {
+			age += ", tracking " + u.Branch
+		}
+		fmt.Fprintf(w, "  [%d] %s %s -> %s (%s)\n", i+1, u.Path, u.Current, u.Latest, age)
+	}
+	fmt.Fprint(w, "Select updates to apply (e.g. 1,3-5 or all; empty for none): ")
+
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("reading selection: %w", err)
+	}
+	picked, err := parseSelection(line, len(updates))
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []moduleUpdate
+	for _, i := range picked {
+		selected = append(selected, updates[i])
+	}
+	return selected, nil
+}
+
+// parseSelection parses a selection of 1-based items out of n into sorted,
+// deduplicated 0-based indexes.
+func parseSelection(s string, n int) ([]int, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "all") {
+		all := make([]int, n)
+		for i := range all {
+			all[i] = i
+		}
+		return all, nil
+	}
+
+	chosen := make(map[int]bool)
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		lo, hi, isRange := strings.Cut(field, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection %q", field)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil {
+				return nil, fmt.Errorf("invalid selection %q", field)
+			}
+		}
+		if first < 1 || last > n || first > last {
+			return nil, fmt.Errorf("selection %q out of range 1-%d", field, n)
+		}
+		for i := first; i <= last; i++ {
+			chosen[i-1] = true
+		}
+	}
+
+	picked := make([]int, 0, len(chosen))
+	for i := range chosen {
+		picked = append(picked, i)
+	}
+	sort.Ints(picked)
+	return picked, nil
+}
+
+// applyUpdates moves go.mod and go.sum in dir to the given versions with go
+// get and regenerates the lockfile, returning the lockfiles before (empty if
+// there was none) and after. On failure go.mod and go.sum are restored.
+func applyUpdates(dir string, cfg *config.Config, updates []moduleUpdate, verbose bool) (prev, next *lockfile.Lockfile, err error) {
+	unlock, err := lockfile.Lock(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	goModPath := filepath.Join(dir, "go.mod")
+	goSumPath := filepath.Join(dir, "go.sum")
+	oldMod, err := os.ReadFile(goModPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading go.mod: %w", err)
+	}
+	oldSum, sumErr := os.ReadFile(goSumPath)
+
+	restore := func() {
+		os.WriteFile(goModPath, oldMod, 0o644)
+		if sumErr == nil {
+			os.WriteFile(goSumPath, oldSum, 0o644)
+		} else {
+			os.Remove(goSumPath)
+		}
+	}
+
+	getArgs := []string{"get"}
+	for _, u := range updates {
+		getArgs = append(getArgs, u.Path+"@"+u.Latest)
+	}
+	get := exec.Command("go", getArgs...)
+	get.Dir = dir
+	if out, err := get.CombinedOutput(); err != nil {
+		restore()
+		return nil, nil, fmt.Errorf("go get: %s", strings.TrimSpace(string(out)))
+	}
+
+	opts, err := configOptions(cfg)
+	if err != nil {
+		restore()
+		return nil, nil, err
+	}
+	opts.Verbose = verbose
+	opts.Profile = lockProfile
+	opts.Meta = generationMeta(false)
+
+	// Keep the meta block out if the existing lockfile was generated without one.
+	prev, _ = lockfile.Load(lockfile.Path(dir, lockProfile))
+	if prev != nil && prev.Meta == nil {
+		opts.Meta = nil
+	}
+	rule, err := minAgeRule(cfg.Policy)
+	if err != nil {
+		restore()
+		return nil, nil, err
+	}
+	if rule != nil {
+		opts.Check = func(lf *lockfile.Lockfile) error {
+			return checkMinAge(os.Stderr, cfg, lf, prev, *rule)
+		}
+	}
+
+	next, err = generator.GenerateAndSave(dir, opts)
+	if err != nil {
+		restore()
+		return nil, nil, fmt.Errorf("regenerating lockfile: %w", err)
+	}
+	if prev == nil {
+		prev = lockfile.New("")
+	}
+	return prev, next, nil
+}
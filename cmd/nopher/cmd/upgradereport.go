@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/anthr76/nopher/internal/depsdev"
+	"github.com/anthr76/nopher/pkg/lockfile"
+)
+
+// summarizeFunc looks up deps.dev data for a module version.
+type summarizeFunc func(modulePath, version string) (*depsdev.Summary, error)
+
+// upgradeEntry is a changed lockfile entry in an upgrade report.
+type upgradeEntry struct {
+	depChange
+	SizeDelta int64
+	// Licenses of the version after the change, or before it for a
+	// removed entry.
+	Licenses []string
+	// LicensesBefore holds the licenses of the version before the change
+	// when they differ from Licenses.
+	LicensesBefore []string
+	LicenseChanged bool
+	// Fixed and Introduced list advisories affecting only the version
+	// before, or only the version after, the change.
+	Fixed      []string
+	Introduced []string
+	// Unknown is set when the module is private, or deps.dev had no data
+	// for the version before or after the change, so neither licenses nor
+	// advisories are compared.
+	Unknown bool
+}
+
+// upgradeReport summarizes what an upgrade changed in the lockfile.
+type upgradeReport struct {
+	Entries               []upgradeEntry
+	SizeBefore, SizeAfter int64
+	Effects               []replaceEffect
+}
+
+// buildUpgradeReport compares the lockfiles before and after an upgrade,
+// looking up the licenses and advisories of every changed entry's versions
+// with summarize, except for private modules.
+func buildUpgradeReport(prev, next *lockfile.Lockfile, private func(string) bool, summarize summarizeFunc) upgradeReport {
+	changes := lockfileChanges(prev, next)
+
+	type key struct{ path, version string }
+	var keys []key
+	for _, c := range changes {
+		for _, lf := range []*lockfile.Lockfile{prev, next} {
+			if path, version, _ := lockedSource(lf, c.Path); path != "" && !private(path) && !slices.Contains(keys, key{path, version}) {
+				keys = append(keys, key{path, version})
+			}
+		}
+	}
+
+	summaries := make([]*depsdev.Summary, len(keys))
+	sem := make(chan struct{}, reportConcurrency)
+	var wg sync.WaitGroup
+	for i, k := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s, err := summarize(k.path, k.version)
+			if err != nil && upgradeVerbose && !errors.Is(err, depsdev.ErrNotFound) {
+				fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+			}
+			summaries[i] = s
+		}()
+	}
+	wg.Wait()
+	summaryOf := func(path, version string) *depsdev.Summary {
+		if i := slices.Index(keys, key{path, version}); i >= 0 {
+			return summaries[i]
+		}
+		return nil
+	}
+
+	report := upgradeReport{
+		SizeBefore: closureSize(prev),
+		SizeAfter:  closureSize(next),
+		Effects:    replaceEffects(prev, next),
+	}
+	for _, c := range changes {
+		beforePath, beforeVersion, beforeSize := lockedSource(prev, c.Path)
+		afterPath, afterVersion, afterSize := lockedSource(next, c.Path)
+		e := upgradeEntry{depChange: c, SizeDelta: afterSize - beforeSize}
+
+		before, after := summaryOf(beforePath, beforeVersion), summaryOf(afterPath, afterVersion)
+		switch {
+		case (beforePath != "" && before == nil) || (afterPath != "" && after == nil):
+			e.Unknown = true
+		case after == nil:
+			e.Licenses = before.Licenses
+			e.Fixed = before.Advisories
+		case before == nil:
+			e.Licenses = after.Licenses
+			e.Introduced = after.Advisories
+		default:
+			e.Licenses = after.Licenses
+			if !slices.Equal(before.Licenses, after.Licenses) {
+				e.LicensesBefore, e.LicenseChanged = before.Licenses, true
+			}
+			e.Fixed = missingFrom(before.Advisories, after.Advisories)
+			e.Introduced = missingFrom(after.Advisories, before.Advisories)
+		}
+		report.Entries = append(report.Entries, e)
+	}
+	return report
+}
+
+// lockedSource returns the module version lf fetches for a change path (a
+// module path, or "replace " and the replaced path) and its size. The path
+// is empty when lf has no such entry, or it is a local replacement.
+func lockedSource(lf *lockfile.Lockfile, changePath string) (modulePath, version string, size int64) {
+	if old, ok := strings.CutPrefix(changePath, "replace "); ok {
+		r, ok := lf.Replace[old]
+		if !ok || r.Path != "" {
+			return "", "", 0
+		}
+		return r.New, r.Version, r.Size
+	}
+	m, ok := lf.Modules[changePath]
+	if !ok {
+		return "", "", 0
+	}
+	return changePath, m.Version, m.Size
+}
+
+// closureSize returns the uncompressed size of every module and remote
+// replacement lf locks.
+func closureSize(lf *lockfile.Lockfile) int64 {
+	var total int64
+	for _, m := range lf.Modules {
+		total += m.Size
+	}
+	for _, r := range lf.Replace {
+		if r.Path == "" {
+			total += r.Size
+		}
+	}
+	return total
+}
+
+// missingFrom returns the elements of a not in b.
+func missingFrom(a, b []string) []string {
+	var out []string
+	for _, s := range a {
+		if !slices.Contains(b, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// printUpgradeMarkdown writes r as markdown ready to post as the body of a
+// pull request or issue.
+func printUpgradeMarkdown(w io.Writer, r upgradeReport) {
+	fmt.Fprintln(w, "### Dependency updates")
+	fmt.Fprintln(w)
+
+	counts := make(map[string]int)
+	for _, e := range r.Entries {
+		counts[e.Kind]++
+	}
+	var parts []string
+	for _, kind := range changeKinds {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+	fmt.Fprintf(w, "%s. Vendored size: %s → %s (%s).\n\n", strings.Join(parts, ", "),
+		formatSize(r.SizeBefore), formatSize(r.SizeAfter), formatSizeDelta(r.SizeAfter-r.SizeBefore))
+
+	fmt.Fprintln(w, "| Module | Change | Before | After | Size | License |")
+	fmt.Fprintln(w, "|--------|--------|--------|-------|------|---------|")
+	var unknown int
+	for _, e := range r.Entries {
+		license := strings.Join(e.Licenses, ", ")
+		if e.Unknown {
+			license = "unknown"
+			unknown++
+		}
+		fmt.Fprintf(w, "| `%s` | %s | %s | %s | %s | %s |\n", e.Path, e.Kind, markdownCode(e.Before), markdownCode(e.After), formatSizeDelta(e.SizeDelta), license)
+	}
+
+	if slices.ContainsFunc(r.Entries, func(e upgradeEntry) bool { return len(e.Fixed) > 0 }) {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "#### Advisories fixed")
+		fmt.Fprintln(w)
+		for _, e := range r.Entries {
+			if len(e.Fixed) > 0 {
+				fmt.Fprintf(w, "- `%s` %s: %s\n", e.Path, markdownCode(e.Before), strings.Join(e.Fixed, ", "))
+			}
+		}
+	}
+	if slices.ContainsFunc(r.Entries, func(e upgradeEntry) bool { return len(e.Introduced) > 0 }) {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "> [!WARNING]")
+		fmt.Fprintln(w, "> These versions have known advisories their predecessors did not:")
+		for _, e := range r.Entries {
+			if len(e.Introduced) > 0 {
+				fmt.Fprintf(w, "> - `%s` %s: %s\n", e.Path, markdownCode(e.After), strings.Join(e.Introduced, ", "))
+			}
+		}
+	}
+
+	if slices.ContainsFunc(r.Entries, func(e upgradeEntry) bool { return e.LicenseChanged }) {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "#### License changes")
+		fmt.Fprintln(w)
+		for _, e := range r.Entries {
+			if e.LicenseChanged {
+				fmt.Fprintf(w, "- `%s`: %s → %s\n", e.Path, formatLicenses(e.LicensesBefore), formatLicenses(e.Licenses))
+			}
+		}
+	}
+
+	if len(r.Effects) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "#### What replacements build")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "| Module | Before | After |")
+		fmt.Fprintln(w, "|--------|--------|-------|")
+		for _, e := range r.Effects {
+			fmt.Fprintf(w, "| `%s` | %s | %s |\n", e.Path, markdownSource(e.Before), markdownSource(e.After))
+		}
+	}
+
+	if unknown > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "%d of these modules are private or unknown to deps.dev; their licenses and advisories are unknown.\n", unknown)
+	}
+}
+
+// formatLicenses joins licenses for display.
+func formatLicenses(licenses []string) string {
+	if len(licenses) == 0 {
+		return "none"
+	}
+	return strings.Join(licenses, ", ")
+}
+
+// formatSizeDelta renders a signed byte count in human-readable units.
+func formatSizeDelta(n int64) string {
+	switch {
+	case n > 0:
+		return "+" + formatSize(n)
+	case n < 0:
+		return "-" + formatSize(-n)
+	}
+	return "0B"
+}
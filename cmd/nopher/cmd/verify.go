@@ -2,10 +2,8 @@ package cmd
 
 import (
 	"fmt"
-	"path/filepath"
-	"sort"
 
-	"github.com/anthr76/nopher/internal/mod"
+	"github.com/anthr76/nopher/pkg/lockcheck"
 	"github.com/anthr76/nopher/pkg/lockfile"
 	"github.com/spf13/cobra"
 )
@@ -18,101 +16,113 @@ var verifyCmd = &cobra.Command{
 This command checks for:
 - Missing modules in the lockfile
 - Extra modules in the lockfile
-- Version mismatches between lockfile and go.mod`,
+- Version mismatches between lockfile and go.mod
+- Replace directives missing from, extra in, or pointing elsewhere than
+  the lockfile's replace entries
+- Lockfile modules without a go.sum entry, or whose recorded h1 hash
+  no longer matches go.sum
+- Modules still in quarantine (see nopher quarantine), unless
+  --allow-quarantined is given
+
+With --since <git-ref>, only modules whose go.mod requirement or replacement,
+or go.sum lines, changed since the ref are checked, for fast pre-merge checks
+on large projects.
+
+With --nix-store, instead report which locked modules are already present
+in the local Nix store and which the next build will need to download.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runVerify,
 }
 
+var (
+	verifyNixStore         bool
+	verifySince            string
+	verifyAllowQuarantined bool
+)
+
 func init() {
 	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().BoolVar(&verifyNixStore, "nix-store", false, "check which locked modules are present in the Nix store (requires nix-store)")
+	verifyCmd.Flags().StringVar(&verifySince, "since", "", "only check modules changed in go.mod/go.sum since this git ref (requires git)")
+	verifyCmd.Flags().BoolVar(&verifyAllowQuarantined, "allow-quarantined", false, "do not fail on quarantined modules")
 }
 
 func runVerify(cmd *cobra.Command, args []string) error {
-	dir := "."
-	if len(args) > 0 {
-		dir = args[0]
-	}
+	dir := projectDir(args, 0)
 
 	// Load existing lockfile
-	lfPath := filepath.Join(dir, lockfile.DefaultLockfile)
+	lfPath := lockfile.Path(dir, lockProfile)
 	existing, err := lockfile.Load(lfPath)
 	if err != nil {
 		return fmt.Errorf("loading lockfile: %w", err)
 	}
 
-	// Parse go.mod
-	goModPath := filepath.Join(dir, "go.mod")
-	modInfo, err := mod.ParseGoMod(goModPath)
-	if err != nil {
-		return fmt.Errorf("parsing go.mod: %w", err)
-	}
-
-	// Check Go version
-	if existing.Go != modInfo.GoVersion {
-		return fmt.Errorf("Go version mismatch: lockfile has %s, go.mod has %s", existing.Go, modInfo.GoVersion)
+	if verifyNixStore {
+		return checkNixStore(cmd.OutOrStdout(), existing)
 	}
 
-	// Build sets for comparison
-	lockfileModules := make(map[string]string) // path -> version
-	for path, m := range existing.Modules {
-		lockfileModules[path] = m.Version
-	}
-
-	gomodModules := make(map[string]string)
-	for _, req := range modInfo.Requires {
-		gomodModules[req.Path] = req.Version
-	}
-
-	// Find differences
-	var missing []string
-	var extra []string
-	var versionMismatch []string
-
-	for path, version := range gomodModules {
-		if lfVersion, ok := lockfileModules[path]; !ok {
-			// Check if it's a local replace
-			if rep, ok := existing.Replace[path]; ok && rep.Path != "" {
-				continue // Local replace, skip
-			}
-			missing = append(missing, fmt.Sprintf("%s@%s", path, version))
-		} else if lfVersion != version {
-			versionMismatch = append(versionMismatch, fmt.Sprintf("%s: lockfile=%s, go.mod=%s", path, lfVersion, version))
+	inScope := func(string) bool { return true }
+	scoped := false
+	var changed map[string]bool
+	if verifySince != "" {
+		var all bool
+		changed, all, err = changedSince(dir, verifySince)
+		if err != nil {
+			return err
 		}
-	}
-
-	for path := range lockfileModules {
-		if _, ok := gomodModules[path]; !ok {
-			extra = append(extra, path)
+		if !all {
+			scoped = true
+			inScope = func(path string) bool { return changed[path] }
 		}
 	}
 
-	sort.Strings(missing)
-	sort.Strings(extra)
-	sort.Strings(versionMismatch)
+	findings, err := lockcheck.CheckWith(dir, lockcheck.Options{Profile: lockProfile, InScope: inScope})
+	if err != nil {
+		return err
+	}
+	if goVersion := lockcheck.Messages(findings, lockcheck.GoVersion); len(goVersion) > 0 {
+		return fmt.Errorf("Go version mismatch: %s", goVersion[0])
+	}
 
-	if len(missing) > 0 || len(extra) > 0 || len(versionMismatch) > 0 {
+	if !lockcheck.Fresh(findings) {
 		fmt.Println("Lockfile is out of sync with go.mod:")
-		if len(missing) > 0 {
-			fmt.Println("\nMissing from lockfile:")
-			for _, m := range missing {
-				fmt.Printf("  + %s\n", m)
-			}
+		sections := []struct {
+			kind    lockcheck.Kind
+			heading string
+			marker  string
+		}{
+			{lockcheck.Missing, "Missing from lockfile:", "+"},
+			{lockcheck.Extra, "Extra in lockfile:", "-"},
+			{lockcheck.Mismatch, "Version mismatches:", "!"},
+			{lockcheck.GoSum, "go.sum inconsistencies:", "!"},
 		}
-		if len(extra) > 0 {
-			fmt.Println("\nExtra in lockfile:")
-			for _, m := range extra {
-				fmt.Printf("  - %s\n", m)
+		for _, sec := range sections {
+			messages := lockcheck.Messages(findings, sec.kind)
+			if len(messages) == 0 {
+				continue
 			}
-		}
-		if len(versionMismatch) > 0 {
-			fmt.Println("\nVersion mismatches:")
-			for _, m := range versionMismatch {
-				fmt.Printf("  ! %s\n", m)
+			fmt.Println("\n" + sec.heading)
+			for _, m := range messages {
+				fmt.Printf("  %s %s\n", sec.marker, m)
 			}
 		}
 		return fmt.Errorf("lockfile verification failed")
 	}
 
+	if !verifyAllowQuarantined {
+		if quarantined := lockcheck.Messages(findings, lockcheck.Quarantined); len(quarantined) > 0 {
+			fmt.Println("Lockfile is in sync with go.mod, but modules are quarantined pending review:")
+			for _, m := range quarantined {
+				fmt.Printf("  ? %s\n", m)
+			}
+			return fmt.Errorf("lockfile verification failed: %d quarantined modules", len(quarantined))
+		}
+	}
+
+	if scoped {
+		fmt.Printf("Lockfile is in sync with go.mod (%d modules changed since %s)\n", len(changed), verifySince)
+		return nil
+	}
 	fmt.Println("Lockfile is in sync with go.mod")
 	return nil
 }
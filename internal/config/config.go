@@ -0,0 +1,179 @@
+// Package config loads the optional per-project nopher configuration file.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Filename is the project configuration file name, looked up in the module directory.
+const Filename = ".nopher.yaml"
+
+// Config is the contents of .nopher.yaml.
+type Config struct {
+	// URLOverrides maps module paths or GOPRIVATE-style patterns to download
+	// URL templates. Templates may use the {module}, {version}, and {rev}
+	// placeholders.
+	URLOverrides map[string]string `yaml:"urlOverrides,omitempty"`
+
+	// Hooks declares commands run during lockfile generation.
+	Hooks Hooks `yaml:"hooks,omitempty"`
+
+	// Suspicious configures typosquatting and fork detection.
+	Suspicious Suspicious `yaml:"suspicious,omitempty"`
+
+	// Policy configures supply-chain rules enforced by generate and policy check.
+	Policy Policy `yaml:"policy,omitempty"`
+
+	// Signing maps proxy hosts (host or host:port) to request signing
+	// settings, for GOPROXY endpoints that reject unsigned requests.
+	Signing map[string]Signing `yaml:"signing,omitempty"`
+
+	// GitHubApp authenticates GitHub fetches as a GitHub App installation
+	// instead of with a personal access token.
+	GitHubApp *GitHubApp `yaml:"githubApp,omitempty"`
+
+	// GitLab lists self-hosted GitLab hosts. Modules on them are fetched
+	// from GitLab archives, and GITLAB_TOKEN is sent to them.
+	GitLab []string `yaml:"gitlab,omitempty"`
+
+	// Mirrors maps module paths or GOPRIVATE-style patterns to equivalent
+	// module proxy URLs. The first is recorded in the lockfile; fetches fail
+	// over to the others when it is unhealthy.
+	Mirrors map[string][]string `yaml:"mirrors,omitempty"`
+
+	// Track maps module paths to the branch they follow. nopher upgrade moves
+	// tracked modules to the pseudo-version of the branch's latest commit
+	// instead of their latest release.
+	Track map[string]string `yaml:"track,omitempty"`
+
+	// Owners maps module paths or GOPRIVATE-style patterns to the teams
+	// responsible for reviewing changes to them, as CODEOWNERS does for
+	// files. See OwnersOf.
+	Owners map[string][]string `yaml:"owners,omitempty"`
+
+	// Symlinks is how symlinks in GitHub archives are extracted: "skip"
+	// (the default), "reject", or "materialize".
+	Symlinks string `yaml:"symlinks,omitempty"`
+
+	// Proxy is the GOPROXY list used when GOPROXY is not set in the
+	// environment.
+	Proxy string `yaml:"proxy,omitempty"`
+
+	// Private lists GOPRIVATE-style patterns of modules to fetch directly,
+	// used when neither GOPRIVATE nor GONOPROXY is set in the environment.
+	Private []string `yaml:"private,omitempty"`
+
+	// CacheDir is the module cache directory, relative to the project
+	// directory. It is the default for --cache-dir.
+	CacheDir string `yaml:"cacheDir,omitempty"`
+
+	// Concurrency is the default for --jobs.
+	Concurrency int `yaml:"concurrency,omitempty"`
+
+	// Format is the lockfile format generate writes, "yaml" or "toml". It
+	// is the default for generate --format.
+	Format string `yaml:"format,omitempty"`
+
+	// Include and Exclude list GOPRIVATE-style patterns of modules generate
+	// fetches, as the defaults for generate --only and --skip.
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
+}
+
+// Signing configures how requests to one host are signed. Exactly one
+// method must be set.
+type Signing struct {
+	SigV4 *SigV4 `yaml:"sigv4,omitempty"`
+	IAP   *IAP   `yaml:"iap,omitempty"`
+}
+
+// SigV4 signs requests with AWS Signature Version 4.
+type SigV4 struct {
+	Region string `yaml:"region"`
+	// Service is the signing service name. Empty means "execute-api".
+	Service string `yaml:"service,omitempty"`
+	// RoleARN, when set, is assumed with STS before signing.
+	RoleARN string `yaml:"roleArn,omitempty"`
+}
+
+// IAP authenticates to Google Cloud Identity-Aware Proxy with an identity token.
+type IAP struct {
+	// Audience is the OAuth client ID of the IAP-protected resource.
+	Audience string `yaml:"audience"`
+	// ServiceAccount, when set, is impersonated to mint the token.
+	ServiceAccount string `yaml:"serviceAccount,omitempty"`
+}
+
+// GitHubApp identifies a GitHub App and the installation to mint tokens for.
+type GitHubApp struct {
+	// AppID is the app's numeric ID.
+	AppID int64 `yaml:"appId"`
+	// InstallationID selects the installation. When unset, Owner's
+	// installation is used, or the app's only installation.
+	InstallationID int64 `yaml:"installationId,omitempty"`
+	// Owner is the organization or user the app is installed on.
+	Owner string `yaml:"owner,omitempty"`
+	// PrivateKeyFile is the path of the app's PEM private key, relative to
+	// the project directory.
+	PrivateKeyFile string `yaml:"privateKeyFile"`
+}
+
+// Policy configures supply-chain rules.
+type Policy struct {
+	// MinAge is the minimum time since a module version was published,
+	// e.g. "7d" or "36h". Empty disables the rule.
+	MinAge string `yaml:"minAge,omitempty"`
+	// Exceptions lists module paths or GOPRIVATE-style patterns exempt from
+	// the minimum age rule.
+	Exceptions []string `yaml:"exceptions,omitempty"`
+	// Deny lists module paths or GOPRIVATE-style patterns, optionally with an
+	// @version suffix, that must never be fetched.
+	Deny []string `yaml:"deny,omitempty"`
+	// Allow, when non-empty, restricts fetching to modules matching one of
+	// its entries. Deny takes precedence.
+	Allow []string `yaml:"allow,omitempty"`
+}
+
+// Suspicious configures the suspicious module heuristics.
+type Suspicious struct {
+	// Allow lists module paths or GOPRIVATE-style patterns that are never
+	// flagged.
+	Allow []string `yaml:"allow,omitempty"`
+}
+
+// Hooks lists shell commands run at points during generation.
+type Hooks struct {
+	// PostFetch commands run once per fetched module. See generator.CommandHook
+	// for the environment they receive.
+	PostFetch []string `yaml:"postFetch,omitempty"`
+}
+
+// Load reads .nopher.yaml from dir. A missing file yields an empty Config.
+func Load(dir string) (*Config, error) {
+	data, err := os.ReadFile(filepath.Join(dir, Filename))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", Filename, err)
+	}
+	if cfg.CacheDir != "" && !filepath.IsAbs(cfg.CacheDir) {
+		cfg.CacheDir = filepath.Join(dir, cfg.CacheDir)
+	}
+	if app := cfg.GitHubApp; app != nil && app.PrivateKeyFile != "" && !filepath.IsAbs(app.PrivateKeyFile) {
+		app.PrivateKeyFile = filepath.Join(dir, app.PrivateKeyFile)
+	}
+
+	return &cfg, nil
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadMissing(t *testing.T) {
+	cfg, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.URLOverrides) != 0 {
+		t.Errorf("URLOverrides = %v, want empty", cfg.URLOverrides)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	content := `urlOverrides:
+  git.internal.example.com/*: https://artifacts.example.com/go/{module}/{version}.zip
+hooks:
+  postFetch:
+    - scan-licenses "$NOPHER_MODULE_DIR"
+track:
+  git.internal.example.com/platform/sdk: main
+`
+	if err := os.WriteFile(filepath.Join(dir, Filename), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := "https://artifacts.example.com/go/{module}/{version}.zip"
+	if got := cfg.URLOverrides["git.internal.example.com/*"]; got != want {
+		t.Errorf("URLOverrides[...] = %q, want %q", got, want)
+	}
+	if len(cfg.Hooks.PostFetch) != 1 || cfg.Hooks.PostFetch[0] != `scan-licenses "$NOPHER_MODULE_DIR"` {
+		t.Errorf("Hooks.PostFetch = %q", cfg.Hooks.PostFetch)
+	}
+	if got := cfg.Track["git.internal.example.com/platform/sdk"]; got != "main" {
+		t.Errorf("Track[...] = %q, want main", got)
+	}
+}
+
+func TestLoadProjectDefaults(t *testing.T) {
+	dir := t.TempDir()
+	content := `proxy: https://goproxy.corp.example.com,direct
+private:
+  - git.corp.example.com
+cacheDir: .cache/nopher
+concurrency: 4
+format: toml
+include: [git.corp.example.com/*]
+exclude: [git.corp.example.com/legacy]
+`
+	if err := os.WriteFile(filepath.Join(dir, Filename), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Proxy != "https://goproxy.corp.example.com,direct" || len(cfg.Private) != 1 || cfg.Concurrency != 4 || cfg.Format != "toml" {
+		t.Errorf("Load() = %+v", cfg)
+	}
+	if want := filepath.Join(dir, ".cache/nopher"); cfg.CacheDir != want {
+		t.Errorf("CacheDir = %q, want %q relative to the project", cfg.CacheDir, want)
+	}
+	if len(cfg.Include) != 1 || len(cfg.Exclude) != 1 {
+		t.Errorf("Include = %q, Exclude = %q", cfg.Include, cfg.Exclude)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, Filename), []byte("urlOverrides: [\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Error("Load() with invalid YAML should return error")
+	}
+}
+
+func TestLoadSigning(t *testing.T) {
+	dir := t.TempDir()
+	content := `signing:
+  goproxy.internal.example.com:
+    sigv4:
+      region: us-east-1
+      roleArn: arn:aws:iam::123456789012:role/goproxy
+  goproxy.corp.example:
+    iap:
+      audience: 1234.apps.googleusercontent.com
+`
+	if err := os.WriteFile(filepath.Join(dir, Filename), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if s := cfg.Signing["goproxy.internal.example.com"].SigV4; s == nil || s.Region != "us-east-1" || s.RoleARN != "arn:aws:iam::123456789012:role/goproxy" {
+		t.Errorf("sigv4 = %+v", s)
+	}
+	if s := cfg.Signing["goproxy.corp.example"].IAP; s == nil || s.Audience != "1234.apps.googleusercontent.com" {
+		t.Errorf("iap = %+v", s)
+	}
+}
+
+func TestOwnersOf(t *testing.T) {
+	dir := t.TempDir()
+	content := `owners:
+  "*": ["@acme/platform"]
+  github.com/aws/*: ["@acme/cloud", "@acme/security"]
+  github.com/aws/smithy-go: []
+  golang.org/x/crypto: ["@acme/security"]
+`
+	if err := os.WriteFile(filepath.Join(dir, Filename), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := map[string][]string{
+		"golang.org/x/crypto":                  {"@acme/security"},
+		"github.com/aws/aws-sdk-go-v2/service": {"@acme/cloud", "@acme/security"},
+		"github.com/aws/smithy-go":             {},
+		"example.com/lib":                      {"@acme/platform"},
+	}
+	for path, want := range tests {
+		if got := cfg.OwnersOf(path); strings.Join(got, ",") != strings.Join(want, ",") || (got == nil) != (want == nil) {
+			t.Errorf("OwnersOf(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	// Equally long patterns are broken by sort order, not map order.
+	tied := &Config{Owners: map[string][]string{"example.com/a*": {"@a"}, "example.com/*b": {"@b"}}}
+	for range 20 {
+		if got := tied.OwnersOf("example.com/ab"); len(got) != 1 || got[0] != "@b" {
+			t.Fatalf("OwnersOf() with tied patterns = %q, want [@b]", got)
+		}
+	}
+
+	if got := (&Config{}).OwnersOf("example.com/lib"); got != nil {
+		t.Errorf("OwnersOf() without owners = %q, want nil", got)
+	}
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// OwnersOf returns the owners of modulePath: the Owners entry for the
+// module path itself, or else for the longest matching pattern, the first
+// in sort order among equally long ones. Replaced
+// modules are owned under their original path. An entry with no owners
+// leaves matching modules unowned, overriding a shorter pattern, the way an
+// ownerless CODEOWNERS line does. nil means no entry matches.
+func (c *Config) OwnersOf(modulePath string) []string {
+	if owners, ok := c.Owners[modulePath]; ok {
+		return owners
+	}
+	best, found := "", false
+	for pattern := range c.Owners {
+		better := len(pattern) > len(best) || (len(pattern) == len(best) && pattern < best)
+		if better && module.MatchPrefixPatterns(strings.ReplaceAll(pattern, " ", ""), modulePath) {
+			best, found = pattern, true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return c.Owners[best]
+}
@@ -0,0 +1,124 @@
+// Package depsdev queries the deps.dev API for dependency health metadata:
+// OpenSSF Scorecard results, repository popularity, and known advisories.
+package depsdev
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultBaseURL is the public deps.dev API endpoint.
+const DefaultBaseURL = "https://api.deps.dev"
+
+// ErrNotFound is returned when deps.dev has no data for a module version.
+var ErrNotFound = errors.New("not found on deps.dev")
+
+// Client talks to the deps.dev v3 API.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// New returns a Client for the public deps.dev API.
+func New() *Client {
+	return &Client{
+		BaseURL:    DefaultBaseURL,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Summary is the reviewer-facing summary for one module version.
+type Summary struct {
+	Module     string   `json:"module"`
+	Version    string   `json:"version"`
+	Licenses   []string `json:"licenses,omitempty"`
+	Advisories []string `json:"advisories,omitempty"`
+	Project    string   `json:"project,omitempty"` // Source repository, e.g. github.com/owner/repo
+	Stars      int      `json:"stars,omitempty"`
+	Forks      int      `json:"forks,omitempty"`
+	// Scorecard is the OpenSSF Scorecard overall score (0-10), or nil if the
+	// project has not been scored.
+	Scorecard *float64 `json:"scorecard,omitempty"`
+}
+
+type versionResponse struct {
+	Licenses     []string `json:"licenses"`
+	AdvisoryKeys []struct {
+		ID string `json:"id"`
+	} `json:"advisoryKeys"`
+	RelatedProjects []struct {
+		ProjectKey struct {
+			ID string `json:"id"`
+		} `json:"projectKey"`
+		RelationType string `json:"relationType"`
+	} `json:"relatedProjects"`
+}
+
+type projectResponse struct {
+	StarsCount int `json:"starsCount"`
+	ForksCount int `json:"forksCount"`
+	Scorecard  *struct {
+		OverallScore float64 `json:"overallScore"`
+	} `json:"scorecard"`
+}
+
+// Summarize looks up a Go module version and its source project.
+// Project data is best-effort: a module whose project lookup fails still
+// yields its licenses and advisories.
+func (c *Client) Summarize(modulePath, version string) (*Summary, error) {
+	var v versionResponse
+	endpoint := fmt.Sprintf("/v3/systems/go/packages/%s/versions/%s", url.PathEscape(modulePath), url.PathEscape(version))
+	if err := c.get(endpoint, &v); err != nil {
+		return nil, fmt.Errorf("%s@%s: %w", modulePath, version, err)
+	}
+
+	s := &Summary{
+		Module:   modulePath,
+		Version:  version,
+		Licenses: v.Licenses,
+	}
+	for _, adv := range v.AdvisoryKeys {
+		s.Advisories = append(s.Advisories, adv.ID)
+	}
+	for _, rp := range v.RelatedProjects {
+		if rp.RelationType == "SOURCE_REPO" {
+			s.Project = rp.ProjectKey.ID
+			break
+		}
+	}
+
+	if s.Project != "" {
+		var p projectResponse
+		if err := c.get("/v3/projects/"+url.PathEscape(s.Project), &p); err == nil {
+			s.Stars = p.StarsCount
+			s.Forks = p.ForksCount
+			if p.Scorecard != nil {
+				score := p.Scorecard.OverallScore
+				s.Scorecard = &score
+			}
+		}
+	}
+
+	return s, nil
+}
+
+func (c *Client) get(endpoint string, v any) error {
+	resp, err := c.HTTPClient.Get(c.BaseURL + endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("deps.dev returned status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
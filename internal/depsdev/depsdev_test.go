@@ -0,0 +1,55 @@
+package depsdev
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/v3/systems/go/packages/github.com%2Fexample%2Flib/versions/v1.2.3":
+			w.Write([]byte(`{
+				"licenses": ["MIT"],
+				"advisoryKeys": [{"id": "GHSA-xxxx-yyyy-zzzz"}],
+				"relatedProjects": [
+					{"projectKey": {"id": "github.com/other/thing"}, "relationType": "ISSUE_TRACKER"},
+					{"projectKey": {"id": "github.com/example/lib"}, "relationType": "SOURCE_REPO"}
+				]
+			}`))
+		case "/v3/projects/github.com%2Fexample%2Flib":
+			w.Write([]byte(`{"starsCount": 1200, "forksCount": 80, "scorecard": {"overallScore": 6.5}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, HTTPClient: srv.Client()}
+
+	s, err := c.Summarize("github.com/example/lib", "v1.2.3")
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if s.Project != "github.com/example/lib" {
+		t.Errorf("Project = %q", s.Project)
+	}
+	if s.Stars != 1200 || s.Forks != 80 {
+		t.Errorf("Stars, Forks = %d, %d", s.Stars, s.Forks)
+	}
+	if s.Scorecard == nil || *s.Scorecard != 6.5 {
+		t.Errorf("Scorecard = %v, want 6.5", s.Scorecard)
+	}
+	if len(s.Advisories) != 1 || s.Advisories[0] != "GHSA-xxxx-yyyy-zzzz" {
+		t.Errorf("Advisories = %v", s.Advisories)
+	}
+	if len(s.Licenses) != 1 || s.Licenses[0] != "MIT" {
+		t.Errorf("Licenses = %v", s.Licenses)
+	}
+
+	if _, err := c.Summarize("example.com/missing", "v0.1.0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Summarize() missing module error = %v, want ErrNotFound", err)
+	}
+}
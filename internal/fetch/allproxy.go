@@ -0,0 +1,95 @@
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// AllProxyFromEnv returns the ALL_PROXY (or all_proxy) environment variable,
+// the proxy curl and git use for any protocol without a more specific one.
+func AllProxyFromEnv() string {
+	if p := os.Getenv("ALL_PROXY"); p != "" {
+		return p
+	}
+	return os.Getenv("all_proxy")
+}
+
+// ParseAllProxy validates an all-proxy URL: socks5://, socks5h://, http://,
+// or https://, with a host.
+func ParseAllProxy(s string) (*url.URL, error) {
+	if s == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", s)
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h", "http", "https":
+		return u, nil
+	}
+	return nil, fmt.Errorf("invalid proxy URL %q: scheme must be socks5, socks5h, http, or https", s)
+}
+
+// proxyFunc returns the transport's Proxy function: HTTPS_PROXY, HTTP_PROXY
+// and NO_PROXY as usual, with allProxy for requests neither covers.
+func proxyFunc(allProxy string) func(*http.Request) (*url.URL, error) {
+	cfg := httpproxy.FromEnvironment()
+	if cfg.HTTPSProxy == "" {
+		cfg.HTTPSProxy = allProxy
+	}
+	if cfg.HTTPProxy == "" {
+		cfg.HTTPProxy = allProxy
+	}
+	proxy := cfg.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+}
+
+// command returns an exec.Cmd for a go or git subprocess that fetches
+// modules or refs, with Network.AllProxy passed on: as ALL_PROXY for git's
+// HTTPS transport, HTTPS_PROXY and HTTP_PROXY for the go command, which
+// ignores ALL_PROXY, and, for a SOCKS proxy, a GIT_SSH_COMMAND that tunnels
+// SSH remotes through it with nc. Variables already set are kept. With a
+// GitHubApp, git also sends an installation token to github.com.
+func (f *Fetcher) command(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	if app := f.githubApp(); app != nil {
+		env, err := app.gitAuthEnv()
+		if err != nil {
+			// Public modules don't need the token; private ones will fail
+			// with git's own error.
+			if f.Verbose {
+				fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+			}
+		} else {
+			cmd.Env = append(os.Environ(), env...)
+		}
+	}
+	u, err := ParseAllProxy(f.Network.allProxy())
+	if err != nil || u == nil {
+		return cmd
+	}
+
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	setenv := func(key, value string) {
+		if os.Getenv(key) == "" {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+	}
+	setenv("ALL_PROXY", u.String())
+	setenv("HTTPS_PROXY", u.String())
+	setenv("HTTP_PROXY", u.String())
+	if u.Scheme == "socks5" || u.Scheme == "socks5h" {
+		setenv("GIT_SSH_COMMAND", fmt.Sprintf("ssh -o ProxyCommand='nc -X 5 -x %s %%h %%p'", u.Host))
+	}
+	return cmd
+}
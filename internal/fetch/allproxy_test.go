@@ -0,0 +1,124 @@
+package fetch
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// socks5Server is a minimal SOCKS5 jump host without authentication. It
+// connects every CONNECT request to target and records the requested host.
+func socks5Server(t *testing.T, target string) (addr string, requested chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	requested = make(chan string, 10)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 262)
+				// Greeting: version, method count, methods.
+				if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+					return
+				}
+				io.ReadFull(conn, buf[:buf[1]])
+				conn.Write([]byte{5, 0})
+
+				// Request: version, CONNECT, reserved, address type.
+				if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+					return
+				}
+				var host string
+				switch buf[3] {
+				case 1:
+					io.ReadFull(conn, buf[:4])
+					host = net.IP(buf[:4]).String()
+				case 3:
+					io.ReadFull(conn, buf[:1])
+					n := int(buf[0])
+					io.ReadFull(conn, buf[:n])
+					host = string(buf[:n])
+				default:
+					return
+				}
+				io.ReadFull(conn, buf[:2])
+				requested <- net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(buf[:2]))))
+
+				upstream, err := net.Dial("tcp", target)
+				if err != nil {
+					conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer upstream.Close()
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return l.Addr().String(), requested
+}
+
+func TestAllProxySOCKS5(t *testing.T) {
+	for _, key := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy", "ALL_PROXY", "all_proxy"} {
+		t.Setenv(key, "")
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("private"))
+	}))
+	defer srv.Close()
+	proxyAddr, requested := socks5Server(t, srv.Listener.Addr().String())
+
+	f := &Fetcher{Network: Network{AllProxy: "socks5h://" + proxyAddr}}
+	resp, err := f.client("git.corp.example.com/lib", "").Get("http://git.corp.example.com/lib.zip")
+	if err != nil {
+		t.Fatalf("request through SOCKS5 proxy: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "private" {
+		t.Errorf("body = %q, want %q", body, "private")
+	}
+	// The jump host resolves the name, which the client can't.
+	if got := <-requested; got != "git.corp.example.com:80" {
+		t.Errorf("proxy asked for %q, want git.corp.example.com:80", got)
+	}
+}
+
+func TestAllProxyCommandEnv(t *testing.T) {
+	t.Setenv("GIT_SSH_COMMAND", "")
+	t.Setenv("HTTPS_PROXY", "http://explicit.example.com:3128")
+
+	f := &Fetcher{Network: Network{AllProxy: "socks5://bastion.example.com:1080"}}
+	env := strings.Join(f.command("git", "ls-remote").Env, "\n")
+	for _, want := range []string{
+		"ALL_PROXY=socks5://bastion.example.com:1080",
+		"HTTP_PROXY=socks5://bastion.example.com:1080",
+		"GIT_SSH_COMMAND=ssh -o ProxyCommand='nc -X 5 -x bastion.example.com:1080 %h %p'",
+	} {
+		if !strings.Contains(env, want) {
+			t.Errorf("command env lacks %s", want)
+		}
+	}
+	if strings.Contains(env, "HTTPS_PROXY=socks5") {
+		t.Error("command env overrides the explicit HTTPS_PROXY")
+	}
+
+	if _, err := ParseAllProxy("ftp://proxy.example.com"); err == nil {
+		t.Error("ParseAllProxy accepted an ftp:// URL")
+	}
+}
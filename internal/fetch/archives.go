@@ -0,0 +1,89 @@
+package fetch
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// repoArchive is a downloaded GitHub repository archive, possibly still in
+// flight, shared by every module fetched from it.
+type repoArchive struct {
+	done chan struct{}
+	path string
+	err  error
+}
+
+// archiveCache holds the repository archives downloaded by a Fetcher until
+// it is closed.
+type archiveCache struct {
+	mu    sync.Mutex
+	files map[string]*repoArchive
+}
+
+// archiveKey identifies the contents of the GitHub archive at downloadURL.
+// Multi-module repositories such as aws-sdk-go-v2 tag each module
+// separately, so modules released together have different archive URLs for
+// the same commit. With a full commit hash the archive is keyed by
+// repository and commit; their canonical module zips, and so the locked
+// hashes, don't depend on which of those URLs was downloaded. Otherwise the
+// raw archive is hashed, and only the same URL is known to give the same
+// bytes.
+func archiveKey(downloadURL, repoURL, rev string) string {
+	if len(rev) == 40 && repoURL != "" {
+		return strings.TrimSuffix(repoURL, ".git") + "@" + rev
+	}
+	return downloadURL
+}
+
+// repoArchive returns the GitHub or GitLab archive at downloadURL for
+// modulePath, downloading it only if no module fetched earlier has the same
+// key. It reports whether the archive was reused, in which case nothing was
+// downloaded. The file belongs to the Fetcher and is removed by Close.
+func (f *Fetcher) repoArchive(key, downloadURL, modulePath, version string) (path string, size int64, reused bool, err error) {
+	f.archives.mu.Lock()
+	if a, ok := f.archives.files[key]; ok {
+		f.archives.mu.Unlock()
+		<-a.done
+		if a.err == nil {
+			if f.Verbose {
+				fmt.Fprintf(os.Stderr, "Reusing repository archive %s for %s@%s\n", key, modulePath, version)
+			}
+			return a.path, 0, true, nil
+		}
+		// The download failed for the module that started it, and was
+		// forgotten; this module's own URL may still work.
+		return f.repoArchive(key, downloadURL, modulePath, version)
+	}
+	a := &repoArchive{done: make(chan struct{})}
+	if f.archives.files == nil {
+		f.archives.files = make(map[string]*repoArchive)
+	}
+	f.archives.files[key] = a
+	f.archives.mu.Unlock()
+
+	a.path, size, _, a.err = f.downloadFromURL(downloadURL, modulePath, version)
+	if a.err != nil {
+		f.archives.mu.Lock()
+		delete(f.archives.files, key)
+		f.archives.mu.Unlock()
+	}
+	close(a.done)
+	return a.path, size, false, a.err
+}
+
+// removeAll deletes every downloaded archive.
+func (c *archiveCache) removeAll() {
+	c.mu.Lock()
+	files := c.files
+	c.files = nil
+	c.mu.Unlock()
+
+	for _, a := range files {
+		<-a.done
+		if a.err == nil {
+			os.Remove(a.path)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestArchiveKey(t *testing.T) {
+	rev := strings.Repeat("a", 40)
+	if got, want := archiveKey("https://github.com/o/r/archive/refs/tags/a/v1.0.0.zip", "https://github.com/o/r.git", rev), "https://github.com/o/r@"+rev; got != want {
+		t.Errorf("archiveKey() with full rev = %q, want %q", got, want)
+	}
+	u := "https://github.com/o/r/archive/refs/tags/a/v1.0.0.zip"
+	if got := archiveKey(u, "https://github.com/o/r", "abc123"); got != u {
+		t.Errorf("archiveKey() with short rev = %q, want the URL", got)
+	}
+}
+
+func TestRepoArchiveShared(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if strings.Contains(r.URL.Path, "missing") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("archive"))
+	}))
+	defer srv.Close()
+
+	f := &Fetcher{}
+	key := "https://github.com/o/r@" + strings.Repeat("a", 40)
+
+	// Modules tagged separately at the same commit share one download.
+	path, size, reused, err := f.repoArchive(key, srv.URL+"/service/a/v1.0.0.zip", "github.com/o/r/service/a", "v1.0.0")
+	if err != nil || reused || size != 7 {
+		t.Fatalf("first repoArchive() = %d, %v, %v; want a 7-byte download", size, reused, err)
+	}
+	path2, size, reused, err := f.repoArchive(key, srv.URL+"/service/b/v1.2.0.zip", "github.com/o/r/service/b", "v1.2.0")
+	if err != nil || !reused || size != 0 || path2 != path {
+		t.Errorf("second repoArchive() = %q, %d, %v, %v; want the first archive reused", path2, size, reused, err)
+	}
+
+	// A failed download is not remembered.
+	if _, _, _, err := f.repoArchive("other", srv.URL+"/missing.zip", "github.com/o/other", "v1.0.0"); err == nil {
+		t.Error("repoArchive() for a missing archive succeeded")
+	}
+	if _, _, reused, err := f.repoArchive("other", srv.URL+"/other.zip", "github.com/o/other", "v1.0.0"); err != nil || reused {
+		t.Errorf("repoArchive() after a failure = %v, %v; want a fresh download", reused, err)
+	}
+
+	if len(requests) != 3 {
+		t.Errorf("requests = %q, want 3", requests)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("archive %s still exists after Close", path)
+	}
+}
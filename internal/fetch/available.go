@@ -0,0 +1,94 @@
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// CheckURL reports the HTTP status of rawURL, a locked download URL of
+// modulePath, without downloading it. Requests are made exactly as Fetch
+// makes them: private GitHub archives through the GitHub API, with netrc
+// credentials. Servers that reject HEAD are asked with a GET whose body is
+// discarded unread.
+func (f *Fetcher) CheckURL(modulePath, rawURL string) (int, error) {
+	actualURL := f.requestURL(modulePath, rawURL)
+	client := f.client(modulePath, actualURL)
+
+	status, err := requestStatus(client, http.MethodHead, actualURL)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = requestStatus(client, http.MethodGet, actualURL)
+	}
+	return status, err
+}
+
+// Gone reports whether a CheckURL status means the file no longer exists,
+// as opposed to the server being unreachable or refusing the request.
+func Gone(status int) bool {
+	return status == http.StatusNotFound || status == http.StatusGone
+}
+
+func requestStatus(client *http.Client, method, rawURL string) (int, error) {
+	req, err := http.NewRequest(method, rawURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// Sources returns the URLs m is downloaded from, in fallback order. An empty
+// locked URL resolves to the URL Fetch would use.
+func (f *Fetcher) Sources(m Locked) []string {
+	if len(m.URLs) == 0 && m.URL == "" {
+		return []string{f.getDownloadURL(m.Path, m.Version)}
+	}
+	return m.sources()
+}
+
+// Rehome finds m on the module proxy, for lockfile entries whose own source
+// has disappeared, and returns its proxy URL and the hash of the proxy zip.
+// The canonical proxy for m.Path is used, or DefaultProxy when modules are
+// fetched directly. The zip must match h1, the go.sum hash, or, when h1 is
+// empty, the locked hash. Private modules are never rehomed.
+func (f *Fetcher) Rehome(m Locked, h1 string) (string, string, error) {
+	if f.isPrivate(m.Path) {
+		return "", "", fmt.Errorf("%s is private", m.Path)
+	}
+	base := f.proxyBase(m.Path)
+	if base == "" {
+		base = DefaultProxy
+	}
+	rawURL := proxyZipURL(base, m.Path, m.Version)
+
+	zipPath, _, _, err := f.downloadFromURL(rawURL, m.Path, m.Version)
+	if err != nil {
+		return "", "", fmt.Errorf("downloading %s@%s from the proxy: %w", m.Path, m.Version, err)
+	}
+	defer os.Remove(zipPath)
+
+	got, err := computeZipHash(zipPath)
+	if err != nil {
+		return "", "", fmt.Errorf("computing zip hash: %w", err)
+	}
+	if h1 != "" {
+		sum, err := dirhash.HashZip(zipPath, dirhash.Hash1)
+		if err != nil {
+			return "", "", fmt.Errorf("hashing proxy zip: %w", err)
+		}
+		if sum != h1 {
+			return "", "", fmt.Errorf("proxy zip for %s@%s does not match go.sum: got %s, want %s", m.Path, m.Version, sum, h1)
+		}
+	} else if got != m.Hash {
+		return "", "", &HashMismatchError{Path: m.Path, Version: m.Version, Want: m.Hash, Got: got}
+	}
+	return rawURL, got, nil
+}
@@ -0,0 +1,61 @@
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckURL(t *testing.T) {
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method+" "+r.URL.Path)
+		switch {
+		case r.URL.Path == "/gone.zip":
+			http.Error(w, "gone", http.StatusGone)
+		case r.URL.Path == "/nohead.zip" && r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		default:
+			w.Write([]byte("zip"))
+		}
+	}))
+	defer srv.Close()
+
+	f := &Fetcher{}
+	tests := []struct {
+		path     string
+		want     int
+		wantGone bool
+	}{
+		{"/ok.zip", http.StatusOK, false},
+		{"/gone.zip", http.StatusGone, true},
+		{"/nohead.zip", http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		status, err := f.CheckURL("example.com/mod", srv.URL+tt.path)
+		if err != nil {
+			t.Fatalf("CheckURL(%s) error = %v", tt.path, err)
+		}
+		if status != tt.want || Gone(status) != tt.wantGone {
+			t.Errorf("CheckURL(%s) = %d (gone %v), want %d (gone %v)", tt.path, status, Gone(status), tt.want, tt.wantGone)
+		}
+	}
+	if n := len(methods); n != 4 || methods[3] != "GET /nohead.zip" {
+		t.Errorf("requests = %q, want HEADs and a GET fallback for /nohead.zip", methods)
+	}
+
+	if _, err := f.CheckURL("example.com/mod", "http://127.0.0.1:0/x.zip"); err == nil {
+		t.Error("CheckURL() of an unreachable server succeeded")
+	}
+}
+
+func TestSources(t *testing.T) {
+	f := &Fetcher{Proxy: DefaultProxy}
+	if got := f.Sources(Locked{Path: "golang.org/x/mod", Version: "v0.32.0"}); len(got) != 1 || got[0] != "https://proxy.golang.org/golang.org/x/mod/@v/v0.32.0.zip" {
+		t.Errorf("Sources() without a locked URL = %q", got)
+	}
+	urls := []string{"https://a.example.com/m.zip", "https://b.example.com/m.zip"}
+	if got := f.Sources(Locked{URL: urls[0], URLs: urls}); len(got) != 2 {
+		t.Errorf("Sources() = %q, want %q", got, urls)
+	}
+}
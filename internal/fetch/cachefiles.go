@@ -0,0 +1,158 @@
+package fetch
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/anthr76/nopher/internal/hash"
+)
+
+// DefaultCachePerm is the permission of cache files when Fetcher.CachePerm
+// is zero: only the owner can read or replace them.
+const DefaultCachePerm os.FileMode = 0o600
+
+// ParseCachePerm parses an octal cache file permission such as "0640". The
+// owner must be able to read and write.
+func ParseCachePerm(s string) (os.FileMode, error) {
+	n, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || n > 0o777 {
+		return 0, fmt.Errorf("invalid cache permission %q: want octal such as 0600", s)
+	}
+	perm := os.FileMode(n)
+	if perm&0o600 != 0o600 {
+		return 0, fmt.Errorf("invalid cache permission %q: the owner needs read and write", s)
+	}
+	return perm, nil
+}
+
+// ErrNotCached is returned by Fetch for modules missing from a read-only
+// cache (see Fetcher.ReadOnlyCache).
+var ErrNotCached = errors.New("not in the read-only cache")
+
+// errCacheReadOnly is returned by cacheFiles.writeFile for a read-only cache.
+var errCacheReadOnly = errors.New("cache is read-only")
+
+// cacheFiles writes cache files with the configured permissions and
+// authenticates cache entries when a key is set.
+type cacheFiles struct {
+	perm     os.FileMode // Zero means DefaultCachePerm
+	key      []byte      // Empty disables MACs
+	readOnly bool        // Writes fail with errCacheReadOnly
+}
+
+func (f *Fetcher) cacheFiles() cacheFiles {
+	return cacheFiles{perm: f.CachePerm, key: f.CacheKey, readOnly: f.ReadOnlyCache}
+}
+
+// filePerm is the permission of regular cache files.
+func (c cacheFiles) filePerm() os.FileMode {
+	if c.perm == 0 {
+		return DefaultCachePerm
+	}
+	return c.perm.Perm()
+}
+
+// dirPerm is the permission of cache directories and executable files:
+// filePerm with search (execute) permission wherever it grants read.
+func (c cacheFiles) dirPerm() os.FileMode {
+	return searchable(c.filePerm())
+}
+
+// searchable adds execute permission to perm wherever it grants read.
+func searchable(perm os.FileMode) os.FileMode {
+	return perm | (perm&0o444)>>2
+}
+
+// writeFile atomically replaces path with data, creating its directory.
+func (c cacheFiles) writeFile(path string, data []byte) error {
+	if c.readOnly {
+		return errCacheReadOnly
+	}
+	if err := os.MkdirAll(filepath.Dir(path), c.dirPerm()); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(c.filePerm())
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// mac returns the hex HMAC-SHA256 of fields under the cache key, or "" if
+// there is no key. Fields are length-prefixed, so their boundaries count.
+func (c cacheFiles) mac(fields ...string) string {
+	if len(c.key) == 0 {
+		return ""
+	}
+	h := hmac.New(sha256.New, c.key)
+	for _, field := range fields {
+		fmt.Fprintf(h, "%d:%s", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// verify reports whether mac authenticates fields. Without a key every
+// entry is accepted.
+func (c cacheFiles) verify(mac string, fields ...string) bool {
+	if len(c.key) == 0 {
+		return true
+	}
+	return hmac.Equal([]byte(mac), []byte(c.mac(fields...)))
+}
+
+// entryMAC returns the MAC of a cached module: its recorded metadata and
+// the NAR hash of its extracted tree.
+func (f *Fetcher) entryMAC(r *FetchResult) (string, error) {
+	tree, err := hash.GoNARHash(r.Dir)
+	if err != nil {
+		return "", err
+	}
+	return f.cacheFiles().mac("module", r.ModulePath, r.Version, r.Hash, r.URL, strings.Join(r.URLs, "\n"), r.Rev, r.Subdir, tree), nil
+}
+
+// seal records the MAC of a freshly fetched module next to it, if there is
+// a cache key.
+func (f *Fetcher) seal(r *FetchResult) error {
+	if len(f.CacheKey) == 0 {
+		return nil
+	}
+	mac, err := f.entryMAC(r)
+	if err != nil {
+		return err
+	}
+	return f.cacheFiles().writeFile(r.Dir+".mac", []byte(mac))
+}
+
+// authentic reports whether a cached module matches its recorded MAC.
+// Without a cache key every entry is.
+func (f *Fetcher) authentic(r *FetchResult) bool {
+	if len(f.CacheKey) == 0 {
+		return true
+	}
+	recorded, err := os.ReadFile(r.Dir + ".mac")
+	if err != nil {
+		return false
+	}
+	mac, err := f.entryMAC(r)
+	return err == nil && hmac.Equal(recorded, []byte(mac))
+}
@@ -0,0 +1,145 @@
+package fetch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCachePerm(t *testing.T) {
+	for in, want := range map[string]os.FileMode{"0600": 0o600, "640": 0o640, "0660": 0o660} {
+		if got, err := ParseCachePerm(in); err != nil || got != want {
+			t.Errorf("ParseCachePerm(%q) = %04o, %v; want %04o", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "rw", "0999", "01777", "0400", "0044"} {
+		if _, err := ParseCachePerm(in); err == nil {
+			t.Errorf("ParseCachePerm(%q) should fail", in)
+		}
+	}
+}
+
+func TestCacheFilesPerm(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		perm, file, dir os.FileMode
+	}{
+		{0, 0o600, 0o700},
+		{0o640, 0o640, 0o750},
+	} {
+		files := cacheFiles{perm: tt.perm}
+		path := filepath.Join(dir, tt.perm.String(), "entry")
+		if err := files.writeFile(path, []byte("data")); err != nil {
+			t.Fatal(err)
+		}
+		for p, want := range map[string]os.FileMode{path: tt.file, filepath.Dir(path): tt.dir} {
+			info, err := os.Stat(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode().Perm(); got != want {
+				t.Errorf("perm %04o: %s mode = %04o, want %04o", tt.perm, p, got, want)
+			}
+		}
+	}
+}
+
+func TestCacheIntegrity(t *testing.T) {
+	cacheDir := t.TempDir()
+	f := &Fetcher{CacheDir: cacheDir, CacheKey: []byte("secret")}
+	r := &FetchResult{ModulePath: "example.com/mod", Version: "v1.0.0", Dir: f.cachedDir("example.com/mod", "v1.0.0"), Hash: "sha256-AAAA", URL: "https://proxy.example/mod.zip"}
+	if err := os.MkdirAll(r.Dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	goFile := filepath.Join(r.Dir, "mod.go")
+	if err := os.WriteFile(goFile, []byte("package mod\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if !(&Fetcher{}).authentic(r) {
+		t.Error("entries are authentic without a cache key")
+	}
+	if f.authentic(r) {
+		t.Error("an entry without a MAC is authentic")
+	}
+	if err := f.seal(r); err != nil {
+		t.Fatal(err)
+	}
+	if !f.authentic(r) {
+		t.Error("a sealed entry is not authentic")
+	}
+	if other := (&Fetcher{CacheDir: cacheDir, CacheKey: []byte("other")}); other.authentic(r) {
+		t.Error("an entry sealed with another key is authentic")
+	}
+
+	tampered := *r
+	tampered.Rev = "0123456789abcdef0123456789abcdef01234567"
+	if f.authentic(&tampered) {
+		t.Error("an entry with tampered metadata is authentic")
+	}
+	if err := os.WriteFile(goFile, []byte("package mod\n\nfunc init() { panic(1) }\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if f.authentic(r) {
+		t.Error("an entry with a tampered tree is authentic")
+	}
+}
+
+func TestMetaCacheIntegrity(t *testing.T) {
+	f := &Fetcher{CacheDir: t.TempDir(), CacheKey: []byte("secret")}
+	const rawURL = "https://proxy.example/example.com/mod/@v/list"
+	_, path := f.loadMeta(rawURL)
+	f.storeMeta(path, rawURL, []byte("v1.0.0\n"))
+	if entry, _ := f.loadMeta(rawURL); entry == nil || entry.Body != "v1.0.0\n" {
+		t.Fatalf("loadMeta() = %+v", entry)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Replace(string(data), "v1.0.0", "v9.9.9", 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := f.loadMeta(rawURL); entry != nil {
+		t.Errorf("loadMeta() of a tampered entry = %+v, want nil", entry)
+	}
+}
+
+func TestReadOnlyCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	f := &Fetcher{CacheDir: cacheDir, Proxy: "http://127.0.0.1:1", ReadOnlyCache: true}
+	seeded := f.cachedDir("example.com/seeded", "v1.0.0")
+	if err := os.MkdirAll(seeded, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(seeded+".hash", []byte("sha256-AAAA"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if r, err := f.Fetch("example.com/seeded", "v1.0.0"); err != nil || !r.CacheHit || r.Hash != "sha256-AAAA" {
+		t.Errorf("Fetch(seeded) = %+v, %v; want a cache hit", r, err)
+	}
+	if _, err := f.Fetch("example.com/missing", "v1.0.0"); !errors.Is(err, ErrNotCached) {
+		t.Errorf("Fetch(missing) error = %v, want ErrNotCached", err)
+	}
+
+	// An entry failing its integrity check is reported, not refetched or
+	// removed.
+	f.CacheKey = []byte("secret")
+	if _, err := f.Fetch("example.com/seeded", "v1.0.0"); !errors.Is(err, ErrNotCached) {
+		t.Errorf("Fetch(unsealed) error = %v, want ErrNotCached", err)
+	}
+	if _, err := os.Stat(seeded + ".hash"); err != nil {
+		t.Errorf("unsealed entry was removed: %v", err)
+	}
+
+	const rawURL = "https://proxy.example/example.com/mod/@v/list"
+	_, path := f.loadMeta(rawURL)
+	f.storeMeta(path, rawURL, []byte("v1.0.0\n"))
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("storeMeta wrote to a read-only cache: %v", err)
+	}
+}
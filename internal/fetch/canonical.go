@@ -0,0 +1,119 @@
+package fetch
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anthr76/nopher/internal/hash"
+	"golang.org/x/mod/module"
+	modzip "golang.org/x/mod/zip"
+)
+
+// canonicalModuleZip rebuilds a GitHub repository archive as a module zip
+// following the same rules the go command uses for proxy zips: files live
+// under modulePath@version/, nested modules and vendor directories are
+// excluded, and a module in a subdirectory inherits the repository root
+// LICENSE when it has none of its own.
+//
+// The returned path is a temporary file that the caller must remove.
+func (f *Fetcher) canonicalModuleZip(archivePath, modulePath, version, subdir string) (string, error) {
+	tmpDir, err := os.MkdirTemp("", "nopher-module-*")
+	if err != nil {
+		return "", fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	modDir := filepath.Join(tmpDir, "module")
+	if err := f.extract(archivePath, modDir, modulePath, version, subdir, 0o644); err != nil {
+		return "", err
+	}
+
+	if subdir != "" {
+		if err := copyRootLicense(archivePath, modDir); err != nil {
+			return "", err
+		}
+	}
+
+	out, err := os.CreateTemp("", "nopher-canonical-*.zip")
+	if err != nil {
+		return "", fmt.Errorf("creating temp file: %w", err)
+	}
+
+	err = modzip.CreateFromDir(out, module.Version{Path: modulePath, Version: version}, modDir)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("creating module zip: %w", err)
+	}
+
+	return out.Name(), nil
+}
+
+// copyRootLicense copies the LICENSE file at the root of a repository archive
+// into modDir, unless the module already has one.
+func copyRootLicense(archivePath, modDir string) error {
+	target := filepath.Join(modDir, "LICENSE")
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	}
+
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("opening zip: %w", err)
+	}
+	defer r.Close()
+
+	for _, file := range r.File {
+		_, name, _ := strings.Cut(file.Name, "/")
+		if name != "LICENSE" || !file.Mode().IsRegular() {
+			continue
+		}
+
+		src, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("opening LICENSE: %w", err)
+		}
+		defer src.Close()
+
+		dst, err := os.Create(target)
+		if err != nil {
+			return fmt.Errorf("creating LICENSE: %w", err)
+		}
+		if _, err := io.Copy(dst, src); err != nil {
+			dst.Close()
+			return fmt.Errorf("copying LICENSE: %w", err)
+		}
+		return dst.Close()
+	}
+
+	return nil
+}
+
+// HashNAR is the HashType of a hash computed by archiveNARHash, matching
+// lockfile.HashNAR.
+const HashNAR = "nar"
+
+// archiveNARHash computes the NAR hash of the tree in a repository archive,
+// below its single top-level directory, as Nix's fetchzip hashes it. Unlike
+// the archive's own bytes, the NAR serialization sorts entries and records
+// no timestamps, owners, or permissions beyond the executable bit, so the
+// hash stays the same when a forge regenerates the archive with new
+// metadata.
+func archiveNARHash(archivePath string) (string, error) {
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("opening zip: %w", err)
+	}
+	root, err := hash.ArchiveRoot(&r.Reader)
+	r.Close()
+	if err != nil {
+		return "", err
+	}
+	return hash.ZipNARHash(archivePath, root)
+}
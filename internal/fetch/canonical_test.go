@@ -0,0 +1,155 @@
+package fetch
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestZip writes a zip archive containing the given files.
+func writeTestZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+
+	zf, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zf.Close()
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	zw := zip.NewWriter(zf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCanonicalModuleZip(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "archive.zip")
+	writeTestZip(t, archive, map[string]string{
+		"repo-1.2.0/LICENSE":                      "license\n",
+		"repo-1.2.0/go.mod":                       "module github.com/owner/repo\n",
+		"repo-1.2.0/root.go":                      "package repo\n",
+		"repo-1.2.0/sub/go.mod":                   "module github.com/owner/repo/sub\n",
+		"repo-1.2.0/sub/a.go":                     "package sub\n",
+		"repo-1.2.0/sub/vendor/x/x.go":            "package x\n",
+		"repo-1.2.0/sub/nested/go.mod":            "module github.com/owner/repo/sub/nested\n",
+		"repo-1.2.0/sub/nested/n.go":              "package nested\n",
+		"repo-1.2.0/sub/internal/testdata/t.json": "{}\n",
+	})
+
+	f := &Fetcher{}
+	canonical, err := f.canonicalModuleZip(archive, "github.com/owner/repo/sub", "v1.2.0", "sub")
+	if err != nil {
+		t.Fatalf("canonicalModuleZip: %v", err)
+	}
+	defer os.Remove(canonical)
+
+	r, err := zip.OpenReader(canonical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var got []string
+	for _, file := range r.File {
+		got = append(got, file.Name)
+	}
+	sort.Strings(got)
+
+	prefix := "github.com/owner/repo/sub@v1.2.0/"
+	want := []string{
+		prefix + "LICENSE",
+		prefix + "a.go",
+		prefix + "go.mod",
+		prefix + "internal/testdata/t.json",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("canonical zip entries = %v, want %v", got, want)
+	}
+}
+
+func TestArchiveNARHash(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"repo-v1.0.0/go.mod":     "module github.com/owner/repo\n",
+		"repo-v1.0.0/a.go":       "package repo\n",
+		"repo-v1.0.0/sub/b.go":   "package sub\n",
+		"repo-v1.0.0/sub/README": "sub\n",
+	}
+	original := filepath.Join(dir, "original.zip")
+	writeTestZip(t, original, files)
+
+	// The same files regenerated in another order, with directory entries,
+	// timestamps, and a comment.
+	regenerated := filepath.Join(dir, "regenerated.zip")
+	out, err := os.Create(regenerated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(out)
+	for _, name := range []string{"repo-v1.0.0/", "repo-v1.0.0/sub/", "repo-v1.0.0/sub/b.go", "repo-v1.0.0/sub/README", "repo-v1.0.0/go.mod", "repo-v1.0.0/a.go"} {
+		h := &zip.FileHeader{Name: name, Method: zip.Store, Modified: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+		if strings.HasSuffix(name, "/") {
+			h.SetMode(os.ModeDir | 0o755)
+		}
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(files[name]))
+	}
+	zw.SetComment("0123456789abcdef0123456789abcdef01234567")
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+
+	rawA, _ := computeZipHash(original)
+	rawB, _ := computeZipHash(regenerated)
+	if rawA == rawB {
+		t.Fatal("test archives should differ in their raw bytes")
+	}
+	narA, err := archiveNARHash(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	narB, err := archiveNARHash(regenerated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if narA != narB {
+		t.Errorf("NAR hash changed with archive metadata: %s != %s", narA, narB)
+	}
+
+	files["repo-v1.0.0/a.go"] = "package repo\r\n"
+	changed := filepath.Join(dir, "changed.zip")
+	writeTestZip(t, changed, files)
+	if narC, err := archiveNARHash(changed); err != nil || narC == narA {
+		t.Errorf("archiveNARHash() = %s, %v after a content change", narC, err)
+	}
+
+	mixed := filepath.Join(dir, "mixed.zip")
+	writeTestZip(t, mixed, map[string]string{"a/go.mod": "module a\n", "b/go.mod": "module b\n"})
+	if _, err := archiveNARHash(mixed); err == nil {
+		t.Error("archiveNARHash() of an archive with two top-level directories should fail")
+	}
+}
@@ -0,0 +1,108 @@
+package fetch
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/anthr76/nopher/internal/hash"
+)
+
+// contentStore is the directory of CacheDir holding one copy of every
+// distinct extracted module tree, named by the tree's NAR hash. The go.sum
+// h1 hash can't serve as the key, since it covers the module path and
+// version as well as the files.
+const contentStore = "content"
+
+// dedupe stores the files of dir, a freshly extracted module, once per
+// distinct tree: the first tree with a given hash is hard linked into the
+// content store, and later identical trees, such as forks and modules moved
+// to a new path, are replaced with hard links to that copy. Extracted trees
+// hold only directories and regular files with normalized modes, so the NAR
+// hash (contents and executable bits) identifies them fully.
+func (f *Fetcher) dedupe(dir string) error {
+	sri, err := hash.GoNARHash(dir)
+	if err != nil {
+		return err
+	}
+	_, sum, err := hash.ParseSRI(sri)
+	if err != nil {
+		return err
+	}
+	store := filepath.Join(f.CacheDir, contentStore)
+	stored := filepath.Join(store, hash.NixBase32(sum))
+	perm := f.cacheFiles().dirPerm()
+
+	if err := os.MkdirAll(store, perm); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(store, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := os.Chmod(tmp, perm); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(stored); err == nil && len(f.CacheKey) > 0 {
+		// Files in a shared cache may have been modified in place; a
+		// tampered copy is replaced by this one. Trees already linked to
+		// it fail their own integrity checks.
+		if got, err := hash.GoNARHash(stored); err != nil || got != sri {
+			os.RemoveAll(stored)
+		}
+	}
+	if _, err := os.Stat(stored); err != nil {
+		if err := linkTree(dir, tmp, perm); err != nil {
+			return err
+		}
+		// Another fetch may have stored the same tree meanwhile; either
+		// copy will do.
+		os.Rename(tmp, stored)
+		return nil
+	}
+
+	links := filepath.Join(tmp, "tree")
+	if err := os.Mkdir(links, perm); err != nil {
+		return err
+	}
+	if err := linkTree(stored, links, perm); err != nil {
+		return err
+	}
+	// Swap the trees, keeping the extracted copy until the links are in
+	// place; it is removed with tmp.
+	extracted := filepath.Join(tmp, "extracted")
+	if err := os.Rename(dir, extracted); err != nil {
+		return err
+	}
+	if err := os.Rename(links, dir); err != nil {
+		os.Rename(extracted, dir)
+		return err
+	}
+	return nil
+}
+
+// linkTree recreates the directories under src in dst, which must exist,
+// with permission perm, and hard links every file.
+func linkTree(src, dst string, perm os.FileMode) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == src {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			return os.Mkdir(target, perm)
+		case d.Type().IsRegular():
+			return os.Link(p, target)
+		default:
+			return fmt.Errorf("%s is not a regular file", p)
+		}
+	})
+}
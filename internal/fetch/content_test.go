@@ -0,0 +1,61 @@
+package fetch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDedupe(t *testing.T) {
+	f := &Fetcher{CacheDir: t.TempDir()}
+	tree := func(name string, mode os.FileMode) string {
+		t.Helper()
+		dir := filepath.Join(f.CacheDir, name)
+		if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/mod\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "sub", "run.sh"), []byte("#!/bin/sh\n"), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.dedupe(dir); err != nil {
+			t.Fatalf("dedupe(%s) error = %v", name, err)
+		}
+		return dir
+	}
+	same := func(a, b string) bool {
+		t.Helper()
+		ia, err := os.Stat(filepath.Join(a, "sub", "run.sh"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ib, err := os.Stat(filepath.Join(b, "sub", "run.sh"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return os.SameFile(ia, ib)
+	}
+
+	original := tree("example.com/mod@v1.0.0", 0o755)
+	fork := tree("example.com/fork@v1.0.0", 0o755)
+	other := tree("example.com/other@v1.0.0", 0o644)
+
+	if !same(original, fork) {
+		t.Error("identical trees should share files")
+	}
+	if same(original, other) {
+		t.Error("trees differing in executable bits should not share files")
+	}
+	if data, err := os.ReadFile(filepath.Join(fork, "go.mod")); err != nil || string(data) != "module example.com/mod\n" {
+		t.Errorf("deduplicated go.mod = %q, %v", data, err)
+	}
+	entries, err := os.ReadDir(filepath.Join(f.CacheDir, contentStore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("content store has %d entries, want 2", len(entries))
+	}
+}
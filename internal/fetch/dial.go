@@ -0,0 +1,125 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Network configures how a Fetcher connects to servers. The zero value uses
+// the system resolver and Go's default dual-stack dialing.
+type Network struct {
+	// Resolve maps host names to the IP address to connect to instead of
+	// resolving them, as curl's --resolve does, for split-horizon DNS.
+	// TLS certificates are still checked against the host name.
+	Resolve map[string]string
+	// IPFamily restricts connections to IPFamily4 or IPFamily6. Empty dials
+	// both address families.
+	IPFamily string
+	// FallbackDelay is how long a dual-stack dial waits on the preferred
+	// address family before racing the other one (Happy Eyeballs, RFC
+	// 6555). Zero uses Go's default of 300ms; negative disables the race.
+	FallbackDelay time.Duration
+	// AllProxy is the proxy for requests HTTPS_PROXY and HTTP_PROXY don't
+	// cover, typically a SOCKS5 jump host (socks5://host:port) in front of
+	// private servers. It is also passed to go and git subprocesses. Empty
+	// uses ALL_PROXY from the environment.
+	AllProxy string
+}
+
+// allProxy returns AllProxy, defaulting to the environment.
+func (n Network) allProxy() string {
+	if n.AllProxy != "" {
+		return n.AllProxy
+	}
+	return AllProxyFromEnv()
+}
+
+const (
+	// IPFamily4 restricts connections to IPv4.
+	IPFamily4 = "4"
+	// IPFamily6 restricts connections to IPv6.
+	IPFamily6 = "6"
+)
+
+// ParseResolve parses --resolve entries of the form host:ip. The IP may be
+// an IPv6 address, with or without brackets.
+func ParseResolve(entries []string) (map[string]string, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	resolve := make(map[string]string, len(entries))
+	for _, e := range entries {
+		host, addr, ok := strings.Cut(e, ":")
+		addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+		if !ok || host == "" || net.ParseIP(addr) == nil {
+			return nil, fmt.Errorf("invalid resolve entry %q: want host:ip", e)
+		}
+		resolve[normalizeHost(host)] = addr
+	}
+	return resolve, nil
+}
+
+// ParseIPFamily validates an IP family setting: "", "4", or "6".
+func ParseIPFamily(s string) (string, error) {
+	switch s {
+	case "", IPFamily4, IPFamily6:
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid IP family %q: want 4 or 6", s)
+}
+
+// baseTransport returns the transport shared by every fetcher request. It
+// is http.DefaultTransport unless Network changes how connections are
+// dialed or proxied; it is built once so connections are pooled across
+// requests. An invalid AllProxy is ignored; callers validate it with
+// ParseAllProxy.
+func (f *Fetcher) baseTransport() http.RoundTripper {
+	f.dialOnce.Do(func() {
+		n := f.Network
+		allProxy, _ := ParseAllProxy(n.allProxy())
+		if len(n.Resolve) == 0 && n.IPFamily == "" && n.FallbackDelay == 0 && allProxy == nil {
+			f.base = http.DefaultTransport
+			return
+		}
+
+		dialer := &net.Dialer{
+			Timeout:       30 * time.Second,
+			KeepAlive:     30 * time.Second,
+			FallbackDelay: n.FallbackDelay,
+		}
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		if allProxy != nil {
+			t.Proxy = proxyFunc(allProxy.String())
+		}
+		t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, dialNetwork(network, n.IPFamily), resolveAddr(n.Resolve, addr))
+		}
+		f.base = t
+	})
+	return f.base
+}
+
+// dialNetwork narrows a "tcp" network to the configured IP family.
+func dialNetwork(network, family string) string {
+	if network == "tcp" && family != "" {
+		return "tcp" + family
+	}
+	return network
+}
+
+// resolveAddr replaces the host of addr (host:port) with its entry in
+// resolve, if any.
+func resolveAddr(resolve map[string]string, addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if ip, ok := resolve[normalizeHost(host)]; ok {
+		return net.JoinHostPort(ip, port)
+	}
+	return addr
+}
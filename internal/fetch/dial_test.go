@@ -0,0 +1,56 @@
+package fetch
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseResolve(t *testing.T) {
+	got, err := ParseResolve([]string{"Proxy.Example.com:10.0.0.5", "v6.example.com:[2001:db8::1]", "raw6.example.com:2001:db8::2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"proxy.example.com": "10.0.0.5",
+		"v6.example.com":    "2001:db8::1",
+		"raw6.example.com":  "2001:db8::2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseResolve() = %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"proxy.example.com", ":10.0.0.5", "proxy.example.com:not-an-ip"} {
+		if _, err := ParseResolve([]string{bad}); err == nil {
+			t.Errorf("ParseResolve(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestNetworkResolve(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	f := &Fetcher{Network: Network{Resolve: map[string]string{"registry.invalid": "127.0.0.1"}}}
+	resp, err := f.client("example.com/mod", "").Get("http://registry.invalid:" + port + "/")
+	if err != nil {
+		t.Fatalf("request through resolve override: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if want := "registry.invalid:" + port; string(body) != want {
+		t.Errorf("Host = %q, want %q", body, want)
+	}
+
+	// An IPv6-only fetcher can't reach the IPv4 address.
+	f = &Fetcher{Network: Network{Resolve: map[string]string{"registry.invalid": "127.0.0.1"}, IPFamily: IPFamily6}}
+	if _, err := f.client("example.com/mod", "").Get("http://registry.invalid:" + port + "/"); err == nil {
+		t.Error("IPv6-only request to an IPv4 address succeeded")
+	}
+}
@@ -0,0 +1,102 @@
+package fetch
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anthr76/nopher/internal/hash"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// ErrNotInDump is returned by FetchFromDump when the dump has no zip for the
+// module version.
+var ErrNotInDump = errors.New("module not in proxy dump")
+
+// FetchFromDump resolves a module's lockfile hash from a GOPROXY storage
+// dump in the Athens disk layout, <dir>/<module>/<version>/source.zip,
+// without any network access. Module paths are looked up both as written
+// and case-escaped, since Athens versions differ in which they store.
+//
+// The zip must match h1, its go.sum hash; a missing hash is an error rather
+// than a reason to trust the dump. The zip is what the proxy served, so the
+// lockfile records the module's proxy URL; private modules, which are
+// fetched from their origin, cannot be locked from a dump.
+func (f *Fetcher) FetchFromDump(dir, modulePath, version, h1 string) (*FetchResult, error) {
+	if f.proxyBase(modulePath) == "" || f.isPrivate(modulePath) {
+		return nil, fmt.Errorf("%s@%s: only modules fetched through a proxy can be locked from a proxy dump", modulePath, version)
+	}
+	if !strings.HasPrefix(h1, "h1:") {
+		return nil, fmt.Errorf("%s@%s: no go.sum hash to verify the dump against", modulePath, version)
+	}
+
+	versionDir, err := dumpDir(dir, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	zipPath := filepath.Join(versionDir, "source.zip")
+
+	got, err := dirhash.HashZip(zipPath, dirhash.Hash1)
+	if err != nil {
+		return nil, fmt.Errorf("hashing dump zip: %w", err)
+	}
+	if got != h1 {
+		return nil, fmt.Errorf("dump zip for %s@%s does not match go.sum: got %s, want %s", modulePath, version, got, h1)
+	}
+
+	data, err := os.ReadFile(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading dump zip: %w", err)
+	}
+	sum := sha256.Sum256(data)
+
+	var size int64
+	var files int
+	if zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data))); err == nil {
+		for _, zf := range zr.File {
+			if zf.Mode().IsRegular() {
+				size += int64(zf.UncompressedSize64)
+				files++
+			}
+		}
+	}
+
+	if f.Verbose {
+		fmt.Fprintf(os.Stderr, "Using proxy dump for %s@%s\n", modulePath, version)
+	}
+
+	downloadURL := f.proxyURL(modulePath, version, ".zip")
+	return &FetchResult{
+		ModulePath: modulePath,
+		Version:    version,
+		Hash:       hash.ToSRI(sum[:]),
+		URL:        downloadURL,
+		URLs:       f.sourceURLs(modulePath, downloadURL, ""),
+		Replaces:   declaredReplaces(filepath.Join(versionDir, "go.mod")),
+		CacheHit:   true,
+		Size:       size,
+		Files:      files,
+	}, nil
+}
+
+// dumpDir returns the directory holding a module version in an Athens disk
+// dump, trying the module path as written and then case-escaped.
+func dumpDir(dir, modulePath, version string) (string, error) {
+	escPath, err := module.EscapePath(modulePath)
+	if err != nil {
+		return "", fmt.Errorf("escaping module path: %w", err)
+	}
+	for _, p := range []string{modulePath, escPath} {
+		versionDir := filepath.Join(dir, filepath.FromSlash(p), version)
+		if _, err := os.Stat(filepath.Join(versionDir, "source.zip")); err == nil {
+			return versionDir, nil
+		}
+	}
+	return "", fmt.Errorf("%s@%s: %w", modulePath, version, ErrNotInDump)
+}
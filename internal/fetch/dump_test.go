@@ -0,0 +1,102 @@
+package fetch
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// writeDumpZip writes a module zip to an Athens disk dump under dir, at
+// pathDir, and returns its h1 hash.
+func writeDumpZip(t *testing.T, dir, pathDir, modulePath, version string) string {
+	t.Helper()
+	versionDir := filepath.Join(dir, filepath.FromSlash(pathDir), version)
+	if err := os.MkdirAll(versionDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	zipPath := filepath.Join(versionDir, "source.zip")
+	out, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(out)
+	for name, content := range map[string]string{
+		"go.mod": "module " + modulePath + "\n",
+		"lib.go": "package lib\n",
+	} {
+		w, err := zw.Create(modulePath + "@" + version + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+
+	h1, err := dirhash.HashZip(zipPath, dirhash.Hash1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h1
+}
+
+func TestFetchFromDump(t *testing.T) {
+	dir := t.TempDir()
+	h1 := writeDumpZip(t, dir, "example.com/lib", "example.com/lib", "v1.0.0")
+	escapedH1 := writeDumpZip(t, dir, "example.com/!big", "example.com/Big", "v1.0.0")
+
+	f := &Fetcher{Proxy: DefaultProxy}
+	result, err := f.FetchFromDump(dir, "example.com/lib", "v1.0.0", h1)
+	if err != nil {
+		t.Fatalf("FetchFromDump() error = %v", err)
+	}
+	if !strings.HasPrefix(result.Hash, "sha256-") {
+		t.Errorf("Hash = %q, want SRI sha256", result.Hash)
+	}
+	if result.URL != "https://proxy.golang.org/example.com/lib/@v/v1.0.0.zip" {
+		t.Errorf("URL = %q", result.URL)
+	}
+	if result.Files != 2 {
+		t.Errorf("Files = %d, want 2", result.Files)
+	}
+
+	result, err = f.FetchFromDump(dir, "example.com/Big", "v1.0.0", escapedH1)
+	if err != nil {
+		t.Fatalf("FetchFromDump() escaped path error = %v", err)
+	}
+	if result.URL != "https://proxy.golang.org/example.com/!big/@v/v1.0.0.zip" {
+		t.Errorf("URL = %q", result.URL)
+	}
+
+	tests := []struct {
+		name       string
+		fetcher    *Fetcher
+		modulePath string
+		h1         string
+		want       string
+	}{
+		{"mismatch", f, "example.com/lib", escapedH1, "does not match go.sum"},
+		{"no go.sum hash", f, "example.com/lib", "", "no go.sum hash"},
+		{"not in dump", f, "example.com/missing", h1, ErrNotInDump.Error()},
+		{"private", &Fetcher{Proxy: DefaultProxy, Private: "example.com/*"}, "example.com/lib", h1, "through a proxy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.fetcher.FetchFromDump(dir, tt.modulePath, "v1.0.0", tt.h1)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("FetchFromDump() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := f.FetchFromDump(dir, "example.com/missing", "v1.0.0", h1); !errors.Is(err, ErrNotInDump) {
+		t.Errorf("FetchFromDump() error = %v, want ErrNotInDump", err)
+	}
+}
@@ -0,0 +1,140 @@
+package fetch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// URLExplanation describes how the download URL of a module is chosen.
+type URLExplanation struct {
+	// Steps are the decisions taken, in order.
+	Steps []string
+	// URL is the download URL recorded in the lockfile, or "" if the module
+	// may not be downloaded.
+	URL string
+	// Tried are the URLs requested when downloading, in failover order.
+	Tried []string
+	// Guessed reports that URL is a heuristic guess.
+	Guessed bool
+}
+
+// ExplainURL walks the same decisions as Fetch when choosing the download URL
+// of modulePath at version, recording each one, without downloading the
+// module. Origin metadata for GitHub modules is still looked up.
+func (f *Fetcher) ExplainURL(modulePath, version string) *URLExplanation {
+	e := &URLExplanation{}
+	step := func(format string, args ...any) {
+		e.Steps = append(e.Steps, fmt.Sprintf(format, args...))
+	}
+
+	if key, ok := matchKey(f.URLOverrides, modulePath); ok {
+		e.URL = expandURLTemplate(f.URLOverrides[key], modulePath, version)
+		step("URL override: %s matches %q", key, f.URLOverrides[key])
+	} else {
+		step("URL override: none")
+		e.URL, e.Guessed = f.explainSource(modulePath, version, step)
+		if e.URL == "" {
+			return e
+		}
+	}
+
+	actualURL := f.requestURL(modulePath, e.URL)
+	if actualURL != e.URL {
+		step("private GitHub archive: downloaded through the GitHub API for token authentication")
+	}
+	e.Tried = f.mirrorURLs(modulePath, actualURL)
+
+	if e.Guessed && f.Strict {
+		step("strict mode: the guessed URL is rejected")
+	}
+	return e
+}
+
+// explainSource follows resolveDownloadURL past URL overrides.
+func (f *Fetcher) explainSource(modulePath, version string, step func(string, ...any)) (string, bool) {
+	if f.isPrivate(modulePath) {
+		step("private: yes, matches %q; fetched directly", f.Private)
+		return f.explainDirect(modulePath, version, step)
+	}
+	if f.Private != "" {
+		step("private: no, does not match %q", f.Private)
+	} else {
+		step("private: no, no private patterns configured")
+	}
+
+	if proxies := f.proxies(modulePath); len(proxies) > 0 {
+		if key, ok := matchKey(f.Mirrors, modulePath); ok && len(f.Mirrors[key]) > 0 {
+			step("proxy chain: mirrors for %s: %s", key, strings.Join(proxies, ", "))
+		} else {
+			step("proxy chain: %s", proxies[0])
+		}
+		for _, spec := range f.Fallback {
+			when := "if the module is missing (404 or 410)"
+			if spec.OnError {
+				when = "on any error"
+			}
+			step("then %s: %s", when, spec.URL)
+		}
+		return f.proxyURL(modulePath, version, ".zip"), false
+	}
+	if f.proxyOff(modulePath) {
+		step("proxy chain: off; downloading is disabled")
+		return "", false
+	}
+	step("proxy chain: none (GOPROXY is direct); fetched directly")
+	return f.explainDirect(modulePath, version, step)
+}
+
+// explainDirect follows resolveDirectURL.
+func (f *Fetcher) explainDirect(modulePath, version string, step func(string, ...any)) (string, bool) {
+	if strings.Contains(modulePath, "/gen/go/") {
+		step("Buf Schema Registry module")
+		return f.buildBSRURL(modulePath, version), false
+	}
+
+	if repo, _, ok := f.lookupGitHubRepo(modulePath); ok {
+		if !strings.HasPrefix(modulePath, "github.com/") {
+			step("GitHub repository: github.com/%s", repo)
+		}
+		info := f.getGitHubModuleInfo(modulePath, version)
+		u, guessed := f.gitHubURLFromInfo(modulePath, version, info)
+		switch {
+		case info == nil || info.Origin == nil:
+			step("origin data: unavailable")
+		default:
+			o := info.Origin
+			step("origin data: vcs=%s url=%s ref=%s hash=%s", o.VCS, o.URL, o.Ref, o.Hash)
+		}
+		if guessed {
+			step("fallback: GitHub tag archive URL (guessed)")
+		} else {
+			step("GitHub archive URL from origin data")
+		}
+		return u, guessed
+	}
+
+	if isVanity(modulePath) {
+		if imports, err := f.goImports(modulePath); err != nil {
+			step("go-import meta tags: unavailable: %v", err)
+		} else if imp, _, ok := matchGoImport(imports, modulePath); ok {
+			step("go-import meta tags: %s repository %s", imp.VCS, imp.RepoRoot)
+		} else {
+			step("go-import meta tags: none for %s", modulePath)
+		}
+	}
+	if u, _, ok := f.vanityArchive(modulePath, version); ok {
+		step("archive URL from go-import meta tags")
+		return u, false
+	}
+	if u, _, guessed, ok := f.buildGitLabURL(modulePath, version); ok {
+		if guessed {
+			step("fallback: GitLab archive of the project named by the first two path elements (guessed)")
+		} else {
+			step("GitLab archive of the project named up to its .git element")
+		}
+		return u, guessed
+	}
+
+	step("fallback: %s assumed to serve the module proxy protocol (guessed)", extractHost(modulePath))
+	return f.buildGenericURL(modulePath, version), true
+}
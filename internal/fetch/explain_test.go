@@ -0,0 +1,92 @@
+package fetch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExplainURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		fetcher     *Fetcher
+		modulePath  string
+		wantURL     string
+		wantTried   []string
+		wantGuessed bool
+		wantStep    string
+	}{
+		{
+			name: "override",
+			fetcher: &Fetcher{
+				Proxy:        DefaultProxy,
+				URLOverrides: map[string]string{"git.example.com": "https://dl.example.com/{module}/{version}.zip"},
+			},
+			modulePath: "git.example.com/team/lib",
+			wantURL:    "https://dl.example.com/git.example.com/team/lib/v1.0.0.zip",
+			wantTried:  []string{"https://dl.example.com/git.example.com/team/lib/v1.0.0.zip"},
+			wantStep:   "URL override: git.example.com matches",
+		},
+		{
+			name:       "proxy",
+			fetcher:    &Fetcher{Proxy: DefaultProxy, Private: "git.example.com"},
+			modulePath: "golang.org/x/mod",
+			wantURL:    "https://proxy.golang.org/golang.org/x/mod/@v/v1.0.0.zip",
+			wantTried:  []string{"https://proxy.golang.org/golang.org/x/mod/@v/v1.0.0.zip"},
+			wantStep:   "proxy chain: https://proxy.golang.org",
+		},
+		{
+			name: "mirror",
+			fetcher: &Fetcher{
+				Proxy:   DefaultProxy,
+				Mirrors: map[string][]string{"corp.example.com": {"https://goproxy.corp.example.com"}},
+			},
+			modulePath: "corp.example.com/lib",
+			wantURL:    "https://goproxy.corp.example.com/corp.example.com/lib/@v/v1.0.0.zip",
+			wantTried:  []string{"https://goproxy.corp.example.com/corp.example.com/lib/@v/v1.0.0.zip"},
+			wantStep:   "proxy chain: mirrors for corp.example.com",
+		},
+		{
+			name:        "private generic host",
+			fetcher:     &Fetcher{Proxy: DefaultProxy, Private: "git.example.com", Strict: true},
+			modulePath:  "git.example.com/team/lib",
+			wantURL:     "https://git.example.com/git.example.com/team/lib/@v/v1.0.0.zip",
+			wantTried:   []string{"https://git.example.com/git.example.com/team/lib/@v/v1.0.0.zip"},
+			wantGuessed: true,
+			wantStep:    "strict mode",
+		},
+		{
+			name:       "direct BSR",
+			fetcher:    &Fetcher{},
+			modulePath: "buf.build/gen/go/acme/api/protocolbuffers/go",
+			wantURL:    "https://buf.build/gen/go/buf.build/gen/go/acme/api/protocolbuffers/go/@v/v1.0.0.zip",
+			wantTried:  []string{"https://buf.build/gen/go/buf.build/gen/go/acme/api/protocolbuffers/go/@v/v1.0.0.zip"},
+			wantStep:   "Buf Schema Registry module",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := tt.fetcher.ExplainURL(tt.modulePath, "v1.0.0")
+			if e.URL != tt.wantURL {
+				t.Errorf("URL = %q, want %q", e.URL, tt.wantURL)
+			}
+			if got, guessed := tt.fetcher.resolveDownloadURL(tt.modulePath, "v1.0.0"); got != e.URL || guessed != e.Guessed {
+				t.Errorf("resolveDownloadURL() = %q, %v; explanation has %q, %v", got, guessed, e.URL, e.Guessed)
+			}
+			if !reflect.DeepEqual(e.Tried, tt.wantTried) {
+				t.Errorf("Tried = %q, want %q", e.Tried, tt.wantTried)
+			}
+			if e.Guessed != tt.wantGuessed {
+				t.Errorf("Guessed = %v, want %v", e.Guessed, tt.wantGuessed)
+			}
+			found := false
+			for _, s := range e.Steps {
+				found = found || strings.HasPrefix(s, tt.wantStep)
+			}
+			if !found {
+				t.Errorf("Steps = %q, want one starting with %q", e.Steps, tt.wantStep)
+			}
+		})
+	}
+}
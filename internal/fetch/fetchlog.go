@@ -0,0 +1,85 @@
+package fetch
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// FetchLogEntry is one line of the fetch log: an artifact downloaded from
+// the network.
+type FetchLogEntry struct {
+	Module  string    `json:"module"`
+	Version string    `json:"version"`
+	URL     string    `json:"url"`
+	Time    time.Time `json:"time"`
+	Size    int64     `json:"size"`
+	// SHA256 is the hex SHA-256 of the downloaded bytes.
+	SHA256 string `json:"sha256"`
+	// TLSPeer is the certificate the server presented, or nil for plain
+	// HTTP.
+	TLSPeer *TLSPeer `json:"tlsPeer,omitempty"`
+}
+
+// TLSPeer identifies the leaf certificate of a TLS connection.
+type TLSPeer struct {
+	Subject string `json:"subject"`
+	Issuer  string `json:"issuer"`
+	// SHA256 is the hex SHA-256 fingerprint of the DER certificate.
+	SHA256 string `json:"sha256"`
+}
+
+// fetchLogMu serializes appends to fetch logs, which may be shared by the
+// fetchers of one process.
+var fetchLogMu sync.Mutex
+
+// tlsPeer returns the leaf certificate of state, if any.
+func tlsPeer(state *tls.ConnectionState) *TLSPeer {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := state.PeerCertificates[0]
+	sum := sha256.Sum256(cert.Raw)
+	return &TLSPeer{
+		Subject: cert.Subject.String(),
+		Issuer:  cert.Issuer.String(),
+		SHA256:  hex.EncodeToString(sum[:]),
+	}
+}
+
+// logFetch appends the download d of modulePath@version from rawURL to
+// FetchLog, if set. The file is only ever appended to.
+func (f *Fetcher) logFetch(modulePath, version, rawURL string, d *download) error {
+	if f.FetchLog == "" {
+		return nil
+	}
+	line, err := json.Marshal(FetchLogEntry{
+		Module:  modulePath,
+		Version: version,
+		URL:     rawURL,
+		Time:    time.Now().UTC(),
+		Size:    d.size,
+		SHA256:  d.sha256,
+		TLSPeer: tlsPeer(d.tls),
+	})
+	if err != nil {
+		return err
+	}
+
+	fetchLogMu.Lock()
+	defer fetchLogMu.Unlock()
+	file, err := os.OpenFile(f.FetchLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("writing fetch log: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("writing fetch log: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,92 @@
+package fetch
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetchLog(t *testing.T) {
+	body := []byte("PK\x05\x06 not really a zip")
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	logPath := filepath.Join(t.TempDir(), "fetch.jsonl")
+	if err := os.WriteFile(logPath, []byte("{\"module\":\"earlier\"}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f := &Fetcher{FetchLog: logPath}
+	f.dialOnce.Do(func() { f.base = srv.Client().Transport })
+
+	for _, version := range []string{"v1.0.0", "v1.1.0"} {
+		zipPath, _, _, err := f.downloadFromURL(srv.URL+"/mod.zip", "example.com/mod", version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(zipPath)
+	}
+
+	file, err := os.Open(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var entries []FetchLogEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e FetchLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 3 || entries[0].Module != "earlier" {
+		t.Fatalf("fetch log has %d entries, want the earlier one and 2 appended: %+v", len(entries), entries)
+	}
+
+	sum := sha256.Sum256(body)
+	cert := srv.Certificate()
+	certSum := sha256.Sum256(cert.Raw)
+	e := entries[2]
+	if e.Module != "example.com/mod" || e.Version != "v1.1.0" || e.URL != srv.URL+"/mod.zip" || e.Time.IsZero() {
+		t.Errorf("entry = %+v", e)
+	}
+	if e.Size != int64(len(body)) || e.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("size, sha256 = %d, %s; want %d, %x", e.Size, e.SHA256, len(body), sum)
+	}
+	if e.TLSPeer == nil || e.TLSPeer.SHA256 != hex.EncodeToString(certSum[:]) || e.TLSPeer.Subject != cert.Subject.String() {
+		t.Errorf("tlsPeer = %+v, want the server certificate", e.TLSPeer)
+	}
+}
+
+func TestOnDownload(t *testing.T) {
+	body := []byte("PK\x05\x06 not really a zip")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	var got []string
+	f := &Fetcher{OnDownload: func(modulePath, version, url string, size int64) {
+		got = append(got, fmt.Sprintf("%s@%s %s %d", modulePath, version, url, size))
+	}}
+	zipPath, _, _, err := f.downloadFromURL(srv.URL+"/mod.zip", "example.com/mod", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(zipPath)
+
+	want := fmt.Sprintf("example.com/mod@v1.0.0 %s/mod.zip %d", srv.URL, len(body))
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("OnDownload calls = %q, want [%q]", got, want)
+	}
+}
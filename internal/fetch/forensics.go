@@ -0,0 +1,259 @@
+package fetch
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Artifact layouts reported by MismatchReport.
+const (
+	layoutModuleZip = "module zip"
+	layoutArchive   = "repository archive"
+	layoutExtracted = "extracted module"
+)
+
+// mismatchListLimit is how many paths of each kind a report lists.
+const mismatchListLimit = 10
+
+// MismatchReport compares a downloaded artifact that failed verification
+// with a cached copy of the artifact the locked hash was computed from, and
+// lists likely causes of the difference.
+type MismatchReport struct {
+	// Compared describes the cached copy, or is empty if none was found.
+	Compared       string
+	LockedLayout   string
+	DownloadLayout string
+
+	// Paths relative to the module root, sorted.
+	Added       []string // Only in the download
+	Removed     []string // Only in the cached copy
+	Changed     []string // Different contents, with the sizes before and after
+	LineEndings []string // Different only in CRLF versus LF line endings
+
+	Causes []string
+}
+
+func (r *MismatchReport) String() string {
+	var b strings.Builder
+	if r.Compared == "" {
+		fmt.Fprintf(&b, "no cached copy of the locked artifact to compare with; downloaded a %s\n", r.DownloadLayout)
+	} else {
+		fmt.Fprintf(&b, "compared with %s\n", r.Compared)
+		fmt.Fprintf(&b, "  layout: locked %s, downloaded %s\n", r.LockedLayout, r.DownloadLayout)
+		list := func(name string, paths []string) {
+			if len(paths) == 0 {
+				return
+			}
+			shown := paths[:min(len(paths), mismatchListLimit)]
+			fmt.Fprintf(&b, "  %s (%d): %s", name, len(paths), strings.Join(shown, ", "))
+			if len(paths) > len(shown) {
+				fmt.Fprintf(&b, ", ...")
+			}
+			b.WriteString("\n")
+		}
+		list("added", r.Added)
+		list("removed", r.Removed)
+		list("changed", r.Changed)
+		list("line endings only", r.LineEndings)
+	}
+	if len(r.Causes) > 0 {
+		b.WriteString("likely causes:\n")
+		for _, c := range r.Causes {
+			fmt.Fprintf(&b, "  - %s\n", c)
+		}
+	}
+	return b.String()
+}
+
+// fileDigest summarizes one file of an artifact.
+type fileDigest struct {
+	size int64
+	sum  [32]byte // SHA-256 of the contents
+	lf   [32]byte // SHA-256 of the contents with CRLF line endings made LF
+}
+
+// explainMismatch compares gotZip, downloaded for m, with a cached copy of
+// the artifact m's locked hash was computed from.
+func (f *Fetcher) explainMismatch(m Locked, gotZip string) *MismatchReport {
+	report := &MismatchReport{}
+	got, layout, err := zipDigests(gotZip, m.Path, m.Version, m.Subdir)
+	report.DownloadLayout = layout
+	if err != nil {
+		report.DownloadLayout = "unreadable file"
+		report.Causes = append(report.Causes, fmt.Sprintf("the download is not a valid zip (%v): the server likely returned an error page or a truncated file", err))
+		return report
+	}
+
+	want, compared, lockedLayout := f.lockedCopy(m)
+	if want == nil {
+		return report
+	}
+	report.Compared, report.LockedLayout = compared, lockedLayout
+
+	for name, g := range got {
+		w, ok := want[name]
+		switch {
+		case !ok:
+			report.Added = append(report.Added, name)
+		case w.sum == g.sum:
+		case w.lf == g.lf:
+			report.LineEndings = append(report.LineEndings, name)
+		default:
+			report.Changed = append(report.Changed, fmt.Sprintf("%s (%d -> %d bytes)", name, w.size, g.size))
+		}
+	}
+	for name := range want {
+		if _, ok := got[name]; !ok {
+			report.Removed = append(report.Removed, name)
+		}
+	}
+	sort.Strings(report.Added)
+	sort.Strings(report.Removed)
+	sort.Strings(report.Changed)
+	sort.Strings(report.LineEndings)
+
+	layoutDiffers := lockedLayout != layoutExtracted && lockedLayout != layout
+	if layoutDiffers {
+		report.Causes = append(report.Causes, fmt.Sprintf("the locked hash covers a %s but a %s was downloaded: the source likely changed between the module proxy and a forge archive (file differences may stem from the layout alone)", lockedLayout, layout))
+	}
+	if len(report.LineEndings) > 0 {
+		report.Causes = append(report.Causes, fmt.Sprintf("%d files differ only in line endings (CRLF versus LF), as produced by .gitattributes eol or export settings", len(report.LineEndings)))
+	}
+	if n := len(report.Added) + len(report.Removed) + len(report.Changed); n > 0 && !layoutDiffers {
+		report.Causes = append(report.Causes, fmt.Sprintf("%d files were added, removed, or changed: the release was likely re-tagged, or the source serves different contents for this version", n))
+	}
+	if len(report.Causes) == 0 {
+		report.Causes = append(report.Causes, "every file is identical; only archive metadata (timestamps, file order, or compression) differs, as when a forge regenerates an archive")
+	}
+	return report
+}
+
+// lockedCopy returns the file digests of a cached copy of m's locked
+// artifact, a description of it, and its layout: the Go module cache zip
+// when it has the locked hash, otherwise nopher's cache when it recorded
+// the locked hash, otherwise the Go module cache zip, which the go command
+// verified against go.sum.
+func (f *Fetcher) lockedCopy(m Locked) (map[string]fileDigest, string, string) {
+	modZip, modErr := modCacheZip(m.Path, m.Version)
+	if modErr == nil {
+		if h, err := computeZipHash(modZip); err == nil && h == m.Hash {
+			if digests, layout, err := zipDigests(modZip, m.Path, m.Version, m.Subdir); err == nil {
+				return digests, "the Go module cache copy (" + modZip + "), which has the locked hash", layout
+			}
+		}
+	}
+
+	if f.CacheDir != "" {
+		dir := f.cachedDir(m.Path, m.Version)
+		if h, err := os.ReadFile(dir + ".hash"); err == nil && strings.TrimSpace(string(h)) == m.Hash {
+			if digests, err := dirDigests(dir); err == nil {
+				return digests, "nopher's cached copy (" + dir + "), which has the locked hash", layoutExtracted
+			}
+		}
+	}
+
+	if modErr == nil {
+		if digests, layout, err := zipDigests(modZip, m.Path, m.Version, m.Subdir); err == nil {
+			return digests, "the Go module cache copy (" + modZip + "), which matches go.sum but not the locked hash", layout
+		}
+	}
+	return nil, "", ""
+}
+
+// zipDigests digests the regular files in a module zip or a repository
+// archive, keyed by path relative to the module root, and reports which of
+// the two the zip is.
+func zipDigests(zipPath, modulePath, version, subdir string) (map[string]fileDigest, string, error) {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, "", err
+	}
+	defer zr.Close()
+
+	prefix := modulePath + "@" + version + "/"
+	layout := layoutModuleZip
+	for _, zf := range zr.File {
+		if !strings.HasPrefix(zf.Name, prefix) {
+			layout = layoutArchive
+			break
+		}
+	}
+
+	digests := make(map[string]fileDigest)
+	for _, zf := range zr.File {
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+		name := strings.TrimPrefix(zf.Name, prefix)
+		if layout == layoutArchive {
+			// Archives hold a single top-level directory, such as
+			// repo-v1.2.3/, and the module may live in a subdirectory.
+			_, name, _ = strings.Cut(zf.Name, "/")
+			if subdir != "" {
+				var ok bool
+				if name, ok = strings.CutPrefix(name, path.Clean(subdir)+"/"); !ok {
+					continue
+				}
+			}
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, "", err
+		}
+		d, err := digest(rc)
+		rc.Close()
+		if err != nil {
+			return nil, "", err
+		}
+		digests[name] = d
+	}
+	return digests, layout, nil
+}
+
+// dirDigests digests the regular files under dir, keyed by slash-separated
+// relative path.
+func dirDigests(dir string) (map[string]fileDigest, error) {
+	digests := make(map[string]fileDigest)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		dg, err := digest(file)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		digests[filepath.ToSlash(rel)] = dg
+		return nil
+	})
+	return digests, err
+}
+
+func digest(r io.Reader) (fileDigest, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fileDigest{}, err
+	}
+	return fileDigest{
+		size: int64(len(data)),
+		sum:  sha256.Sum256(data),
+		lf:   sha256.Sum256(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))),
+	}, nil
+}
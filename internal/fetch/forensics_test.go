@@ -0,0 +1,104 @@
+package fetch
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExplainMismatch(t *testing.T) {
+	modCache := t.TempDir()
+	t.Setenv("GOMODCACHE", modCache)
+
+	writeZip := func(path, prefix string, files map[string]string, modified time.Time) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		out, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zw := zip.NewWriter(out)
+		for name, content := range files {
+			w, err := zw.CreateHeader(&zip.FileHeader{Name: prefix + name, Method: zip.Deflate, Modified: modified})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write([]byte(content))
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		out.Close()
+	}
+
+	locked := map[string]string{"go.mod": "module example.com/mod\n", "a.go": "package mod\n", "b.go": "package mod\n\nvar B = 1\n"}
+	lockedZip := filepath.Join(modCache, "cache", "download", "example.com", "mod", "@v", "v1.0.0.zip")
+	writeZip(lockedZip, "example.com/mod@v1.0.0/", locked, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	lockedHash, err := computeZipHash(lockedZip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := Locked{Path: "example.com/mod", Version: "v1.0.0", Hash: lockedHash}
+	f := &Fetcher{}
+	dir := t.TempDir()
+
+	t.Run("retagged", func(t *testing.T) {
+		got := filepath.Join(dir, "retagged.zip")
+		writeZip(got, "example.com/mod@v1.0.0/", map[string]string{
+			"go.mod": "module example.com/mod\n",
+			"a.go":   "package mod\r\n",
+			"b.go":   "package mod\n\nvar B = 2\n",
+			"c.go":   "package mod\n",
+		}, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+		r := f.explainMismatch(m, got)
+		if !strings.Contains(r.Compared, "Go module cache") || r.LockedLayout != layoutModuleZip || r.DownloadLayout != layoutModuleZip {
+			t.Errorf("report = %+v", r)
+		}
+		if !reflect.DeepEqual(r.Added, []string{"c.go"}) || r.Removed != nil ||
+			!reflect.DeepEqual(r.Changed, []string{"b.go (23 -> 23 bytes)"}) || !reflect.DeepEqual(r.LineEndings, []string{"a.go"}) {
+			t.Errorf("report = %+v", r)
+		}
+		causes := strings.Join(r.Causes, "\n")
+		if !strings.Contains(causes, "line endings") || !strings.Contains(causes, "re-tagged") {
+			t.Errorf("causes = %q", r.Causes)
+		}
+	})
+
+	t.Run("metadata only", func(t *testing.T) {
+		got := filepath.Join(dir, "metadata.zip")
+		writeZip(got, "example.com/mod@v1.0.0/", locked, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+
+		r := f.explainMismatch(m, got)
+		if len(r.Added)+len(r.Removed)+len(r.Changed)+len(r.LineEndings) != 0 || len(r.Causes) != 1 || !strings.Contains(r.Causes[0], "archive metadata") {
+			t.Errorf("report = %+v", r)
+		}
+	})
+
+	t.Run("archive layout", func(t *testing.T) {
+		got := filepath.Join(dir, "archive.zip")
+		writeZip(got, "mod-1.0.0/", locked, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+		r := f.explainMismatch(m, got)
+		if r.DownloadLayout != layoutArchive || len(r.Added)+len(r.Removed)+len(r.Changed) != 0 || len(r.Causes) != 1 || !strings.Contains(r.Causes[0], "forge archive") {
+			t.Errorf("report = %+v", r)
+		}
+	})
+
+	t.Run("not a zip", func(t *testing.T) {
+		got := filepath.Join(dir, "error.html")
+		if err := os.WriteFile(got, []byte("<html>rate limited</html>"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		r := f.explainMismatch(m, got)
+		if r.Compared != "" || len(r.Causes) != 1 || !strings.Contains(r.Causes[0], "not a valid zip") {
+			t.Errorf("report = %+v", r)
+		}
+	})
+}
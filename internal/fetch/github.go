@@ -0,0 +1,228 @@
+package fetch
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GitHubAPI is the GitHub REST API base URL.
+const GitHubAPI = "https://api.github.com"
+
+// ErrGitHubNotFound is returned for GitHub API lookups that return 404.
+var ErrGitHubNotFound = errors.New("not found on GitHub")
+
+// githubClient is a small GitHub REST client. It authenticates with a token
+// when one is available, caches responses under a directory and revalidates
+// them with conditional requests (304s don't count against the rate limit),
+// and waits out rate limiting instead of failing.
+type githubClient struct {
+	baseURL   string
+	token     string
+	cacheDir  string // Empty disables caching
+	files     cacheFiles
+	transport http.RoundTripper
+
+	// maxWait bounds how long a single request waits for a rate limit reset.
+	maxWait time.Duration
+	retries int
+	sleep   func(time.Duration)
+	now     func() time.Time
+}
+
+// cachedResponse is a GitHub API response stored on disk.
+type cachedResponse struct {
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"lastModified,omitempty"`
+	Body         json.RawMessage `json:"body"`
+	// MAC authenticates the response when Fetcher.CacheKey is set.
+	MAC string `json:"mac,omitempty"`
+}
+
+// macFields returns the fields of r its MAC covers, for url.
+func (r *cachedResponse) macFields(url string) []string {
+	return []string{"github", url, r.ETag, r.LastModified, string(r.Body)}
+}
+
+// github returns the fetcher's GitHub API client. The token comes from
+// GITHUB_TOKEN or GH_TOKEN, falling back to netrc credentials for
+// api.github.com or github.com.
+func (f *Fetcher) github() *githubClient {
+	f.ghOnce.Do(func() {
+		token := os.Getenv("GITHUB_TOKEN")
+		if token == "" {
+			token = os.Getenv("GH_TOKEN")
+		}
+		if token == "" {
+			if m := findMachine(f.Netrc, "api.github.com", "github.com"); m != nil {
+				token = m.Password
+			}
+		}
+
+		cacheDir := ""
+		if f.CacheDir != "" {
+			cacheDir = filepath.Join(f.CacheDir, "github")
+		}
+
+		f.gh = &githubClient{
+			baseURL:   GitHubAPI,
+			token:     token,
+			cacheDir:  cacheDir,
+			files:     f.cacheFiles(),
+			transport: f.transport(),
+			maxWait:   2 * time.Minute,
+			retries:   3,
+			sleep:     time.Sleep,
+			now:       time.Now,
+		}
+	})
+	return f.gh
+}
+
+// getJSON fetches path (e.g. "/repos/o/r/commits/abc") and decodes the JSON
+// response into v.
+func (c *githubClient) getJSON(path string, v any) error {
+	body, err := c.get(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("decoding GitHub response for %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *githubClient) get(path string) ([]byte, error) {
+	url := strings.TrimSuffix(c.baseURL, "/") + path
+	cached, cachePath := c.load(url)
+
+	client := http.Client{Transport: c.transport, Timeout: 30 * time.Second}
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		if cached != nil {
+			if cached.ETag != "" {
+				req.Header.Set("If-None-Match", cached.ETag)
+			}
+			if cached.LastModified != "" {
+				req.Header.Set("If-Modified-Since", cached.LastModified)
+			}
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("GitHub API %s: %w", path, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("GitHub API %s: %w", path, err)
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusNotModified && cached != nil:
+			return cached.Body, nil
+		case resp.StatusCode == http.StatusOK:
+			c.store(cachePath, url, resp.Header, body)
+			return body, nil
+		case resp.StatusCode == http.StatusNotFound:
+			return nil, fmt.Errorf("GitHub API %s: %w", path, ErrGitHubNotFound)
+		case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+			wait, limited := c.rateLimitWait(resp, attempt)
+			if !limited {
+				break
+			}
+			if attempt >= c.retries || wait > c.maxWait {
+				hint := ""
+				if c.token == "" {
+					hint = "; set GITHUB_TOKEN to raise the limit"
+				}
+				return nil, fmt.Errorf("GitHub API rate limit exceeded for %s (resets in %s)%s", path, wait.Round(time.Second), hint)
+			}
+			c.sleep(wait)
+			continue
+		}
+		return nil, fmt.Errorf("GitHub API %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+}
+
+// rateLimitWait reports whether resp is a rate limit response and how long
+// to wait before retrying: Retry-After for secondary limits, the
+// X-RateLimit-Reset time when the primary limit is exhausted, or exponential
+// backoff otherwise.
+func (c *githubClient) rateLimitWait(resp *http.Response, attempt int) (time.Duration, bool) {
+	if s := resp.Header.Get("Retry-After"); s != "" {
+		if secs, err := strconv.Atoi(s); err == nil {
+			return time.Duration(secs) * time.Second, true
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			wait := time.Unix(reset, 0).Sub(c.now()) + time.Second
+			return max(wait, time.Second), true
+		}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return time.Duration(1<<attempt) * time.Second, true
+	}
+	return 0, false
+}
+
+func (c *githubClient) load(url string) (*cachedResponse, string) {
+	if c.cacheDir == "" {
+		return nil, ""
+	}
+	sum := sha256.Sum256([]byte(url))
+	path := filepath.Join(c.cacheDir, hex.EncodeToString(sum[:])+".json")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, path
+	}
+	var cached cachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil || (cached.ETag == "" && cached.LastModified == "") || !c.files.verify(cached.MAC, cached.macFields(url)...) {
+		return nil, path
+	}
+	return &cached, path
+}
+
+// store caches body if the response can be revalidated. Cache write
+// failures are ignored; the next lookup just fetches again.
+func (c *githubClient) store(path, url string, header http.Header, body []byte) {
+	// Stored bodies are compacted, so compact before computing the MAC.
+	var compact bytes.Buffer
+	if path == "" || json.Compact(&compact, body) != nil {
+		return
+	}
+	cached := cachedResponse{
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		Body:         compact.Bytes(),
+	}
+	if cached.ETag == "" && cached.LastModified == "" {
+		return
+	}
+	cached.MAC = c.files.mac(cached.macFields(url)...)
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+	c.files.writeFile(path, data)
+}
@@ -0,0 +1,116 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testGitHubClient(baseURL, cacheDir string) (*githubClient, *[]time.Duration) {
+	var slept []time.Duration
+	now := time.Unix(1_700_000_000, 0)
+	return &githubClient{
+		baseURL:   baseURL,
+		token:     "ghp_test",
+		cacheDir:  cacheDir,
+		transport: http.DefaultTransport,
+		maxWait:   time.Minute,
+		retries:   3,
+		sleep:     func(d time.Duration) { slept = append(slept, d) },
+		now:       func() time.Time { return now },
+	}, &slept
+}
+
+func TestGitHubClientConditionalCache(t *testing.T) {
+	var requests, notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Authorization") != "Bearer ghp_test" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `{"sha":"0123456789abcdef0123456789abcdef01234567"}`)
+	}))
+	defer srv.Close()
+
+	c, _ := testGitHubClient(srv.URL, t.TempDir())
+	for i := 0; i < 2; i++ {
+		var result struct{ SHA string }
+		if err := c.getJSON("/repos/foo/bar/commits/0123456", &result); err != nil {
+			t.Fatalf("getJSON() error = %v", err)
+		}
+		if result.SHA != "0123456789abcdef0123456789abcdef01234567" {
+			t.Errorf("SHA = %q", result.SHA)
+		}
+	}
+	if requests != 2 || notModified != 1 {
+		t.Errorf("requests = %d, 304s = %d; want the second lookup revalidated from cache", requests, notModified)
+	}
+}
+
+func TestGitHubClientRateLimit(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", "1700000030")
+			http.Error(w, "API rate limit exceeded", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"sha":"abc"}`)
+	}))
+	defer srv.Close()
+
+	c, slept := testGitHubClient(srv.URL, "")
+	var result struct{ SHA string }
+	if err := c.getJSON("/repos/foo/bar/commits/abc", &result); err != nil {
+		t.Fatalf("getJSON() error = %v", err)
+	}
+	if len(*slept) != 1 || (*slept)[0] != 31*time.Second {
+		t.Errorf("slept %v, want one wait until the reset", *slept)
+	}
+}
+
+func TestGitHubClientErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/limited":
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", "1700003600")
+			http.Error(w, "API rate limit exceeded", http.StatusForbidden)
+		default:
+			http.Error(w, "forbidden", http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	c, slept := testGitHubClient(srv.URL, "")
+	c.token = ""
+
+	if _, err := c.get("/missing"); !errors.Is(err, ErrGitHubNotFound) {
+		t.Errorf("get(/missing) error = %v, want ErrGitHubNotFound", err)
+	}
+	_, err := c.get("/limited")
+	if err == nil || !strings.Contains(err.Error(), "rate limit exceeded") || !strings.Contains(err.Error(), "GITHUB_TOKEN") {
+		t.Errorf("get(/limited) error = %v, want rate limit error with token hint", err)
+	}
+	if len(*slept) != 0 {
+		t.Errorf("slept %v; a reset beyond maxWait should fail immediately", *slept)
+	}
+	if _, err := c.get("/forbidden"); err == nil || strings.Contains(err.Error(), "rate limit") {
+		t.Errorf("get(/forbidden) error = %v, want plain 403", err)
+	}
+}
@@ -0,0 +1,256 @@
+package fetch
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitHubApp authenticates as an installation of a GitHub App, so private
+// repositories across an organization can be fetched without a personal
+// access token. Installation tokens are minted with a JWT signed by the
+// app's private key and reused until shortly before they expire (an hour
+// after minting).
+type GitHubApp struct {
+	AppID int64
+	// InstallationID selects the installation. When zero, it is looked up:
+	// Owner's installation if Owner is set, otherwise the app's only one.
+	InstallationID int64
+	Owner          string
+	PrivateKey     *rsa.PrivateKey
+
+	// APIURL overrides GitHubAPI.
+	APIURL string
+	// Transport carries the token requests; Fetcher sets it to its own
+	// transport when nil.
+	Transport http.RoundTripper
+	// Now overrides the clock used for JWTs and token expiry.
+	Now func() time.Time
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// ParseGitHubAppKey parses a GitHub App private key in PEM form, as
+// downloaded from the app's settings (PKCS #1) or converted to PKCS #8.
+func ParseGitHubAppKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block in private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// Sign implements Signer, authenticating the request with an installation
+// token.
+func (a *GitHubApp) Sign(req *http.Request) error {
+	token, err := a.Token()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Token returns an installation token, minting a new one when the cached
+// token is missing or about to expire.
+func (a *GitHubApp) Token() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && a.now().Add(5*time.Minute).Before(a.expiry) {
+		return a.token, nil
+	}
+
+	jwt, err := a.jwt()
+	if err != nil {
+		return "", fmt.Errorf("github app: %w", err)
+	}
+	id := a.InstallationID
+	if id == 0 {
+		if id, err = a.installation(jwt); err != nil {
+			return "", fmt.Errorf("github app: %w", err)
+		}
+	}
+	var resp struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := a.call("POST", "/app/installations/"+strconv.FormatInt(id, 10)+"/access_tokens", jwt, &resp); err != nil {
+		return "", fmt.Errorf("github app: minting installation token: %w", err)
+	}
+	if resp.Token == "" {
+		return "", errors.New("github app: no installation token in response")
+	}
+	a.token, a.expiry = resp.Token, resp.ExpiresAt
+	return a.token, nil
+}
+
+// installation looks up the installation to mint tokens for.
+func (a *GitHubApp) installation(jwt string) (int64, error) {
+	var inst struct {
+		ID int64 `json:"id"`
+	}
+	if a.Owner != "" {
+		// Organizations and users have separate endpoints.
+		err := a.call("GET", "/orgs/"+a.Owner+"/installation", jwt, &inst)
+		if errors.Is(err, ErrGitHubNotFound) {
+			err = a.call("GET", "/users/"+a.Owner+"/installation", jwt, &inst)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("finding the installation for %s: %w", a.Owner, err)
+		}
+		return inst.ID, nil
+	}
+
+	var all []struct {
+		ID      int64 `json:"id"`
+		Account struct {
+			Login string `json:"login"`
+		} `json:"account"`
+	}
+	if err := a.call("GET", "/app/installations", jwt, &all); err != nil {
+		return 0, fmt.Errorf("listing installations: %w", err)
+	}
+	switch len(all) {
+	case 0:
+		return 0, errors.New("the app is not installed anywhere")
+	case 1:
+		return all[0].ID, nil
+	}
+	owners := make([]string, len(all))
+	for i, inst := range all {
+		owners[i] = inst.Account.Login
+	}
+	return 0, fmt.Errorf("the app has %d installations (%s); set its installation ID or owner", len(all), strings.Join(owners, ", "))
+}
+
+// jwt returns a JWT identifying the app, valid for ten minutes. It is
+// backdated a minute to allow for clock drift, as GitHub recommends.
+func (a *GitHubApp) jwt() (string, error) {
+	if a.PrivateKey == nil {
+		return "", errors.New("no private key")
+	}
+	now := a.now()
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(a.AppID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := header + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.PrivateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("signing JWT: %w", err)
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
+
+// call makes a GitHub API request authenticated as the app and decodes
+// the JSON response into v.
+func (a *GitHubApp) call(method, path, jwt string, v any) error {
+	base := a.APIURL
+	if base == "" {
+		base = GitHubAPI
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(base, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	client := http.Client{Transport: a.Transport, Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrGitHubNotFound
+	case resp.StatusCode/100 != 2:
+		var msg struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &msg) == nil && msg.Message != "" {
+			return fmt.Errorf("%s: %s", resp.Status, msg.Message)
+		}
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (a *GitHubApp) now() time.Time {
+	if a.Now != nil {
+		return a.Now()
+	}
+	return time.Now()
+}
+
+// gitAuthEnv returns environment variables that make git send the
+// installation token to github.com, for the git and go commands nopher
+// runs. Existing GIT_CONFIG_COUNT entries are kept.
+func (a *GitHubApp) gitAuthEnv() ([]string, error) {
+	token, err := a.Token()
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	basic := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + token))
+	return []string{
+		fmt.Sprintf("GIT_CONFIG_KEY_%d=http.https://github.com/.extraheader", n),
+		fmt.Sprintf("GIT_CONFIG_VALUE_%d=Authorization: Basic %s", n, basic),
+		fmt.Sprintf("GIT_CONFIG_COUNT=%d", n+1),
+	}, nil
+}
+
+// githubApp returns GitHubApp with its Transport defaulted to the fetcher's
+// base transport, or nil.
+func (f *Fetcher) githubApp() *GitHubApp {
+	if f.GitHubApp == nil {
+		return nil
+	}
+	f.appOnce.Do(func() {
+		if f.GitHubApp.Transport == nil {
+			f.GitHubApp.Transport = f.baseTransport()
+		}
+	})
+	return f.GitHubApp
+}
@@ -0,0 +1,139 @@
+package fetch
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGitHubApp(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	mints := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every app request carries a JWT signed by the app's key.
+		jwt, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		parts := strings.Split(jwt, ".")
+		if !ok || len(parts) != 3 {
+			t.Errorf("%s: Authorization = %q", r.URL.Path, r.Header.Get("Authorization"))
+			return
+		}
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+			t.Errorf("JWT signature: %v", err)
+		}
+		var claims struct {
+			Iat, Exp int64
+			Iss      string
+		}
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		json.Unmarshal(payload, &claims)
+		if claims.Iss != "42" || claims.Iat < now.Add(-time.Minute).Unix() || claims.Exp-claims.Iat != 600 {
+			t.Errorf("claims = %+v", claims)
+		}
+
+		switch r.Method + " " + r.URL.Path {
+		case "GET /orgs/example-user/installation":
+			http.NotFound(w, r)
+		case "GET /users/example-user/installation":
+			fmt.Fprint(w, `{"id": 7}`)
+		case "GET /app/installations":
+			fmt.Fprint(w, `[{"id": 7, "account": {"login": "acme"}}, {"id": 8, "account": {"login": "other"}}]`)
+		case "POST /app/installations/7/access_tokens":
+			mints++
+			fmt.Fprintf(w, `{"token": "ghs_%d", "expires_at": %q}`, mints, now.Add(time.Hour).Format(time.RFC3339))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	t.Run("owner lookup and caching", func(t *testing.T) {
+		mints = 0
+		clock := now
+		app := &GitHubApp{AppID: 42, Owner: "example-user", PrivateKey: key, APIURL: srv.URL, Now: func() time.Time { return clock }}
+
+		req, _ := http.NewRequest("GET", "https://api.github.com/repos/acme/private", nil)
+		if err := app.Sign(req); err != nil {
+			t.Fatal(err)
+		}
+		if got := req.Header.Get("Authorization"); got != "Bearer ghs_1" {
+			t.Errorf("Authorization = %q, want Bearer ghs_1", got)
+		}
+		if token, err := app.Token(); err != nil || token != "ghs_1" {
+			t.Errorf("Token() = %q, %v; want the cached ghs_1", token, err)
+		}
+
+		// Tokens are renewed shortly before they expire.
+		clock = now.Add(58 * time.Minute)
+		if token, err := app.Token(); err != nil || token != "ghs_2" {
+			t.Errorf("Token() near expiry = %q, %v; want ghs_2", token, err)
+		}
+	})
+
+	t.Run("several installations", func(t *testing.T) {
+		app := &GitHubApp{AppID: 42, PrivateKey: key, APIURL: srv.URL, Now: func() time.Time { return now }}
+		_, err := app.Token()
+		if err == nil || !strings.Contains(err.Error(), "acme, other") {
+			t.Errorf("Token() error = %v, want the installations listed", err)
+		}
+	})
+
+	t.Run("git auth", func(t *testing.T) {
+		t.Setenv("GIT_CONFIG_COUNT", "2")
+		app := &GitHubApp{AppID: 42, InstallationID: 7, PrivateKey: key, APIURL: srv.URL, Now: func() time.Time { return now }}
+		env, err := app.gitAuthEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		basic := base64.StdEncoding.EncodeToString([]byte("x-access-token:ghs_" + fmt.Sprint(mints)))
+		want := []string{
+			"GIT_CONFIG_KEY_2=http.https://github.com/.extraheader",
+			"GIT_CONFIG_VALUE_2=Authorization: Basic " + basic,
+			"GIT_CONFIG_COUNT=3",
+		}
+		if strings.Join(env, "\n") != strings.Join(want, "\n") {
+			t.Errorf("gitAuthEnv() = %q, want %q", env, want)
+		}
+	})
+}
+
+func TestParseGitHubAppKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, block := range map[string]*pem.Block{
+		"pkcs1": {Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		"pkcs8": {Type: "PRIVATE KEY", Bytes: pkcs8},
+	} {
+		got, err := ParseGitHubAppKey(pem.EncodeToMemory(block))
+		if err != nil || !got.Equal(key) {
+			t.Errorf("%s: ParseGitHubAppKey() = %v, %v", name, got, err)
+		}
+	}
+	if _, err := ParseGitHubAppKey([]byte("not a key")); err == nil {
+		t.Error("ParseGitHubAppKey(garbage) succeeded")
+	}
+}
@@ -0,0 +1,149 @@
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// isGitLabHost reports whether host runs GitLab: gitlab.com, hosts named
+// gitlab.*, and the hosts in GitLabHosts.
+func (f *Fetcher) isGitLabHost(host string) bool {
+	host = normalizeHost(host)
+	if name, _ := splitHostPort(host); name == "gitlab.com" || strings.HasPrefix(name, "gitlab.") {
+		return true
+	}
+	for _, h := range f.GitLabHosts {
+		if normalizeHost(h) == host {
+			return true
+		}
+	}
+	return false
+}
+
+// gitLabArchiveURL returns the API URL of the zip archive of ref in the
+// GitLab project at https://host/project. Unlike the web archive URL, the
+// API accepts token authentication, and it names the project by its full
+// path, so subgroups need no lookup.
+func gitLabArchiveURL(host, project, ref string) string {
+	return fmt.Sprintf("https://%s/api/v4/projects/%s/repository/archive.zip?sha=%s",
+		host, url.PathEscape(project), url.QueryEscape(ref))
+}
+
+// isGitLabArchiveURL reports whether u is a GitLab API archive URL.
+func isGitLabArchiveURL(u string) bool {
+	return strings.HasPrefix(u, "https://") && strings.Contains(u, "/api/v4/projects/") &&
+		strings.Contains(u, "/repository/archive.zip?")
+}
+
+// isRepoArchiveURL reports whether u is the archive of a whole repository
+// on a forge whose archives Fetch rebuilds into module zips and hashes by
+// their tree: GitHub or GitLab.
+func isRepoArchiveURL(u string) bool {
+	return isGitHubArchiveURL(u) || isGitLabArchiveURL(u)
+}
+
+// buildGitLabURL constructs the GitLab API archive URL of a module on a
+// GitLab host whose go-import meta tags could not be read, and returns the
+// module's directory within the archive. The project is taken to be the
+// path up to an element ending in .git, as the go command reads such
+// paths, or otherwise the first two elements after the host, which is a
+// guess for projects in subgroups.
+func (f *Fetcher) buildGitLabURL(modulePath, version string) (downloadURL, subdir string, guessed, ok bool) {
+	host, rest, found := strings.Cut(modulePath, "/")
+	if !found || !f.isGitLabHost(host) {
+		return "", "", false, false
+	}
+
+	elems := strings.Split(rest, "/")
+	n := 0
+	for i, e := range elems {
+		if strings.HasSuffix(e, ".git") {
+			n = i + 1
+			break
+		}
+	}
+	if n == 0 {
+		if len(elems) < 2 {
+			return "", "", false, false
+		}
+		n, guessed = 2, true
+	}
+
+	project := strings.TrimSuffix(strings.Join(elems[:n], "/"), ".git")
+	dir, ref := archiveRef(strings.Join(elems[n:], "/"), version)
+	return gitLabArchiveURL(host, project, ref), dir, guessed, true
+}
+
+// archiveRef returns the directory of a module at subpath within its
+// repository, and the ref whose archive holds version of it: the commit of
+// a pseudo-version, or else the version's tag, prefixed with the directory.
+func archiveRef(subpath, version string) (dir, ref string) {
+	dir = subpathTagPrefix(subpath)
+	if module.IsPseudoVersion(version) {
+		ref, _ = module.PseudoVersionRev(version)
+		return dir, ref
+	}
+	// +incompatible versions are tagged without the suffix.
+	ref = strings.TrimSuffix(version, "+incompatible")
+	if dir != "" {
+		ref = dir + "/" + ref
+	}
+	return dir, ref
+}
+
+// gitLabToken returns the token sent to the GitLab API on host and the
+// header it goes in: GITLAB_TOKEN, a personal, group, or project access
+// token, on gitlab.com and the hosts in GitLabHosts only; then CI_JOB_TOKEN
+// inside a GitLab CI job on the same host; then the netrc password for the
+// host, which go's own GitLab setup stores a personal access token in.
+func (f *Fetcher) gitLabToken(host string) (header, token string) {
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" && f.isGitLabTokenHost(host) {
+		return "PRIVATE-TOKEN", token
+	}
+	if token := os.Getenv("CI_JOB_TOKEN"); token != "" && normalizeHost(os.Getenv("CI_SERVER_HOST")) == normalizeHost(host) {
+		return "JOB-TOKEN", token
+	}
+	if m := findMachine(f.Netrc, host); m != nil && m.Password != "" {
+		return "PRIVATE-TOKEN", m.Password
+	}
+	return "", ""
+}
+
+// isGitLabTokenHost reports whether GITLAB_TOKEN may be sent to host:
+// gitlab.com or one of GitLabHosts. Unlike isGitLabHost, it doesn't trust
+// any host named gitlab.*, which anyone can register.
+func (f *Fetcher) isGitLabTokenHost(host string) bool {
+	host = normalizeHost(host)
+	if name, _ := splitHostPort(host); name == "gitlab.com" {
+		return true
+	}
+	for _, h := range f.GitLabHosts {
+		if normalizeHost(h) == host {
+			return true
+		}
+	}
+	return false
+}
+
+// headerTransport sets a header on every request to host, for token
+// authentication. Requests redirected to other hosts go without it.
+type headerTransport struct {
+	base   http.RoundTripper
+	host   string
+	header string
+	value  string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.host {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(t.header, t.value)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,226 @@
+package fetch
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/git-lfs/go-netrc/netrc"
+)
+
+func TestIsGitLabHost(t *testing.T) {
+	f := &Fetcher{GitLabHosts: []string{"code.example.com", "git.example.com:8443"}}
+	for host, want := range map[string]bool{
+		"gitlab.com":           true,
+		"GitLab.com":           true,
+		"gitlab.example.com":   true,
+		"code.example.com":     true,
+		"git.example.com:8443": true,
+		"git.example.com":      false,
+		"github.com":           false,
+		"mygitlab.com":         false,
+	} {
+		if got := f.isGitLabHost(host); got != want {
+			t.Errorf("isGitLabHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestBuildGitLabURL(t *testing.T) {
+	f := &Fetcher{GitLabHosts: []string{"code.example.com"}}
+	tests := []struct {
+		modulePath  string
+		version     string
+		wantURL     string
+		wantSubdir  string
+		wantGuessed bool
+	}{
+		{
+			modulePath:  "gitlab.com/acme/lib",
+			version:     "v1.2.0",
+			wantURL:     "https://gitlab.com/api/v4/projects/acme%2Flib/repository/archive.zip?sha=v1.2.0",
+			wantGuessed: true,
+		},
+		{
+			modulePath: "gitlab.com/acme/platform/sdk.git/tools/v2",
+			version:    "v2.0.1",
+			wantURL:    "https://gitlab.com/api/v4/projects/acme%2Fplatform%2Fsdk/repository/archive.zip?sha=tools%2Fv2.0.1",
+			wantSubdir: "tools",
+		},
+		{
+			modulePath:  "code.example.com/acme/lib",
+			version:     "v0.0.0-20240102150405-abcdef123456",
+			wantURL:     "https://code.example.com/api/v4/projects/acme%2Flib/repository/archive.zip?sha=abcdef123456",
+			wantGuessed: true,
+		},
+		{
+			modulePath:  "gitlab.com/acme/lib",
+			version:     "v2.0.0+incompatible",
+			wantURL:     "https://gitlab.com/api/v4/projects/acme%2Flib/repository/archive.zip?sha=v2.0.0",
+			wantGuessed: true,
+		},
+		{modulePath: "gitlab.com/acme", version: "v1.0.0"},
+		{modulePath: "git.example.com/acme/lib", version: "v1.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.modulePath, func(t *testing.T) {
+			u, subdir, guessed, ok := f.buildGitLabURL(tt.modulePath, tt.version)
+			if u != tt.wantURL || subdir != tt.wantSubdir || guessed != tt.wantGuessed || ok != (tt.wantURL != "") {
+				t.Errorf("buildGitLabURL() = %q, %q, %v, %v; want %q, %q, %v", u, subdir, guessed, ok, tt.wantURL, tt.wantSubdir, tt.wantGuessed)
+			}
+			if ok && !isGitLabArchiveURL(u) {
+				t.Errorf("isGitLabArchiveURL(%q) = false", u)
+			}
+		})
+	}
+}
+
+func TestGitLabToken(t *testing.T) {
+	rc, err := netrc.ParseFile(writeNetrc(t, "machine gitlab.example.com login me password glpat-netrc\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &Fetcher{Netrc: rc, GitLabHosts: []string{"code.example.com"}}
+
+	t.Setenv("GITLAB_TOKEN", "")
+	t.Setenv("CI_JOB_TOKEN", "")
+	if header, token := f.gitLabToken("gitlab.example.com"); header != "PRIVATE-TOKEN" || token != "glpat-netrc" {
+		t.Errorf("gitLabToken() = %q, %q; want the netrc password", header, token)
+	}
+	if _, token := f.gitLabToken("gitlab.com"); token != "" {
+		t.Errorf("gitLabToken(gitlab.com) = %q, want none", token)
+	}
+
+	t.Setenv("CI_JOB_TOKEN", "job")
+	t.Setenv("CI_SERVER_HOST", "gitlab.example.com")
+	if header, token := f.gitLabToken("gitlab.example.com"); header != "JOB-TOKEN" || token != "job" {
+		t.Errorf("gitLabToken() in CI = %q, %q; want the job token", header, token)
+	}
+	if _, token := f.gitLabToken("gitlab.com"); token != "" {
+		t.Errorf("gitLabToken(gitlab.com) in CI = %q, want no job token for another host", token)
+	}
+
+	t.Setenv("GITLAB_TOKEN", "glpat-env")
+	for _, host := range []string{"gitlab.com", "code.example.com"} {
+		if header, token := f.gitLabToken(host); header != "PRIVATE-TOKEN" || token != "glpat-env" {
+			t.Errorf("gitLabToken(%s) with GITLAB_TOKEN = %q, %q", host, header, token)
+		}
+	}
+	// Anyone can name a host gitlab.*, so GITLAB_TOKEN only goes to the
+	// hosts configured for it.
+	t.Setenv("CI_JOB_TOKEN", "")
+	if _, token := f.gitLabToken("gitlab.attacker.example"); token != "" {
+		t.Errorf("gitLabToken(gitlab.attacker.example) = %q, want none", token)
+	}
+	if _, token := f.gitLabToken("gitlab.example.com"); token != "glpat-netrc" {
+		t.Errorf("gitLabToken(gitlab.example.com) with GITLAB_TOKEN = %q, want the netrc password", token)
+	}
+}
+
+func TestHeaderTransportRedirect(t *testing.T) {
+	var leaked string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = r.Header.Get("PRIVATE-TOKEN")
+	}))
+	defer other.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, other.URL+"/archive.zip", http.StatusFound)
+	}))
+	defer api.Close()
+
+	u, _ := url.Parse(api.URL)
+	client := &http.Client{Transport: &headerTransport{base: http.DefaultTransport, host: u.Host, header: "PRIVATE-TOKEN", value: "secret"}}
+	resp, err := client.Get(api.URL + "/api/v4/projects/acme%2Flib/repository/archive.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want the redirect followed", resp.StatusCode)
+	}
+	if leaked != "" {
+		t.Errorf("token sent to the redirect target: %q", leaked)
+	}
+}
+
+func writeNetrc(t *testing.T, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// TestFetchGitLabSubgroup fetches a nested module of a project in a GitLab
+// subgroup, found through its go-import meta tags, from the API archive
+// endpoint with a personal access token.
+func TestFetchGitLabSubgroup(t *testing.T) {
+	const modulePath = "gitlab.example.com/acme/platform/sdk/tools"
+	t.Setenv("GITLAB_TOKEN", "glpat-test")
+
+	var archive strings.Builder
+	zw := zip.NewWriter(&archive)
+	for name, content := range map[string]string{
+		"sdk-v1.2.0-0123/README":        "sdk\n",
+		"sdk-v1.2.0-0123/tools/go.mod":  "module " + modulePath + "\n",
+		"sdk-v1.2.0-0123/tools/lint.go": "package tools\n",
+	} {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("go-get") == "1":
+			w.Write([]byte(`<head><meta name="go-import" content="gitlab.example.com/acme/platform/sdk git https://gitlab.example.com/acme/platform/sdk.git"></head>`))
+		case r.URL.EscapedPath() == "/api/v4/projects/acme%2Fplatform%2Fsdk/repository/archive.zip" && r.URL.Query().Get("sha") == "tools/v1.2.0":
+			if r.Header.Get("PRIVATE-TOKEN") != "glpat-test" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(archive.String()))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	transport := srv.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+	}
+	f := &Fetcher{CacheDir: t.TempDir(), GitLabHosts: []string{"gitlab.example.com"}}
+	f.dialOnce.Do(func() { f.base = transport })
+
+	result, err := f.Fetch(modulePath, "v1.2.0")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if want := "https://gitlab.example.com/api/v4/projects/acme%2Fplatform%2Fsdk/repository/archive.zip?sha=tools%2Fv1.2.0"; result.URL != want {
+		t.Errorf("URL = %q, want %q", result.URL, want)
+	}
+	if result.HashType != HashNAR || result.Subdir != "tools" {
+		t.Errorf("HashType = %q, Subdir = %q; want nar and tools", result.HashType, result.Subdir)
+	}
+	if _, err := os.Stat(filepath.Join(result.Dir, "lint.go")); err != nil {
+		t.Errorf("lint.go not extracted: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(result.Dir, "README")); err == nil {
+		t.Error("README outside the module was extracted")
+	}
+}
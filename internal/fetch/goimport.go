@@ -0,0 +1,254 @@
+package fetch
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// goImportTTL is how long cached go-import meta tags are reused.
+const goImportTTL = 24 * time.Hour
+
+// goImport is a <meta name="go-import"> tag served for ?go-get=1.
+type goImport struct {
+	Prefix   string // import path prefix of the repository root
+	VCS      string
+	RepoRoot string
+	Subdir   string // directory of Prefix within the repository, if not its root
+}
+
+// isVanity reports whether modulePath is a vanity import path whose
+// repository is best found from its go-import meta tags: not a github.com
+// or gopkg.in path, and not a family pinned by a monorepos entry without a
+// wildcard. Wildcard entries such as k8s.io/* only say where most of a
+// host's repositories live (k8s.io/helm is github.com/helm/helm), so they
+// are the fallback when the meta tags can't be fetched.
+func isVanity(modulePath string) bool {
+	if strings.HasPrefix(modulePath, "github.com/") || strings.HasPrefix(modulePath, "gopkg.in/") {
+		return false
+	}
+	for _, m := range monorepos {
+		if _, _, ok := m.match(modulePath); ok && !strings.HasSuffix(m.Prefix, "/*") {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupGitHubRepo is gitHubRepo, but vanity import paths are first looked
+// up in their go-import meta tags, as the go command does.
+func (f *Fetcher) lookupGitHubRepo(modulePath string) (repo, subpath string, ok bool) {
+	if isVanity(modulePath) {
+		imports, err := f.goImports(modulePath)
+		if err != nil && f.Verbose {
+			fmt.Fprintf(os.Stderr, "warning: go-import lookup for %s: %v\n", modulePath, err)
+		}
+		if repo, subpath, ok := gitHubFromGoImports(imports, modulePath); ok {
+			return repo, subpath, true
+		}
+	}
+	return gitHubRepo(modulePath)
+}
+
+// goImports fetches the go-import meta tags for modulePath from
+// https://<modulePath>?go-get=1. Responses are cached for goImportTTL.
+func (f *Fetcher) goImports(modulePath string) ([]goImport, error) {
+	rawURL := "https://" + modulePath + "?go-get=1"
+
+	cached, cachePath := f.loadMeta(rawURL)
+	if cached != nil && time.Since(cached.Fetched) < goImportTTL {
+		return parseGoImports(strings.NewReader(cached.Body))
+	}
+
+	client := f.client(modulePath, rawURL)
+	client.Timeout = 30 * time.Second
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", rawURL, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	f.storeMeta(cachePath, rawURL, body)
+	return parseGoImports(strings.NewReader(string(body)))
+}
+
+// parseGoImports returns the go-import meta tags in the <head> of an HTML
+// page, parsed leniently the way the go command does.
+func parseGoImports(r io.Reader) ([]goImport, error) {
+	d := xml.NewDecoder(r)
+	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		switch strings.ToLower(charset) {
+		case "utf-8", "ascii":
+			return input, nil
+		}
+		return nil, fmt.Errorf("can't decode XML document using charset %q", charset)
+	}
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+
+	var imports []goImport
+	for {
+		t, err := d.RawToken()
+		if err != nil {
+			if err == io.EOF || len(imports) > 0 {
+				break
+			}
+			return nil, err
+		}
+		if e, ok := t.(xml.StartElement); ok && strings.EqualFold(e.Name.Local, "body") {
+			break
+		}
+		if e, ok := t.(xml.EndElement); ok && strings.EqualFold(e.Name.Local, "head") {
+			break
+		}
+		e, ok := t.(xml.StartElement)
+		if !ok || !strings.EqualFold(e.Name.Local, "meta") || attrValue(e.Attr, "name") != "go-import" {
+			continue
+		}
+		fields := strings.Fields(attrValue(e.Attr, "content"))
+		if len(fields) < 3 {
+			continue
+		}
+		imp := goImport{Prefix: fields[0], VCS: fields[1], RepoRoot: fields[2]}
+		if len(fields) > 3 {
+			imp.Subdir = fields[3]
+		}
+		imports = append(imports, imp)
+	}
+	return imports, nil
+}
+
+func attrValue(attrs []xml.Attr, name string) string {
+	for _, a := range attrs {
+		if strings.EqualFold(a.Name.Local, name) {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// matchGoImport returns the import in imports that applies to modulePath,
+// the one with the longest prefix of it, and the path of modulePath within
+// its repository. A repository is preferred over a module proxy ("mod")
+// entry for the same prefix, as the go command does.
+func matchGoImport(imports []goImport, modulePath string) (imp goImport, subpath string, ok bool) {
+	var best *goImport
+	for i, imp := range imports {
+		if modulePath != imp.Prefix && !strings.HasPrefix(modulePath, imp.Prefix+"/") {
+			continue
+		}
+		if best == nil || len(imp.Prefix) > len(best.Prefix) || (len(imp.Prefix) == len(best.Prefix) && best.VCS == "mod") {
+			best = &imports[i]
+		}
+	}
+	if best == nil {
+		return goImport{}, "", false
+	}
+
+	rest := strings.TrimPrefix(strings.TrimPrefix(modulePath, best.Prefix), "/")
+	if best.Subdir != "" {
+		rest = path.Join(best.Subdir, rest)
+	}
+	return *best, rest, true
+}
+
+// gitHubFromGoImports returns the GitHub repository and the path within it
+// of modulePath according to imports: the git import with the longest
+// prefix of modulePath, if its repository is on github.com.
+func gitHubFromGoImports(imports []goImport, modulePath string) (repo, subpath string, ok bool) {
+	imp, subpath, ok := matchGoImport(imports, modulePath)
+	if !ok || imp.VCS != "git" {
+		return "", "", false
+	}
+
+	root, found := strings.CutPrefix(imp.RepoRoot, "https://github.com/")
+	if !found {
+		return "", "", false
+	}
+	owner, name, found := strings.Cut(strings.TrimSuffix(strings.TrimSuffix(root, "/"), ".git"), "/")
+	if !found || owner == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", false
+	}
+	return owner + "/" + name, subpath, true
+}
+
+// vanityArchive returns the download URL of a vanity import path found
+// through its go-import meta tags, and the module's directory within the
+// downloaded archive. It succeeds when the tags point at a module proxy
+// ("mod"), or at a git repository on a forge whose archive URLs are known;
+// repositories on GitHub are left to lookupGitHubRepo. Paths on GitLab
+// hosts are looked up the same way, since GitLab's meta tags tell which
+// part of the path is a project in a subgroup.
+func (f *Fetcher) vanityArchive(modulePath, version string) (downloadURL, subdir string, ok bool) {
+	if !isVanity(modulePath) {
+		return "", "", false
+	}
+	imports, err := f.goImports(modulePath)
+	if err != nil {
+		return "", "", false
+	}
+	imp, subpath, ok := matchGoImport(imports, modulePath)
+	if !ok {
+		return "", "", false
+	}
+
+	switch imp.VCS {
+	case "mod":
+		return fmt.Sprintf("%s/%s/@v/%s.zip", strings.TrimSuffix(imp.RepoRoot, "/"), escapePath(modulePath), escapeVersion(version)), "", true
+	case "git":
+		dir, ref := archiveRef(subpath, version)
+		if u, ok := f.forgeArchiveURL(imp.RepoRoot, ref); ok {
+			return u, dir, true
+		}
+	}
+	return "", "", false
+}
+
+// directArchive returns the download URL of a module fetched directly from
+// a forge other than GitHub, and the module's directory within the
+// archive: from its go-import meta tags, or for a GitLab host without them,
+// from buildGitLabURL, which may guess.
+func (f *Fetcher) directArchive(modulePath, version string) (downloadURL, subdir string, guessed, ok bool) {
+	if u, dir, ok := f.vanityArchive(modulePath, version); ok {
+		return u, dir, false, true
+	}
+	return f.buildGitLabURL(modulePath, version)
+}
+
+// forgeArchiveURL returns the URL of the zip archive of ref, a tag or
+// commit, in the git repository at repoRoot. Only forges whose archive URLs
+// are known are supported: GitLab (see isGitLabHost), Bitbucket Cloud, and
+// Gitea and Forgejo, which are recognized on codeberg.org, gitea.com, and
+// hosts named gitea.* or forgejo.*.
+func (f *Fetcher) forgeArchiveURL(repoRoot, ref string) (string, bool) {
+	u, err := url.Parse(strings.TrimSuffix(strings.TrimSuffix(repoRoot, "/"), ".git"))
+	if err != nil || u.Scheme != "https" || strings.Count(strings.Trim(u.Path, "/"), "/") < 1 {
+		return "", false
+	}
+	root := u.String()
+
+	host := u.Hostname()
+	switch {
+	case f.isGitLabHost(u.Host):
+		return gitLabArchiveURL(u.Host, strings.Trim(u.Path, "/"), ref), true
+	case host == "bitbucket.org":
+		return root + "/get/" + ref + ".zip", true
+	case host == "codeberg.org", host == "gitea.com",
+		strings.HasPrefix(host, "gitea."), strings.HasPrefix(host, "forgejo."):
+		return root + "/archive/" + ref + ".zip", true
+	}
+	return "", false
+}
@@ -0,0 +1,229 @@
+package fetch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// k8sAPIPage is the ?go-get=1 response of k8s.io/api, which is developed in
+// the staging directory of kubernetes/kubernetes and published to its own
+// repository.
+const k8sAPIPage = `<html><head>
+<meta name="go-import" content="k8s.io/api git https://github.com/kubernetes/api">
+<meta name="go-source" content="k8s.io/api https://github.com/kubernetes/api https://github.com/kubernetes/api/tree/master{/dir} https://github.com/kubernetes/api/blob/master{/dir}/{file}#L{line}">
+</head><body>Nothing to see here; <a href="https://pkg.go.dev/k8s.io/api">see the package on pkg.go.dev</a>.</body></html>`
+
+func TestParseGoImports(t *testing.T) {
+	got, err := parseGoImports(strings.NewReader(k8sAPIPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []goImport{{Prefix: "k8s.io/api", VCS: "git", RepoRoot: "https://github.com/kubernetes/api"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGoImports() = %+v, want %+v", got, want)
+	}
+
+	page := `<!DOCTYPE html><html><head><meta charset="utf-8">
+<meta name="go-import" content="example.com/mono git https://github.com/acme/mono go">
+<meta name="go-import" content="example.com/mono mod https://proxy.example.com">
+<body><meta name="go-import" content="example.com/ignored git https://github.com/acme/ignored">`
+	got, err = parseGoImports(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []goImport{
+		{Prefix: "example.com/mono", VCS: "git", RepoRoot: "https://github.com/acme/mono", Subdir: "go"},
+		{Prefix: "example.com/mono", VCS: "mod", RepoRoot: "https://proxy.example.com"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGoImports() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGitHubFromGoImports(t *testing.T) {
+	tests := []struct {
+		name        string
+		imports     []goImport
+		modulePath  string
+		wantRepo    string
+		wantSubpath string
+		wantOK      bool
+	}{
+		{
+			name:       "staged k8s repository",
+			imports:    []goImport{{Prefix: "k8s.io/client-go", VCS: "git", RepoRoot: "https://github.com/kubernetes/client-go"}},
+			modulePath: "k8s.io/client-go",
+			wantRepo:   "kubernetes/client-go",
+			wantOK:     true,
+		},
+		{
+			name:        "sigs.k8s.io major version",
+			imports:     []goImport{{Prefix: "sigs.k8s.io/structured-merge-diff", VCS: "git", RepoRoot: "https://github.com/kubernetes-sigs/structured-merge-diff.git"}},
+			modulePath:  "sigs.k8s.io/structured-merge-diff/v4",
+			wantRepo:    "kubernetes-sigs/structured-merge-diff",
+			wantSubpath: "v4",
+			wantOK:      true,
+		},
+		{
+			name:       "k8s.io path outside the kubernetes organization",
+			imports:    []goImport{{Prefix: "k8s.io/helm", VCS: "git", RepoRoot: "https://github.com/helm/helm"}},
+			modulePath: "k8s.io/helm",
+			wantRepo:   "helm/helm",
+			wantOK:     true,
+		},
+		{
+			name: "longest prefix and subdirectory",
+			imports: []goImport{
+				{Prefix: "example.com/mono", VCS: "git", RepoRoot: "https://github.com/acme/other"},
+				{Prefix: "example.com/mono/tools", VCS: "git", RepoRoot: "https://github.com/acme/mono", Subdir: "go"},
+			},
+			modulePath:  "example.com/mono/tools/lint",
+			wantRepo:    "acme/mono",
+			wantSubpath: "go/lint",
+			wantOK:      true,
+		},
+		{
+			name:       "module proxy entries are skipped",
+			imports:    []goImport{{Prefix: "example.com/mod", VCS: "mod", RepoRoot: "https://proxy.example.com"}},
+			modulePath: "example.com/mod",
+		},
+		{
+			name: "repository preferred over module proxy",
+			imports: []goImport{
+				{Prefix: "example.com/mod", VCS: "mod", RepoRoot: "https://proxy.example.com"},
+				{Prefix: "example.com/mod", VCS: "git", RepoRoot: "https://github.com/acme/mod"},
+			},
+			modulePath: "example.com/mod",
+			wantRepo:   "acme/mod",
+			wantOK:     true,
+		},
+		{
+			name:       "not on GitHub",
+			imports:    []goImport{{Prefix: "golang.org/x/mod", VCS: "git", RepoRoot: "https://go.googlesource.com/mod"}},
+			modulePath: "golang.org/x/mod",
+		},
+		{
+			name:       "prefix of another path element",
+			imports:    []goImport{{Prefix: "example.com/mod", VCS: "git", RepoRoot: "https://github.com/acme/mod"}},
+			modulePath: "example.com/module",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, subpath, ok := gitHubFromGoImports(tt.imports, tt.modulePath)
+			if repo != tt.wantRepo || subpath != tt.wantSubpath || ok != tt.wantOK {
+				t.Errorf("gitHubFromGoImports() = %q, %q, %v; want %q, %q, %v", repo, subpath, ok, tt.wantRepo, tt.wantSubpath, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLookupGitHubRepoFromCachedMetaTags(t *testing.T) {
+	f := &Fetcher{CacheDir: t.TempDir()}
+	for modulePath, page := range map[string]string{
+		"k8s.io/api":  k8sAPIPage,
+		"k8s.io/helm": `<head><meta name="go-import" content="k8s.io/helm git https://github.com/helm/helm"></head>`,
+	} {
+		rawURL := "https://" + modulePath + "?go-get=1"
+		_, cachePath := f.loadMeta(rawURL)
+		f.storeMeta(cachePath, rawURL, []byte(page))
+	}
+
+	if repo, _, ok := f.lookupGitHubRepo("k8s.io/api"); !ok || repo != "kubernetes/api" {
+		t.Errorf("lookupGitHubRepo(k8s.io/api) = %q, %v; want kubernetes/api", repo, ok)
+	}
+	// The meta tags win over the k8s.io/* default.
+	if repo, _, ok := f.lookupGitHubRepo("k8s.io/helm"); !ok || repo != "helm/helm" {
+		t.Errorf("lookupGitHubRepo(k8s.io/helm) = %q, %v; want helm/helm", repo, ok)
+	}
+	if u, _ := f.resolveDirectURL("k8s.io/helm", "v2.17.0+incompatible"); !strings.HasPrefix(u, "https://github.com/helm/helm/archive/") {
+		t.Errorf("resolveDirectURL(k8s.io/helm) = %q, want a helm/helm archive", u)
+	}
+}
+
+func TestVanityArchiveFromCachedMetaTags(t *testing.T) {
+	f := &Fetcher{CacheDir: t.TempDir()}
+	for modulePath, page := range map[string]string{
+		"go.example.com/proxied": `<head><meta name="go-import" content="go.example.com/proxied mod https://athens.example.com/"></head>`,
+		"go.example.com/forge": `<head>
+<meta name="go-import" content="go.example.com/forge mod https://athens.example.com">
+<meta name="go-import" content="go.example.com/forge git https://codeberg.org/acme/forge.git">
+</head>`,
+		"go.example.com/forge/tools/v2": `<head><meta name="go-import" content="go.example.com/forge git https://codeberg.org/acme/forge"></head>`,
+		"go.example.com/bb":             `<head><meta name="go-import" content="go.example.com/bb git https://bitbucket.org/acme/bb"></head>`,
+		"go.example.com/hg":             `<head><meta name="go-import" content="go.example.com/hg hg https://hg.example.com/hg"></head>`,
+		"go.example.com/selfhosted":     `<head><meta name="go-import" content="go.example.com/selfhosted git https://git.example.com/acme/selfhosted"></head>`,
+	} {
+		rawURL := "https://" + modulePath + "?go-get=1"
+		_, cachePath := f.loadMeta(rawURL)
+		f.storeMeta(cachePath, rawURL, []byte(page))
+	}
+
+	tests := []struct {
+		modulePath string
+		version    string
+		wantURL    string
+		wantSubdir string
+	}{
+		{"go.example.com/proxied", "v1.0.0", "https://athens.example.com/go.example.com/proxied/@v/v1.0.0.zip", ""},
+		{"go.example.com/forge", "v1.2.0", "https://codeberg.org/acme/forge/archive/v1.2.0.zip", ""},
+		{"go.example.com/forge/tools/v2", "v2.1.0", "https://codeberg.org/acme/forge/archive/tools/v2.1.0.zip", "tools"},
+		{"go.example.com/bb", "v0.0.0-20240102150405-abcdef123456", "https://bitbucket.org/acme/bb/get/abcdef123456.zip", ""},
+		{"go.example.com/hg", "v1.0.0", "", ""},
+		{"go.example.com/selfhosted", "v1.0.0", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.modulePath, func(t *testing.T) {
+			u, subdir, ok := f.vanityArchive(tt.modulePath, tt.version)
+			if u != tt.wantURL || subdir != tt.wantSubdir || ok != (tt.wantURL != "") {
+				t.Errorf("vanityArchive() = %q, %q, %v; want %q, %q", u, subdir, ok, tt.wantURL, tt.wantSubdir)
+			}
+		})
+	}
+
+	if u, guessed := f.resolveDirectURL("go.example.com/forge", "v1.2.0"); guessed || u != "https://codeberg.org/acme/forge/archive/v1.2.0.zip" {
+		t.Errorf("resolveDirectURL(go.example.com/forge) = %q, %v; want the codeberg archive", u, guessed)
+	}
+	if _, guessed := f.resolveDirectURL("go.example.com/selfhosted", "v1.0.0"); !guessed {
+		t.Error("resolveDirectURL(go.example.com/selfhosted) should be a guess")
+	}
+}
+
+func TestForgeArchiveURL(t *testing.T) {
+	tests := []struct {
+		repoRoot string
+		want     string
+	}{
+		{"https://bitbucket.org/acme/lib", "https://bitbucket.org/acme/lib/get/v1.0.0.zip"},
+		{"https://codeberg.org/acme/lib.git", "https://codeberg.org/acme/lib/archive/v1.0.0.zip"},
+		{"https://gitea.example.com/acme/lib/", "https://gitea.example.com/acme/lib/archive/v1.0.0.zip"},
+		{"https://forgejo.example.com/acme/lib", "https://forgejo.example.com/acme/lib/archive/v1.0.0.zip"},
+		{"https://git.example.com/acme/lib", ""},
+		{"http://gitea.example.com/acme/lib", ""},
+		{"https://codeberg.org/acme", ""},
+	}
+	for _, tt := range tests {
+		got, ok := (&Fetcher{}).forgeArchiveURL(tt.repoRoot, "v1.0.0")
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("forgeArchiveURL(%q) = %q, %v; want %q", tt.repoRoot, got, ok, tt.want)
+		}
+	}
+}
+
+func TestIsVanity(t *testing.T) {
+	for modulePath, want := range map[string]bool{
+		"k8s.io/api":                 true,
+		"sigs.k8s.io/yaml":           true,
+		"git.example.com/team/lib":   true,
+		"github.com/owner/repo":      false,
+		"gopkg.in/yaml.v3":           false,
+		"google.golang.org/grpc":     false,
+		"cloud.google.com/go/pubsub": false,
+	} {
+		if got := isVanity(modulePath); got != want {
+			t.Errorf("isVanity(%q) = %v, want %v", modulePath, got, want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package fetch
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchFromModCache(t *testing.T) {
+	const (
+		modulePath = "golang.org/x/mod"
+		version    = "v0.32.0"
+	)
+
+	out, err := exec.Command("go", "env", "GOMODCACHE").Output()
+	if err != nil {
+		t.Skip("go env GOMODCACHE unavailable")
+	}
+	cacheDir := strings.TrimSpace(string(out))
+	zipPath := filepath.Join(cacheDir, "cache", "download", modulePath, "@v", version+".zip")
+	if _, err := os.Stat(zipPath); err != nil {
+		t.Skipf("%s@%s not in module cache", modulePath, version)
+	}
+	h1, err := os.ReadFile(strings.TrimSuffix(zipPath, ".zip") + ".ziphash")
+	if err != nil {
+		t.Skip("ziphash not in module cache")
+	}
+	t.Setenv("GOMODCACHE", cacheDir)
+
+	f := &Fetcher{Proxy: DefaultProxy}
+	result, err := f.FetchFromModCache(modulePath, version, strings.TrimSpace(string(h1)))
+	if err != nil {
+		t.Fatalf("FetchFromModCache() error = %v", err)
+	}
+	if !strings.HasPrefix(result.Hash, "sha256-") {
+		t.Errorf("Hash = %q, want SRI sha256", result.Hash)
+	}
+	if result.URL != "https://proxy.golang.org/golang.org/x/mod/@v/v0.32.0.zip" {
+		t.Errorf("URL = %q", result.URL)
+	}
+	if result.Files == 0 || result.Size == 0 {
+		t.Errorf("Files = %d, Size = %d, want non-zero", result.Files, result.Size)
+	}
+
+	if _, err := f.FetchFromModCache(modulePath, version, "h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="); err == nil {
+		t.Error("FetchFromModCache() with mismatched h1 should fail")
+	}
+}
+
+func TestFetchFromModCacheSkips(t *testing.T) {
+	t.Setenv("GOMODCACHE", t.TempDir())
+
+	tests := []struct {
+		name       string
+		fetcher    *Fetcher
+		modulePath string
+	}{
+		{"no proxy", &Fetcher{}, "golang.org/x/mod"},
+		{"private", &Fetcher{Proxy: DefaultProxy, Private: "golang.org/x/*"}, "golang.org/x/mod"},
+		{"github needs rev", &Fetcher{Proxy: DefaultProxy}, "github.com/spf13/cobra"},
+		{"not cached", &Fetcher{Proxy: DefaultProxy}, "example.com/missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.fetcher.FetchFromModCache(tt.modulePath, "v1.0.0", "h1:abc=")
+			if !errors.Is(err, ErrNotInModCache) {
+				t.Errorf("FetchFromModCache() error = %v, want ErrNotInModCache", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package fetch
+
+import (
+	"net"
+	"strings"
+
+	"github.com/git-lfs/go-netrc/netrc"
+	"golang.org/x/net/idna"
+)
+
+// normalizeHost returns host, which may carry a port, in the canonical form
+// used for URLs and credential lookups: lower case, without a trailing dot,
+// and with internationalized labels in their ASCII (punycode) form. Hosts
+// that are not valid domain names, such as IP addresses, are only lowered.
+func normalizeHost(host string) string {
+	name, port := splitHostPort(host)
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if ascii, err := idna.Lookup.ToASCII(name); err == nil {
+		name = ascii
+	}
+	if port != "" {
+		return net.JoinHostPort(name, port)
+	}
+	if strings.Contains(name, ":") {
+		return "[" + name + "]"
+	}
+	return name
+}
+
+// splitHostPort splits an optional port off host, unlike net.SplitHostPort
+// which requires one. IPv6 addresses are returned without brackets.
+func splitHostPort(host string) (name, port string) {
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		return h, p
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"), ""
+}
+
+// hostNames returns the names a credential entry for host may be written
+// as, most specific first: the normalized host with its port, then without
+// it, each followed by its Unicode form when it is an internationalized
+// name.
+func hostNames(host string) []string {
+	host = normalizeHost(host)
+	name, port := splitHostPort(host)
+
+	forms := []string{name}
+	if unicode, err := idna.Lookup.ToUnicode(name); err == nil && unicode != name {
+		forms = append(forms, unicode)
+	}
+
+	var names []string
+	if port != "" {
+		for _, n := range forms {
+			names = append(names, net.JoinHostPort(n, port))
+		}
+	}
+	return append(names, forms...)
+}
+
+// findMachine returns the netrc entry for the first of hosts that has one,
+// trying every name each host may be written as (see hostNames), and falls
+// back to the default entry.
+func findMachine(rc *netrc.Netrc, hosts ...string) *netrc.Machine {
+	if rc == nil {
+		return nil
+	}
+	var def *netrc.Machine
+	for _, host := range hosts {
+		for _, name := range hostNames(host) {
+			m := rc.FindMachine(name, "")
+			if m == nil {
+				continue
+			}
+			if !m.IsDefault() {
+				return m
+			}
+			def = m
+		}
+	}
+	return def
+}
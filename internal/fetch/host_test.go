@@ -0,0 +1,111 @@
+package fetch
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/git-lfs/go-netrc/netrc"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		host, want string
+	}{
+		{"github.com", "github.com"},
+		{"GitHub.COM", "github.com"},
+		{"example.com.", "example.com"},
+		{"Example.com:8443", "example.com:8443"},
+		{"bücher.example", "xn--bcher-kva.example"},
+		{"BÜCHER.example:443", "xn--bcher-kva.example:443"},
+		{"xn--bcher-kva.example", "xn--bcher-kva.example"},
+		{"127.0.0.1:8080", "127.0.0.1:8080"},
+		{"[::1]:8080", "[::1]:8080"},
+		{"[::1]", "[::1]"},
+	}
+	for _, tt := range tests {
+		if got := normalizeHost(tt.host); got != tt.want {
+			t.Errorf("normalizeHost(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestExtractHostNormalizes(t *testing.T) {
+	if got := extractHost("Git.Example.com/team/repo"); got != "git.example.com" {
+		t.Errorf("extractHost() = %q, want %q", got, "git.example.com")
+	}
+	if got := extractHost("bücher.example/mod"); got != "xn--bcher-kva.example" {
+		t.Errorf("extractHost() = %q, want %q", got, "xn--bcher-kva.example")
+	}
+}
+
+func TestHostNames(t *testing.T) {
+	got := hostNames("Bücher.example:8443")
+	want := []string{"xn--bcher-kva.example:8443", "bücher.example:8443", "xn--bcher-kva.example", "bücher.example"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("hostNames() = %q, want %q", got, want)
+	}
+	if got := hostNames("GitHub.com"); !reflect.DeepEqual(got, []string{"github.com"}) {
+		t.Errorf("hostNames() = %q, want [github.com]", got)
+	}
+}
+
+func TestFindMachine(t *testing.T) {
+	rc, err := netrc.Parse(strings.NewReader(`machine git.example.com:8443 login port password p1
+machine bücher.example login idn password p2
+machine git.example.com login plain password p3
+default login anon password p4
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		hosts []string
+		want  string
+	}{
+		{[]string{"GIT.example.com:8443"}, "port"},
+		{[]string{"git.example.com:9000"}, "plain"},
+		{[]string{"xn--bcher-kva.example"}, "idn"},
+		{[]string{"other.example", "Git.Example.com"}, "plain"}, // exact entries beat default
+		{[]string{"other.example"}, "anon"},
+	}
+	for _, tt := range tests {
+		m := findMachine(rc, tt.hosts...)
+		if m == nil || m.Login != tt.want {
+			t.Errorf("findMachine(%q) = %+v, want login %q", tt.hosts, m, tt.want)
+		}
+	}
+	if m := findMachine(nil, "git.example.com"); m != nil {
+		t.Errorf("findMachine(nil) = %+v, want nil", m)
+	}
+}
+
+func TestSigningTransportNormalizesHosts(t *testing.T) {
+	var signed bool
+	f := &Fetcher{Signers: map[string]Signer{
+		"Proxy.Example.com": SignerFunc(func(*http.Request) error {
+			signed = true
+			return nil
+		}),
+	}}
+	rt := f.transport().(*signingTransport)
+	rt.base = roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "https://PROXY.example.com:443/mod/@v/list", nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if !signed {
+		t.Error("request to a differently cased host was not signed")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
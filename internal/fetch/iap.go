@@ -0,0 +1,157 @@
+package fetch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMetadataURL is the GCE metadata server identity token endpoint.
+const DefaultMetadataURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/identity"
+
+// IAPSigner authenticates requests to a proxy behind Google Cloud
+// Identity-Aware Proxy with an OIDC identity token for Audience (the IAP
+// OAuth client ID). Tokens come from the GCE metadata server when available,
+// otherwise from gcloud, impersonating ServiceAccount when set.
+type IAPSigner struct {
+	Audience       string
+	ServiceAccount string
+
+	// MetadataURL overrides DefaultMetadataURL. Set it to "-" to skip the
+	// metadata server.
+	MetadataURL string
+	// Now overrides the clock used for token expiry.
+	Now func() time.Time
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// Sign implements Signer. The token goes in Authorization, or in
+// Proxy-Authorization when the request already carries credentials, which
+// IAP also accepts.
+func (s *IAPSigner) Sign(req *http.Request) error {
+	token, err := s.identityToken()
+	if err != nil {
+		return fmt.Errorf("iap: %w", err)
+	}
+	header := "Authorization"
+	if req.Header.Get("Authorization") != "" {
+		header = "Proxy-Authorization"
+	}
+	req.Header.Set(header, "Bearer "+token)
+	return nil
+}
+
+func (s *IAPSigner) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *IAPSigner) identityToken() (string, error) {
+	if s.Audience == "" {
+		return "", fmt.Errorf("audience is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && s.now().Add(time.Minute).Before(s.expiry) {
+		return s.token, nil
+	}
+
+	var token string
+	var err error
+	if s.ServiceAccount == "" && s.MetadataURL != "-" {
+		token, err = s.metadataToken()
+	}
+	if token == "" {
+		token, err = s.gcloudToken()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	s.token = token
+	s.expiry = tokenExpiry(token, s.now())
+	return token, nil
+}
+
+// metadataToken requests a token from the metadata server. An unreachable
+// server yields no token and no error, so gcloud is tried next.
+func (s *IAPSigner) metadataToken() (string, error) {
+	endpoint := s.MetadataURL
+	if endpoint == "" {
+		endpoint = DefaultMetadataURL
+	}
+	q := url.Values{"audience": {s.Audience}, "format": {"full"}}
+	req, err := http.NewRequest("GET", endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	client := http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("reading metadata token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+func (s *IAPSigner) gcloudToken() (string, error) {
+	args := []string{"auth", "print-identity-token", "--audiences=" + s.Audience}
+	if s.ServiceAccount != "" {
+		args = append(args, "--impersonate-service-account="+s.ServiceAccount, "--include-email")
+	}
+	out, err := exec.Command("gcloud", args...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("gcloud auth print-identity-token: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("gcloud auth print-identity-token: %w", err)
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", fmt.Errorf("gcloud auth print-identity-token returned no token")
+	}
+	return token, nil
+}
+
+// tokenExpiry returns the exp claim of a JWT, or five minutes from now when
+// it can't be read.
+func tokenExpiry(token string, now time.Time) time.Time {
+	fallback := now.Add(5 * time.Minute)
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fallback
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fallback
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return fallback
+	}
+	return time.Unix(claims.Exp, 0)
+}
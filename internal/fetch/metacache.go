@@ -0,0 +1,265 @@
+package fetch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// DefaultListTTL is how long cached @v/list and @latest responses are reused.
+const DefaultListTTL = 15 * time.Minute
+
+// ErrNoVersions is returned when a module has no versions available.
+var ErrNoVersions = errors.New("no versions available")
+
+// errProxyDirect is returned by proxyMeta when GOPROXY falls back to direct,
+// so the lookup has to be answered by the module's origin.
+var errProxyDirect = errors.New("falling back to direct")
+
+// metaEntry is a proxy metadata response stored on disk.
+type metaEntry struct {
+	URL     string    `json:"url"`
+	Fetched time.Time `json:"fetched"`
+	Body    string    `json:"body"`
+	// MAC authenticates the entry when Fetcher.CacheKey is set.
+	MAC string `json:"mac,omitempty"`
+}
+
+// macFields returns the fields of e its MAC covers.
+func (e *metaEntry) macFields() []string {
+	return []string{"meta", e.URL, e.Fetched.Format(time.RFC3339Nano), e.Body}
+}
+
+// Versions returns the known versions of modulePath in semver order, from
+// the proxy's @v/list endpoint or, for private modules and when GOPROXY
+// falls back to direct, go list. Responses are cached for ListTTL.
+func (f *Fetcher) Versions(modulePath string) ([]string, error) {
+	var versions []string
+	direct := f.isPrivate(modulePath) || f.proxyBase(modulePath) == ""
+	if !direct {
+		body, err := f.proxyMeta(modulePath, "@v/list", f.listTTL())
+		switch {
+		case errors.Is(err, errProxyDirect):
+			direct = true
+		case err != nil:
+			return nil, err
+		default:
+			versions = strings.Fields(string(body))
+		}
+	}
+	if direct {
+		out, err := f.goListModule(modulePath, "-versions")
+		if err != nil {
+			return nil, err
+		}
+		versions = out.Versions
+	}
+
+	var valid []string
+	for _, v := range versions {
+		if semver.IsValid(v) {
+			valid = append(valid, v)
+		}
+	}
+	semver.Sort(valid)
+	return valid, nil
+}
+
+// Latest returns the proxy's @latest metadata for modulePath, or go list's
+// for private modules and when GOPROXY falls back to direct. Responses are
+// cached for ListTTL.
+func (f *Fetcher) Latest(modulePath string) (*ModuleInfo, error) {
+	if f.isPrivate(modulePath) || f.proxyBase(modulePath) == "" {
+		return f.latestDirect(modulePath)
+	}
+
+	body, err := f.proxyMeta(modulePath, "@latest", f.listTTL())
+	if errors.Is(err, errProxyDirect) {
+		return f.latestDirect(modulePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var info ModuleInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("decoding %s@latest: %w", modulePath, err)
+	}
+	if info.Version == "" {
+		return nil, fmt.Errorf("%s: %w", modulePath, ErrNoVersions)
+	}
+	return &info, nil
+}
+
+// latestDirect returns go list's @latest metadata for modulePath.
+func (f *Fetcher) latestDirect(modulePath string) (*ModuleInfo, error) {
+	out, err := f.goListModule(modulePath + "@latest")
+	if err != nil {
+		return nil, err
+	}
+	return &ModuleInfo{Version: out.Version, Time: out.Time}, nil
+}
+
+func (f *Fetcher) listTTL() time.Duration {
+	if f.ListTTL == 0 {
+		return DefaultListTTL
+	}
+	return f.ListTTL
+}
+
+// proxyMeta fetches a metadata endpoint (such as "@v/list", "@latest", or
+// "@v/v1.0.0.info") for modulePath from its proxy. When the proxy fails,
+// the rest of GOPROXY is tried as for downloads: the next proxy, or
+// errProxyDirect when the list falls back to direct, which callers answer
+// with go list.
+func (f *Fetcher) proxyMeta(modulePath, endpoint string, ttl time.Duration) ([]byte, error) {
+	base := f.proxyBase(modulePath)
+	if base == "" {
+		return nil, fmt.Errorf("no module proxy configured for %s", modulePath)
+	}
+	body, err := f.proxyMetaFrom(base, modulePath, endpoint, ttl)
+	if err == nil || !f.viaProxy(modulePath) {
+		return body, err
+	}
+	for _, spec := range f.Fallback {
+		if !spec.OnError && !errors.Is(err, ErrNoVersions) {
+			break
+		}
+		switch spec.URL {
+		case ProxyOff:
+			return nil, fmt.Errorf("%w after %v", ErrProxyOff, err)
+		case ProxyDirect:
+			return nil, fmt.Errorf("%w after %v", errProxyDirect, err)
+		}
+		if f.Verbose {
+			fmt.Fprintf(os.Stderr, "Falling back to %s for %s %s: %v\n", spec.URL, modulePath, endpoint, err)
+		}
+		if body, err = f.proxyMetaFrom(strings.TrimSuffix(spec.URL, "/"), modulePath, endpoint, ttl); err == nil {
+			return body, nil
+		}
+	}
+	return nil, err
+}
+
+// proxyMetaFrom fetches a metadata endpoint from the proxy at base, failing
+// over between its mirrors. Cached responses younger than ttl are reused; a
+// zero ttl caches forever, for immutable responses, and a negative ttl
+// disables the cache. A stale cached response is still used when every
+// mirror fails.
+func (f *Fetcher) proxyMetaFrom(base, modulePath, endpoint string, ttl time.Duration) ([]byte, error) {
+	rawURL := fmt.Sprintf("%s/%s/%s", base, escapePath(modulePath), endpoint)
+
+	cached, cachePath := f.loadMeta(rawURL)
+	if cached != nil && ttl >= 0 && (ttl == 0 || time.Since(cached.Fetched) < ttl) {
+		return []byte(cached.Body), nil
+	}
+
+	client := http.Client{Transport: f.transport(), Timeout: 30 * time.Second}
+	var lastErr error
+	for _, u := range f.mirrorURLs(modulePath, rawURL) {
+		resp, err := client.Get(u)
+		if err != nil {
+			f.mirrorFailed(modulePath, u)
+			lastErr = err
+			continue
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			f.mirrorFailed(modulePath, u)
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+			lastErr = fmt.Errorf("%s %s: %w", modulePath, endpoint, ErrNoVersions)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			if mirrorUnhealthy(resp.StatusCode) {
+				f.mirrorFailed(modulePath, u)
+			}
+			lastErr = fmt.Errorf("%s: %s", u, resp.Status)
+			continue
+		}
+		if ttl >= 0 {
+			f.storeMeta(cachePath, rawURL, body)
+		}
+		return body, nil
+	}
+
+	if cached != nil {
+		if f.Verbose {
+			fmt.Fprintf(os.Stderr, "warning: using stale %s for %s: %v\n", endpoint, modulePath, lastErr)
+		}
+		return []byte(cached.Body), nil
+	}
+	return nil, lastErr
+}
+
+func (f *Fetcher) loadMeta(rawURL string) (*metaEntry, string) {
+	if f.CacheDir == "" {
+		return nil, ""
+	}
+	sum := sha256.Sum256([]byte(rawURL))
+	path := filepath.Join(f.CacheDir, "meta", hex.EncodeToString(sum[:])+".json")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, path
+	}
+	var entry metaEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != rawURL || !f.cacheFiles().verify(entry.MAC, entry.macFields()...) {
+		return nil, path
+	}
+	return &entry, path
+}
+
+// storeMeta caches body. Write failures are ignored; the next lookup just
+// fetches again.
+func (f *Fetcher) storeMeta(path, rawURL string, body []byte) {
+	if path == "" {
+		return
+	}
+	files := f.cacheFiles()
+	entry := metaEntry{URL: rawURL, Fetched: time.Now(), Body: string(body)}
+	entry.MAC = files.mac(entry.macFields()...)
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	files.writeFile(path, data)
+}
+
+// goListOutput is the subset of go list -m -json output used here.
+type goListOutput struct {
+	Version  string
+	Time     string
+	Versions []string
+}
+
+func (f *Fetcher) goListModule(query string, flags ...string) (*goListOutput, error) {
+	args := append([]string{"list", "-m", "-json"}, flags...)
+	args = append(args, query)
+	out, err := f.command("go", args...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("go list -m %s: %s", query, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("go list -m %s: %w", query, err)
+	}
+	var result goListOutput
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("parsing go list output: %w", err)
+	}
+	return &result, nil
+}
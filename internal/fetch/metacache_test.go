@@ -0,0 +1,79 @@
+package fetch
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVersionsCached(t *testing.T) {
+	hits := 0
+	up := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/@v/list"):
+			w.Write([]byte("v1.10.0\nv1.2.0\nbogus\nv1.9.0\n"))
+		case strings.HasSuffix(r.URL.Path, "/@latest"):
+			w.Write([]byte(`{"Version":"v1.10.0","Time":"2024-01-02T03:04:05Z"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	f := &Fetcher{Proxy: srv.URL, CacheDir: t.TempDir()}
+
+	want := []string{"v1.2.0", "v1.9.0", "v1.10.0"}
+	for i := 0; i < 2; i++ {
+		got, err := f.Versions("example.com/mod")
+		if err != nil {
+			t.Fatalf("Versions() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Versions() = %v, want %v", got, want)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("proxy hits = %d, want 1 (second call cached)", hits)
+	}
+
+	info, err := f.Latest("example.com/mod")
+	if err != nil || info.Version != "v1.10.0" {
+		t.Fatalf("Latest() = %+v, %v", info, err)
+	}
+
+	// Expired entries are refetched, but still served if the proxy is down.
+	f.ListTTL = time.Nanosecond
+	up = false
+	hits = 0
+	got, err := f.Versions("example.com/mod")
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Versions() with stale cache = %v, %v", got, err)
+	}
+	if hits != 1 {
+		t.Errorf("proxy hits = %d, want 1 (expired entry refetched)", hits)
+	}
+
+	if _, err := f.Versions("example.com/missing"); err == nil {
+		t.Error("Versions() for uncached module with proxy down should fail")
+	}
+}
+
+func TestVersionsNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	f := &Fetcher{Proxy: srv.URL, ListTTL: -1}
+	if _, err := f.Latest("example.com/mod"); !errors.Is(err, ErrNoVersions) {
+		t.Errorf("Latest() error = %v, want ErrNoVersions", err)
+	}
+}
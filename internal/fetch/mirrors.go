@@ -0,0 +1,199 @@
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// mirrorProbeTimeout bounds a mirror health probe.
+	mirrorProbeTimeout = 5 * time.Second
+	// mirrorCooldown is how long a failed mirror is tried last.
+	mirrorCooldown = time.Minute
+)
+
+// mirrorHealth tracks which proxy mirrors are reachable. Each mirror is
+// probed once, on first use; a mirror that fails a probe or a request is
+// moved to the back of the failover order for mirrorCooldown.
+type mirrorHealth struct {
+	mu     sync.Mutex
+	probed map[string]bool
+	down   map[string]time.Time
+}
+
+// proxies returns the module proxies for modulePath: the Mirrors entry with
+// the longest matching pattern, or the configured Proxy. The first proxy is
+// canonical; it is the one recorded in lockfile URLs.
+func (f *Fetcher) proxies(modulePath string) []string {
+	if key, ok := matchKey(f.Mirrors, modulePath); ok && len(f.Mirrors[key]) > 0 {
+		return f.Mirrors[key]
+	}
+	if f.Proxy == "" || f.Proxy == ProxyOff {
+		return nil
+	}
+	return []string{f.Proxy}
+}
+
+// proxyBase returns the canonical proxy for modulePath, or "" if modules are
+// fetched directly.
+func (f *Fetcher) proxyBase(modulePath string) string {
+	if p := f.proxies(modulePath); len(p) > 0 {
+		return strings.TrimSuffix(p[0], "/")
+	}
+	return ""
+}
+
+// proxyURL returns the canonical proxy URL of a module file such as ".zip"
+// or ".info", or "" if modules are fetched directly.
+func (f *Fetcher) proxyURL(modulePath, version, ext string) string {
+	base := f.proxyBase(modulePath)
+	if base == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/@v/%s%s", base, escapePath(modulePath), escapeVersion(version), ext)
+}
+
+// mirrorURLs returns the URLs to try for rawURL, in failover order. If rawURL
+// is served by the canonical proxy for modulePath, the equivalent URL on
+// every mirror is returned, healthy mirrors first; otherwise just rawURL.
+func (f *Fetcher) mirrorURLs(modulePath, rawURL string) []string {
+	proxies := f.proxies(modulePath)
+	if len(proxies) < 2 {
+		return []string{rawURL}
+	}
+	canonical := strings.TrimSuffix(proxies[0], "/")
+	rest, ok := strings.CutPrefix(rawURL, canonical+"/")
+	if !ok {
+		return []string{rawURL}
+	}
+
+	var urls []string
+	for _, base := range f.mirrorOrder(proxies) {
+		urls = append(urls, strings.TrimSuffix(base, "/")+"/"+rest)
+	}
+	return urls
+}
+
+// sourceURLs lists every known source of the zip downloaded from rawURL,
+// rawURL first: the same file on the other mirrors of its proxy, then the
+// origin archive, if any, whose canonical module zip has the same hash. It
+// returns nil when rawURL is the only source.
+func (f *Fetcher) sourceURLs(modulePath, rawURL, originURL string) []string {
+	urls := []string{rawURL}
+	if proxies := f.proxies(modulePath); len(proxies) > 1 {
+		canonical := strings.TrimSuffix(proxies[0], "/")
+		if rest, ok := strings.CutPrefix(rawURL, canonical+"/"); ok {
+			for _, base := range proxies[1:] {
+				urls = append(urls, strings.TrimSuffix(base, "/")+"/"+rest)
+			}
+		}
+	}
+	if originURL != "" && originURL != rawURL {
+		urls = append(urls, originURL)
+	}
+	if len(urls) < 2 {
+		return nil
+	}
+	return urls
+}
+
+// mirrorOrder probes any mirrors not probed yet and returns proxies with
+// healthy ones first, each group in configured order.
+func (f *Fetcher) mirrorOrder(proxies []string) []string {
+	h := &f.health
+	h.mu.Lock()
+	var unprobed []string
+	for _, p := range proxies {
+		if !h.probed[p] {
+			unprobed = append(unprobed, p)
+		}
+	}
+	h.mu.Unlock()
+
+	if len(unprobed) > 0 {
+		var wg sync.WaitGroup
+		healthy := make([]bool, len(unprobed))
+		for i, p := range unprobed {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				healthy[i] = f.probeMirror(p)
+			}()
+		}
+		wg.Wait()
+
+		h.mu.Lock()
+		if h.probed == nil {
+			h.probed = make(map[string]bool)
+		}
+		for i, p := range unprobed {
+			h.probed[p] = true
+			if !healthy[i] {
+				h.markDownLocked(p)
+				if f.Verbose {
+					fmt.Fprintf(os.Stderr, "Mirror %s failed its health check\n", p)
+				}
+			}
+		}
+		h.mu.Unlock()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	var up, down []string
+	for _, p := range proxies {
+		if until, ok := h.down[p]; ok && now.Before(until) {
+			down = append(down, p)
+		} else {
+			up = append(up, p)
+		}
+	}
+	return append(up, down...)
+}
+
+// probeMirror reports whether a proxy answers at all. The module proxy
+// protocol has no health endpoint, so any response below 500 counts.
+func (f *Fetcher) probeMirror(base string) bool {
+	client := http.Client{Transport: f.transport(), Timeout: mirrorProbeTimeout}
+	resp, err := client.Get(strings.TrimSuffix(base, "/") + "/")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode < http.StatusInternalServerError
+}
+
+// mirrorFailed records a failed request to the mirror serving rawURL.
+func (f *Fetcher) mirrorFailed(modulePath, rawURL string) {
+	proxies := f.proxies(modulePath)
+	if len(proxies) < 2 {
+		return
+	}
+	h := &f.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, p := range proxies {
+		if strings.HasPrefix(rawURL, strings.TrimSuffix(p, "/")+"/") {
+			h.markDownLocked(p)
+			return
+		}
+	}
+}
+
+func (h *mirrorHealth) markDownLocked(proxy string) {
+	if h.down == nil {
+		h.down = make(map[string]time.Time)
+	}
+	h.down[proxy] = time.Now().Add(mirrorCooldown)
+}
+
+// mirrorUnhealthy reports whether a response status means the mirror itself
+// is failing, as opposed to the module being unavailable there.
+func mirrorUnhealthy(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+}
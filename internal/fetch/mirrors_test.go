@@ -0,0 +1,143 @@
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMirrorFailover(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	var served []string
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = append(served, r.URL.Path)
+		switch {
+		case strings.HasSuffix(r.URL.Path, ".zip"):
+			w.Write([]byte("zip"))
+		case strings.HasSuffix(r.URL.Path, ".info"):
+			w.Write([]byte(`{"Version":"v1.0.0","Time":"2024-01-02T03:04:05Z"}`))
+		}
+	}))
+	defer up.Close()
+
+	f := &Fetcher{
+		Proxy:   "https://proxy.invalid",
+		Mirrors: map[string][]string{"example.com": {down.URL, up.URL}},
+	}
+
+	// The lockfile URL always names the canonical (first) mirror.
+	zipURL := f.getDownloadURL("example.com/mod", "v1.0.0")
+	if want := down.URL + "/example.com/mod/@v/v1.0.0.zip"; zipURL != want {
+		t.Fatalf("getDownloadURL() = %q, want %q", zipURL, want)
+	}
+	if got := f.getDownloadURL("other.org/mod", "v1.0.0"); !strings.HasPrefix(got, "https://proxy.invalid/") {
+		t.Errorf("unmatched module should use Proxy, got %q", got)
+	}
+
+	path, size, _, err := f.downloadFromURL(zipURL, "example.com/mod", "v1.0.0")
+	if err != nil {
+		t.Fatalf("downloadFromURL() error = %v", err)
+	}
+	os.Remove(path)
+	if size != 3 {
+		t.Errorf("size = %d, want 3", size)
+	}
+
+	info, _ := f.getModuleInfo("example.com/mod", "v1.0.0")
+	if info == nil || info.Time != "2024-01-02T03:04:05Z" {
+		t.Errorf("getModuleInfo() = %+v, want info from the healthy mirror", info)
+	}
+
+	if order := f.mirrorOrder(f.Mirrors["example.com"]); order[0] != up.URL {
+		t.Errorf("mirrorOrder() = %v, want the healthy mirror first", order)
+	}
+	for _, p := range served {
+		if p == "/" {
+			continue
+		}
+		if !strings.HasPrefix(p, "/example.com/mod/@v/") {
+			t.Errorf("unexpected request %s", p)
+		}
+	}
+}
+
+// TestFetchRetries fails over from a mirror that passes its health check
+// but fails the download, and reports the retry.
+func TestFetchRetries(t *testing.T) {
+	zipPath := filepath.Join(t.TempDir(), "mod.zip")
+	writeTestZip(t, zipPath, map[string]string{
+		"example.com/mod@v1.0.0/go.mod": "module example.com/mod\n",
+		"example.com/mod@v1.0.0/mod.go": "package mod\n",
+	})
+	zipData, err := os.ReadFile(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer flaky.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".zip") {
+			w.Write(zipData)
+		}
+	}))
+	defer up.Close()
+
+	f := &Fetcher{
+		Proxy:    "https://proxy.invalid",
+		Mirrors:  map[string][]string{"example.com": {flaky.URL, up.URL}},
+		CacheDir: t.TempDir(),
+	}
+	result, err := f.Fetch("example.com/mod", "v1.0.0")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if result.Retries != 1 {
+		t.Errorf("Retries = %d, want 1", result.Retries)
+	}
+}
+
+func TestMirrorURLsSingleProxy(t *testing.T) {
+	f := &Fetcher{Proxy: "https://proxy.golang.org"}
+	u := "https://proxy.golang.org/example.com/mod/@v/v1.0.0.zip"
+	if got := f.mirrorURLs("example.com/mod", u); len(got) != 1 || got[0] != u {
+		t.Errorf("mirrorURLs() = %v, want just %q", got, u)
+	}
+}
+
+func TestSourceURLs(t *testing.T) {
+	f := &Fetcher{
+		Proxy:   DefaultProxy,
+		Mirrors: map[string][]string{"example.com": {"https://a.example.net/", "https://b.example.net"}},
+	}
+	origin := "https://github.com/example/mod/archive/refs/tags/v1.0.0.zip"
+
+	got := f.sourceURLs("example.com/mod", "https://a.example.net/example.com/mod/@v/v1.0.0.zip", origin)
+	want := []string{
+		"https://a.example.net/example.com/mod/@v/v1.0.0.zip",
+		"https://b.example.net/example.com/mod/@v/v1.0.0.zip",
+		origin,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sourceURLs() = %q, want %q", got, want)
+	}
+
+	if got := f.sourceURLs("other.com/mod", "https://proxy.golang.org/other.com/mod/@v/v1.0.0.zip", ""); got != nil {
+		t.Errorf("sourceURLs() with a single source = %q, want nil", got)
+	}
+	if got := f.sourceURLs("github.com/example/mod", origin, origin); got != nil {
+		t.Errorf("sourceURLs() of an origin archive = %q, want nil", got)
+	}
+}
@@ -3,18 +3,25 @@ package fetch
 import (
 	"archive/zip"
 	"crypto/sha256"
+	"crypto/tls"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/anthr76/nopher/internal/telemetry"
 	"github.com/git-lfs/go-netrc/netrc"
+	"golang.org/x/mod/module"
 )
 
 const (
@@ -37,20 +44,94 @@ type ModuleInfo struct {
 
 // Fetcher handles fetching Go modules from proxies and direct sources.
 type Fetcher struct {
-	// Proxy is the GOPROXY URL to use.
+	// Proxy is the first GOPROXY entry: a proxy URL, "" for direct, or
+	// ProxyOff.
 	Proxy string
-	// Private is a comma-separated list of module path prefixes to fetch directly.
+	// Fallback lists the GOPROXY entries after Proxy, tried in turn when a
+	// download from Proxy fails. It is ignored unless Proxy is a URL.
+	Fallback []ProxySpec
+	// Private is a comma-separated list of GOPRIVATE-style module path
+	// patterns to fetch directly.
 	Private string
 	// CacheDir is the directory to cache downloaded modules.
 	CacheDir string
+	// CachePerm is the permission of files written to CacheDir.
+	// Directories, and executable files of extracted modules, also get
+	// search permission wherever it grants read. Zero means
+	// DefaultCachePerm, so other users of a build host can neither read
+	// nor tamper with cached modules; widen it only for a group-shared
+	// cache.
+	CachePerm os.FileMode
+	// CacheKey, if set, authenticates cache entries with HMAC-SHA256, for
+	// caches shared between users: entries whose MAC is missing or wrong
+	// are fetched again instead of being trusted.
+	CacheKey []byte
+	// ReadOnlyCache serves modules only from a pre-seeded CacheDir, for
+	// sandboxes such as the Nix build sandbox: Fetch fails with
+	// ErrNotCached instead of downloading a missing module, and nothing
+	// is written to CacheDir. Metadata lookups may still use the network.
+	ReadOnlyCache bool
 	// Netrc contains credentials for private repositories.
 	Netrc *netrc.Netrc
 	// Verbose enables verbose output.
 	Verbose bool
+	// URLOverrides maps module paths or GOPRIVATE-style patterns to download
+	// URL templates with {module}, {version}, and {rev} placeholders.
+	URLOverrides map[string]string
+	// Strict rejects modules whose download URL would be a heuristic guess
+	// instead of coming from the proxy, a known forge, or origin metadata.
+	Strict bool
+	// Tracer records spans for fetch operations. Nil disables tracing.
+	Tracer *telemetry.Tracer
+	// Signers sign requests to the given hosts (host or host:port), for
+	// proxies that need request signing rather than netrc credentials.
+	Signers map[string]Signer
+	// Mirrors maps module paths or GOPRIVATE-style patterns to equivalent
+	// module proxies, overriding Proxy. The first entry is canonical and is
+	// the one recorded in the lockfile; requests fail over to the others,
+	// healthy mirrors first.
+	Mirrors map[string][]string
+	// ListTTL is how long cached @v/list and @latest responses are reused.
+	// Zero uses DefaultListTTL; a negative value disables caching.
+	ListTTL time.Duration
+	// Symlinks controls how symlinks in GitHub archives are extracted.
+	// Empty means SymlinkSkip.
+	Symlinks SymlinkPolicy
+	// Network configures DNS overrides and dual-stack dialing. It must be
+	// set before the first request.
+	Network Network
+	// FetchLog is the path of a JSONL file every downloaded artifact is
+	// appended to, for provenance archiving. Empty disables the log.
+	FetchLog string
+	// OnDownload, if set, is called after each artifact is downloaded from
+	// the network, with the URL it came from and its size in bytes. It may
+	// be called from several goroutines at once.
+	OnDownload func(modulePath, version, url string, size int64)
+	// ExplainMismatches makes verified fetches compare an artifact that
+	// does not match its locked hash with a cached copy of the locked
+	// artifact, reporting the result in HashMismatchError.Report.
+	ExplainMismatches bool
+	// GitHubApp, if set, authenticates GitHub API requests and the git and
+	// go commands nopher runs with installation tokens of a GitHub App,
+	// instead of GITHUB_TOKEN or netrc credentials.
+	GitHubApp *GitHubApp
+	// GitLabHosts lists self-hosted GitLab hosts (host or host:port).
+	// Modules on them are fetched from GitLab archives, as on hosts named
+	// gitlab.*, and they are sent GITLAB_TOKEN.
+	GitLabHosts []string
+
+	health   mirrorHealth
+	archives archiveCache
+	ghOnce   sync.Once
+	gh       *githubClient
+	dialOnce sync.Once
+	base     http.RoundTripper
+	appOnce  sync.Once
 }
 
 // NewFetcher creates a new Fetcher with default settings.
 // Reads configuration from environment variables GOPROXY, GOPRIVATE, and GONOPROXY.
+// Enables tracing when an OTLP endpoint is configured via OTEL_EXPORTER_OTLP_ENDPOINT.
 // Parses ~/.netrc for authentication credentials.
 // Creates cache directory in user's cache dir or temp dir if unavailable.
 func NewFetcher() (*Fetcher, error) {
@@ -60,7 +141,7 @@ func NewFetcher() (*Fetcher, error) {
 	}
 	cacheDir = filepath.Join(cacheDir, "nopher")
 
-	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+	if err := os.MkdirAll(cacheDir, cacheFiles{}.dirPerm()); err != nil {
 		return nil, fmt.Errorf("creating cache directory: %w", err)
 	}
 
@@ -77,54 +158,89 @@ func NewFetcher() (*Fetcher, error) {
 		netrcFile = &netrc.Netrc{}
 	}
 
-	proxy := os.Getenv("GOPROXY")
-	if proxy == "" {
-		proxy = DefaultProxy
-	}
-	if idx := strings.Index(proxy, ","); idx != -1 {
-		proxy = proxy[:idx]
-	}
-	if proxy == "direct" || proxy == "off" {
-		proxy = ""
-	}
-
-	private := os.Getenv("GOPRIVATE")
-	if private == "" {
-		private = os.Getenv("GONOPROXY")
-	}
+	proxy, fallback := splitProxyList(ParseProxyList(os.Getenv("GOPROXY")))
 
 	return &Fetcher{
 		Proxy:    proxy,
-		Private:  private,
+		Fallback: fallback,
+		Private:  PrivateFromEnv(),
 		CacheDir: cacheDir,
 		Netrc:    netrcFile,
+		Tracer:   telemetry.NewFromEnv(),
 	}, nil
 }
 
+// PrivateFromEnv returns the patterns of modules to fetch directly rather
+// than through the proxy: GONOPROXY, which defaults to GOPRIVATE, as for the
+// go command.
+func PrivateFromEnv() string {
+	if noproxy := os.Getenv("GONOPROXY"); noproxy != "" {
+		return noproxy
+	}
+	return os.Getenv("GOPRIVATE")
+}
+
+// ProxyFromEnv returns the first proxy from GOPROXY, defaulting to DefaultProxy.
+// Returns an empty string when the first entry is "direct", and ProxyOff
+// when it is "off".
+func ProxyFromEnv() string {
+	proxy, _ := splitProxyList(ParseProxyList(os.Getenv("GOPROXY")))
+	return proxy
+}
+
+// Close flushes any recorded trace spans to the configured collector and
+// removes the repository archives kept for reuse.
+func (f *Fetcher) Close() error {
+	f.archives.removeAll()
+	return f.Tracer.Shutdown()
+}
+
 // FetchResult contains the result of fetching a module.
 type FetchResult struct {
 	ModulePath string
 	Version    string
-	Dir        string // Path to extracted module
-	Hash       string // SHA256 hash of zip file in SRI format
-	URL        string // Source URL used for fetching
-	Rev        string // Git commit hash (for GitHub modules)
+	Dir        string   // Path to extracted module
+	Hash       string   // SHA256 hash of zip file in SRI format
+	HashType   string   // HashNAR when Hash covers an unpacked archive tree
+	URL        string   // Source URL used for fetching
+	URLs       []string // Every known source of the same zip, URL first; nil if URL is the only one
+	Rev        string   // Git commit hash (for GitHub modules)
+	Subdir     string   // Module subdirectory within the repository (for GitHub modules)
+	ModFile    string   // Module path declared by the extracted go.mod, empty if none
+	Replaces   []string // Replace directives in the module's go.mod, which go ignores
+	Bytes      int64    // Size of the downloaded zip (zero on cache hit)
+	CacheHit   bool     // True if the result was served from CacheDir
+	Retries    int      // Number of download attempts beyond the first
+	Size       int64    // Total uncompressed size of the extracted module in bytes
+	Files      int      // Number of regular files in the extracted module
 }
 
 // Fetch downloads a Go module, extracts it, and computes its SRI hash.
 // Results are cached in CacheDir keyed by modulePath@version.
 // Returns FetchResult with the extracted directory, hash, source URL, and git revision.
-func (f *Fetcher) Fetch(modulePath, version string) (*FetchResult, error) {
-	cacheKey := escapePath(modulePath) + "@" + version
-	cachedDir := filepath.Join(f.CacheDir, cacheKey)
+func (f *Fetcher) Fetch(modulePath, version string) (result *FetchResult, err error) {
+	span := f.Tracer.Start("fetch")
+	span.SetAttr("module.path", modulePath)
+	span.SetAttr("module.version", version)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
+	cachedDir := f.cachedDir(modulePath, version)
 	hashFile := cachedDir + ".hash"
 	urlFile := cachedDir + ".url"
 	revFile := cachedDir + ".rev"
+	subdirFile := cachedDir + ".subdir"
+	urlsFile := cachedDir + ".urls"
+	files := f.cacheFiles()
 
 	if info, err := os.Stat(cachedDir); err == nil && info.IsDir() {
 		hashData, hashErr := os.ReadFile(hashFile)
 		urlData, urlErr := os.ReadFile(urlFile)
 		revData, revErr := os.ReadFile(revFile)
+		subdirData, _ := os.ReadFile(subdirFile)
+		urlsData, _ := os.ReadFile(urlsFile)
 		if hashErr == nil {
 			cachedURL := ""
 			if urlErr == nil {
@@ -134,44 +250,57 @@ func (f *Fetcher) Fetch(modulePath, version string) (*FetchResult, error) {
 			if revErr == nil {
 				cachedRev = strings.TrimSpace(string(revData))
 			}
-			return &FetchResult{
+			size, count := dirStats(cachedDir)
+			cached := &FetchResult{
 				ModulePath: modulePath,
 				Version:    version,
 				Dir:        cachedDir,
 				Hash:       strings.TrimSpace(string(hashData)),
+				HashType:   archiveHashType(cachedURL, cachedRev),
 				URL:        cachedURL,
+				URLs:       strings.Fields(string(urlsData)),
 				Rev:        cachedRev,
-			}, nil
+				Subdir:     strings.TrimSpace(string(subdirData)),
+				ModFile:    declaredModulePath(cachedDir),
+				Replaces:   declaredReplaces(filepath.Join(cachedDir, "go.mod")),
+				CacheHit:   true,
+				Size:       size,
+				Files:      count,
+			}
+			if f.authentic(cached) {
+				span.SetAttr("cache.hit", "true")
+				return cached, nil
+			}
+			if f.ReadOnlyCache {
+				return nil, fmt.Errorf("cached %s@%s failed its integrity check: %w", modulePath, version, ErrNotCached)
+			}
+			fmt.Fprintf(os.Stderr, "warning: cached %s@%s failed its integrity check; fetching it again\n", modulePath, version)
+			for _, file := range []string{hashFile, urlFile, revFile, subdirFile, urlsFile, cachedDir + ".mac"} {
+				os.Remove(file)
+			}
 		}
 	}
 
-	downloadURL := f.getDownloadURL(modulePath, version)
-
-	zipPath, err := f.downloadFromURL(downloadURL, modulePath, version)
-	if err != nil {
-		return nil, fmt.Errorf("downloading module: %w", err)
-	}
-	defer os.Remove(zipPath)
-
-	zipHash, err := computeZipHash(zipPath)
-	if err != nil {
-		return nil, fmt.Errorf("computing zip hash: %w", err)
-	}
-
-	if err := f.extract(zipPath, cachedDir, modulePath, version); err != nil {
-		return nil, fmt.Errorf("extracting module: %w", err)
+	span.SetAttr("cache.hit", "false")
+	if f.ReadOnlyCache {
+		return nil, fmt.Errorf("%s@%s: %w", modulePath, version, ErrNotCached)
 	}
 
-	if err := os.WriteFile(hashFile, []byte(zipHash), 0o644); err != nil && f.Verbose {
-		fmt.Fprintf(os.Stderr, "warning: failed to cache hash: %v\n", err)
+	if f.proxyOff(modulePath) {
+		return nil, fmt.Errorf("fetching %s@%s: %w", modulePath, version, ErrProxyOff)
 	}
-
-	if err := os.WriteFile(urlFile, []byte(downloadURL), 0o644); err != nil && f.Verbose {
-		fmt.Fprintf(os.Stderr, "warning: failed to cache URL: %v\n", err)
+	downloadURL, guessed := f.resolveDownloadURL(modulePath, version)
+	if guessed && f.Strict {
+		return nil, fmt.Errorf("strict mode: no verified source for %s@%s (would guess %s)", modulePath, version, downloadURL)
 	}
 
 	gitRev := ""
-	if strings.HasPrefix(modulePath, "github.com/") {
+	subdir := ""
+	originURL := ""
+	repoURL := ""
+	if strings.HasPrefix(modulePath, "github.com/") || isGitHubArchiveURL(downloadURL) {
+		child := span.Child("metadata")
+
 		var info *ModuleInfo
 		var err error
 
@@ -186,6 +315,11 @@ func (f *Fetcher) Fetch(modulePath, version string) (*FetchResult, error) {
 
 		if err == nil && info != nil && info.Origin != nil {
 			gitRev = info.Origin.Hash
+			subdir = info.Origin.Subdir
+			repoURL = info.Origin.URL
+			if info.Origin.VCS == "git" && strings.HasPrefix(info.Origin.URL, "https://github.com/") {
+				originURL = f.buildGitHubArchiveURL(info)
+			}
 		}
 
 		// Resolve full 40-char commit hash if missing or truncated.
@@ -195,22 +329,179 @@ func (f *Fetcher) Fetch(modulePath, version string) (*FetchResult, error) {
 				gitRev = resolved
 			}
 		}
+		child.End()
+	} else if !f.viaProxy(modulePath) {
+		// Forge archives hold the whole repository.
+		if u, dir, _, ok := f.directArchive(modulePath, version); ok && u == downloadURL {
+			subdir = dir
+		}
+	}
+
+	// Repository archives are shared by the modules they contain, which
+	// are often fetched together; they are removed by Close.
+	child := span.Child("download")
+	var zipPath string
+	var size int64
+	var retries int
+	if isRepoArchiveURL(downloadURL) {
+		var reused bool
+		zipPath, size, reused, err = f.repoArchive(archiveKey(downloadURL, repoURL, gitRev), downloadURL, modulePath, version)
+		child.SetAttr("archive.reused", strconv.FormatBool(reused))
+	} else {
+		zipPath, size, retries, err = f.downloadFromURL(downloadURL, modulePath, version)
+		if err != nil && f.viaProxy(modulePath) {
+			var fallbackRetries int
+			downloadURL, guessed, zipPath, size, fallbackRetries, err = f.downloadFallback(modulePath, version, err)
+			retries += fallbackRetries
+		}
+		if err == nil {
+			defer os.Remove(zipPath)
+		}
+	}
+	span.SetAttr("url", downloadURL)
+	child.SetError(err)
+	child.End()
+	if err != nil {
+		return nil, fmt.Errorf("downloading module: %w", err)
+	}
+
+	child = span.Child("hash")
+	zipHash, err := computeZipHash(zipPath)
+	child.SetError(err)
+	child.End()
+	if err != nil {
+		return nil, fmt.Errorf("computing zip hash: %w", err)
+	}
+
+	// GitHub and GitLab archives contain the whole repository with a
+	// different layout from proxy zips. Rebuild the canonical module zip and
+	// extract that instead. Its hash is recorded when Nix will use fetchGit
+	// (a full rev on GitHub); otherwise Nix unpacks the archive with
+	// fetchzip, so the NAR hash of its tree is recorded. Neither depends on
+	// the archive's metadata, which forges do not keep stable.
+	extractPath := zipPath
+	if isRepoArchiveURL(downloadURL) {
+		child = span.Child("canonicalize")
+		canonicalPath, err := f.canonicalModuleZip(zipPath, modulePath, version, subdir)
+		child.SetError(err)
+		child.End()
+		if err != nil {
+			return nil, fmt.Errorf("building canonical module zip: %w", err)
+		}
+		defer os.Remove(canonicalPath)
+		extractPath = canonicalPath
+
+		if len(gitRev) == 40 {
+			zipHash, err = computeZipHash(canonicalPath)
+		} else {
+			zipHash, err = archiveNARHash(zipPath)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("computing zip hash: %w", err)
+		}
+	}
+
+	// Canonical zips already hold just the module; other archives are
+	// narrowed to its directory while extracting.
+	extractSubdir := subdir
+	if isRepoArchiveURL(downloadURL) {
+		extractSubdir = ""
+	}
+	child = span.Child("extract")
+	err = f.extract(extractPath, cachedDir, modulePath, version, extractSubdir, files.filePerm())
+	child.SetError(err)
+	child.End()
+	if err != nil {
+		return nil, fmt.Errorf("extracting module: %w", err)
+	}
+
+	if err := checkModFile(cachedDir, version); err != nil {
+		os.RemoveAll(cachedDir)
+		return nil, fmt.Errorf("validating %s@%s: %w", modulePath, version, err)
+	}
+
+	if err := f.dedupe(cachedDir); err != nil && f.Verbose {
+		fmt.Fprintf(os.Stderr, "warning: failed to deduplicate %s@%s: %v\n", modulePath, version, err)
+	}
+
+	if err := files.writeFile(hashFile, []byte(zipHash)); err != nil && f.Verbose {
+		fmt.Fprintf(os.Stderr, "warning: failed to cache hash: %v\n", err)
+	}
+
+	if err := files.writeFile(urlFile, []byte(downloadURL)); err != nil && f.Verbose {
+		fmt.Fprintf(os.Stderr, "warning: failed to cache URL: %v\n", err)
+	}
+
+	urls := f.sourceURLs(modulePath, downloadURL, originURL)
+	if len(urls) > 0 {
+		if err := files.writeFile(urlsFile, []byte(strings.Join(urls, "\n")+"\n")); err != nil && f.Verbose {
+			fmt.Fprintf(os.Stderr, "warning: failed to cache URLs: %v\n", err)
+		}
 	}
 
+	extractedSize, extractedFiles := dirStats(cachedDir)
+
 	if gitRev != "" {
-		if err := os.WriteFile(revFile, []byte(gitRev), 0o644); err != nil && f.Verbose {
+		if err := files.writeFile(revFile, []byte(gitRev)); err != nil && f.Verbose {
 			fmt.Fprintf(os.Stderr, "warning: failed to cache rev: %v\n", err)
 		}
 	}
 
-	return &FetchResult{
+	if subdir != "" {
+		if err := files.writeFile(subdirFile, []byte(subdir)); err != nil && f.Verbose {
+			fmt.Fprintf(os.Stderr, "warning: failed to cache subdir: %v\n", err)
+		}
+	}
+
+	fetched := &FetchResult{
 		ModulePath: modulePath,
 		Version:    version,
 		Dir:        cachedDir,
 		Hash:       zipHash,
+		HashType:   archiveHashType(downloadURL, gitRev),
 		URL:        downloadURL,
+		URLs:       urls,
 		Rev:        gitRev,
-	}, nil
+		Subdir:     subdir,
+		ModFile:    declaredModulePath(cachedDir),
+		Replaces:   declaredReplaces(filepath.Join(cachedDir, "go.mod")),
+		Bytes:      size,
+		Size:       extractedSize,
+		Files:      extractedFiles,
+		Retries:    retries,
+	}
+	if err := f.seal(fetched); err != nil && f.Verbose {
+		fmt.Fprintf(os.Stderr, "warning: failed to record cache MAC: %v\n", err)
+	}
+	return fetched, nil
+}
+
+// cachedDir returns the directory Fetch extracts modulePath@version to in
+// CacheDir. Its cached hash, URL, and revision sit next to it.
+func (f *Fetcher) cachedDir(modulePath, version string) string {
+	cacheKey := escapePath(modulePath) + "@" + version
+	if f.Symlinks != "" && f.Symlinks != SymlinkSkip {
+		// The policy changes archive contents, so results aren't shared.
+		cacheKey += "+symlinks-" + string(f.Symlinks)
+	}
+	return filepath.Join(f.CacheDir, cacheKey)
+}
+
+// dirStats returns the total size and count of regular files under dir.
+func dirStats(dir string) (int64, int) {
+	var size int64
+	var files int
+	_ = filepath.WalkDir(dir, func(_ string, d os.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+			files++
+		}
+		return nil
+	})
+	return size, files
 }
 
 // computeZipHash computes the SHA256 hash of a file in SRI format.
@@ -231,143 +522,203 @@ func computeZipHash(path string) (string, error) {
 
 // isPrivate checks if a module path should be fetched directly (not via proxy).
 func (f *Fetcher) isPrivate(modulePath string) bool {
-	if f.Private == "" {
-		return false
-	}
-
-	for _, pattern := range strings.Split(f.Private, ",") {
-		pattern = strings.TrimSpace(pattern)
-		if pattern == "" {
-			continue
-		}
-		if matchPattern(pattern, modulePath) {
-			return true
-		}
-	}
-	return false
+	return matchPattern(f.Private, modulePath)
 }
 
-// matchPattern checks if modulePath matches a GOPRIVATE pattern.
+// matchPattern reports whether modulePath matches a GOPRIVATE-style pattern,
+// or any of a comma-separated list of them, exactly as the go command does:
+// each pattern is a path.Match glob matched against the same number of
+// leading path elements, so "*.corp.example.com" matches
+// "git.corp.example.com/team/repo" and "example.com/org" matches
+// "example.com/org/repo" but not "example.com/organic".
 func matchPattern(pattern, modulePath string) bool {
-	if prefix, found := strings.CutSuffix(pattern, "/*"); found {
-		return strings.HasPrefix(modulePath, prefix+"/") || modulePath == prefix
-	}
-	if prefix, found := strings.CutSuffix(pattern, "*"); found {
-		return strings.HasPrefix(modulePath, prefix)
-	}
-	return strings.HasPrefix(modulePath, pattern)
+	return module.MatchPrefixPatterns(strings.ReplaceAll(pattern, " ", ""), modulePath)
 }
 
 // getDownloadURL determines the download URL for a module.
-// Private modules use direct URLs, public modules use the configured proxy.
+// URL overrides take precedence. Private modules use direct URLs, public
+// modules use the configured proxy.
 func (f *Fetcher) getDownloadURL(modulePath, version string) string {
+	u, _ := f.resolveDownloadURL(modulePath, version)
+	return u
+}
+
+// resolveDownloadURL is like getDownloadURL but also reports whether the URL
+// is a heuristic guess rather than the result of a verified mechanism.
+func (f *Fetcher) resolveDownloadURL(modulePath, version string) (string, bool) {
+	if u := f.overrideURL(modulePath, version); u != "" {
+		return u, false
+	}
+
 	if f.isPrivate(modulePath) {
-		return f.directURL(modulePath, version)
+		return f.resolveDirectURL(modulePath, version)
 	}
 
-	if f.Proxy != "" {
-		escapedPath := escapePath(modulePath)
-		escapedVersion := escapeVersion(version)
-		return fmt.Sprintf("%s/%s/@v/%s.zip", f.Proxy, escapedPath, escapedVersion)
+	if u := f.proxyURL(modulePath, version, ".zip"); u != "" {
+		return u, false
 	}
 
-	return f.directURL(modulePath, version)
+	return f.resolveDirectURL(modulePath, version)
+}
+
+// viaProxy reports whether modulePath is downloaded from its proxy rather
+// than a URL override or its origin.
+func (f *Fetcher) viaProxy(modulePath string) bool {
+	return f.overrideURL(modulePath, "") == "" && !f.isPrivate(modulePath) && f.proxyBase(modulePath) != ""
 }
 
-// downloadFromURL fetches a module zip file from the given URL.
+// downloadFromURL fetches a module zip file from the given URL and returns the
+// temporary file path, the number of bytes downloaded, and the number of
+// attempts made beyond the first, on the URL's other mirrors.
 // For private GitHub modules, converts archive URLs to GitHub API URLs which
 // properly support token-based authentication. The archive URL is kept in the
 // lockfile so the Nix build can parse it for fetchGit.
-func (f *Fetcher) downloadFromURL(downloadURL, modulePath, version string) (string, error) {
-	actualURL := downloadURL
-	if f.isPrivate(modulePath) {
-		if apiURL := archiveToAPIURL(downloadURL); apiURL != "" {
-			actualURL = apiURL
-		}
-	}
+func (f *Fetcher) downloadFromURL(downloadURL, modulePath, version string) (zipPath string, size int64, retries int, _ error) {
+	actualURL := f.requestURL(modulePath, downloadURL)
 
 	if f.Verbose {
 		fmt.Fprintf(os.Stderr, "Downloading %s@%s from %s\n", modulePath, version, actualURL)
 	}
 
-	var client http.Client
+	client := f.client(modulePath, actualURL)
 
-	if f.isPrivate(modulePath) {
-		var machine *netrc.Machine
-		if u, err := url.Parse(actualURL); err == nil {
-			machine = f.Netrc.FindMachine(u.Host, "")
-		}
-		if machine == nil {
-			host := extractHost(modulePath)
-			machine = f.Netrc.FindMachine(host, "")
-		}
-		if machine != nil {
-			transport := &authTransport{
-				base:     http.DefaultTransport,
-				login:    machine.Login,
-				password: machine.Password,
+	var lastErr error
+	for i, u := range f.mirrorURLs(modulePath, actualURL) {
+		if i > 0 && f.Verbose {
+			fmt.Fprintf(os.Stderr, "Retrying %s@%s from %s\n", modulePath, version, u)
+		}
+		d, unhealthy, err := downloadZip(client, u)
+		if err == nil {
+			if err := f.logFetch(modulePath, version, u, d); err != nil {
+				os.Remove(d.path)
+				return "", 0, i, err
 			}
-			client.Transport = transport
+			if f.OnDownload != nil {
+				f.OnDownload(modulePath, version, u, d.size)
+			}
+			return d.path, d.size, i, nil
+		}
+		if unhealthy {
+			f.mirrorFailed(modulePath, u)
+		}
+		retries, lastErr = i, err
+	}
+	return "", 0, retries, lastErr
+}
+
+// requestURL returns the URL actually requested for downloadURL: private
+// GitHub archives go through the GitHub API, which supports token
+// authentication. GitLab archive URLs already are API URLs.
+func (f *Fetcher) requestURL(modulePath, downloadURL string) string {
+	if f.isPrivate(modulePath) {
+		if apiURL := archiveToAPIURL(downloadURL); apiURL != "" {
+			return apiURL
+		}
+	}
+	return downloadURL
+}
+
+// client returns the HTTP client for requesting rawURL on behalf of
+// modulePath, with netrc credentials for private modules, or for the
+// GitLab API, a GitLab token when one is available (see gitLabToken).
+func (f *Fetcher) client(modulePath, rawURL string) *http.Client {
+	client := &http.Client{Transport: f.transport()}
+	if u, err := url.Parse(rawURL); err == nil && f.isGitLabHost(u.Host) && strings.HasPrefix(u.Path, "/api/v4/") {
+		if header, token := f.gitLabToken(u.Host); token != "" {
+			client.Transport = &headerTransport{base: f.transport(), host: u.Host, header: header, value: token}
+			return client
 		}
 	}
+	if machine := f.netrcMachine(modulePath, rawURL); machine != nil {
+		client.Transport = &authTransport{
+			base:     f.transport(),
+			login:    machine.Login,
+			password: machine.Password,
+		}
+	}
+	return client
+}
+
+// netrcMachine returns the netrc credentials sent when requesting rawURL
+// on behalf of modulePath: those for the URL's host or the module's, for
+// private modules only.
+func (f *Fetcher) netrcMachine(modulePath, rawURL string) *netrc.Machine {
+	if !f.isPrivate(modulePath) {
+		return nil
+	}
+	hosts := []string{extractHost(modulePath)}
+	if u, err := url.Parse(rawURL); err == nil {
+		hosts = append([]string{u.Host}, hosts...)
+	}
+	return findMachine(f.Netrc, hosts...)
+}
+
+// download is a module zip saved to a temporary file by downloadZip.
+type download struct {
+	path   string
+	size   int64
+	sha256 string // hex SHA-256 of the zip
+	tls    *tls.ConnectionState
+}
 
-	req, err := http.NewRequest("GET", actualURL, nil)
+// downloadZip saves the response body for rawURL to a temporary file. It also
+// reports whether the failure lies with the server rather than the module.
+func downloadZip(client *http.Client, rawURL string) (*download, bool, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("creating request: %w", err)
+		return nil, false, fmt.Errorf("creating request: %w", err)
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("fetching module: %w", err)
+		return nil, true, fmt.Errorf("fetching module: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status: %s", resp.Status)
+		return nil, mirrorUnhealthy(resp.StatusCode), &statusError{status: resp.StatusCode, text: resp.Status}
 	}
 
 	tmpFile, err := os.CreateTemp("", "nopher-*.zip")
 	if err != nil {
-		return "", fmt.Errorf("creating temp file: %w", err)
+		return nil, false, fmt.Errorf("creating temp file: %w", err)
 	}
 
-	if _, err := io.Copy(tmpFile, resp.Body); err != nil {
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmpFile, h), resp.Body)
+	if err != nil {
 		tmpFile.Close()
 		os.Remove(tmpFile.Name())
-		return "", fmt.Errorf("downloading: %w", err)
+		return nil, true, fmt.Errorf("downloading: %w", err)
 	}
 
 	tmpFile.Close()
-	return tmpFile.Name(), nil
+	return &download{
+		path:   tmpFile.Name(),
+		size:   size,
+		sha256: hex.EncodeToString(h.Sum(nil)),
+		tls:    resp.TLS,
+	}, false, nil
 }
 
 // getModuleInfo fetches module metadata from the proxy's .info endpoint.
 // Returns nil if proxy is not configured or if the .info endpoint is unavailable.
 // Errors are treated as non-fatal and result in nil return.
 func (f *Fetcher) getModuleInfo(modulePath, version string) (*ModuleInfo, error) {
-	if f.Proxy == "" {
+	if f.proxyBase(modulePath) == "" {
 		return nil, nil
 	}
 
-	escapedPath := escapePath(modulePath)
-	escapedVersion := escapeVersion(version)
-	infoURL := fmt.Sprintf("%s/%s/@v/%s.info", f.Proxy, escapedPath, escapedVersion)
-
-	resp, err := http.Get(infoURL)
+	// .info responses are immutable, so they are cached indefinitely.
+	body, err := f.proxyMeta(modulePath, "@v/"+escapeVersion(version)+".info", 0)
 	if err != nil {
-		return nil, nil // Not fatal, just return nil
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
 		return nil, nil // Not fatal
 	}
 
 	var info ModuleInfo
-	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+	if err := json.Unmarshal(body, &info); err != nil {
 		return nil, nil // Not fatal
 	}
-
 	return &info, nil
 }
 
@@ -377,7 +728,7 @@ func (f *Fetcher) getModuleInfo(modulePath, version string) (*ModuleInfo, error)
 // Returns nil for non-GitHub modules.
 func (f *Fetcher) getModuleInfoFromGoList(modulePath, version string) (*ModuleInfo, error) {
 	// Actually call `go list -m -json` to get accurate Origin data with full commit hash
-	cmd := exec.Command("go", "list", "-m", "-json", modulePath+"@"+version)
+	cmd := f.command("go", "list", "-m", "-json", modulePath+"@"+version)
 	output, err := cmd.Output()
 	if err != nil {
 		// Fallback to manual parsing if go list fails
@@ -400,37 +751,30 @@ func (f *Fetcher) getModuleInfoManual(modulePath, version string) (*ModuleInfo,
 		Version: version,
 	}
 
-	if strings.HasPrefix(modulePath, "github.com/") {
-		parts := strings.SplitN(modulePath, "/", 4)
-		if len(parts) >= 3 {
-			owner := parts[1]
-			repoName := parts[2]
-			tagPrefix := moduleTagPrefix(modulePath)
-
-			info.Origin = &struct {
-				VCS    string
-				URL    string
-				Ref    string
-				Hash   string
-				Subdir string
-			}{
-				VCS:    "git",
-				URL:    fmt.Sprintf("https://github.com/%s/%s", owner, repoName),
-				Subdir: tagPrefix,
-			}
+	if repo, subpath, ok := f.lookupGitHubRepo(modulePath); ok {
+		tagPrefix := subpathTagPrefix(subpath)
 
-			if strings.HasPrefix(version, "v0.0.0-") {
-				idx := strings.LastIndex(version, "-")
-				if idx != -1 && idx < len(version)-1 {
-					info.Origin.Hash = version[idx+1:]
-				}
-			} else {
-				tag := version
-				if tagPrefix != "" {
-					tag = tagPrefix + "/" + version
-				}
-				info.Origin.Ref = "refs/tags/" + tag
+		info.Origin = &struct {
+			VCS    string
+			URL    string
+			Ref    string
+			Hash   string
+			Subdir string
+		}{
+			VCS:    "git",
+			URL:    "https://github.com/" + repo,
+			Subdir: tagPrefix,
+		}
+
+		if rev, err := module.PseudoVersionRev(version); err == nil {
+			info.Origin.Hash = rev
+		} else {
+			// +incompatible versions are tagged without the suffix.
+			tag := strings.TrimSuffix(version, "+incompatible")
+			if tagPrefix != "" {
+				tag = tagPrefix + "/" + tag
 			}
+			info.Origin.Ref = "refs/tags/" + tag
 		}
 	}
 
@@ -440,43 +784,68 @@ func (f *Fetcher) getModuleInfoManual(modulePath, version string) (*ModuleInfo,
 // directURL constructs a direct download URL for a module.
 // Routes to the appropriate URL builder based on module type.
 func (f *Fetcher) directURL(modulePath, version string) string {
-	if strings.HasPrefix(modulePath, "github.com/") {
+	u, _ := f.resolveDirectURL(modulePath, version)
+	return u
+}
+
+// resolveDirectURL is like directURL but also reports whether the URL is a
+// heuristic guess: a GitHub tag URL built without origin metadata, or a
+// generic host assumed to speak the proxy protocol. Modules in the known
+// repositories of monorepos, and vanity import paths whose go-import meta
+// tags point at GitHub, are fetched from GitHub like github.com modules.
+// Other vanity import paths, and modules on GitLab, are fetched from forge
+// archives, see directArchive.
+func (f *Fetcher) resolveDirectURL(modulePath, version string) (string, bool) {
+	if strings.Contains(modulePath, "/gen/go/") {
+		return f.buildBSRURL(modulePath, version), false
+	}
+
+	if _, _, ok := f.lookupGitHubRepo(modulePath); ok {
 		return f.buildGitHubURL(modulePath, version)
 	}
 
-	if strings.Contains(modulePath, "/gen/go/") {
-		return f.buildBSRURL(modulePath, version)
+	if u, _, guessed, ok := f.directArchive(modulePath, version); ok {
+		return u, guessed
 	}
 
-	return f.buildGenericURL(modulePath, version)
+	return f.buildGenericURL(modulePath, version), true
 }
 
 // buildGitHubURL constructs a GitHub archive download URL.
 // Always returns github.com/archive URLs so the Nix build can parse them for fetchGit.
 // Attempts to use Origin metadata for accurate refs/commits, falls back to tag-based URL.
-func (f *Fetcher) buildGitHubURL(modulePath, version string) string {
-	info := f.getGitHubModuleInfo(modulePath, version)
+// The second return value is true when the fallback guess was used.
+func (f *Fetcher) buildGitHubURL(modulePath, version string) (string, bool) {
+	return f.gitHubURLFromInfo(modulePath, version, f.getGitHubModuleInfo(modulePath, version))
+}
 
+// gitHubURLFromInfo is buildGitHubURL with the module metadata already
+// looked up; info may be nil.
+func (f *Fetcher) gitHubURLFromInfo(modulePath, version string, info *ModuleInfo) (string, bool) {
 	if info != nil && info.Origin != nil && info.Origin.VCS == "git" &&
 		strings.HasPrefix(info.Origin.URL, "https://github.com/") {
 
 		if archiveURL := f.buildGitHubArchiveURL(info); archiveURL != "" {
-			return archiveURL
+			return archiveURL, false
 		}
 	}
 
-	parts := strings.SplitN(modulePath, "/", 4)
-	if len(parts) >= 3 {
-		owner := parts[1]
-		repo := parts[2]
+	// Origins such as gopkg.in redirect to GitHub; their commit hash
+	// names the same tree there.
+	if repo, _, ok := f.lookupGitHubRepo(modulePath); ok && info != nil && info.Origin != nil &&
+		info.Origin.VCS == "git" && info.Origin.Hash != "" {
+		return fmt.Sprintf("https://github.com/%s/archive/%s.zip", repo, info.Origin.Hash), false
+	}
+
+	if repo, subpath, ok := f.lookupGitHubRepo(modulePath); ok {
 		ref := version
-		if prefix := moduleTagPrefix(modulePath); prefix != "" {
+		if prefix := subpathTagPrefix(subpath); prefix != "" {
 			ref = prefix + "/" + version
 		}
-		return fmt.Sprintf("https://github.com/%s/%s/archive/refs/tags/%s.zip", owner, repo, ref)
+		return fmt.Sprintf("https://github.com/%s/archive/refs/tags/%s.zip", repo, ref), true
 	}
 
-	return f.buildGenericURL(modulePath, version)
+	return f.buildGenericURL(modulePath, version), true
 }
 
 // getGitHubModuleInfo retrieves module metadata for GitHub repositories.
@@ -549,7 +918,11 @@ func (f *Fetcher) buildGenericURL(modulePath, version string) string {
 // extract unpacks a module zip to the target directory.
 // Module zips contain files under modulePath@version/ prefix which is stripped during extraction.
 // Handles archives with non-standard directory structures by stripping the first path segment.
-func (f *Fetcher) extract(zipPath, targetDir, modulePath, version string) error {
+// When subdir is set, only entries under that repository subdirectory are extracted,
+// relative to it. Symlink entries are handled according to f.Symlinks, and
+// entries whose names collide on case-insensitive or Unicode-normalizing
+// filesystems are rejected. Files get perm, see normalizedMode.
+func (f *Fetcher) extract(zipPath, targetDir, modulePath, version, subdir string, perm os.FileMode) error {
 	os.RemoveAll(targetDir)
 
 	r, err := zip.OpenReader(zipPath)
@@ -560,6 +933,8 @@ func (f *Fetcher) extract(zipPath, targetDir, modulePath, version string) error
 
 	prefix := modulePath + "@" + version + "/"
 
+	var names []string
+	entries := make(map[string]*zip.File)
 	for _, file := range r.File {
 		name := file.Name
 		if after, found := strings.CutPrefix(name, prefix); found {
@@ -568,22 +943,50 @@ func (f *Fetcher) extract(zipPath, targetDir, modulePath, version string) error
 			if _, after, found := strings.Cut(name, "/"); found {
 				name = after
 			}
+			if subdir != "" {
+				after, found := strings.CutPrefix(name, subdir+"/")
+				if !found {
+					continue
+				}
+				name = after
+			}
 		}
 
 		if name == "" {
 			continue
 		}
+		names = append(names, name)
+		entries[name] = file
+	}
+	if err := checkNameCollisions(names); err != nil {
+		return err
+	}
 
+	for _, name := range names {
+		file := entries[name]
 		targetPath := filepath.Join(targetDir, name)
 
 		if file.FileInfo().IsDir() {
-			if err := os.MkdirAll(targetPath, 0o755); err != nil {
+			if err := os.MkdirAll(targetPath, searchable(perm)); err != nil {
 				return fmt.Errorf("creating directory: %w", err)
 			}
 			continue
 		}
 
-		if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
+		if isSymlink(file) {
+			switch f.Symlinks {
+			case SymlinkReject:
+				return fmt.Errorf("archive contains symlink %s (symlink policy is %s)", name, SymlinkReject)
+			case SymlinkMaterialize:
+				if file, err = resolveSymlink(entries, name); err != nil {
+					return err
+				}
+			default:
+				continue
+			}
+		}
+
+		if err := os.MkdirAll(filepath.Dir(targetPath), searchable(perm)); err != nil {
 			return fmt.Errorf("creating parent directory: %w", err)
 		}
 
@@ -592,13 +995,18 @@ func (f *Fetcher) extract(zipPath, targetDir, modulePath, version string) error
 			return fmt.Errorf("opening zip entry: %w", err)
 		}
 
-		dst, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, file.Mode())
+		mode := normalizedMode(file.Mode(), perm)
+		dst, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
 		if err != nil {
 			src.Close()
 			return fmt.Errorf("creating file: %w", err)
 		}
 
 		_, err = io.Copy(dst, src)
+		if err == nil {
+			// Set the mode explicitly so the umask can't change it.
+			err = dst.Chmod(mode)
+		}
 		src.Close()
 		dst.Close()
 		if err != nil {
@@ -609,6 +1017,17 @@ func (f *Fetcher) extract(zipPath, targetDir, modulePath, version string) error
 	return nil
 }
 
+// normalizedMode maps an archive entry's mode to perm, plus search
+// permission wherever perm grants read if the entry is executable by its
+// owner (the only bit a NAR records), so extracted trees hash the same
+// whichever tool built the archive.
+func normalizedMode(mode, perm os.FileMode) os.FileMode {
+	if mode&0o100 != 0 {
+		return searchable(perm)
+	}
+	return perm
+}
+
 // escapePath escapes a module path for use in URLs.
 func escapePath(path string) string {
 	// Go module proxy encodes uppercase letters
@@ -636,23 +1055,8 @@ func escapeVersion(version string) string {
 //   - "github.com/owner/repo/api/v1alpha1" → "api/v1alpha1" (true submodule)
 //   - "github.com/owner/repo/sub/v3" → "sub" (submodule with major version suffix)
 func moduleTagPrefix(modulePath string) string {
-	parts := strings.SplitN(modulePath, "/", 4)
-	if len(parts) < 4 {
-		return ""
-	}
-	suffix := parts[3]
-
-	if isMajorVersionSuffix(suffix) {
-		return ""
-	}
-
-	if idx := strings.LastIndex(suffix, "/"); idx != -1 {
-		if isMajorVersionSuffix(suffix[idx+1:]) {
-			return suffix[:idx]
-		}
-	}
-
-	return suffix
+	_, subpath, _ := gitHubRepo(modulePath)
+	return subpathTagPrefix(subpath)
 }
 
 // resolveGitRev resolves a git ref or short hash to a full 40-character commit hash.
@@ -664,12 +1068,12 @@ func (f *Fetcher) resolveGitRev(repoURL, ref, shortRev string) string {
 	// For refs (tags, branches), use git ls-remote
 	if ref != "" {
 		// Try dereferenced tag first (annotated tags point to tag objects, not commits)
-		if output, err := exec.Command("git", "ls-remote", gitURL, ref+"^{}").Output(); err == nil {
+		if output, err := f.command("git", "ls-remote", gitURL, ref+"^{}").Output(); err == nil {
 			if fields := strings.Fields(strings.TrimSpace(string(output))); len(fields) >= 1 && len(fields[0]) == 40 {
 				return fields[0]
 			}
 		}
-		if output, err := exec.Command("git", "ls-remote", gitURL, ref).Output(); err == nil {
+		if output, err := f.command("git", "ls-remote", gitURL, ref).Output(); err == nil {
 			if fields := strings.Fields(strings.TrimSpace(string(output))); len(fields) >= 1 && len(fields[0]) == 40 {
 				return fields[0]
 			}
@@ -680,35 +1084,16 @@ func (f *Fetcher) resolveGitRev(repoURL, ref, shortRev string) string {
 	if shortRev != "" && len(shortRev) < 40 && strings.HasPrefix(repoURL, "https://github.com/") {
 		repoPath := strings.TrimPrefix(repoURL, "https://github.com/")
 		repoPath = strings.TrimSuffix(repoPath, ".git")
-		apiURL := fmt.Sprintf("https://api.github.com/repos/%s/commits/%s", repoPath, shortRev)
 
-		var client http.Client
-		host := "api.github.com"
-		if machine := f.Netrc.FindMachine(host, ""); machine != nil {
-			client.Transport = &authTransport{base: http.DefaultTransport, login: machine.Login, password: machine.Password}
-		} else if machine := f.Netrc.FindMachine("github.com", ""); machine != nil {
-			client.Transport = &authTransport{base: http.DefaultTransport, login: machine.Login, password: machine.Password}
+		var result struct {
+			SHA string `json:"sha"`
 		}
-
-		req, err := http.NewRequest("GET", apiURL, nil)
-		if err != nil {
-			return ""
+		err := f.github().getJSON(fmt.Sprintf("/repos/%s/commits/%s", repoPath, shortRev), &result)
+		if err == nil && len(result.SHA) == 40 {
+			return result.SHA
 		}
-		req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-		resp, err := client.Do(req)
-		if err != nil {
-			return ""
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode == http.StatusOK {
-			var result struct {
-				SHA string `json:"sha"`
-			}
-			if err := json.NewDecoder(resp.Body).Decode(&result); err == nil && len(result.SHA) == 40 {
-				return result.SHA
-			}
+		if err != nil && !errors.Is(err, ErrGitHubNotFound) {
+			fmt.Fprintf(os.Stderr, "warning: resolving %s@%s: %v\n", repoPath, shortRev, err)
 		}
 	}
 
@@ -740,6 +1125,21 @@ func archiveToAPIURL(archiveURL string) string {
 	return fmt.Sprintf("https://api.github.com/repos/%s/zipball/%s", repoPath, ref)
 }
 
+// archiveHashType returns the HashType of a module fetched from downloadURL
+// at rev: HashNAR for a GitHub archive without a full rev, or a GitLab
+// archive, whose tree Fetch hashes, and otherwise empty.
+func archiveHashType(downloadURL, rev string) string {
+	if isGitLabArchiveURL(downloadURL) || (isGitHubArchiveURL(downloadURL) && len(rev) != 40) {
+		return HashNAR
+	}
+	return ""
+}
+
+// isGitHubArchiveURL reports whether u is a github.com repository archive URL.
+func isGitHubArchiveURL(u string) bool {
+	return strings.HasPrefix(u, "https://github.com/") && strings.Contains(u, "/archive/")
+}
+
 // isMajorVersionSuffix reports whether s is a Go major version suffix (v2, v3, ...).
 func isMajorVersionSuffix(s string) bool {
 	if len(s) < 2 || s[0] != 'v' {
@@ -755,13 +1155,11 @@ func isMajorVersionSuffix(s string) bool {
 	return n >= 2
 }
 
-// extractHost gets the host part of a module path.
+// extractHost gets the host part of a module path, normalized (see
+// normalizeHost).
 func extractHost(modulePath string) string {
-	host, _, found := strings.Cut(modulePath, "/")
-	if found {
-		return host
-	}
-	return modulePath
+	host, _, _ := strings.Cut(modulePath, "/")
+	return normalizeHost(host)
 }
 
 // authTransport adds basic auth to HTTP requests.
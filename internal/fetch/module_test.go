@@ -1,7 +1,10 @@
 package fetch
 
 import (
+	"archive/zip"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -208,13 +211,22 @@ func TestMatchPattern(t *testing.T) {
 		want       bool
 	}{
 		{"github.com/myorg/*", "github.com/myorg/repo", true},
-		{"github.com/myorg/*", "github.com/myorg", true},
+		{"github.com/myorg/*", "github.com/myorg", false}, // the pattern needs three elements, as in go
 		{"github.com/myorg/*", "github.com/other/repo", false},
 		{"github.com/myorg*", "github.com/myorg", true},
 		{"github.com/myorg*", "github.com/myorgtest", true},
 		{"github.com/myorg", "github.com/myorg/repo", true},
 		{"github.com/myorg", "github.com/myorg", true},
 		{"github.com/myorg", "github.com/other", false},
+		{"github.com/myorg", "github.com/myorganic", false},
+		{"*.corp.example.com", "git.corp.example.com/team/repo", true},
+		{"*.corp.example.com", "corp.example.com/repo", false},
+		{"example.com/*/internal", "example.com/team/internal/tool", true},
+		{"example.com/*/internal", "example.com/team/public", false},
+		{"example.com/team?", "example.com/team1/repo", true},
+		{"github.com/a/*,gitlab.com/b", "gitlab.com/b/c", true},
+		{"github.com/a/*, gitlab.com/b", "gitlab.com/b/c", true},
+		{"github.com/a/*,,", "gitlab.com/b/c", false},
 	}
 
 	for _, tt := range tests {
@@ -418,3 +430,107 @@ func TestIsMajorVersionSuffix(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractSubdir(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "archive.zip")
+
+	writeTestZip(t, zipPath, map[string]string{
+		"repo-1.0.0/go.mod":           "module github.com/owner/repo\n",
+		"repo-1.0.0/root.go":          "package repo\n",
+		"repo-1.0.0/sub/mod/go.mod":   "module github.com/owner/repo/sub/mod\n",
+		"repo-1.0.0/sub/mod/pkg/a.go": "package pkg\n",
+		"repo-1.0.0/sub/module/b.go":  "package module\n",
+	})
+
+	target := filepath.Join(dir, "out")
+	f := &Fetcher{}
+	if err := f.extract(zipPath, target, "github.com/owner/repo/sub/mod", "v1.0.0", "sub/mod", 0o644); err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+
+	var got []string
+	filepath.WalkDir(target, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(target, path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+
+	want := []string{"go.mod", "pkg/a.go"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("extracted files = %v, want %v", got, want)
+	}
+}
+
+func TestExtractNormalizesModes(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "mod.zip")
+
+	zf, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(zf)
+	modes := map[string]os.FileMode{
+		"group-writable.go": 0o664,
+		"read-only.go":      0o444,
+		"script.sh":         0o775,
+		"owner-only.sh":     0o700,
+	}
+	for name, mode := range modes {
+		h := &zip.FileHeader{Name: "example.com/mod@v1.0.0/" + name}
+		h.SetMode(mode)
+		if _, err := zw.CreateHeader(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zf.Close()
+
+	f := &Fetcher{}
+	for perm, want := range map[os.FileMode]map[string]os.FileMode{
+		0o644: {
+			"group-writable.go": 0o644,
+			"read-only.go":      0o644,
+			"script.sh":         0o755,
+			"owner-only.sh":     0o755,
+		},
+		DefaultCachePerm: {
+			"group-writable.go": 0o600,
+			"read-only.go":      0o600,
+			"script.sh":         0o700,
+			"owner-only.sh":     0o700,
+		},
+	} {
+		target := filepath.Join(dir, fmt.Sprintf("out-%04o", perm))
+		if err := f.extract(zipPath, target, "example.com/mod", "v1.0.0", "", perm); err != nil {
+			t.Fatalf("extract: %v", err)
+		}
+		for name, mode := range want {
+			info, err := os.Stat(filepath.Join(target, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != mode {
+				t.Errorf("perm %04o: %s mode = %04o, want %04o", perm, name, info.Mode().Perm(), mode)
+			}
+		}
+	}
+}
+
+func TestPrivateFromEnv(t *testing.T) {
+	t.Setenv("GOPRIVATE", "example.com/private")
+	t.Setenv("GONOPROXY", "")
+	if got := PrivateFromEnv(); got != "example.com/private" {
+		t.Errorf("PrivateFromEnv() = %q, want GOPRIVATE", got)
+	}
+
+	t.Setenv("GONOPROXY", "example.com/noproxy,*.corp.example.com")
+	if got := PrivateFromEnv(); got != "example.com/noproxy,*.corp.example.com" {
+		t.Errorf("PrivateFromEnv() = %q, want GONOPROXY", got)
+	}
+}
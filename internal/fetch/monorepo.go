@@ -0,0 +1,132 @@
+package fetch
+
+import "strings"
+
+// monorepo maps a family of modules to the GitHub repository holding them.
+// Each module below Prefix lives in the repository directory named by the
+// rest of its path, less any major version suffix, and is tagged with that
+// directory as prefix, as the go command expects of multi-module
+// repositories.
+type monorepo struct {
+	// Prefix is the module path of the repository root. A final "/*"
+	// stands for one path element naming the repository.
+	Prefix string
+	// Repo is the repository as owner/name; "*" is replaced by the path
+	// element matched in Prefix.
+	Repo string
+}
+
+// monorepos lists module families whose repository can't be told from the
+// module path, because they are served under a vanity import path, or that
+// hold many modules sharing the repository's tags.
+var monorepos = []monorepo{
+	{Prefix: "github.com/aws/aws-sdk-go-v2", Repo: "aws/aws-sdk-go-v2"},
+	{Prefix: "google.golang.org/genproto", Repo: "googleapis/go-genproto"},
+	{Prefix: "google.golang.org/grpc", Repo: "grpc/grpc-go"},
+	{Prefix: "google.golang.org/protobuf", Repo: "protocolbuffers/protobuf-go"},
+	{Prefix: "google.golang.org/api", Repo: "googleapis/google-api-go-client"},
+	{Prefix: "cloud.google.com/go", Repo: "googleapis/google-cloud-go"},
+	{Prefix: "go.opentelemetry.io/otel", Repo: "open-telemetry/opentelemetry-go"},
+	{Prefix: "go.opentelemetry.io/contrib", Repo: "open-telemetry/opentelemetry-go-contrib"},
+	{Prefix: "k8s.io/*", Repo: "kubernetes/*"},
+	{Prefix: "sigs.k8s.io/*", Repo: "kubernetes-sigs/*"},
+}
+
+// gitHubRepo returns the GitHub repository (owner/name) holding modulePath
+// and the module's path within it, from monorepos, the gopkg.in naming
+// rules or, for github.com modules, the path itself. The longest matching
+// Prefix wins.
+func gitHubRepo(modulePath string) (repo, subpath string, ok bool) {
+	if rest, found := strings.CutPrefix(modulePath, "gopkg.in/"); found {
+		return gopkgInRepo(rest)
+	}
+
+	best := -1
+	for _, m := range monorepos {
+		r, sub, matched := m.match(modulePath)
+		if matched && len(m.Prefix) > best {
+			repo, subpath, ok, best = r, sub, true, len(m.Prefix)
+		}
+	}
+	if ok {
+		return repo, subpath, true
+	}
+
+	parts := strings.SplitN(modulePath, "/", 4)
+	if parts[0] != "github.com" || len(parts) < 3 {
+		return "", "", false
+	}
+	repo = parts[1] + "/" + parts[2]
+	if len(parts) == 4 {
+		subpath = parts[3]
+	}
+	return repo, subpath, true
+}
+
+// match reports whether modulePath belongs to m, returning its repository
+// and its path within it.
+func (m monorepo) match(modulePath string) (repo, subpath string, ok bool) {
+	base, wildcard := strings.CutSuffix(m.Prefix, "/*")
+	rest, found := strings.CutPrefix(modulePath, base)
+	if !found || (rest != "" && rest[0] != '/') {
+		return "", "", false
+	}
+	rest = strings.TrimPrefix(rest, "/")
+	if !wildcard {
+		return m.Repo, rest, true
+	}
+
+	name, rest, _ := strings.Cut(rest, "/")
+	if name == "" {
+		return "", "", false
+	}
+	return strings.ReplaceAll(m.Repo, "*", name), rest, true
+}
+
+// subpathTagPrefix returns the git tag prefix, which is also the repository
+// directory, of the module at subpath within its repository. A major
+// version suffix (/v2, /v3, ...) is not part of it.
+func subpathTagPrefix(subpath string) string {
+	if subpath == "" || isMajorVersionSuffix(subpath) {
+		return ""
+	}
+	if idx := strings.LastIndex(subpath, "/"); idx != -1 {
+		if isMajorVersionSuffix(subpath[idx+1:]) {
+			return subpath[:idx]
+		}
+	}
+	return subpath
+}
+
+// gopkgInRepo maps the path of a gopkg.in module after the host to its
+// GitHub repository, following the rules of the gopkg.in redirector:
+// gopkg.in/pkg.vN is github.com/go-pkg/pkg and gopkg.in/user/pkg.vN is
+// github.com/user/pkg. The major version is part of the path only; tags
+// are plain versions.
+func gopkgInRepo(rest string) (repo, subpath string, ok bool) {
+	elems := strings.Split(rest, "/")
+	if name, found := cutGopkgInMajor(elems[0]); found {
+		return "go-" + name + "/" + name, strings.Join(elems[1:], "/"), true
+	}
+	if len(elems) >= 2 {
+		if name, found := cutGopkgInMajor(elems[1]); found {
+			return elems[0] + "/" + name, strings.Join(elems[2:], "/"), true
+		}
+	}
+	return "", "", false
+}
+
+// cutGopkgInMajor splits the ".vN" major version off a gopkg.in package
+// name such as "yaml.v3".
+func cutGopkgInMajor(elem string) (string, bool) {
+	i := strings.LastIndex(elem, ".v")
+	if i <= 0 || i+2 == len(elem) {
+		return "", false
+	}
+	for _, c := range elem[i+2:] {
+		if c < '0' || c > '9' {
+			return "", false
+		}
+	}
+	return elem[:i], true
+}
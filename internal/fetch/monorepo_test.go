@@ -0,0 +1,103 @@
+package fetch
+
+import "testing"
+
+func TestGitHubRepo(t *testing.T) {
+	tests := []struct {
+		modulePath  string
+		wantRepo    string
+		wantSubpath string
+		wantOK      bool
+	}{
+		{"github.com/owner/repo/sub/v2", "owner/repo", "sub/v2", true},
+		{"github.com/aws/aws-sdk-go-v2/service/s3", "aws/aws-sdk-go-v2", "service/s3", true},
+		{"github.com/aws/aws-sdk-go", "aws/aws-sdk-go", "", true},
+		{"google.golang.org/genproto", "googleapis/go-genproto", "", true},
+		{"google.golang.org/genproto/googleapis/api", "googleapis/go-genproto", "googleapis/api", true},
+		{"google.golang.org/grpcx", "", "", false},
+		{"cloud.google.com/go/storage", "googleapis/google-cloud-go", "storage", true},
+		{"k8s.io/klog/v2", "kubernetes/klog", "v2", true},
+		{"k8s.io", "", "", false},
+		{"gopkg.in/yaml.v3", "go-yaml/yaml", "", true},
+		{"gopkg.in/src-d/go-git.v4", "src-d/go-git", "", true},
+		{"gopkg.in/check.v1/sub", "go-check/check", "sub", true},
+		{"gopkg.in/yaml", "", "", false},
+		{"gopkg.in/yaml.v", "", "", false},
+		{"example.com/mod", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.modulePath, func(t *testing.T) {
+			repo, subpath, ok := gitHubRepo(tt.modulePath)
+			if repo != tt.wantRepo || subpath != tt.wantSubpath || ok != tt.wantOK {
+				t.Errorf("gitHubRepo() = %q, %q, %v; want %q, %q, %v", repo, subpath, ok, tt.wantRepo, tt.wantSubpath, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMonorepoModuleInfo(t *testing.T) {
+	tests := []struct {
+		modulePath string
+		version    string
+		wantURL    string
+		wantSubdir string
+	}{
+		{
+			modulePath: "google.golang.org/genproto/googleapis/api",
+			version:    "v0.0.0-20240123012728-ef4313101c80",
+			wantURL:    "https://github.com/googleapis/go-genproto/archive/ef4313101c80.zip",
+			wantSubdir: "googleapis/api",
+		},
+		{
+			modulePath: "github.com/aws/aws-sdk-go-v2/service/s3",
+			version:    "v1.48.0",
+			wantURL:    "https://github.com/aws/aws-sdk-go-v2/archive/refs/tags/service/s3/v1.48.0.zip",
+			wantSubdir: "service/s3",
+		},
+		{
+			modulePath: "cloud.google.com/go/storage",
+			version:    "v1.36.0",
+			wantURL:    "https://github.com/googleapis/google-cloud-go/archive/refs/tags/storage/v1.36.0.zip",
+			wantSubdir: "storage",
+		},
+		{
+			modulePath: "gopkg.in/yaml.v3",
+			version:    "v3.0.1",
+			wantURL:    "https://github.com/go-yaml/yaml/archive/refs/tags/v3.0.1.zip",
+		},
+		{
+			modulePath: "github.com/owner/repo",
+			version:    "v2.1.0+incompatible",
+			wantURL:    "https://github.com/owner/repo/archive/refs/tags/v2.1.0.zip",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.modulePath, func(t *testing.T) {
+			f := &Fetcher{}
+			info, err := f.getModuleInfoManual(tt.modulePath, tt.version)
+			if err != nil || info.Origin == nil {
+				t.Fatalf("getModuleInfoManual() = %+v, %v", info, err)
+			}
+			if got, guessed := f.gitHubURLFromInfo(tt.modulePath, tt.version, info); got != tt.wantURL || guessed {
+				t.Errorf("gitHubURLFromInfo() = %q, %v; want %q", got, guessed, tt.wantURL)
+			}
+			if info.Origin.Subdir != tt.wantSubdir {
+				t.Errorf("Subdir = %q, want %q", info.Origin.Subdir, tt.wantSubdir)
+			}
+		})
+	}
+}
+
+func TestGitHubURLFromRedirectedOrigin(t *testing.T) {
+	f := &Fetcher{}
+	info, _ := f.getModuleInfoManual("gopkg.in/yaml.v3", "v3.0.1")
+	info.Origin.URL = "https://gopkg.in/yaml.v3"
+	info.Origin.Hash = "f6f7691f1bdeb7d0b2bbdb2e1aa4f4f5a1b4b8f1"
+
+	want := "https://github.com/go-yaml/yaml/archive/f6f7691f1bdeb7d0b2bbdb2e1aa4f4f5a1b4b8f1.zip"
+	if got, guessed := f.gitHubURLFromInfo("gopkg.in/yaml.v3", "v3.0.1", info); got != want || guessed {
+		t.Errorf("gitHubURLFromInfo() = %q, %v; want %q", got, guessed, want)
+	}
+}
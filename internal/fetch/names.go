@@ -0,0 +1,45 @@
+package fetch
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// foldName returns the key under which name collides on case-insensitive or
+// Unicode-normalizing filesystems (such as APFS and HFS+ on macOS).
+func foldName(name string) string {
+	return strings.ToLower(norm.NFC.String(name))
+}
+
+// checkNameCollisions rejects archive entries whose paths, or any of their
+// parent directories, differ only in case or Unicode normalization (NFC vs
+// NFD). Extracting them would yield a different tree, and so a different
+// hash, depending on the filesystem.
+func checkNameCollisions(names []string) error {
+	seen := make(map[string]string, len(names))
+	for _, name := range names {
+		name = strings.TrimSuffix(name, "/")
+		for i := 0; i <= len(name); i++ {
+			if i < len(name) && name[i] != '/' {
+				continue
+			}
+			p := name[:i]
+			key := foldName(p)
+			prev, ok := seen[key]
+			if !ok {
+				seen[key] = p
+				continue
+			}
+			if prev == p {
+				continue
+			}
+			if norm.NFC.String(prev) == norm.NFC.String(p) {
+				return fmt.Errorf("archive entries %q and %q differ only in Unicode normalization", prev, p)
+			}
+			return fmt.Errorf("archive entries %q and %q differ only in case", prev, p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package fetch
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckNameCollisions(t *testing.T) {
+	tests := []struct {
+		names   []string
+		wantErr string
+	}{
+		{names: []string{"go.mod", "pkg/", "pkg/a.go", "pkg/b.go", "café.go"}},
+		{names: []string{"README", "readme"}, wantErr: "differ only in case"},
+		{names: []string{"Pkg/a.go", "pkg/b.go"}, wantErr: `"Pkg" and "pkg" differ only in case`},
+		{names: []string{"café.go", "café.go"}, wantErr: "differ only in Unicode normalization"},
+		{names: []string{"café/x.go", "café/y.go"}, wantErr: "differ only in Unicode normalization"},
+	}
+	for _, tt := range tests {
+		err := checkNameCollisions(tt.names)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("checkNameCollisions(%q) error = %v", tt.names, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("checkNameCollisions(%q) error = %v, want %q", tt.names, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExtractRejectsNormalizationCollisions(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "mod.zip")
+	writeTestZip(t, zipPath, map[string]string{
+		"example.com/mod@v1.0.0/go.mod":   "module example.com/mod\n",
+		"example.com/mod@v1.0.0/café.go":  "package mod\n",
+		"example.com/mod@v1.0.0/café.go": "package mod\n",
+	})
+
+	f := &Fetcher{}
+	if err := f.extract(zipPath, filepath.Join(dir, "out"), "example.com/mod", "v1.0.0", "", 0o644); err == nil {
+		t.Error("extract() should reject names differing only in normalization")
+	}
+}
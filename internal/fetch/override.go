@@ -0,0 +1,57 @@
+package fetch
+
+import (
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// overrideURL returns the download URL from the first matching URLOverrides
+// entry, or "" if none match. Exact module paths take precedence; otherwise
+// the longest matching pattern wins.
+func (f *Fetcher) overrideURL(modulePath, version string) string {
+	key, ok := matchKey(f.URLOverrides, modulePath)
+	if !ok {
+		return ""
+	}
+	return expandURLTemplate(f.URLOverrides[key], modulePath, version)
+}
+
+// matchKey returns the key of m that applies to modulePath: the module path
+// itself if present, otherwise the longest matching GOPRIVATE-style pattern,
+// the first in sort order among equally long ones.
+func matchKey[V any](m map[string]V, modulePath string) (string, bool) {
+	if _, ok := m[modulePath]; ok {
+		return modulePath, true
+	}
+	best := ""
+	for pattern := range m {
+		better := len(pattern) > len(best) || (len(pattern) == len(best) && pattern < best)
+		if better && matchPattern(pattern, modulePath) {
+			best = pattern
+		}
+	}
+	return best, best != ""
+}
+
+// expandURLTemplate substitutes {module}, {version}, and {rev} in tmpl.
+// {rev} is the commit hash for pseudo-versions and the version otherwise.
+func expandURLTemplate(tmpl, modulePath, version string) string {
+	r := strings.NewReplacer(
+		"{module}", modulePath,
+		"{version}", version,
+		"{rev}", versionRev(version),
+	)
+	return r.Replace(tmpl)
+}
+
+// versionRev returns the revision identifier embedded in version: the commit
+// hash suffix of a pseudo-version, or the version itself for tags.
+func versionRev(version string) string {
+	if module.IsPseudoVersion(version) {
+		if rev, err := module.PseudoVersionRev(version); err == nil {
+			return rev
+		}
+	}
+	return version
+}
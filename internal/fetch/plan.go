@@ -0,0 +1,93 @@
+package fetch
+
+import (
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Sources of a download URL in a FetchPlan.
+const (
+	SourceOverride = "override"
+	SourcePrivate  = "private"
+	SourceMirror   = "mirror"
+	SourceProxy    = "proxy"
+	SourceDirect   = "direct"
+	SourceOff      = "off"
+)
+
+// FetchPlan describes how Fetch would obtain a module.
+type FetchPlan struct {
+	// Source is where the module comes from: SourceOverride, SourcePrivate,
+	// SourceMirror, SourceProxy, SourceDirect, or SourceOff if GOPROXY
+	// forbids downloading it.
+	Source string
+	// URL is the download URL, or "" for SourceOff.
+	URL string
+	// Guessed reports that URL is a heuristic guess, which strict mode
+	// rejects.
+	Guessed bool
+	// Auth is how requests for URL are authenticated: "netrc", "signed",
+	// or "" for none.
+	Auth string
+	// CacheHit reports that the module is in CacheDir, so Fetch would not
+	// download it.
+	CacheHit bool
+}
+
+// Plan resolves the source and download URL of modulePath at version the way
+// Fetch does, without downloading the module. Origin metadata for GitHub
+// modules is still looked up unless the module is cached, in which case
+// the URL it was fetched from is reported.
+func (f *Fetcher) Plan(modulePath, version string) *FetchPlan {
+	p := &FetchPlan{}
+	cachedDir := f.cachedDir(modulePath, version)
+	if _, err := os.Stat(cachedDir + ".hash"); err == nil {
+		p.CacheHit = true
+	}
+
+	switch {
+	case f.overrideURL(modulePath, "") != "":
+		p.Source = SourceOverride
+	case f.isPrivate(modulePath):
+		p.Source = SourcePrivate
+	case f.proxyOff(modulePath):
+		p.Source = SourceOff
+	case f.proxyBase(modulePath) == "":
+		p.Source = SourceDirect
+	default:
+		p.Source = SourceProxy
+		if key, ok := matchKey(f.Mirrors, modulePath); ok && len(f.Mirrors[key]) > 0 {
+			p.Source = SourceMirror
+		}
+	}
+	if p.CacheHit {
+		// The URL recorded when the module was fetched.
+		if data, err := os.ReadFile(cachedDir + ".url"); err == nil {
+			p.URL = strings.TrimSpace(string(data))
+		}
+		return p
+	}
+	if p.Source == SourceOff {
+		return p
+	}
+
+	p.URL, p.Guessed = f.resolveDownloadURL(modulePath, version)
+	requestURL := f.requestURL(modulePath, p.URL)
+	if f.netrcMachine(modulePath, requestURL) != nil {
+		p.Auth = "netrc"
+	} else if u, err := url.Parse(requestURL); err == nil && f.signerFor(u.Host) {
+		p.Auth = "signed"
+	}
+	return p
+}
+
+// signerFor reports whether requests to host are signed.
+func (f *Fetcher) signerFor(host string) bool {
+	signers := make(map[string]Signer, len(f.Signers))
+	for h, signer := range f.Signers {
+		signers[normalizeHost(h)] = signer
+	}
+	_, ok := lookupSigner(signers, host)
+	return ok
+}
@@ -0,0 +1,59 @@
+package fetch
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/git-lfs/go-netrc/netrc"
+)
+
+func TestPlan(t *testing.T) {
+	rc, err := netrc.Parse(strings.NewReader("machine git.example.com login u password p\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		fetcher    *Fetcher
+		modulePath string
+		want       FetchPlan
+	}{
+		{
+			name:       "proxy",
+			fetcher:    &Fetcher{Proxy: DefaultProxy},
+			modulePath: "golang.org/x/mod",
+			want:       FetchPlan{Source: SourceProxy, URL: "https://proxy.golang.org/golang.org/x/mod/@v/v1.0.0.zip"},
+		},
+		{
+			name: "mirror",
+			fetcher: &Fetcher{
+				Proxy:   DefaultProxy,
+				Mirrors: map[string][]string{"corp.example.com": {"https://goproxy.corp.example.com"}},
+			},
+			modulePath: "corp.example.com/lib",
+			want:       FetchPlan{Source: SourceMirror, URL: "https://goproxy.corp.example.com/corp.example.com/lib/@v/v1.0.0.zip"},
+		},
+		{
+			name:       "private with netrc credentials",
+			fetcher:    &Fetcher{Proxy: DefaultProxy, Private: "git.example.com", Netrc: rc},
+			modulePath: "git.example.com/team/lib",
+			want:       FetchPlan{Source: SourcePrivate, URL: "https://git.example.com/git.example.com/team/lib/@v/v1.0.0.zip", Guessed: true, Auth: "netrc"},
+		},
+		{
+			name:       "proxy off",
+			fetcher:    &Fetcher{Proxy: ProxyOff},
+			modulePath: "golang.org/x/mod",
+			want:       FetchPlan{Source: SourceOff},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fetcher.CacheDir = t.TempDir()
+			if got := tt.fetcher.Plan(tt.modulePath, "v1.0.0"); *got != tt.want {
+				t.Errorf("Plan() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ProxyDirect is the GOPROXY keyword for fetching from version control.
+	ProxyDirect = "direct"
+	// ProxyOff is the GOPROXY keyword that disallows downloading.
+	ProxyOff = "off"
+)
+
+// ErrProxyOff is returned for modules that GOPROXY=off forbids downloading.
+var ErrProxyOff = errors.New("module lookup disabled by GOPROXY=off")
+
+// ProxySpec is an entry of a GOPROXY list.
+type ProxySpec struct {
+	// URL is a proxy base URL, or ProxyDirect or ProxyOff.
+	URL string
+	// OnError reports that the entry is tried after any failure of the one
+	// before it ("|" separator), not only when that one reports the module
+	// missing with 404 or 410 ("," separator).
+	OnError bool
+}
+
+// ParseProxyList parses a GOPROXY value the way the go command does. Entries
+// are separated by "," or "|"; "direct" and "off" end the list, so anything
+// after them is ignored. An entry without a scheme gets "https://". An empty
+// value means the go command's default, DefaultProxy then direct.
+func ParseProxyList(goproxy string) []ProxySpec {
+	if strings.TrimSpace(goproxy) == "" {
+		goproxy = DefaultProxy + "," + ProxyDirect
+	}
+
+	var list []ProxySpec
+	onError := false
+	for goproxy != "" {
+		var entry string
+		nextOnError := false
+		if i := strings.IndexAny(goproxy, ",|"); i >= 0 {
+			entry = goproxy[:i]
+			nextOnError = goproxy[i] == '|'
+			goproxy = goproxy[i+1:]
+		} else {
+			entry, goproxy = goproxy, ""
+		}
+
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		spec := ProxySpec{URL: entry, OnError: onError}
+		onError = nextOnError
+
+		if entry == ProxyDirect || entry == ProxyOff {
+			list = append(list, spec)
+			break
+		}
+		if strings.ContainsAny(entry, ".:/") && !strings.Contains(entry, ":/") && !filepath.IsAbs(entry) && !path.IsAbs(entry) {
+			spec.URL = "https://" + entry
+		}
+		list = append(list, spec)
+	}
+	return list
+}
+
+// splitProxyList splits a parsed GOPROXY list into the Proxy and Fallback
+// fields of a Fetcher.
+func splitProxyList(list []ProxySpec) (string, []ProxySpec) {
+	if len(list) == 0 {
+		return ProxyOff, nil
+	}
+	switch list[0].URL {
+	case ProxyDirect:
+		return "", nil
+	case ProxyOff:
+		return ProxyOff, nil
+	}
+	return list[0].URL, list[1:]
+}
+
+// proxyOff reports whether GOPROXY forbids downloading modulePath: it is
+// neither private nor covered by URL overrides or mirrors, and the proxy
+// list is "off".
+func (f *Fetcher) proxyOff(modulePath string) bool {
+	if f.Proxy != ProxyOff || f.isPrivate(modulePath) || f.overrideURL(modulePath, "") != "" {
+		return false
+	}
+	key, ok := matchKey(f.Mirrors, modulePath)
+	return !ok || len(f.Mirrors[key]) == 0
+}
+
+// downloadFallback walks the Fallback list after the proxy download of
+// modulePath@version failed with err, as the go command walks GOPROXY: each
+// entry is tried only after a 404 or 410 from the one before, unless it is
+// marked OnError. It returns the URL that succeeded, whether that URL is a
+// heuristic guess, the downloaded zip, and the number of download attempts
+// made, each entry and mirror tried counting as one.
+func (f *Fetcher) downloadFallback(modulePath, version string, err error) (downloadURL string, guessed bool, zipPath string, size int64, attempts int, _ error) {
+	for _, spec := range f.Fallback {
+		if !spec.OnError && !isNotFound(err) {
+			break
+		}
+		switch spec.URL {
+		case ProxyOff:
+			return "", false, "", 0, attempts, fmt.Errorf("%w after %v", ErrProxyOff, err)
+		case ProxyDirect:
+			downloadURL, guessed = f.resolveDirectURL(modulePath, version)
+		default:
+			downloadURL, guessed = proxyZipURL(spec.URL, modulePath, version), false
+		}
+		if guessed && f.Strict {
+			return "", false, "", 0, attempts, fmt.Errorf("strict mode: no verified source for %s@%s (would guess %s)", modulePath, version, downloadURL)
+		}
+		if f.Verbose {
+			fmt.Fprintf(os.Stderr, "Falling back to %s for %s@%s: %v\n", downloadURL, modulePath, version, err)
+		}
+		var retries int
+		zipPath, size, retries, err = f.downloadFromURL(downloadURL, modulePath, version)
+		attempts += 1 + retries
+		if err == nil {
+			return downloadURL, guessed, zipPath, size, attempts, nil
+		}
+	}
+	return "", false, "", 0, attempts, err
+}
+
+// proxyZipURL returns the URL of the zip of modulePath@version on the proxy
+// at base.
+func proxyZipURL(base, modulePath, version string) string {
+	return fmt.Sprintf("%s/%s/@v/%s.zip", strings.TrimSuffix(base, "/"), escapePath(modulePath), escapeVersion(version))
+}
+
+// isNotFound reports whether err is a download failure because the server
+// does not have the file, as opposed to the server failing.
+func isNotFound(err error) bool {
+	var se *statusError
+	return errors.As(err, &se) && Gone(se.status)
+}
+
+// statusError is a download that got an unexpected HTTP status.
+type statusError struct {
+	status int
+	text   string
+}
+
+func (e *statusError) Error() string {
+	return "unexpected status: " + e.text
+}
@@ -0,0 +1,184 @@
+package fetch
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseProxyList(t *testing.T) {
+	tests := []struct {
+		goproxy string
+		want    []ProxySpec
+	}{
+		{"", []ProxySpec{{URL: DefaultProxy}, {URL: "direct"}}},
+		{"https://corp-proxy,direct", []ProxySpec{{URL: "https://corp-proxy"}, {URL: "direct"}}},
+		{"corp.example.com|https://proxy.golang.org,off", []ProxySpec{
+			{URL: "https://corp.example.com"},
+			{URL: "https://proxy.golang.org", OnError: true},
+			{URL: "off"},
+		}},
+		{"direct,https://ignored", []ProxySpec{{URL: "direct"}}},
+		{"off", []ProxySpec{{URL: "off"}}},
+		{" https://a , ,https://b ", []ProxySpec{{URL: "https://a"}, {URL: "https://b"}}},
+		{"file:///srv/goproxy", []ProxySpec{{URL: "file:///srv/goproxy"}}},
+	}
+	for _, tt := range tests {
+		if got := ParseProxyList(tt.goproxy); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseProxyList(%q) = %+v, want %+v", tt.goproxy, got, tt.want)
+		}
+	}
+}
+
+func TestProxyFromEnvKeywords(t *testing.T) {
+	for goproxy, want := range map[string]string{
+		"":                          DefaultProxy,
+		"https://corp-proxy,direct": "https://corp-proxy",
+		"direct":                    "",
+		"off":                       ProxyOff,
+	} {
+		t.Setenv("GOPROXY", goproxy)
+		if got := ProxyFromEnv(); got != want {
+			t.Errorf("GOPROXY=%q: ProxyFromEnv() = %q, want %q", goproxy, got, want)
+		}
+	}
+}
+
+func TestDownloadFallback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/broken/"):
+			w.WriteHeader(http.StatusInternalServerError)
+		case strings.HasPrefix(r.URL.Path, "/backup/"):
+			w.Write([]byte("zip"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	const mod, version = "example.com/mod", "v1.0.0"
+	missing := &statusError{status: http.StatusNotFound, text: "404 Not Found"}
+	broken := &statusError{status: http.StatusInternalServerError, text: "500 Internal Server Error"}
+
+	f := &Fetcher{Proxy: srv.URL + "/corp", Fallback: []ProxySpec{{URL: srv.URL + "/empty"}, {URL: srv.URL + "/backup"}}}
+	u, _, path, _, attempts, err := f.downloadFallback(mod, version, missing)
+	if err != nil {
+		t.Fatalf("downloadFallback() error = %v", err)
+	}
+	os.Remove(path)
+	if want := srv.URL + "/backup/example.com/mod/@v/v1.0.0.zip"; u != want {
+		t.Errorf("downloadFallback() URL = %q, want %q", u, want)
+	}
+	if attempts != 2 {
+		t.Errorf("downloadFallback() attempts = %d, want 2", attempts)
+	}
+
+	// "," only falls back when the module is missing; "|" on any error.
+	if _, _, _, _, _, err := f.downloadFallback(mod, version, broken); err != broken {
+		t.Errorf("downloadFallback() after a server error = %v, want the original error", err)
+	}
+	f.Fallback = []ProxySpec{{URL: srv.URL + "/backup", OnError: true}}
+	_, _, path, _, _, err = f.downloadFallback(mod, version, broken)
+	if err != nil {
+		t.Errorf("downloadFallback() after a server error with | = %v", err)
+	}
+	os.Remove(path)
+
+	f.Fallback = []ProxySpec{{URL: ProxyOff}}
+	if _, _, _, _, _, err := f.downloadFallback(mod, version, missing); !errors.Is(err, ErrProxyOff) {
+		t.Errorf("downloadFallback() with off = %v, want ErrProxyOff", err)
+	}
+
+	f.Fallback = []ProxySpec{{URL: ProxyDirect}}
+	f.Strict = true
+	if _, _, _, _, _, err := f.downloadFallback(mod, version, missing); err == nil || !strings.Contains(err.Error(), "strict mode") {
+		t.Errorf("downloadFallback() to a guessed direct URL in strict mode = %v", err)
+	}
+}
+
+func TestProxyMetaFallback(t *testing.T) {
+	const (
+		commit = "0123456789abcdef0123456789abcdef01234567"
+		pseudo = "v0.0.0-20240101000000-0123456789ab"
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/broken/"):
+			w.WriteHeader(http.StatusInternalServerError)
+		case strings.HasPrefix(r.URL.Path, "/backup/") && strings.HasSuffix(r.URL.Path, ".info"):
+			w.Write([]byte(`{"Version":"` + pseudo + `","Time":"2024-01-01T00:00:00Z"}`))
+		case strings.HasPrefix(r.URL.Path, "/backup/") && strings.HasSuffix(r.URL.Path, ".mod"):
+			w.Write([]byte("module example.com/mod\n"))
+		case strings.HasPrefix(r.URL.Path, "/backup/"):
+			w.Write([]byte("v1.0.0\nv1.1.0\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	const mod = "example.com/mod"
+	f := &Fetcher{Proxy: srv.URL + "/corp", Fallback: []ProxySpec{{URL: srv.URL + "/empty"}, {URL: srv.URL + "/backup"}}}
+	versions, err := f.Versions(mod)
+	if err != nil {
+		t.Fatalf("Versions() error = %v", err)
+	}
+	if want := []string{"v1.0.0", "v1.1.0"}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("Versions() = %v, want %v", versions, want)
+	}
+
+	// "," only falls back when the module is missing; "|" on any error.
+	f.Proxy = srv.URL + "/broken"
+	f.Fallback = []ProxySpec{{URL: srv.URL + "/backup"}}
+	if _, err := f.Versions(mod); err == nil {
+		t.Error("Versions() after a server error should not fall back after ,")
+	}
+	f.Fallback = []ProxySpec{{URL: srv.URL + "/backup", OnError: true}}
+	if _, err := f.Versions(mod); err != nil {
+		t.Errorf("Versions() after a server error with | = %v", err)
+	}
+
+	f.Proxy = srv.URL + "/corp"
+	f.Fallback = []ProxySpec{{URL: ProxyOff}}
+	if _, err := f.Latest(mod); !errors.Is(err, ErrProxyOff) {
+		t.Errorf("Latest() with off = %v, want ErrProxyOff", err)
+	}
+	f.Fallback = []ProxySpec{{URL: ProxyDirect}}
+	if _, err := f.proxyMeta(mod, "@latest", -1); !errors.Is(err, errProxyDirect) {
+		t.Errorf("proxyMeta() with direct = %v, want errProxyDirect", err)
+	}
+	f.Fallback = []ProxySpec{{URL: srv.URL + "/backup"}}
+	if info, err := f.ResolveRev(mod, commit); err != nil || info.Version != pseudo {
+		t.Errorf("ResolveRev() via fallback proxy = %+v, %v", info, err)
+	}
+
+	// direct answers with go list, pointed at the test server so it stays
+	// offline.
+	t.Setenv("GOPROXY", srv.URL+"/backup")
+	t.Setenv("GOSUMDB", "off")
+	t.Setenv("GOFLAGS", "-mod=mod")
+	f.Fallback = []ProxySpec{{URL: ProxyDirect}}
+	if info, err := f.ResolveRev(mod, commit); err != nil || info.Version != pseudo {
+		t.Errorf("ResolveRev() via direct = %+v, %v", info, err)
+	}
+
+	f.Fallback = nil
+	if _, err := f.Versions(mod); !errors.Is(err, ErrNoVersions) {
+		t.Errorf("Versions() without fallbacks = %v, want ErrNoVersions", err)
+	}
+}
+
+func TestFetchProxyOff(t *testing.T) {
+	f := &Fetcher{Proxy: ProxyOff, CacheDir: t.TempDir(), Private: "corp.example.com"}
+	if _, err := f.Fetch("example.com/mod", "v1.0.0"); !errors.Is(err, ErrProxyOff) {
+		t.Errorf("Fetch() with GOPROXY=off = %v, want ErrProxyOff", err)
+	}
+	if !f.proxyOff("example.com/mod") || f.proxyOff("corp.example.com/mod") {
+		t.Error("proxyOff() should cover public modules only")
+	}
+}
@@ -0,0 +1,123 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// CommitVersion is the module version Go assigns a commit.
+type CommitVersion struct {
+	// Module is the module path declared by go.mod at the commit, or empty
+	// if it has none.
+	Module string
+	// Version is the commit's tagged version if it has one, otherwise its
+	// pseudo-version.
+	Version string
+	Tagged  bool
+	// Rev is the full commit hash.
+	Rev  string
+	Time time.Time
+}
+
+// ResolveCommit computes the version of rev (a commit hash, possibly
+// abbreviated, branch, or tag) in the git repository at repoURL, for the
+// module in subdir ("" for the repository root), without going through a
+// module proxy. Following the go command, the pseudo-version builds on the
+// highest semver tag for the module's major version that is an ancestor of
+// the commit. Only commits are fetched, into a temporary repository; the
+// go.mod is fetched on demand.
+func (f *Fetcher) ResolveCommit(repoURL, rev, subdir string) (*CommitVersion, error) {
+	tmp, err := os.MkdirTemp("", "nopher-commit-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	git := func(args ...string) (string, error) {
+		out, err := f.command("git", append([]string{"-C", tmp}, args...)...).Output()
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			err = errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return strings.TrimSpace(string(out)), err
+	}
+
+	if _, err := git("init", "-q", "--bare"); err != nil {
+		return nil, fmt.Errorf("git init: %w", err)
+	}
+	if _, err := git("remote", "add", "origin", repoURL); err != nil {
+		return nil, fmt.Errorf("git remote add: %w", err)
+	}
+	target := "refs/nopher/rev"
+	if _, err := git("fetch", "-q", "--filter=tree:0", "--tags", "origin", "+"+rev+":"+target); err != nil {
+		// Abbreviated hashes can't be fetched by name; fetch every branch
+		// and look the commit up instead.
+		if _, err := git("fetch", "-q", "--filter=tree:0", "--tags", "origin", "+refs/heads/*:refs/heads/*"); err != nil {
+			return nil, fmt.Errorf("fetching %s: %w", repoURL, err)
+		}
+		target = rev
+	}
+	commit, err := git("rev-parse", "--verify", "--quiet", target+"^{commit}")
+	if err != nil || commit == "" {
+		return nil, fmt.Errorf("%s has no commit %q", repoURL, rev)
+	}
+
+	ct, err := git("show", "-s", "--format=%ct", commit)
+	if err != nil {
+		return nil, fmt.Errorf("reading commit time: %w", err)
+	}
+	unix, err := strconv.ParseInt(ct, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("reading commit time: %w", err)
+	}
+	cv := &CommitVersion{Rev: commit, Time: time.Unix(unix, 0).UTC()}
+
+	if data, err := git("show", commit+":"+path.Join(subdir, "go.mod")); err == nil {
+		cv.Module = modfile.ModulePath([]byte(data))
+	}
+	var pathMajor string
+	if cv.Module != "" {
+		_, pathMajor, _ = module.SplitPathVersion(cv.Module)
+	}
+
+	prefix := ""
+	if subdir != "" {
+		prefix = strings.Trim(subdir, "/") + "/"
+	}
+	// highestTag returns the highest canonical version among the tags git
+	// lists, for the module's major version.
+	highestTag := func(args ...string) string {
+		out, err := git(append([]string{"tag", "--list", prefix + "v*"}, args...)...)
+		if err != nil {
+			return ""
+		}
+		best := ""
+		for _, tag := range strings.Fields(out) {
+			v := strings.TrimPrefix(tag, prefix)
+			if semver.Canonical(v) != v || module.CheckPathMajor(v, pathMajor) != nil {
+				continue
+			}
+			if best == "" || semver.Compare(v, best) > 0 {
+				best = v
+			}
+		}
+		return best
+	}
+
+	if tagged := highestTag("--points-at", commit); tagged != "" {
+		cv.Version, cv.Tagged = tagged, true
+		return cv, nil
+	}
+	older := highestTag("--merged", commit)
+	cv.Version = module.PseudoVersion(module.PathMajorPrefix(pathMajor), older, cv.Time, commit[:12])
+	return cv, nil
+}
@@ -0,0 +1,76 @@
+package fetch
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_COMMITTER_DATE=2024-03-05T10:20:30Z", "GIT_AUTHOR_DATE=2024-03-05T10:20:30Z")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	commit := func(name string) string {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		git("add", "-A")
+		git("commit", "-q", "-m", name)
+		return git("rev-parse", "HEAD")
+	}
+
+	git("init", "-q", "-b", "main")
+	git("config", "uploadpack.allowFilter", "true")
+	git("config", "uploadpack.allowAnySHA1InWant", "true")
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/lib/v2\n\ngo 1.22\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	commit("a")
+	git("tag", "v2.1.0")
+	git("tag", "v1.9.0") // Wrong major: ignored
+	tagged := commit("b")
+	git("tag", "v2.2.0-rc.1")
+	git("tag", "v2.2.0")
+	head := commit("c")
+
+	f := &Fetcher{}
+	repoURL := "file://" + dir
+	tests := []struct {
+		rev    string
+		want   string
+		tagged bool
+	}{
+		{rev: head, want: "v2.2.1-0.20240305102030-" + head[:12]},
+		{rev: head[:7], want: "v2.2.1-0.20240305102030-" + head[:12]},
+		{rev: "main", want: "v2.2.1-0.20240305102030-" + head[:12]},
+		{rev: tagged, want: "v2.2.0", tagged: true},
+	}
+	for _, tt := range tests {
+		cv, err := f.ResolveCommit(repoURL, tt.rev, "")
+		if err != nil {
+			t.Fatalf("ResolveCommit(%s): %v", tt.rev, err)
+		}
+		if cv.Version != tt.want || cv.Tagged != tt.tagged || cv.Module != "example.com/lib/v2" {
+			t.Errorf("ResolveCommit(%s) = %+v, want %s (tagged %v)", tt.rev, cv, tt.want, tt.tagged)
+		}
+	}
+
+	if _, err := f.ResolveCommit(repoURL, "no-such-branch", ""); err == nil {
+		t.Error("ResolveCommit of an unknown rev should fail")
+	}
+}
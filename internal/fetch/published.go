@@ -0,0 +1,34 @@
+package fetch
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/mod/module"
+)
+
+// PublishTime returns when a module version was published. Pseudo-versions
+// carry their commit time; other versions use the Time field of the proxy's
+// .info metadata, falling back to go list for private modules.
+func (f *Fetcher) PublishTime(modulePath, version string) (time.Time, error) {
+	if module.IsPseudoVersion(version) {
+		return module.PseudoVersionTime(version)
+	}
+
+	var info *ModuleInfo
+	if !f.isPrivate(modulePath) {
+		info, _ = f.getModuleInfo(modulePath, version)
+	}
+	if info == nil || info.Time == "" {
+		info, _ = f.getModuleInfoFromGoList(modulePath, version)
+	}
+	if info == nil || info.Time == "" {
+		return time.Time{}, fmt.Errorf("no publish time available for %s@%s", modulePath, version)
+	}
+
+	t, err := time.Parse(time.RFC3339, info.Time)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing publish time for %s@%s: %w", modulePath, version, err)
+	}
+	return t, nil
+}
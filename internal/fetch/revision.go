@@ -0,0 +1,76 @@
+package fetch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// fullCommit matches a complete git commit hash.
+var fullCommit = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// ResolveRev resolves a VCS revision of modulePath, such as a commit hash or
+// branch name, to its canonical module version: the tagged version when the
+// commit is tagged, otherwise its pseudo-version. Public modules are
+// resolved through the proxy's .info endpoint; private modules, or any when
+// no proxy is configured or GOPROXY falls back to direct, with go list.
+// Lookups of full commit hashes are cached; other revisions move, so they
+// are always resolved again.
+func (f *Fetcher) ResolveRev(modulePath, rev string) (*ModuleInfo, error) {
+	var info *ModuleInfo
+	direct := f.isPrivate(modulePath) || f.proxyBase(modulePath) == ""
+	if !direct {
+		var ttl time.Duration
+		if !fullCommit.MatchString(rev) {
+			ttl = -1
+		}
+		body, err := f.proxyMeta(modulePath, "@v/"+escapeVersion(rev)+".info", ttl)
+		switch {
+		case errors.Is(err, errProxyDirect):
+			direct = true
+		case err != nil:
+			return nil, fmt.Errorf("resolving %s@%s: %w", modulePath, rev, err)
+		default:
+			info = &ModuleInfo{}
+			if err := json.Unmarshal(body, info); err != nil {
+				return nil, fmt.Errorf("decoding %s@%s: %w", modulePath, rev, err)
+			}
+		}
+	}
+	if direct {
+		out, err := f.goListModule(modulePath + "@" + rev)
+		if err != nil {
+			return nil, err
+		}
+		info = &ModuleInfo{Version: out.Version, Time: out.Time}
+	}
+
+	if !semver.IsValid(info.Version) {
+		return nil, fmt.Errorf("resolving %s@%s: invalid version %q", modulePath, rev, info.Version)
+	}
+	if module.IsPseudoVersion(info.Version) && isHex(rev) {
+		short, err := module.PseudoVersionRev(info.Version)
+		if err != nil || !(strings.HasPrefix(rev, short) || strings.HasPrefix(short, rev)) {
+			return nil, fmt.Errorf("resolving %s@%s: proxy returned %s for a different commit", modulePath, rev, info.Version)
+		}
+	}
+	return info, nil
+}
+
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
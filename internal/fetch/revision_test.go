@@ -0,0 +1,55 @@
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResolveRev(t *testing.T) {
+	const commit = "0123456789abcdef0123456789abcdef01234567"
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/@v/"+commit+".info"), strings.HasSuffix(r.URL.Path, "/@v/0123456.info"):
+			w.Write([]byte(`{"Version":"v1.2.4-0.20240102030405-0123456789ab","Time":"2024-01-02T03:04:05Z"}`))
+		case strings.HasSuffix(r.URL.Path, "/@v/fedcba9.info"):
+			w.Write([]byte(`{"Version":"v1.2.4-0.20240102030405-0123456789ab"}`))
+		case strings.HasSuffix(r.URL.Path, "/@v/main.info"):
+			w.Write([]byte(`{"Version":"v1.3.0"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	f := &Fetcher{Proxy: srv.URL, CacheDir: t.TempDir()}
+
+	for i := 0; i < 2; i++ {
+		info, err := f.ResolveRev("example.com/mod", commit)
+		if err != nil {
+			t.Fatalf("ResolveRev() error = %v", err)
+		}
+		if info.Version != "v1.2.4-0.20240102030405-0123456789ab" {
+			t.Errorf("ResolveRev() = %q", info.Version)
+		}
+	}
+	if len(requests) != 1 {
+		t.Errorf("requests = %v, want full commit lookups cached", requests)
+	}
+
+	if _, err := f.ResolveRev("example.com/mod", "0123456"); err != nil {
+		t.Errorf("ResolveRev(short) error = %v", err)
+	}
+	if _, err := f.ResolveRev("example.com/mod", "fedcba9"); err == nil {
+		t.Error("ResolveRev() should reject a pseudo-version for a different commit")
+	}
+	if info, err := f.ResolveRev("example.com/mod", "main"); err != nil || info.Version != "v1.3.0" {
+		t.Errorf("ResolveRev(main) = %+v, %v", info, err)
+	}
+	if _, err := f.ResolveRev("example.com/mod", "deadbeef"); err == nil {
+		t.Error("ResolveRev() for unknown commit should fail")
+	}
+}
@@ -0,0 +1,80 @@
+package fetch
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Signer adds authentication to an outgoing request, for proxies that need
+// more than netrc basic auth (for example AWS SigV4 or a GCP IAP token).
+type Signer interface {
+	Sign(req *http.Request) error
+}
+
+// SignerFunc adapts a function to the Signer interface.
+type SignerFunc func(req *http.Request) error
+
+// Sign calls fn(req).
+func (fn SignerFunc) Sign(req *http.Request) error {
+	return fn(req)
+}
+
+// transport returns the round tripper for fetcher requests: requests to a
+// host in Signers are signed before they are sent, and GitHub API requests
+// are authenticated as GitHubApp unless Signers covers the API host.
+func (f *Fetcher) transport() http.RoundTripper {
+	if len(f.Signers) == 0 && f.GitHubApp == nil {
+		return f.baseTransport()
+	}
+	signers := make(map[string]Signer, len(f.Signers)+1)
+	for host, signer := range f.Signers {
+		signers[normalizeHost(host)] = signer
+	}
+	if app := f.githubApp(); app != nil {
+		host := "api.github.com"
+		if u, err := url.Parse(app.APIURL); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		if _, ok := lookupSigner(signers, host); !ok {
+			signers[normalizeHost(host)] = app
+		}
+	}
+	return &signingTransport{base: f.baseTransport(), signers: signers}
+}
+
+// signingTransport signs requests by host. Keys may include a port; an entry
+// without one matches any port. Hosts are compared in normalized form (see
+// normalizeHost), so keys may use any case or Unicode domain names.
+type signingTransport struct {
+	base    http.RoundTripper
+	signers map[string]Signer
+}
+
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	signer, ok := lookupSigner(t.signers, req.URL.Host)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the caller's request.
+	req = req.Clone(req.Context())
+	if err := signer.Sign(req); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// lookupSigner returns the signer for host from signers, keyed by
+// normalized host with or without a port.
+func lookupSigner(signers map[string]Signer, host string) (Signer, bool) {
+	host = normalizeHost(host)
+	if signer, ok := signers[host]; ok {
+		return signer, true
+	}
+	name, _ := splitHostPort(host)
+	signer, ok := signers[normalizeHost(name)]
+	return signer, ok
+}
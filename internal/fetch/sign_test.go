@@ -0,0 +1,165 @@
+package fetch
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testAWSCredentials = AWSCredentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+// Vectors from the AWS SigV4 test suite (get-vanilla, get-vanilla-query-order-key-case).
+func TestSignV4(t *testing.T) {
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{
+			url:  "https://example.amazonaws.com/",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			url:  "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("GET", tt.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := signV4(req, testAWSCredentials, "us-east-1", "service", now); err != nil {
+			t.Fatalf("signV4(%s) error = %v", tt.url, err)
+		}
+		if got := req.Header.Get("Authorization"); got != tt.want {
+			t.Errorf("signV4(%s) Authorization =\n  %s\nwant\n  %s", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestSigV4SignerAssumeRole(t *testing.T) {
+	calls := 0
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Query().Get("Action") != "AssumeRole" || r.URL.Query().Get("RoleArn") != "arn:aws:iam::123456789012:role/goproxy" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential=AKIDEXAMPLE/") {
+			http.Error(w, "unsigned", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `<AssumeRoleResponse><AssumeRoleResult><Credentials>
+<AccessKeyId>ASIAROLE</AccessKeyId><SecretAccessKey>secret</SecretAccessKey>
+<SessionToken>session</SessionToken><Expiration>2030-01-01T00:00:00Z</Expiration>
+</Credentials></AssumeRoleResult></AssumeRoleResponse>`)
+	}))
+	defer sts.Close()
+
+	s := &SigV4Signer{
+		Region:      "us-east-1",
+		RoleARN:     "arn:aws:iam::123456789012:role/goproxy",
+		STSEndpoint: sts.URL,
+		Credentials: func() (AWSCredentials, error) { return testAWSCredentials, nil },
+	}
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "https://proxy.example.com/github.com/foo/bar/@v/v1.0.0.zip", nil)
+		if err := s.Sign(req); err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		if !strings.Contains(req.Header.Get("Authorization"), "Credential=ASIAROLE/") || !strings.Contains(req.Header.Get("Authorization"), "/us-east-1/execute-api/") {
+			t.Errorf("Authorization = %q, want role credentials for execute-api", req.Header.Get("Authorization"))
+		}
+		if req.Header.Get("X-Amz-Security-Token") != "session" {
+			t.Errorf("X-Amz-Security-Token = %q, want session", req.Header.Get("X-Amz-Security-Token"))
+		}
+	}
+	if calls != 1 {
+		t.Errorf("STS called %d times, want role credentials cached", calls)
+	}
+}
+
+func TestIAPSigner(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp)))
+	token := "header." + payload + ".sig"
+
+	calls := 0
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Metadata-Flavor") != "Google" || r.URL.Query().Get("audience") != "client-id" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, token)
+	}))
+	defer metadata.Close()
+
+	s := &IAPSigner{Audience: "client-id", MetadataURL: metadata.URL}
+
+	req, _ := http.NewRequest("GET", "https://proxy.example.com/", nil)
+	if err := s.Sign(req); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer "+token {
+		t.Errorf("Authorization = %q", got)
+	}
+
+	req, _ = http.NewRequest("GET", "https://proxy.example.com/", nil)
+	req.SetBasicAuth("user", "pass")
+	if err := s.Sign(req); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if got := req.Header.Get("Proxy-Authorization"); got != "Bearer "+token {
+		t.Errorf("Proxy-Authorization = %q, want token alongside basic auth", got)
+	}
+	if calls != 1 {
+		t.Errorf("metadata server called %d times, want token cached", calls)
+	}
+}
+
+func TestSigningTransport(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("X-Signed"))
+	}))
+	defer srv.Close()
+
+	f := &Fetcher{Signers: map[string]Signer{
+		"127.0.0.1": SignerFunc(func(req *http.Request) error {
+			req.Header.Set("X-Signed", "yes")
+			return nil
+		}),
+	}}
+	client := http.Client{Transport: f.transport()}
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if req.Header.Get("X-Signed") != "" {
+		t.Error("signing modified the caller's request")
+	}
+
+	f.Signers = map[string]Signer{"other.example.com": f.Signers["127.0.0.1"]}
+	client.Transport = f.transport()
+	resp, err = client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(got) != 2 || got[0] != "yes" || got[1] != "" {
+		t.Errorf("X-Signed per request = %q, want [yes \"\"]", got)
+	}
+}
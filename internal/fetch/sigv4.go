@@ -0,0 +1,356 @@
+package fetch
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AWSCredentials are the keys used to sign AWS requests.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expires         time.Time // Zero for long-lived keys
+}
+
+// SigV4Signer signs requests with AWS Signature Version 4, for proxies behind
+// API Gateway, a Lambda function URL, or S3. Base credentials come from the
+// AWS_ACCESS_KEY_ID environment variables or the shared credentials file;
+// when RoleARN is set they are exchanged for temporary role credentials with
+// STS AssumeRole.
+type SigV4Signer struct {
+	Region  string
+	Service string // Defaults to "execute-api"
+	RoleARN string
+
+	// STSEndpoint overrides https://sts.<region>.amazonaws.com.
+	STSEndpoint string
+	// Credentials overrides the environment and shared credentials file.
+	Credentials func() (AWSCredentials, error)
+	// Now overrides the signing clock.
+	Now func() time.Time
+
+	mu   sync.Mutex
+	role AWSCredentials
+}
+
+// Sign implements Signer.
+func (s *SigV4Signer) Sign(req *http.Request) error {
+	if s.Region == "" {
+		return fmt.Errorf("sigv4: region is required")
+	}
+	creds, err := s.credentials()
+	if err != nil {
+		return fmt.Errorf("sigv4: %w", err)
+	}
+	service := s.Service
+	if service == "" {
+		service = "execute-api"
+	}
+	return signV4(req, creds, s.Region, service, s.now())
+}
+
+func (s *SigV4Signer) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *SigV4Signer) credentials() (AWSCredentials, error) {
+	base := s.Credentials
+	if base == nil {
+		base = awsCredentialsFromEnv
+	}
+	if s.RoleARN == "" {
+		return base()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.role.AccessKeyID != "" && s.now().Add(time.Minute).Before(s.role.Expires) {
+		return s.role, nil
+	}
+	creds, err := base()
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	role, err := s.assumeRole(creds)
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	s.role = role
+	return role, nil
+}
+
+// assumeRole exchanges creds for temporary credentials for RoleARN.
+func (s *SigV4Signer) assumeRole(creds AWSCredentials) (AWSCredentials, error) {
+	endpoint := s.STSEndpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://sts.%s.amazonaws.com", s.Region)
+	}
+	q := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {"2011-06-15"},
+		"RoleArn":         {s.RoleARN},
+		"RoleSessionName": {fmt.Sprintf("nopher-%d", s.now().Unix())},
+		"DurationSeconds": {"3600"},
+	}
+	req, err := http.NewRequest("GET", strings.TrimSuffix(endpoint, "/")+"/?"+q.Encode(), nil)
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	if err := signV4(req, creds, s.Region, "sts", s.now()); err != nil {
+		return AWSCredentials{}, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return AWSCredentials{}, fmt.Errorf("assuming role %s: %w", s.RoleARN, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return AWSCredentials{}, fmt.Errorf("assuming role %s: %w", s.RoleARN, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return AWSCredentials{}, fmt.Errorf("assuming role %s: %s: %s", s.RoleARN, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var out struct {
+		Credentials struct {
+			AccessKeyID     string `xml:"AccessKeyId"`
+			SecretAccessKey string `xml:"SecretAccessKey"`
+			SessionToken    string `xml:"SessionToken"`
+			Expiration      string `xml:"Expiration"`
+		} `xml:"AssumeRoleResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &out); err != nil {
+		return AWSCredentials{}, fmt.Errorf("parsing AssumeRole response: %w", err)
+	}
+	c := out.Credentials
+	if c.AccessKeyID == "" {
+		return AWSCredentials{}, fmt.Errorf("assuming role %s: no credentials in response", s.RoleARN)
+	}
+	expires, err := time.Parse(time.RFC3339, c.Expiration)
+	if err != nil {
+		return AWSCredentials{}, fmt.Errorf("parsing AssumeRole expiration: %w", err)
+	}
+	return AWSCredentials{
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.SessionToken,
+		Expires:         expires,
+	}, nil
+}
+
+// awsCredentialsFromEnv reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and
+// AWS_SESSION_TOKEN, falling back to the AWS_PROFILE (or default) profile in
+// the shared credentials file.
+func awsCredentialsFromEnv() (AWSCredentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return AWSCredentials{
+			AccessKeyID:     id,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return AWSCredentials{}, errors.New("no AWS credentials: AWS_ACCESS_KEY_ID is not set")
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return AWSCredentials{}, errors.New("no AWS credentials: AWS_ACCESS_KEY_ID is not set and no shared credentials file")
+	}
+	defer f.Close()
+
+	var creds AWSCredentials
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			creds.SessionToken = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return AWSCredentials{}, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if creds.AccessKeyID == "" {
+		return AWSCredentials{}, fmt.Errorf("no AWS credentials for profile %q in %s", profile, path)
+	}
+	return creds, nil
+}
+
+// signV4 adds SigV4 headers to req. The body, if any, is read through
+// GetBody so it can still be sent.
+func signV4(req *http.Request, creds AWSCredentials, region, service string, now time.Time) error {
+	payload := sha256.New()
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return fmt.Errorf("sigv4: request body cannot be re-read for signing")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(payload, body)
+		body.Close()
+		if err != nil {
+			return err
+		}
+	}
+	payloadHash := hex.EncodeToString(payload.Sum(nil))
+
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	if service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.Join(values, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, strings.Join(strings.Fields(headers[name]), " "))
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// Every service except S3 signs the already-escaped path, escaped again.
+	canonicalURI := awsEscape(req.URL.EscapedPath(), false)
+	if service == "s3" {
+		canonicalURI = awsEscape(req.URL.Path, false)
+	}
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+func canonicalQuery(values url.Values) string {
+	pairs := make([]string, 0, len(values))
+	for key, vals := range values {
+		for _, v := range vals {
+			pairs = append(pairs, awsEscape(key, true)+"="+awsEscape(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape percent-encodes everything except RFC 3986 unreserved characters,
+// and '/' unless encodeSlash is set.
+func awsEscape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
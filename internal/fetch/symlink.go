@@ -0,0 +1,102 @@
+package fetch
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// SymlinkPolicy controls how extract handles symlink entries. Module zips
+// never contain symlinks, but GitHub repository archives can.
+type SymlinkPolicy string
+
+const (
+	// SymlinkSkip drops symlink entries, as the go command does when it
+	// builds a module zip from a repository. It is the default.
+	SymlinkSkip SymlinkPolicy = "skip"
+	// SymlinkReject fails extraction of any archive containing a symlink.
+	SymlinkReject SymlinkPolicy = "reject"
+	// SymlinkMaterialize replaces each symlink with a copy of the regular
+	// file it points to. Links that leave the extracted tree, dangle, or
+	// point at directories are errors.
+	SymlinkMaterialize SymlinkPolicy = "materialize"
+)
+
+// maxSymlinkHops bounds symlink chains followed when materializing.
+const maxSymlinkHops = 40
+
+// ParseSymlinkPolicy validates a policy name. Empty means SymlinkSkip.
+func ParseSymlinkPolicy(s string) (SymlinkPolicy, error) {
+	switch p := SymlinkPolicy(s); p {
+	case "":
+		return SymlinkSkip, nil
+	case SymlinkSkip, SymlinkReject, SymlinkMaterialize:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid symlink policy %q (want skip, reject, or materialize)", s)
+}
+
+func isSymlink(file *zip.File) bool {
+	return file.Mode()&fs.ModeSymlink != 0
+}
+
+// resolveSymlink follows the symlink entry at name through entries (keyed
+// by extracted path) to the regular file it points to.
+func resolveSymlink(entries map[string]*zip.File, name string) (*zip.File, error) {
+	current := name
+	for hops := 0; hops < maxSymlinkHops; hops++ {
+		file := entries[current]
+		if !isSymlink(file) {
+			return file, nil
+		}
+
+		target, err := readEntry(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading symlink %s: %w", current, err)
+		}
+		if path.IsAbs(target) {
+			return nil, fmt.Errorf("symlink %s points outside the module: %s", name, target)
+		}
+		next := path.Join(path.Dir(current), target)
+		if next == ".." || strings.HasPrefix(next, "../") {
+			return nil, fmt.Errorf("symlink %s points outside the module: %s", name, target)
+		}
+
+		switch f, ok := entries[next]; {
+		case ok && f.FileInfo().IsDir():
+			return nil, fmt.Errorf("symlink %s points to directory %s", name, next)
+		case ok:
+			current = next
+		case entries[next+"/"] != nil || hasEntryUnder(entries, next):
+			return nil, fmt.Errorf("symlink %s points to directory %s", name, next)
+		default:
+			return nil, fmt.Errorf("symlink %s is dangling: %s", name, target)
+		}
+	}
+	return nil, fmt.Errorf("symlink %s: too many levels of symbolic links", name)
+}
+
+func hasEntryUnder(entries map[string]*zip.File, dir string) bool {
+	for name := range entries {
+		if strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func readEntry(file *zip.File) (string, error) {
+	r, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
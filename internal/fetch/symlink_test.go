@@ -0,0 +1,141 @@
+package fetch
+
+import (
+	"archive/zip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSymlinkZip writes a repository archive with regular files and
+// symlinks (name -> target).
+func writeSymlinkZip(t *testing.T, path string, files, links map[string]string) {
+	t.Helper()
+	zf, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zf.Close()
+
+	zw := zip.NewWriter(zf)
+	add := func(name, content string, mode fs.FileMode) {
+		h := &zip.FileHeader{Name: name, Method: zip.Store}
+		h.SetMode(mode)
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		add(name, content, 0o644)
+	}
+	for name, target := range links {
+		add(name, target, fs.ModeSymlink|0o777)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "archive.zip")
+	writeSymlinkZip(t, zipPath, map[string]string{
+		"repo-1.0.0/go.mod":        "module github.com/owner/repo\n",
+		"repo-1.0.0/docs/guide.md": "guide\n",
+	}, map[string]string{
+		"repo-1.0.0/README.md":     "docs/guide.md",
+		"repo-1.0.0/pkg/README.md": "../README.md",
+	})
+
+	tests := []struct {
+		policy  SymlinkPolicy
+		want    map[string]string
+		wantErr string
+	}{
+		{policy: "", want: map[string]string{"go.mod": "module github.com/owner/repo\n", "docs/guide.md": "guide\n"}},
+		{policy: SymlinkReject, wantErr: "symlink policy is reject"},
+		{policy: SymlinkMaterialize, want: map[string]string{
+			"go.mod":        "module github.com/owner/repo\n",
+			"docs/guide.md": "guide\n",
+			"README.md":     "guide\n",
+			"pkg/README.md": "guide\n",
+		}},
+	}
+	for _, tt := range tests {
+		target := filepath.Join(dir, "out-"+string(tt.policy))
+		f := &Fetcher{Symlinks: tt.policy}
+		err := f.extract(zipPath, target, "github.com/owner/repo", "v1.0.0", "", 0o644)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q: extract() error = %v, want %q", tt.policy, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: extract() error = %v", tt.policy, err)
+		}
+
+		got := make(map[string]string)
+		filepath.WalkDir(target, func(path string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				if !d.Type().IsRegular() {
+					t.Errorf("%q: %s is not a regular file", tt.policy, path)
+				}
+				data, _ := os.ReadFile(path)
+				rel, _ := filepath.Rel(target, path)
+				got[filepath.ToSlash(rel)] = string(data)
+			}
+			return nil
+		})
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: extracted %v, want %v", tt.policy, got, tt.want)
+		}
+		for name, content := range tt.want {
+			if got[name] != content {
+				t.Errorf("%q: %s = %q, want %q", tt.policy, name, got[name], content)
+			}
+		}
+	}
+}
+
+func TestMaterializeSymlinkErrors(t *testing.T) {
+	tests := map[string]string{
+		"../outside":  "points outside the module",
+		"/etc/passwd": "points outside the module",
+		"missing.go":  "dangling",
+		"docs":        "points to directory",
+		"link":        "too many levels",
+	}
+	for target, wantErr := range tests {
+		dir := t.TempDir()
+		zipPath := filepath.Join(dir, "archive.zip")
+		writeSymlinkZip(t, zipPath, map[string]string{
+			"repo-1.0.0/go.mod":        "module github.com/owner/repo\n",
+			"repo-1.0.0/docs/guide.md": "guide\n",
+		}, map[string]string{"repo-1.0.0/link": target})
+
+		f := &Fetcher{Symlinks: SymlinkMaterialize}
+		err := f.extract(zipPath, filepath.Join(dir, "out"), "github.com/owner/repo", "v1.0.0", "", 0o644)
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("link -> %s: extract() error = %v, want %q", target, err, wantErr)
+		}
+	}
+}
+
+func TestParseSymlinkPolicy(t *testing.T) {
+	if p, err := ParseSymlinkPolicy(""); err != nil || p != SymlinkSkip {
+		t.Errorf("ParseSymlinkPolicy(\"\") = %q, %v", p, err)
+	}
+	if p, err := ParseSymlinkPolicy("materialize"); err != nil || p != SymlinkMaterialize {
+		t.Errorf("ParseSymlinkPolicy(materialize) = %q, %v", p, err)
+	}
+	if _, err := ParseSymlinkPolicy("follow"); err == nil {
+		t.Error("ParseSymlinkPolicy(follow) should fail")
+	}
+}
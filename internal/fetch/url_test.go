@@ -53,6 +53,37 @@ func TestGetDownloadURL(t *testing.T) {
 	}
 }
 
+func TestResolveDownloadURLGuessed(t *testing.T) {
+	tests := []struct {
+		name        string
+		proxy       string
+		modulePath  string
+		wantGuessed bool
+	}{
+		{"proxy is verified", "https://proxy.golang.org", "example.com/repo", false},
+		{"BSR is verified", "", "buf.build/gen/go/org/repo", false},
+		{"generic host is a guess", "", "example.com/repo", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Fetcher{Proxy: tt.proxy}
+			_, guessed := f.resolveDownloadURL(tt.modulePath, "v1.0.0")
+			if guessed != tt.wantGuessed {
+				t.Errorf("resolveDownloadURL() guessed = %v, want %v", guessed, tt.wantGuessed)
+			}
+		})
+	}
+}
+
+func TestStrictRejectsGuess(t *testing.T) {
+	f := &Fetcher{CacheDir: t.TempDir(), Strict: true}
+	_, err := f.Fetch("example.com/repo", "v1.0.0")
+	if err == nil || !contains(err.Error(), "strict mode") {
+		t.Errorf("Fetch() error = %v, want strict mode error", err)
+	}
+}
+
 func TestDirectURLWithOrigin(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -197,3 +228,46 @@ func TestArchiveToAPIURL(t *testing.T) {
 		})
 	}
 }
+
+func TestURLOverrides(t *testing.T) {
+	f := &Fetcher{
+		Proxy: "https://proxy.golang.org",
+		URLOverrides: map[string]string{
+			"git.corp.example.com/*":        "https://artifacts.corp/{module}/{version}.zip",
+			"git.corp.example.com/team/*":   "https://team.corp/{module}/{rev}.zip",
+			"git.corp.example.com/team/lib": "https://lib.corp/{version}.zip",
+		},
+	}
+
+	tests := []struct {
+		modulePath string
+		version    string
+		want       string
+	}{
+		{"git.corp.example.com/other", "v1.0.0", "https://artifacts.corp/git.corp.example.com/other/v1.0.0.zip"},
+		{"git.corp.example.com/team/svc", "v0.0.0-20231201120000-abcdef123456", "https://team.corp/git.corp.example.com/team/svc/abcdef123456.zip"},
+		{"git.corp.example.com/team/lib", "v2.0.0", "https://lib.corp/v2.0.0.zip"},
+		{"golang.org/x/mod", "v0.32.0", "https://proxy.golang.org/golang.org/x/mod/@v/v0.32.0.zip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.modulePath, func(t *testing.T) {
+			got, guessed := f.resolveDownloadURL(tt.modulePath, tt.version)
+			if got != tt.want {
+				t.Errorf("resolveDownloadURL() = %q, want %q", got, tt.want)
+			}
+			if guessed {
+				t.Error("override URL reported as guessed")
+			}
+		})
+	}
+}
+
+func TestMatchKeyTies(t *testing.T) {
+	m := map[string]string{"example.com/a*": "a", "example.com/*b": "b", "example.com": "short"}
+	for range 20 {
+		if key, ok := matchKey(m, "example.com/ab"); !ok || key != "example.com/*b" {
+			t.Fatalf("matchKey() = %q, %v; want the first of the equally long patterns", key, ok)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package fetch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// declaredModulePath returns the module path declared by the go.mod in dir,
+// or "" if the module has no go.mod (legacy modules) or it cannot be read.
+func declaredModulePath(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	return modfile.ModulePath(data)
+}
+
+// declaredReplaces returns the replace directives in the go.mod file at
+// path, as old => new with versions in path@version form, or nil if it has
+// none or cannot be read. The go command ignores replace directives outside
+// the main module, so a dependency that carries them is built against other
+// versions than its own go.mod asks for.
+func declaredReplaces(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	mf, err := modfile.Parse(path, data, nil)
+	if err != nil {
+		return nil
+	}
+	var replaces []string
+	for _, r := range mf.Replace {
+		replaces = append(replaces, r.Old.String()+" => "+r.New.String())
+	}
+	return replaces
+}
+
+// checkModFile verifies that the go.mod in an extracted module is consistent
+// with the version being fetched: a module at v2 or later must declare a
+// matching major version suffix, unless the version is +incompatible.
+func checkModFile(dir, version string) error {
+	declared := declaredModulePath(dir)
+	if declared == "" {
+		return nil
+	}
+
+	_, pathMajor, ok := module.SplitPathVersion(declared)
+	if !ok {
+		return fmt.Errorf("go.mod declares invalid module path %q", declared)
+	}
+	if err := module.CheckPathMajor(version, pathMajor); err != nil {
+		return fmt.Errorf("go.mod declares module path %s: %w", declared, err)
+	}
+	return nil
+}
+
+// CheckModulePath reports an error when a module's go.mod declared a path
+// other than one of want. An empty declared path (no go.mod) is accepted.
+// Replacements may declare either the original or the replacement path,
+// matching what the go command allows.
+func CheckModulePath(declared string, want ...string) error {
+	if declared == "" {
+		return nil
+	}
+	for _, w := range want {
+		if declared == w {
+			return nil
+		}
+	}
+	return fmt.Errorf("go.mod declares module path %s, expected %s (wrong subdirectory or repository?)",
+		declared, strings.Join(want, " or "))
+}
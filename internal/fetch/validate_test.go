@@ -0,0 +1,82 @@
+package fetch
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckModFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		gomod   string
+		version string
+		wantErr bool
+	}{
+		{"no go.mod", "", "v1.0.0", false},
+		{"v1", "module example.com/foo\n", "v1.2.3", false},
+		{"v2 with suffix", "module example.com/foo/v2\n", "v2.0.1", false},
+		{"v2 without suffix", "module example.com/foo\n", "v2.0.1", true},
+		{"incompatible", "module example.com/foo\n", "v2.0.1+incompatible", false},
+		{"suffix on v1", "module example.com/foo/v3\n", "v1.0.0", true},
+		{"gopkg.in", "module gopkg.in/yaml.v3\n", "v3.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.gomod != "" {
+				if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(tt.gomod), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := checkModFile(dir, tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkModFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckModulePath(t *testing.T) {
+	if err := CheckModulePath("", "example.com/a"); err != nil {
+		t.Errorf("empty declared path: %v", err)
+	}
+	if err := CheckModulePath("example.com/a", "example.com/a"); err != nil {
+		t.Errorf("matching path: %v", err)
+	}
+	if err := CheckModulePath("example.com/a", "example.com/b", "example.com/a"); err != nil {
+		t.Errorf("replacement declaring original path: %v", err)
+	}
+	if err := CheckModulePath("example.com/a/sub", "example.com/a"); err == nil {
+		t.Error("expected error for mismatched path")
+	}
+}
+
+func TestDeclaredReplaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go.mod")
+	gomod := `module example.com/app
+
+require example.com/dep v1.0.0
+
+replace (
+	example.com/dep => github.com/fork/dep v1.0.1
+	example.com/tool v0.3.0 => ../tool
+)
+`
+	if err := os.WriteFile(path, []byte(gomod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"example.com/dep => github.com/fork/dep@v1.0.1",
+		"example.com/tool@v0.3.0 => ../tool",
+	}
+	if got := declaredReplaces(path); !reflect.DeepEqual(got, want) {
+		t.Errorf("declaredReplaces() = %q, want %q", got, want)
+	}
+
+	if got := declaredReplaces(filepath.Join(t.TempDir(), "go.mod")); got != nil {
+		t.Errorf("declaredReplaces() without go.mod = %q, want nil", got)
+	}
+}
@@ -0,0 +1,101 @@
+package fetch
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetchVerifiedFromSource(t *testing.T) {
+	source := t.TempDir()
+	zipDir := filepath.Join(source, "example.com", "!foo", "@v")
+	if err := os.MkdirAll(zipDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	zipPath := filepath.Join(zipDir, "v1.0.0.zip")
+	writeTestZip(t, zipPath, map[string]string{
+		"example.com/Foo@v1.0.0/go.mod": "module example.com/Foo\n",
+		"example.com/Foo@v1.0.0/foo.go": "package foo\n",
+	})
+	hash, err := computeZipHash(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &Fetcher{}
+	dest := filepath.Join(t.TempDir(), "example.com", "Foo")
+	m := Locked{Path: "example.com/Foo", Version: "v1.0.0", Hash: hash}
+	if err := f.FetchVerified(m, source, dest); err != nil {
+		t.Fatalf("FetchVerified() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "foo.go")); err != nil {
+		t.Errorf("expected extracted foo.go: %v", err)
+	}
+
+	m.Hash = "sha256-AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+	err = f.FetchVerified(m, source, dest)
+	var hm *HashMismatchError
+	if !errors.As(err, &hm) {
+		t.Fatalf("FetchVerified() error = %v, want HashMismatchError", err)
+	}
+	if hm.Got != hash {
+		t.Errorf("HashMismatchError.Got = %s, want %s", hm.Got, hash)
+	}
+
+	m.Version = "v2.0.0"
+	if err := f.FetchVerified(m, source, dest); err == nil {
+		t.Error("expected error for module missing from source")
+	}
+}
+
+func TestFetchVerifiedFallsBack(t *testing.T) {
+	zipPath := filepath.Join(t.TempDir(), "v1.0.0.zip")
+	writeTestZip(t, zipPath, map[string]string{
+		"example.com/mod@v1.0.0/go.mod": "module example.com/mod\n",
+	})
+	hash, err := computeZipHash(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/mirror/v1.0.0.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, zipPath)
+	}))
+	defer srv.Close()
+
+	f := &Fetcher{}
+	m := Locked{
+		Path:    "example.com/mod",
+		Version: "v1.0.0",
+		Hash:    hash,
+		URL:     srv.URL + "/gone/v1.0.0.zip",
+		URLs:    []string{srv.URL + "/gone/v1.0.0.zip", srv.URL + "/mirror/v1.0.0.zip"},
+	}
+	dest := filepath.Join(t.TempDir(), "mod")
+	if err := f.FetchVerified(m, "", dest); err != nil {
+		t.Fatalf("FetchVerified() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "go.mod")); err != nil {
+		t.Errorf("expected extracted go.mod: %v", err)
+	}
+
+	zip, cleanup, err := f.VerifiedZip(m, "")
+	if err != nil {
+		t.Fatalf("VerifiedZip() error = %v", err)
+	}
+	cleanup()
+	if zip == "" {
+		t.Error("VerifiedZip() returned no path")
+	}
+
+	m.URLs = nil
+	if err := f.FetchVerified(m, "", dest); err == nil {
+		t.Error("FetchVerified() succeeded without a working source")
+	}
+}
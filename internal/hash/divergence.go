@@ -0,0 +1,79 @@
+package hash
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// caseHackSuffix marks entry names renamed by nix's case hack, which nix
+// strips when serializing on macOS (use-case-hack is on by default there).
+const caseHackSuffix = "~nix~case~hack~"
+
+// Divergence is a filesystem structure that the pure-Go NAR serializer is
+// known to hash differently from nix hash path.
+type Divergence struct {
+	Path   string // Relative to the hashed root
+	Reason string
+}
+
+func (d Divergence) String() string {
+	return d.Path + ": " + d.Reason
+}
+
+// DivergenceError is returned by ComputeNARHash when nix is unavailable and
+// the tree contains structures the Go fallback would hash differently.
+type DivergenceError struct {
+	Divergences []Divergence
+}
+
+func (e *DivergenceError) Error() string {
+	msg := fmt.Sprintf("pure-Go NAR hash would diverge from nix: %s", e.Divergences[0])
+	if n := len(e.Divergences) - 1; n > 0 {
+		msg += fmt.Sprintf(" (and %d more)", n)
+	}
+	return msg + "; install nix to hash this tree"
+}
+
+// FindDivergences reports the entries under root that GoNARHash is known to
+// serialize differently from nix:
+//   - regular files executable by group or others but not their owner,
+//     which nix treats as non-executable
+//   - on macOS, entry names carrying nix's case-hack suffix
+//   - file types NAR cannot represent (sockets, devices, named pipes)
+func FindDivergences(root string) ([]Divergence, error) {
+	var divergences []Divergence
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+
+		if runtime.GOOS == "darwin" && strings.Contains(d.Name(), caseHackSuffix) {
+			divergences = append(divergences, Divergence{rel, "name contains nix's case-hack suffix"})
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mode := info.Mode()
+		switch {
+		case mode.IsRegular():
+			if mode&0o100 == 0 && mode&0o011 != 0 {
+				divergences = append(divergences, Divergence{rel, fmt.Sprintf("mode %04o is executable but not by its owner", mode.Perm())})
+			}
+		case mode.IsDir(), mode&fs.ModeSymlink != 0:
+		default:
+			divergences = append(divergences, Divergence{rel, "unsupported file type " + mode.Type().String()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return divergences, nil
+}
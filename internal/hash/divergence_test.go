@@ -0,0 +1,105 @@
+package hash
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindDivergences(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]os.FileMode{
+		"plain.go":       0o644,
+		"run.sh":         0o755,
+		"sub/group-exec": 0o654,
+	}
+	for name, mode := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("plain.go", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	divergences, err := FindDivergences(dir)
+	if err != nil {
+		t.Fatalf("FindDivergences() error = %v", err)
+	}
+	if len(divergences) != 1 || divergences[0].Path != "sub/group-exec" {
+		t.Fatalf("FindDivergences() = %v, want only sub/group-exec", divergences)
+	}
+
+	// Without nix, ComputeNARHash must refuse rather than emit the Go hash.
+	t.Setenv("PATH", "")
+	_, err = ComputeNARHash(dir)
+	var de *DivergenceError
+	if !errors.As(err, &de) || len(de.Divergences) != 1 {
+		t.Errorf("ComputeNARHash() error = %v, want DivergenceError", err)
+	}
+
+	if err := os.Chmod(filepath.Join(dir, "sub/group-exec"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ComputeNARHash(dir); err != nil {
+		t.Errorf("ComputeNARHash() on a clean tree error = %v", err)
+	}
+	if _, err := NixNARHash(dir); !errors.Is(err, ErrNixUnavailable) {
+		t.Errorf("NixNARHash() error = %v, want ErrNixUnavailable", err)
+	}
+}
+
+// FuzzGoNARHashMatchesNix compares GoNARHash with nix hash path on generated
+// trees. It is skipped when nix is not installed.
+func FuzzGoNARHashMatchesNix(f *testing.F) {
+	if _, err := NixNARHash(f.TempDir()); err != nil {
+		f.Skipf("nix unavailable: %v", err)
+	}
+	f.Add([]byte("package main\n"), uint8(0), "main.go")
+	f.Add([]byte{}, uint8(1), "empty")
+	f.Add([]byte("#!/bin/sh\n"), uint8(2), "run.sh")
+	f.Add([]byte("x"), uint8(3), "link")
+
+	f.Fuzz(func(t *testing.T, content []byte, kind uint8, name string) {
+		if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+			t.Skip()
+		}
+		dir := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(dir, "nested", "empty"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "nested", name)
+		var err error
+		switch kind % 4 {
+		case 0, 1:
+			err = os.WriteFile(path, content, 0o644)
+		case 2:
+			err = os.WriteFile(path, content, 0o755)
+		case 3:
+			err = os.Symlink(string(content)+"-target", path)
+		}
+		if err != nil {
+			t.Skip()
+		}
+
+		want, err := NixNARHash(dir)
+		if err != nil {
+			t.Skip()
+		}
+		got, err := GoNARHash(dir)
+		if err != nil {
+			t.Fatalf("GoNARHash() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("GoNARHash() = %s, nix = %s (kind %d, name %q)", got, want, kind%4, name)
+		}
+	})
+}
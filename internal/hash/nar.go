@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -14,34 +15,54 @@ import (
 	"strings"
 )
 
+// ErrNixUnavailable is returned by NixNARHash when the nix command is not
+// installed.
+var ErrNixUnavailable = errors.New("nix command not available")
+
 // ComputeNARHash computes the Nix NAR hash of a directory.
 // It first tries to use the nix command if available, otherwise falls back
-// to a pure Go implementation.
+// to a pure Go implementation. The fallback refuses trees containing
+// structures it is known to hash differently from nix (see FindDivergences)
+// and returns a *DivergenceError instead.
 func ComputeNARHash(path string) (string, error) {
 	// Try using nix hash path first (most accurate)
-	if hash, err := computeWithNix(path); err == nil {
+	if hash, err := NixNARHash(path); err == nil {
 		return hash, nil
 	}
 
+	divergences, err := FindDivergences(path)
+	if err != nil {
+		return "", fmt.Errorf("computing NAR: %w", err)
+	}
+	if len(divergences) > 0 {
+		return "", &DivergenceError{Divergences: divergences}
+	}
+
 	// Fall back to pure Go NAR implementation
-	return computeNARHashGo(path)
+	return GoNARHash(path)
 }
 
-// computeWithNix uses the nix command to compute the hash.
-func computeWithNix(path string) (string, error) {
+// NixNARHash computes the NAR hash of path with nix hash path.
+func NixNARHash(path string) (string, error) {
+	if _, err := exec.LookPath("nix"); err != nil {
+		return "", ErrNixUnavailable
+	}
 	cmd := exec.Command("nix", "hash", "path", "--sri", path)
 	out, err := cmd.Output()
 	if err != nil {
-		return "", err
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("nix hash path: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("nix hash path: %w", err)
 	}
 	return strings.TrimSpace(string(out)), nil
 }
 
-// computeNARHashGo computes a NAR hash using pure Go.
+// GoNARHash computes a NAR hash using pure Go.
 // NAR (Nix Archive) format is a deterministic archive format.
-func computeNARHashGo(path string) (string, error) {
+func GoNARHash(path string) (string, error) {
 	h := sha256.New()
-	if err := writeNAR(h, path); err != nil {
+	if err := WriteNAR(h, path); err != nil {
 		return "", fmt.Errorf("computing NAR: %w", err)
 	}
 
@@ -50,19 +71,16 @@ func computeNARHashGo(path string) (string, error) {
 	return "sha256-" + base64.StdEncoding.EncodeToString(hash), nil
 }
 
-// writeNAR writes the NAR representation of path to w.
+// WriteNAR writes the NAR representation of path to w.
 // NAR format specification: https://nixos.org/manual/nix/stable/protocols/nix-archive-format.html
-func writeNAR(w io.Writer, path string) error {
+func WriteNAR(w io.Writer, path string) error {
 	info, err := os.Lstat(path)
 	if err != nil {
 		return err
 	}
 
 	// Write NAR header
-	if _, err := w.Write([]byte("nix-archive-1")); err != nil {
-		return err
-	}
-	if err := writeString(w, ""); err != nil { // padding
+	if err := writeString(w, "nix-archive-1"); err != nil {
 		return err
 	}
 
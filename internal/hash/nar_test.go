@@ -0,0 +1,29 @@
+package hash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func BenchmarkGoNARHash(b *testing.B) {
+	dir := b.TempDir()
+	for i := 0; i < 500; i++ {
+		sub := filepath.Join(dir, fmt.Sprintf("pkg%03d", i))
+		if err := os.MkdirAll(sub, 0o755); err != nil {
+			b.Fatal(err)
+		}
+		content := []byte(fmt.Sprintf("package pkg%03d\n\nconst N = %d\n", i, i))
+		if err := os.WriteFile(filepath.Join(sub, "file.go"), content, 0o644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := GoNARHash(dir); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
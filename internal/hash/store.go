@@ -0,0 +1,55 @@
+package hash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// DefaultStoreDir is the standard Nix store location.
+const DefaultStoreDir = "/nix/store"
+
+const nixBase32Chars = "0123456789abcdfghijklmnpqrsvwxyz"
+
+// NixBase32 encodes b in Nix's base32 variant, as used in store paths and
+// narinfo hashes.
+func NixBase32(b []byte) string {
+	n := (len(b)*8-1)/5 + 1
+	out := make([]byte, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		bit := i * 5
+		j, k := bit/8, uint(bit%8)
+		c := b[j] >> k
+		if j+1 < len(b) {
+			c |= b[j+1] << (8 - k)
+		}
+		out = append(out, nixBase32Chars[c&0x1f])
+	}
+	return string(out)
+}
+
+// FixedOutputPath returns the store path of a flat (non-recursive) sha256
+// fixed-output derivation output, such as a fetchurl download.
+func FixedOutputPath(storeDir, name string, flatSHA256 []byte) string {
+	inner := sha256.Sum256([]byte("fixed:out:sha256:" + hex.EncodeToString(flatSHA256) + ":"))
+	return makeStorePath(storeDir, "output:out", inner[:], name)
+}
+
+// RecursiveOutputPath returns the store path of a recursive sha256
+// fixed-output derivation output, such as a fetchzip download, from the
+// sha256 of its NAR serialization.
+func RecursiveOutputPath(storeDir, name string, narSHA256 []byte) string {
+	return makeStorePath(storeDir, "source", narSHA256, name)
+}
+
+// makeStorePath mirrors Nix's makeStorePath: the path hash is a 160-bit
+// compression of sha256 over the type, inner hash, store dir and name.
+func makeStorePath(storeDir, typ string, inner []byte, name string) string {
+	s := typ + ":sha256:" + hex.EncodeToString(inner) + ":" + storeDir + ":" + name
+	digest := sha256.Sum256([]byte(s))
+
+	var compressed [20]byte
+	for i, b := range digest {
+		compressed[i%20] ^= b
+	}
+	return storeDir + "/" + NixBase32(compressed[:]) + "-" + name
+}
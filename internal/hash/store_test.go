@@ -0,0 +1,72 @@
+package hash
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNixBase32(t *testing.T) {
+	empty := sha256.Sum256(nil)
+	if got, want := NixBase32(empty[:]), "0mdqa9w1p6cmli6976v4wi0sw9r4p5prkj7lzfd1877wk11c9c73"; got != want {
+		t.Errorf("NixBase32(sha256(\"\")) = %s, want %s", got, want)
+	}
+}
+
+func TestFixedOutputPath(t *testing.T) {
+	sum := sha256.Sum256([]byte("hello\n"))
+	got := FixedOutputPath(DefaultStoreDir, "hello.txt", sum[:])
+
+	if len(got) != len(DefaultStoreDir)+1+32+1+len("hello.txt") {
+		t.Fatalf("unexpected store path shape: %s", got)
+	}
+	if got != FixedOutputPath(DefaultStoreDir, "hello.txt", sum[:]) {
+		t.Error("FixedOutputPath is not deterministic")
+	}
+	if got == FixedOutputPath(DefaultStoreDir, "other.txt", sum[:]) {
+		t.Error("store path should depend on the name")
+	}
+}
+
+func TestRecursiveOutputPath(t *testing.T) {
+	sum := sha256.Sum256([]byte("nar"))
+	got := RecursiveOutputPath(DefaultStoreDir, "source", sum[:])
+
+	if len(got) != len(DefaultStoreDir)+1+32+1+len("source") {
+		t.Fatalf("unexpected store path shape: %s", got)
+	}
+	if got == FixedOutputPath(DefaultStoreDir, "source", sum[:]) {
+		t.Error("recursive and flat outputs with the same hash should have different store paths")
+	}
+}
+
+func TestWriteNARRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f")
+	if err := os.WriteFile(path, []byte("hi"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteNAR(&buf, path); err != nil {
+		t.Fatal(err)
+	}
+
+	str := func(s string) []byte {
+		b := make([]byte, 8, 8+len(s)+8)
+		b[0] = byte(len(s))
+		b = append(b, s...)
+		for len(b)%8 != 0 {
+			b = append(b, 0)
+		}
+		return b
+	}
+	var want []byte
+	for _, s := range []string{"nix-archive-1", "(", "type", "regular", "contents", "hi", ")"} {
+		want = append(want, str(s)...)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("WriteNAR() =\n%q\nwant\n%q", buf.Bytes(), want)
+	}
+}
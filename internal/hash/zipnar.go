@@ -0,0 +1,227 @@
+package hash
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// zipNode is a node of the virtual tree built from zip entries. Directories
+// have a nil or directory file and non-nil children.
+type zipNode struct {
+	file     *zip.File
+	children map[string]*zipNode
+}
+
+func (n *zipNode) isDir() bool {
+	return n.children != nil
+}
+
+// ZipNARHash computes the NAR hash of the tree stored in a zip file under
+// prefix (e.g. "example.com/mod@v1.0.0/"), without extracting it. See
+// WriteZipNAR.
+//
+// The fetcher only uses it to hash repository archives locked as NARs; it
+// still extracts every module into its cache, which later stages read.
+func ZipNARHash(zipPath, prefix string) (string, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return "", fmt.Errorf("opening zip: %w", err)
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	if err := WriteZipNAR(h, &r.Reader, prefix); err != nil {
+		return "", fmt.Errorf("computing NAR: %w", err)
+	}
+	return ToSRI(h.Sum(nil)), nil
+}
+
+// WriteZipNAR writes the NAR representation of the entries of r under
+// prefix to w, as if they had been extracted to a directory and serialized
+// with WriteNAR. Entries outside prefix are ignored. The tree is built in
+// memory from entry names, so the result does not depend on filesystem
+// quirks such as case folding, Unicode normalization, or the umask: files
+// are executable when their owner execute bit is set, and symlink entries
+// are serialized as symlinks.
+func WriteZipNAR(w io.Writer, r *zip.Reader, prefix string) error {
+	root, err := zipTree(r, prefix)
+	if err != nil {
+		return err
+	}
+	if err := writeString(w, "nix-archive-1"); err != nil {
+		return err
+	}
+	return writeZipNode(w, root)
+}
+
+// ArchiveRoot returns the top-level directory of a repository archive, such
+// as "repo-v1.2.3/", which every entry must share. Passed to WriteZipNAR, it
+// serializes the tree fetchzip unpacks from the archive.
+func ArchiveRoot(r *zip.Reader) (string, error) {
+	var root string
+	for _, f := range r.File {
+		dir, _, ok := strings.Cut(f.Name, "/")
+		if !ok || (root != "" && dir+"/" != root) {
+			return "", fmt.Errorf("archive has no single top-level directory (entry %q)", f.Name)
+		}
+		root = dir + "/"
+	}
+	if root == "" {
+		return "", fmt.Errorf("archive is empty")
+	}
+	return root, nil
+}
+
+// zipTree arranges the entries of r under prefix into a tree.
+func zipTree(r *zip.Reader, prefix string) (*zipNode, error) {
+	root := &zipNode{children: make(map[string]*zipNode)}
+	for _, f := range r.File {
+		name, ok := strings.CutPrefix(f.Name, prefix)
+		if !ok {
+			continue
+		}
+		isDir := strings.HasSuffix(name, "/") || f.FileInfo().IsDir()
+		name = strings.TrimSuffix(name, "/")
+		if name == "" {
+			continue
+		}
+
+		node := root
+		elems := strings.Split(name, "/")
+		for i, elem := range elems {
+			if elem == "" || elem == "." || elem == ".." {
+				return nil, fmt.Errorf("invalid entry name %q", f.Name)
+			}
+			if !node.isDir() {
+				return nil, fmt.Errorf("entry %q is inside a file", f.Name)
+			}
+			child, exists := node.children[elem]
+			last := i == len(elems)-1
+			switch {
+			case !exists:
+				child = &zipNode{}
+				if !last || isDir {
+					child.children = make(map[string]*zipNode)
+				}
+				node.children[elem] = child
+			case last && (isDir != child.isDir() || !isDir):
+				return nil, fmt.Errorf("duplicate entry %q", f.Name)
+			}
+			if last && !isDir {
+				child.file = f
+			}
+			node = child
+		}
+	}
+	return root, nil
+}
+
+func writeZipNode(w io.Writer, n *zipNode) error {
+	for _, s := range []string{"(", "type"} {
+		if err := writeString(w, s); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case n.isDir():
+		if err := writeString(w, "directory"); err != nil {
+			return err
+		}
+		names := make([]string, 0, len(n.children))
+		for name := range n.children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, s := range []string{"entry", "(", "name", name, "node"} {
+				if err := writeString(w, s); err != nil {
+					return err
+				}
+			}
+			if err := writeZipNode(w, n.children[name]); err != nil {
+				return err
+			}
+			if err := writeString(w, ")"); err != nil {
+				return err
+			}
+		}
+
+	case n.file.Mode()&fs.ModeSymlink != 0:
+		rc, err := n.file.Open()
+		if err != nil {
+			return err
+		}
+		target, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		for _, s := range []string{"symlink", "target", string(target)} {
+			if err := writeString(w, s); err != nil {
+				return err
+			}
+		}
+
+	case n.file.Mode().IsRegular():
+		if err := writeString(w, "regular"); err != nil {
+			return err
+		}
+		if n.file.Mode()&0o100 != 0 {
+			if err := writeString(w, "executable"); err != nil {
+				return err
+			}
+			if err := writeString(w, ""); err != nil {
+				return err
+			}
+		}
+		if err := writeString(w, "contents"); err != nil {
+			return err
+		}
+		if err := writeZipContents(w, n.file); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unsupported file type %s: %s", n.file.Mode().Type(), n.file.Name)
+	}
+
+	return writeString(w, ")")
+}
+
+// writeZipContents streams a zip entry's contents as a NAR byte string.
+func writeZipContents(w io.Writer, f *zip.File) error {
+	size := f.UncompressedSize64
+	lengthBytes := make([]byte, 8)
+	for i := 0; i < 8; i++ {
+		lengthBytes[i] = byte(size >> (i * 8))
+	}
+	if _, err := w.Write(lengthBytes); err != nil {
+		return err
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	n, err := io.Copy(w, rc)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", f.Name, err)
+	}
+	if uint64(n) != size {
+		return fmt.Errorf("reading %s: got %d bytes, want %d", f.Name, n, size)
+	}
+
+	if padding := (8 - size%8) % 8; padding > 0 {
+		if _, err := w.Write(make([]byte, padding)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
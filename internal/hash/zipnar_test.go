@@ -0,0 +1,134 @@
+package hash
+
+import (
+	"archive/zip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// zipDir writes the tree at dir to zipPath under prefix, keeping modes and
+// symlinks.
+func zipDir(t *testing.T, dir, zipPath, prefix string) {
+	t.Helper()
+	zf, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zf.Close()
+	zw := zip.NewWriter(zf)
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		h := &zip.FileHeader{Name: prefix + filepath.ToSlash(rel)}
+		if d.IsDir() {
+			h.Name += "/"
+		}
+		h.SetMode(info.Mode())
+		fw, err := zw.CreateHeader(h)
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			_, err = fw.Write([]byte(target))
+			return err
+		case info.Mode().IsRegular():
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			_, err = fw.Write(data)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestZipNARHashMatchesGoNARHash(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tree")
+	for name, content := range map[string]string{
+		"go.mod":          "module example.com/mod\n",
+		"pkg/a.go":        "package pkg\n",
+		"pkg/sub/b.go":    "package sub\n\n// padded to not be a multiple of 8\n",
+		"Upper/README.md": "",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("pkg/a.go", filepath.Join(dir, "link.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := GoNARHash(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zipPath := filepath.Join(t.TempDir(), "mod.zip")
+	zipDir(t, dir, zipPath, "example.com/mod@v1.0.0/")
+	got, err := ZipNARHash(zipPath, "example.com/mod@v1.0.0/")
+	if err != nil {
+		t.Fatalf("ZipNARHash() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("ZipNARHash() = %s, GoNARHash() = %s", got, want)
+	}
+}
+
+func TestZipNARHashInvalid(t *testing.T) {
+	tests := map[string][]string{
+		"duplicate entry": {"p/a.go", "p/a.go"},
+		"inside a file":   {"p/a.go", "p/a.go/b.go"},
+		"invalid entry":   {"p/../a.go"},
+	}
+	for wantErr, names := range tests {
+		zipPath := filepath.Join(t.TempDir(), "bad.zip")
+		zf, err := os.Create(zipPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zw := zip.NewWriter(zf)
+		for _, name := range names {
+			if _, err := zw.Create(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		zw.Close()
+		zf.Close()
+
+		if _, err := ZipNARHash(zipPath, "p/"); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("ZipNARHash(%q) error = %v, want %q", names, err, wantErr)
+		}
+	}
+}
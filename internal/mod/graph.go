@@ -0,0 +1,58 @@
+package mod
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// Graph maps a module (path or path@version) to the modules it requires.
+type Graph map[string][]string
+
+// ModGraph runs `go mod graph` in dir and parses its output.
+func ModGraph(dir string) (Graph, error) {
+	cmd := exec.Command("go", "mod", "graph")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("go mod graph: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("go mod graph: %w", err)
+	}
+	return ParseModGraph(strings.NewReader(string(out)))
+}
+
+// ParseModGraph parses `go mod graph` output. Each line is a space-separated
+// edge "from to". Requirement lists are sorted and deduplicated.
+func ParseModGraph(r io.Reader) (Graph, error) {
+	graph := make(Graph)
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue // Skip malformed lines
+		}
+		from, to := fields[0], fields[1]
+		if seen[from+" "+to] {
+			continue
+		}
+		seen[from+" "+to] = true
+		graph[from] = append(graph[from], to)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanning go mod graph: %w", err)
+	}
+
+	for _, deps := range graph {
+		sort.Strings(deps)
+	}
+
+	return graph, nil
+}
@@ -0,0 +1,33 @@
+package mod
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseModGraph(t *testing.T) {
+	input := `example.com/main golang.org/x/mod@v0.32.0
+example.com/main github.com/spf13/cobra@v1.10.2
+github.com/spf13/cobra@v1.10.2 github.com/spf13/pflag@v1.0.9
+github.com/spf13/cobra@v1.10.2 github.com/spf13/pflag@v1.0.9
+malformed
+`
+	graph, err := ParseModGraph(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseModGraph() error = %v", err)
+	}
+
+	if len(graph) != 2 {
+		t.Fatalf("len(graph) = %d, want 2", len(graph))
+	}
+
+	main := graph["example.com/main"]
+	if len(main) != 2 || main[0] != "github.com/spf13/cobra@v1.10.2" || main[1] != "golang.org/x/mod@v0.32.0" {
+		t.Errorf("graph[main] = %v, want sorted two edges", main)
+	}
+
+	cobra := graph["github.com/spf13/cobra@v1.10.2"]
+	if len(cobra) != 1 {
+		t.Errorf("graph[cobra] = %v, want deduplicated single edge", cobra)
+	}
+}
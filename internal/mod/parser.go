@@ -5,18 +5,21 @@ import (
 	"bufio"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
 )
 
 // ModInfo contains parsed information from go.mod.
 type ModInfo struct {
 	ModulePath string
 	GoVersion  string
+	Toolchain  string // toolchain directive, e.g. "go1.22.3"; empty if absent
 	Requires   []Require
 	Replaces   []Replace
+	Excludes   []Exclude
+	Retracts   []Retract
 }
 
 // Require represents a single require directive.
@@ -35,6 +38,31 @@ type Replace struct {
 	IsLocal    bool // True if New is a local filesystem path
 }
 
+// Applies reports whether the directive replaces version of Old: any
+// version when the directive names none, otherwise only that one.
+func (r Replace) Applies(version string) bool {
+	return r.OldVersion == "" || r.OldVersion == version
+}
+
+// Exclude represents an exclude directive.
+type Exclude struct {
+	Path    string
+	Version string
+}
+
+// Retract represents a retract directive: a single version when Low and
+// High are equal, otherwise the closed interval [Low, High].
+type Retract struct {
+	Low       string
+	High      string
+	Rationale string
+}
+
+// Covers reports whether version lies in the retracted interval.
+func (r Retract) Covers(version string) bool {
+	return semver.Compare(r.Low, version) <= 0 && semver.Compare(version, r.High) <= 0
+}
+
 // SumEntry represents a single entry from go.sum.
 type SumEntry struct {
 	Path    string
@@ -42,13 +70,26 @@ type SumEntry struct {
 	Hash    string // The h1: hash
 }
 
+// ToolchainVersion returns the Go version the module is developed with: the
+// toolchain directive when present, otherwise the go directive.
+func (m *ModInfo) ToolchainVersion() string {
+	if v, ok := strings.CutPrefix(m.Toolchain, "go"); ok && v != "" {
+		return v
+	}
+	return m.GoVersion
+}
+
 // ParseGoMod reads and parses a go.mod file.
 func ParseGoMod(path string) (*ModInfo, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("reading go.mod: %w", err)
 	}
+	return ParseGoModData(path, data)
+}
 
+// ParseGoModData parses go.mod content; path is used in error messages.
+func ParseGoModData(path string, data []byte) (*ModInfo, error) {
 	f, err := modfile.Parse(path, data, nil)
 	if err != nil {
 		return nil, fmt.Errorf("parsing go.mod: %w", err)
@@ -61,6 +102,9 @@ func ParseGoMod(path string) (*ModInfo, error) {
 	if f.Go != nil {
 		info.GoVersion = f.Go.Version
 	}
+	if f.Toolchain != nil {
+		info.Toolchain = f.Toolchain.Name
+	}
 
 	for _, req := range f.Require {
 		info.Requires = append(info.Requires, Require{
@@ -78,12 +122,24 @@ func ParseGoMod(path string) (*ModInfo, error) {
 			NewVersion: rep.New.Version,
 		}
 		// Check if it's a local path replacement
-		if strings.HasPrefix(rep.New.Path, "./") || strings.HasPrefix(rep.New.Path, "../") || filepath.IsAbs(rep.New.Path) {
+		if isLocalPath(rep.New.Path) {
 			r.IsLocal = true
 		}
 		info.Replaces = append(info.Replaces, r)
 	}
 
+	for _, ex := range f.Exclude {
+		info.Excludes = append(info.Excludes, Exclude{Path: ex.Mod.Path, Version: ex.Mod.Version})
+	}
+
+	for _, ret := range f.Retract {
+		info.Retracts = append(info.Retracts, Retract{
+			Low:       ret.Low,
+			High:      ret.High,
+			Rationale: ret.Rationale,
+		})
+	}
+
 	return info, nil
 }
 
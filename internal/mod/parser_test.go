@@ -245,3 +245,51 @@ go 1.21
 		})
 	}
 }
+
+func TestToolchainVersion(t *testing.T) {
+	tests := []struct {
+		info ModInfo
+		want string
+	}{
+		{ModInfo{GoVersion: "1.21"}, "1.21"},
+		{ModInfo{GoVersion: "1.22.1", Toolchain: "go1.22.5"}, "1.22.5"},
+		{ModInfo{GoVersion: "1.22.1", Toolchain: "default"}, "1.22.1"},
+	}
+	for _, tt := range tests {
+		if got := tt.info.ToolchainVersion(); got != tt.want {
+			t.Errorf("ToolchainVersion(%+v) = %q, want %q", tt.info, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetractAndExclude(t *testing.T) {
+	data := []byte(`module example.com/lib
+
+go 1.21
+
+exclude example.com/dep v1.0.1
+
+retract (
+	v1.0.0 // Published by mistake.
+	[v1.1.0, v1.1.3]
+)
+`)
+	info, err := ParseGoModData("go.mod", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Excludes) != 1 || info.Excludes[0] != (Exclude{Path: "example.com/dep", Version: "v1.0.1"}) {
+		t.Errorf("Excludes = %+v", info.Excludes)
+	}
+	if len(info.Retracts) != 2 {
+		t.Fatalf("Retracts = %+v, want 2", info.Retracts)
+	}
+	if r := info.Retracts[0]; r.Low != "v1.0.0" || r.High != "v1.0.0" || r.Rationale != "Published by mistake." {
+		t.Errorf("Retracts[0] = %+v", r)
+	}
+	for version, want := range map[string]bool{"v1.0.9": false, "v1.1.0": true, "v1.1.2": true, "v1.1.3": true, "v1.1.4": false} {
+		if got := info.Retracts[1].Covers(version); got != want {
+			t.Errorf("Covers(%s) = %v, want %v", version, got, want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package mod
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoGo is returned by Tidy when the go command is not in PATH.
+var ErrNoGo = errors.New("go mod tidy requires the go command in PATH")
+
+// Tidy runs `go mod tidy` in dir, so go.mod and go.sum list exactly the
+// modules the build needs. When dir holds a go.work file (and GOWORK is not
+// "off"), every member module is tidied instead.
+func Tidy(dir string) error {
+	if _, err := exec.LookPath("go"); err != nil {
+		return ErrNoGo
+	}
+
+	dirs := []string{dir}
+	workPath := filepath.Join(dir, "go.work")
+	if _, err := os.Stat(workPath); err == nil && os.Getenv("GOWORK") != "off" {
+		work, err := ParseGoWork(workPath)
+		if err != nil {
+			return err
+		}
+		dirs = dirs[:0]
+		for _, m := range work.Members {
+			dirs = append(dirs, filepath.Join(dir, m.Dir))
+		}
+	}
+
+	for _, d := range dirs {
+		cmd := exec.Command("go", "mod", "tidy")
+		cmd.Dir = d
+		if out, err := cmd.CombinedOutput(); err != nil {
+			if len(out) > 0 {
+				return fmt.Errorf("go mod tidy in %s: %s", d, strings.TrimSpace(string(out)))
+			}
+			return fmt.Errorf("go mod tidy in %s: %w", d, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package mod
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTidy(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	t.Setenv("GOTOOLCHAIN", "local")
+	t.Setenv("GOFLAGS", "-mod=mod")
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/tidy\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Tidy(dir); err != nil {
+		t.Fatalf("Tidy() error = %v", err)
+	}
+	info, err := ParseGoMod(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.GoVersion == "" {
+		t.Error("go mod tidy should have added a go directive")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nimport _ \"example.com/missing\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOPROXY", "off")
+	if err := Tidy(dir); err == nil || !strings.Contains(err.Error(), "go mod tidy in") {
+		t.Errorf("Tidy() with an unresolvable import error = %v", err)
+	}
+}
+
+func TestTidyNoGo(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if err := Tidy(t.TempDir()); !errors.Is(err, ErrNoGo) {
+		t.Errorf("Tidy() without go error = %v, want ErrNoGo", err)
+	}
+}
@@ -0,0 +1,62 @@
+package mod
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// hexRev matches the 12-character commit hash of a pseudo-version.
+var hexRev = regexp.MustCompile(`^[0-9a-f]{12}$`)
+
+// pseudoLike matches versions that end like a pseudo-version, a timestamp
+// and a commit hash, whether or not they are well formed.
+var pseudoLike = regexp.MustCompile(`[-.][0-9]{8,}-[0-9A-Za-z]{6,}(\+incompatible)?$`)
+
+// CanonicalVersion validates a version of modulePath given by a user and
+// returns it as go.mod would record it. A missing v prefix is added, so
+// 1.2.3 is read as v1.2.3; anything else that is not already canonical is
+// rejected with an error saying what to write instead, since the module
+// proxy and origin only serve canonical versions.
+//
+// Build metadata other than +incompatible, incomplete versions such as v1.2
+// (which go get reads as "latest v1.2.x"), malformed pseudo-versions, and
+// major versions that don't match the module path are all rejected.
+func CanonicalVersion(modulePath, version string) (string, error) {
+	v := strings.TrimSpace(version)
+	if v == "" {
+		return "", fmt.Errorf("%s: empty version", modulePath)
+	}
+	if !strings.HasPrefix(v, "v") && semver.IsValid("v"+v) {
+		v = "v" + v
+	}
+	if !semver.IsValid(v) {
+		return "", fmt.Errorf("%s: invalid version %q: want a semantic version such as v1.2.3", modulePath, version)
+	}
+
+	if build := semver.Build(v); build != "" && build != "+incompatible" {
+		return "", fmt.Errorf("%s: invalid version %q: module versions can't carry build metadata (%s); use %s", modulePath, version, build, module.CanonicalVersion(v))
+	}
+	if c := module.CanonicalVersion(v); c != v {
+		return "", fmt.Errorf("%s: incomplete version %q: use a full version such as %s", modulePath, version, c)
+	}
+
+	if module.IsPseudoVersion(v) {
+		if rev, _ := module.PseudoVersionRev(v); !hexRev.MatchString(rev) {
+			return "", fmt.Errorf("%s: invalid pseudo-version %q: commit %q is not a 12-character lowercase hash", modulePath, version, rev)
+		}
+		if _, err := module.PseudoVersionTime(v); err != nil {
+			return "", fmt.Errorf("%s: invalid pseudo-version %q: %v", modulePath, version, err)
+		}
+	} else if pseudoLike.MatchString(v) {
+		return "", fmt.Errorf("%s: malformed pseudo-version %q: want vX.Y.Z-yyyymmddhhmmss-abcdef123456; nopher pseudo-version computes one", modulePath, version)
+	}
+
+	if err := module.Check(modulePath, v); err != nil {
+		return "", err
+	}
+	return v, nil
+}
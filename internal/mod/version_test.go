@@ -0,0 +1,51 @@
+package mod
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanonicalVersion(t *testing.T) {
+	tests := []struct {
+		modulePath string
+		version    string
+		want       string
+		wantErr    string
+	}{
+		{"example.com/lib", "v1.2.3", "v1.2.3", ""},
+		{"example.com/lib", "1.2.3", "v1.2.3", ""},
+		{"example.com/lib", " v1.2.3-rc.1 ", "v1.2.3-rc.1", ""},
+		{"example.com/lib", "v2.0.0+incompatible", "v2.0.0+incompatible", ""},
+		{"example.com/lib/v2", "2.1.0", "v2.1.0", ""},
+		{"example.com/lib", "v0.0.0-20240102150405-abcdef123456", "v0.0.0-20240102150405-abcdef123456", ""},
+		{"example.com/lib", "v1.2.4-0.20240102150405-abcdef123456", "v1.2.4-0.20240102150405-abcdef123456", ""},
+		{"example.com/lib", "", "", "empty version"},
+		{"example.com/lib", "latest", "", "want a semantic version"},
+		{"example.com/lib", "v1.2.3.4", "", "want a semantic version"},
+		{"example.com/lib", "v1.2", "", "use a full version such as v1.2.0"},
+		{"example.com/lib", "v1.2.3+build.5", "", "use v1.2.3"},
+		{"example.com/lib", "v0.0.0-20240102150405-ABCDEF123456", "", "12-character lowercase hash"},
+		{"example.com/lib", "v0.0.0-20241302150405-abcdef123456", "", "invalid pseudo-version"},
+		{"example.com/lib", "v0.0.0-2024010215040-abcdef123456", "", "malformed pseudo-version"},
+		{"example.com/lib", "v2.0.0", "", "should be v0 or v1"},
+		{"example.com/lib/v2", "v1.0.0", "", "should be v2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.modulePath+"@"+tt.version, func(t *testing.T) {
+			got, err := CanonicalVersion(tt.modulePath, tt.version)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CanonicalVersion() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CanonicalVersion() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("CanonicalVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package mod
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// WorkInfo contains parsed information from go.work and the go.mod files of
+// its members.
+type WorkInfo struct {
+	GoVersion string
+	Toolchain string // toolchain directive, e.g. "go1.22.3"; empty if absent
+	Members   []WorkMember
+	Replaces  []Replace // go.work replacements; local paths are relative to the workspace root
+}
+
+// WorkMember is a module listed in a go.work use directive.
+type WorkMember struct {
+	Dir        string // Directory relative to the workspace root, e.g. "./services/api"
+	ModulePath string
+	Requires   []Require
+	Replaces   []Replace // Local paths are rewritten relative to the workspace root
+}
+
+// ParseGoWork reads a go.work file and the go.mod file of every module it uses.
+func ParseGoWork(path string) (*WorkInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading go.work: %w", err)
+	}
+
+	f, err := modfile.ParseWork(path, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("parsing go.work: %w", err)
+	}
+
+	info := &WorkInfo{}
+	if f.Go != nil {
+		info.GoVersion = f.Go.Version
+	}
+	if f.Toolchain != nil {
+		info.Toolchain = f.Toolchain.Name
+	}
+	for _, rep := range f.Replace {
+		info.Replaces = append(info.Replaces, newReplace(rep, "."))
+	}
+
+	root := filepath.Dir(path)
+	for _, use := range f.Use {
+		dir := workRelative(".", use.Path)
+		modInfo, err := ParseGoMod(filepath.Join(root, dir, "go.mod"))
+		if err != nil {
+			return nil, fmt.Errorf("workspace member %s: %w", use.Path, err)
+		}
+		member := WorkMember{
+			Dir:        dir,
+			ModulePath: modInfo.ModulePath,
+			Requires:   modInfo.Requires,
+		}
+		for _, rep := range modInfo.Replaces {
+			if rep.IsLocal {
+				rep.New = workRelative(dir, rep.New)
+			}
+			member.Replaces = append(member.Replaces, rep)
+		}
+		info.Members = append(info.Members, member)
+	}
+	if len(info.Members) == 0 {
+		return nil, fmt.Errorf("go.work has no use directives")
+	}
+
+	return info, nil
+}
+
+// ToolchainVersion returns the Go version the workspace is developed with: the
+// toolchain directive when present, otherwise the go directive.
+func (w *WorkInfo) ToolchainVersion() string {
+	m := ModInfo{GoVersion: w.GoVersion, Toolchain: w.Toolchain}
+	return m.ToolchainVersion()
+}
+
+// ModInfo merges the members' requirements and replacements into a single
+// module set, as go resolves them in workspace mode. Requirements on other
+// members are dropped, and each module is required at the highest version any
+// member asks for. go.work replacements override member replacements; members
+// replacing the same module differently is an error unless go.work settles it.
+func (w *WorkInfo) ModInfo() (*ModInfo, error) {
+	members := make(map[string]bool, len(w.Members))
+	for _, m := range w.Members {
+		members[m.ModulePath] = true
+	}
+
+	info := &ModInfo{GoVersion: w.GoVersion, Toolchain: w.Toolchain}
+
+	requires := make(map[string]Require)
+	for _, m := range w.Members {
+		for _, req := range m.Requires {
+			if members[req.Path] {
+				continue
+			}
+			prev, ok := requires[req.Path]
+			if !ok {
+				requires[req.Path] = req
+				continue
+			}
+			if semver.Compare(req.Version, prev.Version) > 0 {
+				prev.Version = req.Version
+			}
+			prev.Indirect = prev.Indirect && req.Indirect
+			requires[req.Path] = prev
+		}
+	}
+
+	replaceKey := func(r Replace) string { return r.Old + "@" + r.OldVersion }
+	workReplaces := make(map[string]bool, len(w.Replaces))
+	for _, r := range w.Replaces {
+		workReplaces[replaceKey(r)] = true
+	}
+
+	replaces := make(map[string]Replace)
+	replacedBy := make(map[string]string)
+	for _, m := range w.Members {
+		for _, r := range m.Replaces {
+			key := replaceKey(r)
+			if members[r.Old] || workReplaces[key] {
+				continue
+			}
+			if prev, ok := replaces[key]; ok && (prev.New != r.New || prev.NewVersion != r.NewVersion) {
+				return nil, fmt.Errorf("conflicting replacements for %s in %s and %s; add a replace directive to go.work", r.Old, replacedBy[key], m.Dir)
+			}
+			replaces[key] = r
+			replacedBy[key] = m.Dir
+		}
+	}
+	for _, r := range w.Replaces {
+		replaces[replaceKey(r)] = r
+	}
+
+	for _, req := range requires {
+		info.Requires = append(info.Requires, req)
+	}
+	sort.Slice(info.Requires, func(i, j int) bool { return info.Requires[i].Path < info.Requires[j].Path })
+	for _, r := range replaces {
+		info.Replaces = append(info.Replaces, r)
+	}
+	sort.Slice(info.Replaces, func(i, j int) bool { return replaceKey(info.Replaces[i]) < replaceKey(info.Replaces[j]) })
+
+	return info, nil
+}
+
+// SumFiles returns the checksum files of the workspace rooted at root:
+// go.work.sum followed by each member's go.sum. Files may not exist.
+func (w *WorkInfo) SumFiles(root string) []string {
+	paths := []string{filepath.Join(root, "go.work.sum")}
+	for _, m := range w.Members {
+		paths = append(paths, filepath.Join(root, m.Dir, "go.sum"))
+	}
+	return paths
+}
+
+func newReplace(rep *modfile.Replace, dir string) Replace {
+	r := Replace{
+		Old:        rep.Old.Path,
+		OldVersion: rep.Old.Version,
+		New:        rep.New.Path,
+		NewVersion: rep.New.Version,
+	}
+	if isLocalPath(rep.New.Path) {
+		r.IsLocal = true
+		r.New = workRelative(dir, rep.New.Path)
+	}
+	return r
+}
+
+// workRelative resolves p relative to dir (both relative to the workspace
+// root) and returns it in "./" form. Absolute paths are returned unchanged.
+func workRelative(dir, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	p = filepath.ToSlash(filepath.Join(dir, p))
+	if p == "." || strings.HasPrefix(p, "../") {
+		return p
+	}
+	return "./" + p
+}
+
+func isLocalPath(p string) bool {
+	return strings.HasPrefix(p, "./") || strings.HasPrefix(p, "../") || filepath.IsAbs(p)
+}
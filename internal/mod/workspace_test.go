@@ -0,0 +1,107 @@
+package mod
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseGoWork(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.work": "go 1.22\n\nuse (\n\t./services/api\n\t./lib\n)\n\nreplace github.com/foo/baz => github.com/fork/baz v1.1.0\n",
+		"services/api/go.mod": `module example.com/api
+
+go 1.22
+
+require (
+	example.com/lib v0.0.0
+	github.com/foo/bar v1.2.0
+	github.com/foo/baz v1.0.0
+)
+
+replace github.com/foo/qux => ../../third_party/qux
+
+replace github.com/foo/baz => github.com/other/baz v1.0.0
+`,
+		"lib/go.mod": `module example.com/lib
+
+go 1.21
+
+require github.com/foo/bar v1.3.0 // indirect
+`,
+	})
+
+	work, err := ParseGoWork(filepath.Join(root, "go.work"))
+	if err != nil {
+		t.Fatalf("ParseGoWork() error = %v", err)
+	}
+	if len(work.Members) != 2 || work.Members[0].Dir != "./services/api" || work.Members[1].ModulePath != "example.com/lib" {
+		t.Fatalf("Members = %+v", work.Members)
+	}
+
+	info, err := work.ModInfo()
+	if err != nil {
+		t.Fatalf("ModInfo() error = %v", err)
+	}
+	if info.GoVersion != "1.22" {
+		t.Errorf("GoVersion = %q, want 1.22", info.GoVersion)
+	}
+
+	want := []Require{
+		{Path: "github.com/foo/bar", Version: "v1.3.0"},
+		{Path: "github.com/foo/baz", Version: "v1.0.0"},
+	}
+	if len(info.Requires) != len(want) {
+		t.Fatalf("Requires = %+v, want %+v", info.Requires, want)
+	}
+	for i, req := range want {
+		if info.Requires[i] != req {
+			t.Errorf("Requires[%d] = %+v, want %+v", i, info.Requires[i], req)
+		}
+	}
+
+	replaces := make(map[string]Replace)
+	for _, r := range info.Replaces {
+		replaces[r.Old] = r
+	}
+	if r := replaces["github.com/foo/qux"]; !r.IsLocal || r.New != "./third_party/qux" {
+		t.Errorf("local replacement = %+v, want ./third_party/qux", r)
+	}
+	if r := replaces["github.com/foo/baz"]; r.New != "github.com/fork/baz" {
+		t.Errorf("go.work replacement should win, got %+v", r)
+	}
+
+	sums := work.SumFiles(root)
+	if len(sums) != 3 || filepath.Base(sums[0]) != "go.work.sum" {
+		t.Errorf("SumFiles() = %v", sums)
+	}
+}
+
+func TestWorkInfoConflictingReplacements(t *testing.T) {
+	work := &WorkInfo{
+		GoVersion: "1.22",
+		Members: []WorkMember{
+			{Dir: "./a", ModulePath: "example.com/a", Replaces: []Replace{{Old: "github.com/foo/bar", New: "github.com/x/bar", NewVersion: "v1.0.0"}}},
+			{Dir: "./b", ModulePath: "example.com/b", Replaces: []Replace{{Old: "github.com/foo/bar", New: "github.com/y/bar", NewVersion: "v1.0.0"}}},
+		},
+	}
+	_, err := work.ModInfo()
+	if err == nil || !strings.Contains(err.Error(), "conflicting replacements") {
+		t.Errorf("ModInfo() error = %v, want conflicting replacements", err)
+	}
+}
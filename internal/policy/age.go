@@ -0,0 +1,121 @@
+// Package policy enforces supply-chain rules on locked modules.
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"golang.org/x/mod/module"
+)
+
+// TimeFunc returns when a module version was published.
+type TimeFunc func(modulePath, version string) (time.Time, error)
+
+// MinAge requires locked module versions to have been published at least
+// Age ago. Modules matching an Exceptions pattern (GOPRIVATE-style globs) are
+// exempt.
+type MinAge struct {
+	Age        time.Duration
+	Exceptions []string
+	// Now is the reference time; zero means time.Now().
+	Now time.Time
+}
+
+// AgeViolation is a module version younger than the minimum age.
+type AgeViolation struct {
+	Module    string
+	Version   string
+	Published time.Time
+	Age       time.Duration
+}
+
+func (v AgeViolation) String() string {
+	return fmt.Sprintf("%s@%s was published %s ago (%s)",
+		v.Module, v.Version, FormatAge(v.Age), v.Published.UTC().Format(time.RFC3339))
+}
+
+// CheckMinAge reports locked modules that violate rule. When prev is non-nil
+// only module versions that are new relative to prev are checked. A module
+// whose publish time cannot be determined is an error, so the policy fails
+// closed; exempt such modules explicitly.
+func CheckMinAge(lf, prev *lockfile.Lockfile, rule MinAge, published TimeFunc) ([]AgeViolation, error) {
+	now := rule.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	exceptions := strings.Join(rule.Exceptions, ",")
+
+	type target struct{ path, version string }
+	var targets []target
+	for path, m := range lf.Modules {
+		if prev != nil {
+			if p, ok := prev.Modules[path]; ok && p.Version == m.Version {
+				continue
+			}
+		}
+		targets = append(targets, target{path, m.Version})
+	}
+	for old, r := range lf.Replace {
+		if r.Path != "" || r.New == "" {
+			continue
+		}
+		if prev != nil {
+			if p, ok := prev.Replace[old]; ok && p.New == r.New && p.Version == r.Version {
+				continue
+			}
+		}
+		targets = append(targets, target{r.New, r.Version})
+	}
+
+	var violations []AgeViolation
+	for _, t := range targets {
+		if exceptions != "" && module.MatchPrefixPatterns(exceptions, t.path) {
+			continue
+		}
+		pub, err := published(t.path, t.version)
+		if err != nil {
+			return nil, err
+		}
+		if age := now.Sub(pub); age < rule.Age {
+			violations = append(violations, AgeViolation{Module: t.path, Version: t.version, Published: pub, Age: age})
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		return violations[i].Module < violations[j].Module
+	})
+	return violations, nil
+}
+
+// ParseAge parses a minimum age such as "7d", "36h", or "90m". A bare
+// number is a count of days.
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	days := strings.TrimSuffix(s, "d")
+	if n, err := strconv.Atoi(days); err == nil {
+		if n < 0 {
+			return 0, fmt.Errorf("invalid minimum age %q: must not be negative", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid minimum age %q (want e.g. 7d or 36h)", s)
+	}
+	return d, nil
+}
+
+// FormatAge renders d compactly, in days and hours once it exceeds a day.
+func FormatAge(d time.Duration) string {
+	if d < 0 {
+		return "0s"
+	}
+	if d >= 24*time.Hour {
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+	return d.Round(time.Minute).String()
+}
@@ -0,0 +1,78 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/anthr76/nopher/pkg/lockfile"
+)
+
+func TestCheckMinAge(t *testing.T) {
+	now := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+	published := map[string]time.Time{
+		"example.com/old@v1.0.0":      now.Add(-30 * 24 * time.Hour),
+		"example.com/fresh@v1.1.0":    now.Add(-2 * 24 * time.Hour),
+		"example.com/internal@v0.1.0": now.Add(-time.Hour),
+		"example.com/fork@v1.0.1":     now.Add(-time.Hour),
+	}
+	lookup := func(path, version string) (time.Time, error) {
+		if t, ok := published[path+"@"+version]; ok {
+			return t, nil
+		}
+		return time.Time{}, errors.New("unknown")
+	}
+
+	lf := lockfile.New("1.22")
+	lf.Modules["example.com/old"] = lockfile.Module{Version: "v1.0.0"}
+	lf.Modules["example.com/fresh"] = lockfile.Module{Version: "v1.1.0"}
+	lf.Modules["example.com/internal"] = lockfile.Module{Version: "v0.1.0"}
+	lf.Replace["example.com/upstream"] = lockfile.Replace{New: "example.com/fork", Version: "v1.0.1"}
+
+	rule := MinAge{Age: 7 * 24 * time.Hour, Exceptions: []string{"example.com/internal"}, Now: now}
+	got, err := CheckMinAge(lf, nil, rule, lookup)
+	if err != nil {
+		t.Fatalf("CheckMinAge() error = %v", err)
+	}
+	if len(got) != 2 || got[0].Module != "example.com/fork" || got[1].Module != "example.com/fresh" {
+		t.Fatalf("violations = %v, want fork and fresh", got)
+	}
+
+	// Modules unchanged from the previous lockfile are not re-checked.
+	prev := lockfile.New("1.22")
+	prev.Modules["example.com/fresh"] = lockfile.Module{Version: "v1.1.0"}
+	prev.Replace["example.com/upstream"] = lockfile.Replace{New: "example.com/fork", Version: "v1.0.1"}
+	got, err = CheckMinAge(lf, prev, rule, lookup)
+	if err != nil {
+		t.Fatalf("CheckMinAge() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("violations = %v, want none", got)
+	}
+
+	// Unknown publish times fail closed.
+	lf.Modules["example.com/unknown"] = lockfile.Module{Version: "v1.0.0"}
+	if _, err := CheckMinAge(lf, nil, rule, lookup); err == nil {
+		t.Error("expected error for unknown publish time")
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"7d", 7 * 24 * time.Hour, false},
+		{"14", 14 * 24 * time.Hour, false},
+		{"36h", 36 * time.Hour, false},
+		{"-1d", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAge(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"golang.org/x/mod/module"
+)
+
+// ModuleRules restricts which modules may be fetched at all. Entries are
+// module paths or GOPRIVATE-style patterns, optionally suffixed with
+// @version to match a single version. A module matching a Deny entry is
+// rejected; when Allow is non-empty, a module must also match one of its
+// entries. Deny takes precedence over Allow.
+type ModuleRules struct {
+	Allow []string
+	Deny  []string
+}
+
+// Empty reports whether r has no entries.
+func (r ModuleRules) Empty() bool {
+	return len(r.Allow) == 0 && len(r.Deny) == 0
+}
+
+// Validate reports malformed entries.
+func (r ModuleRules) Validate() error {
+	for _, entry := range append(append([]string(nil), r.Deny...), r.Allow...) {
+		pattern, version, _ := strings.Cut(entry, "@")
+		if pattern == "" {
+			return fmt.Errorf("invalid module rule %q: empty module pattern", entry)
+		}
+		if strings.Contains(entry, "@") && version == "" {
+			return fmt.Errorf("invalid module rule %q: empty version", entry)
+		}
+	}
+	return nil
+}
+
+// Check returns an error if modulePath at version may not be fetched.
+func (r ModuleRules) Check(modulePath, version string) error {
+	for _, entry := range r.Deny {
+		if matchRule(entry, modulePath, version) {
+			return fmt.Errorf("%s@%s is denied by %q", modulePath, version, entry)
+		}
+	}
+	if len(r.Allow) == 0 {
+		return nil
+	}
+	for _, entry := range r.Allow {
+		if matchRule(entry, modulePath, version) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s@%s is not in the allow list", modulePath, version)
+}
+
+// CheckLockfile returns the rule violations among the modules and
+// replacements locked in lf, sorted by module path.
+func (r ModuleRules) CheckLockfile(lf *lockfile.Lockfile) []error {
+	type target struct{ path, version string }
+	var targets []target
+	for path, m := range lf.Modules {
+		targets = append(targets, target{path, m.Version})
+	}
+	for _, rep := range lf.Replace {
+		if rep.Path != "" || rep.New == "" {
+			continue
+		}
+		targets = append(targets, target{rep.New, rep.Version})
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].path != targets[j].path {
+			return targets[i].path < targets[j].path
+		}
+		return targets[i].version < targets[j].version
+	})
+
+	var violations []error
+	for _, t := range targets {
+		if err := r.Check(t.path, t.version); err != nil {
+			violations = append(violations, err)
+		}
+	}
+	return violations
+}
+
+func matchRule(entry, modulePath, version string) bool {
+	pattern, want, hasVersion := strings.Cut(entry, "@")
+	if hasVersion && want != version {
+		return false
+	}
+	return module.MatchPrefixPatterns(pattern, modulePath)
+}
@@ -0,0 +1,60 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/anthr76/nopher/pkg/lockfile"
+)
+
+func TestModuleRulesCheck(t *testing.T) {
+	rules := ModuleRules{
+		Allow: []string{"example.com", "golang.org/x/*"},
+		Deny:  []string{"example.com/evil", "example.com/lib@v1.2.3"},
+	}
+
+	tests := []struct {
+		path, version string
+		ok            bool
+	}{
+		{"example.com/lib", "v1.2.2", true},
+		{"example.com/lib", "v1.2.3", false},
+		{"example.com/evil", "v0.1.0", false},
+		{"example.com/evil/sub", "v0.1.0", false},
+		{"golang.org/x/mod", "v0.20.0", true},
+		{"github.com/other/pkg", "v1.0.0", false},
+	}
+	for _, tt := range tests {
+		err := rules.Check(tt.path, tt.version)
+		if (err == nil) != tt.ok {
+			t.Errorf("Check(%s, %s) error = %v, want ok=%v", tt.path, tt.version, err, tt.ok)
+		}
+	}
+
+	if err := (ModuleRules{}).Check("github.com/any/thing", "v1.0.0"); err != nil {
+		t.Errorf("empty rules should allow everything, got %v", err)
+	}
+}
+
+func TestModuleRulesValidate(t *testing.T) {
+	for _, entry := range []string{"@v1.0.0", "example.com/a@"} {
+		if err := (ModuleRules{Deny: []string{entry}}).Validate(); err == nil {
+			t.Errorf("Validate() should reject %q", entry)
+		}
+	}
+	if err := (ModuleRules{Deny: []string{"example.com/a@v1.0.0", "*.corp.example"}}).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestModuleRulesCheckLockfile(t *testing.T) {
+	lf := lockfile.New("1.22")
+	lf.Modules["example.com/ok"] = lockfile.Module{Version: "v1.0.0"}
+	lf.Modules["example.com/bad"] = lockfile.Module{Version: "v1.0.0"}
+	lf.Replace["example.com/upstream"] = lockfile.Replace{New: "example.com/badfork", Version: "v1.0.1"}
+	lf.Replace["example.com/local"] = lockfile.Replace{Path: "../local"}
+
+	got := ModuleRules{Deny: []string{"example.com/bad*"}}.CheckLockfile(lf)
+	if len(got) != 2 {
+		t.Fatalf("violations = %v, want bad and badfork", got)
+	}
+}
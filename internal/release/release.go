@@ -0,0 +1,149 @@
+// Package release checks a module's own go.mod for directives that would
+// break or surprise its consumers once a version is tagged.
+//
+// Go applies replace and exclude directives only in the main module, and
+// reads retract directives only from the latest version of a module. A
+// consumer who locks the module with nopher therefore resolves a different
+// set of dependencies than the module was developed and tested against.
+package release
+
+import (
+	"fmt"
+
+	"github.com/anthr76/nopher/internal/mod"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// Severity classifies a finding.
+type Severity string
+
+const (
+	// Error means consumers will not get what the release was tested with,
+	// or the release cannot be tagged as planned.
+	Error Severity = "error"
+	// Warning means consumers may see behavior the maintainer did not
+	// intend.
+	Warning Severity = "warning"
+)
+
+// Finding is a single problem with a go.mod directive or the release
+// version.
+type Finding struct {
+	Severity  Severity
+	Directive string // The directive or version the finding is about
+	Message   string
+}
+
+func (f Finding) String() string {
+	return f.Directive + ": " + f.Message
+}
+
+// Check reports problems consumers of info's module would hit. version is
+// the version about to be tagged, or empty if not known; published lists
+// the versions already published, or is nil if not known.
+func Check(info *mod.ModInfo, version string, published []string) []Finding {
+	required := make(map[string]string)
+	for _, req := range info.Requires {
+		required[req.Path] = req.Version
+	}
+
+	var findings []Finding
+	add := func(severity Severity, directive, format string, args ...any) {
+		findings = append(findings, Finding{Severity: severity, Directive: directive, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if version != "" {
+		findings = append(findings, checkVersion(info, version, published)...)
+	}
+
+	for _, r := range info.Replaces {
+		directive := "replace " + pathVersion(r.Old, r.OldVersion) + " => " + pathVersion(r.New, r.NewVersion)
+		req, ok := required[r.Old]
+		switch {
+		case r.IsLocal && ok:
+			add(Error, directive, "consumers ignore replace directives and build %s@%s from its published source instead of this directory", r.Old, req)
+		case r.IsLocal:
+			add(Error, directive, "consumers ignore replace directives and get whichever version of %s their dependency graph selects instead of this directory", r.Old)
+		case !ok:
+			add(Warning, directive, "consumers ignore replace directives and get whichever version of %s their dependency graph selects", r.Old)
+		case r.New != r.Old:
+			add(Warning, directive, "consumers ignore replace directives and get %s@%s, not the fork; depend on the fork directly if the release needs it", r.Old, req)
+		case r.Applies(req):
+			add(Warning, directive, "consumers ignore replace directives and get %s@%s; require %s directly instead", r.Old, req, r.NewVersion)
+		}
+	}
+
+	for _, ex := range info.Excludes {
+		add(Warning, "exclude "+ex.Path+" "+ex.Version, "consumers ignore exclude directives and may still select this version")
+	}
+
+	for _, r := range info.Retracts {
+		if r.Rationale == "" {
+			add(Warning, retractDirective(r), "has no rationale comment; consumers are told a version is retracted but not why")
+		}
+	}
+	return findings
+}
+
+// checkVersion reports problems with tagging version.
+func checkVersion(info *mod.ModInfo, version string, published []string) []Finding {
+	var findings []Finding
+	add := func(severity Severity, format string, args ...any) {
+		findings = append(findings, Finding{Severity: severity, Directive: version, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if !semver.IsValid(version) || semver.Canonical(version) != version {
+		add(Error, "not a canonical semantic version such as v1.2.3")
+		return findings
+	}
+	if _, pathMajor, ok := module.SplitPathVersion(info.ModulePath); ok {
+		if err := module.CheckPathMajor(version, pathMajor); err != nil {
+			add(Error, "does not match the module path %s: %v", info.ModulePath, err)
+		}
+	}
+
+	for _, p := range published {
+		if p == version {
+			add(Error, "is already published; proxies keep serving the original contents, and consumers' lockfiles keep its hash")
+			break
+		}
+	}
+
+	for _, r := range info.Retracts {
+		if !r.Covers(version) {
+			continue
+		}
+		// Retracting [earlier, this] is the documented way to retract the
+		// latest published version.
+		if r.High == version && r.Low != r.High {
+			add(Warning, "retracts itself (%s); consumers will not select it when upgrading", retractDirective(r))
+		} else {
+			add(Error, "is retracted by %s", retractDirective(r))
+		}
+	}
+
+	if len(info.Retracts) > 0 && len(published) > 0 {
+		latest := published[len(published)-1]
+		if semver.Compare(version, latest) < 0 {
+			add(Warning, "is older than the published %s; consumers only read retract directives from the latest version, so this release's retractions have no effect", latest)
+		}
+	}
+	return findings
+}
+
+// pathVersion formats a module path with an optional version.
+func pathVersion(path, version string) string {
+	if version == "" {
+		return path
+	}
+	return path + " " + version
+}
+
+// retractDirective formats r as it appears in go.mod.
+func retractDirective(r mod.Retract) string {
+	if r.Low == r.High {
+		return "retract " + r.Low
+	}
+	return fmt.Sprintf("retract [%s, %s]", r.Low, r.High)
+}
@@ -0,0 +1,100 @@
+package release
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anthr76/nopher/internal/mod"
+)
+
+func TestCheck(t *testing.T) {
+	info, err := mod.ParseGoModData("go.mod", []byte(`module example.com/lib/v2
+
+go 1.21
+
+require (
+	example.com/local v1.0.0
+	example.com/pinned v1.2.0
+	example.com/forked v1.0.0
+)
+
+replace example.com/local => ../local
+
+replace example.com/pinned => example.com/pinned v1.3.0
+
+replace example.com/forked => github.com/someone/forked v1.0.1
+
+exclude example.com/bad v1.0.0
+
+retract (
+	v2.0.0 // Broken build.
+	[v2.1.0, v2.1.1]
+)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		version   string
+		published []string
+		want      []string
+	}{
+		{
+			name: "no version",
+			want: []string{
+				"error replace example.com/local => ../local",
+				"warning replace example.com/pinned => example.com/pinned v1.3.0",
+				"warning replace example.com/forked => github.com/someone/forked v1.0.1",
+				"warning exclude example.com/bad v1.0.0",
+				"warning retract [v2.1.0, v2.1.1]",
+			},
+		},
+		{
+			name:      "self-retraction",
+			version:   "v2.1.1",
+			published: []string{"v2.0.0", "v2.1.0"},
+			want:      []string{"warning v2.1.1"},
+		},
+		{
+			name:    "retracted",
+			version: "v2.0.0",
+			want:    []string{"error v2.0.0"},
+		},
+		{
+			name:      "already published and not latest",
+			version:   "v2.0.5",
+			published: []string{"v2.0.5", "v2.2.0"},
+			want:      []string{"error v2.0.5", "warning v2.0.5"},
+		},
+		{
+			name:    "wrong major",
+			version: "v3.0.0",
+			want:    []string{"error v3.0.0"},
+		},
+		{
+			name:    "not canonical",
+			version: "v2.1",
+			want:    []string{"error v2.1"},
+		},
+		{
+			name:      "fine",
+			version:   "v2.3.0",
+			published: []string{"v2.0.0", "v2.2.0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range Check(info, tt.version, tt.published) {
+				if tt.version == "" || f.Directive == tt.version {
+					got = append(got, string(f.Severity)+" "+f.Directive)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("findings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package suspect
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"golang.org/x/mod/module"
+)
+
+// DuplicateKind classifies a Duplicate.
+type DuplicateKind string
+
+const (
+	// Majors means several major versions of one module are locked.
+	Majors DuplicateKind = "majors"
+	// Forked means a popular module and a fork of it (the same repository
+	// name under another owner) are both locked. When both are popular,
+	// either may be the fork.
+	Forked DuplicateKind = "fork"
+)
+
+// Duplicate is a set of locked modules that likely provide the same code.
+type Duplicate struct {
+	Kind    DuplicateKind
+	Modules []string // path@version, sorted; for Forked, the popular module
+	Forks   []string // path@version of the forks, for Forked
+}
+
+func (d Duplicate) String() string {
+	switch d.Kind {
+	case Forked:
+		return fmt.Sprintf("%s and %s look like forks of the same repository", strings.Join(d.Modules, ", "), strings.Join(d.Forks, ", "))
+	default:
+		return fmt.Sprintf("%d major versions are locked: %s", len(d.Modules), strings.Join(d.Modules, ", "))
+	}
+}
+
+// Duplicates reports groups of modules in lf that commonly indicate
+// accidental bloat: several major versions of the same module, and a
+// popular module locked next to a fork of it. Remote replacement targets
+// count as locked under their new path. Modules matching an allow pattern
+// (GOPRIVATE-style globs) are ignored.
+func Duplicates(lf *lockfile.Lockfile, allow []string) []Duplicate {
+	locked := make(map[string]string)
+	for path, m := range lf.Modules {
+		locked[path] = m.Version
+	}
+	for _, r := range lf.Replace {
+		if r.Path == "" && r.New != "" {
+			locked[r.New] = r.Version
+		}
+	}
+	for path := range locked {
+		if len(allow) > 0 && module.MatchPrefixPatterns(strings.Join(allow, ","), path) {
+			delete(locked, path)
+		}
+	}
+
+	families := make(map[string][]string)
+	for path := range locked {
+		family := stripMajor(path)
+		families[family] = append(families[family], path)
+	}
+
+	var dups []Duplicate
+	for _, paths := range families {
+		if len(paths) > 1 {
+			dups = append(dups, Duplicate{Kind: Majors, Modules: withVersions(paths, locked)})
+		}
+	}
+
+	// Pair each popular family with the forks of it. A pair of popular
+	// families is reported once, under the one that sorts first.
+	forks := make(map[string][]string)
+	for a := range families {
+		for b := range families {
+			if a >= b || !forkOf(a, b) {
+				continue
+			}
+			switch {
+			case popularFamily(families[a]):
+				forks[a] = append(forks[a], families[b]...)
+			case popularFamily(families[b]):
+				forks[b] = append(forks[b], families[a]...)
+			}
+		}
+	}
+	for family, forked := range forks {
+		dups = append(dups, Duplicate{Kind: Forked, Modules: withVersions(families[family], locked), Forks: withVersions(forked, locked)})
+	}
+
+	sort.Slice(dups, func(i, j int) bool {
+		if dups[i].Modules[0] != dups[j].Modules[0] {
+			return dups[i].Modules[0] < dups[j].Modules[0]
+		}
+		return dups[i].Kind < dups[j].Kind
+	})
+	return dups
+}
+
+// popularFamily reports whether any of paths is a popular module.
+func popularFamily(paths []string) bool {
+	for _, p := range paths {
+		if popular[p] || popular[stripMajor(p)] {
+			return true
+		}
+	}
+	return false
+}
+
+// withVersions returns paths sorted and suffixed with their locked version.
+func withVersions(paths []string, locked map[string]string) []string {
+	sorted := append([]string(nil), paths...)
+	sort.Strings(sorted)
+	out := make([]string, len(sorted))
+	for i, p := range sorted {
+		out[i] = p + "@" + locked[p]
+	}
+	return out
+}
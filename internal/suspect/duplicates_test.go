@@ -0,0 +1,41 @@
+package suspect
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/anthr76/nopher/pkg/lockfile"
+)
+
+func TestDuplicates(t *testing.T) {
+	lf := lockfile.New("1.22")
+	for path, version := range map[string]string{
+		"github.com/spf13/cobra":      "v1.8.0",
+		"github.com/someone/cobra":    "v1.7.1", // fork of a popular module
+		"github.com/golang/protobuf":  "v1.5.4",
+		"github.com/gogo/protobuf":    "v1.3.2", // both popular: reported once
+		"gopkg.in/yaml.v2":            "v2.4.0",
+		"gopkg.in/yaml.v3":            "v3.0.1", // two majors
+		"github.com/cenk/backoff":     "v2.0.0+incompatible",
+		"github.com/cenk/backoff/v4":  "v4.3.0", // two majors
+		"github.com/acme/widgets":     "v1.0.0",
+		"github.com/other/widgets":    "v1.0.0", // same name, neither popular
+		"github.com/allowed/cobra":    "v1.0.0", // fork but allowlisted
+		"github.com/google/uuid":      "v1.6.0",
+		"github.com/google/uuid/cmd":  "v0.1.0", // nested module, not a major
+		"github.com/stretchr/testify": "v1.9.0",
+	} {
+		lf.Modules[path] = lockfile.Module{Version: version}
+	}
+
+	got := Duplicates(lf, []string{"github.com/allowed/*"})
+	want := []Duplicate{
+		{Kind: Majors, Modules: []string{"github.com/cenk/backoff@v2.0.0+incompatible", "github.com/cenk/backoff/v4@v4.3.0"}},
+		{Kind: Forked, Modules: []string{"github.com/gogo/protobuf@v1.3.2"}, Forks: []string{"github.com/golang/protobuf@v1.5.4"}},
+		{Kind: Forked, Modules: []string{"github.com/spf13/cobra@v1.8.0"}, Forks: []string{"github.com/someone/cobra@v1.7.1"}},
+		{Kind: Majors, Modules: []string{"gopkg.in/yaml.v2@v2.4.0", "gopkg.in/yaml.v3@v3.0.1"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Duplicates() =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,226 @@
+// Package suspect flags newly added modules that look like typosquats of
+// well-known modules, or that pin a fork of one at a pseudo-version, and
+// reports locked modules that duplicate each other.
+//
+// The checks are heuristics meant to draw a reviewer's attention, not a
+// verdict; legitimate modules can be silenced with an allowlist.
+package suspect
+
+import (
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"golang.org/x/mod/module"
+)
+
+//go:embed popular.txt
+var popularList string
+
+// popular is the set of well-known module paths from popular.txt.
+var popular = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, line := range strings.Split(popularList, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			m[line] = true
+		}
+	}
+	return m
+}()
+
+// Kind classifies a finding.
+type Kind string
+
+const (
+	// NearMiss means the module path is a small edit away from a known module.
+	NearMiss Kind = "near-miss"
+	// Fork means a pseudo-version pins a repository with the same name as a
+	// known module under a different owner.
+	Fork Kind = "fork"
+)
+
+// Finding is a single suspicious module.
+type Finding struct {
+	Module  string
+	Version string
+	Kind    Kind
+	Similar string // Known module the finding resembles
+}
+
+func (f Finding) String() string {
+	switch f.Kind {
+	case Fork:
+		return fmt.Sprintf("%s@%s pins a pseudo-version of what looks like a fork of %s", f.Module, f.Version, f.Similar)
+	default:
+		return fmt.Sprintf("%s@%s looks like a near-miss of %s", f.Module, f.Version, f.Similar)
+	}
+}
+
+// Check reports suspicious modules that lf adds relative to prev. A nil prev
+// treats every module as new. New modules are compared against the built-in
+// list of popular modules and against every module prev already locks.
+// Modules matching an allow pattern (GOPRIVATE-style globs) are skipped.
+func Check(lf, prev *lockfile.Lockfile, allow []string) []Finding {
+	known := make(map[string]bool, len(popular))
+	for p := range popular {
+		known[p] = true
+	}
+	if prev != nil {
+		for p := range prev.Modules {
+			known[p] = true
+		}
+		for p, r := range prev.Replace {
+			known[p] = true
+			if r.New != "" {
+				known[r.New] = true
+			}
+		}
+	}
+
+	allowed := func(path string) bool {
+		return len(allow) > 0 && module.MatchPrefixPatterns(strings.Join(allow, ","), path)
+	}
+
+	var findings []Finding
+	for path, m := range lf.Modules {
+		if prev != nil {
+			if _, ok := prev.Modules[path]; ok {
+				continue
+			}
+		}
+		if allowed(path) {
+			continue
+		}
+		findings = append(findings, checkPath(path, m.Version, known)...)
+	}
+
+	for old, r := range lf.Replace {
+		if r.Path != "" || r.New == "" || r.New == old {
+			continue
+		}
+		if prev != nil {
+			if p, ok := prev.Replace[old]; ok && p.New == r.New && p.Version == r.Version {
+				continue
+			}
+		}
+		if allowed(r.New) {
+			continue
+		}
+		withOld := map[string]bool{old: true}
+		for p := range known {
+			withOld[p] = true
+		}
+		findings = append(findings, checkPath(r.New, r.Version, withOld)...)
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Module != findings[j].Module {
+			return findings[i].Module < findings[j].Module
+		}
+		return findings[i].Similar < findings[j].Similar
+	})
+	return findings
+}
+
+// checkPath compares one new module against the known set.
+func checkPath(path, version string, known map[string]bool) []Finding {
+	if known[path] {
+		return nil
+	}
+
+	var findings []Finding
+	pseudo := module.IsPseudoVersion(version)
+	for k := range known {
+		if hasPathPrefix(path, k) || hasPathPrefix(k, path) {
+			continue
+		}
+		switch {
+		case nearMiss(path, k):
+			findings = append(findings, Finding{Module: path, Version: version, Kind: NearMiss, Similar: k})
+		case pseudo && forkOf(path, k):
+			findings = append(findings, Finding{Module: path, Version: version, Kind: Fork, Similar: k})
+		}
+	}
+	return findings
+}
+
+// nearMiss reports whether path (or its leading segments) differs from known
+// in exactly one segment by a small edit distance, or only in letter case.
+func nearMiss(path, known string) bool {
+	a := strings.Split(stripMajor(path), "/")
+	b := strings.Split(stripMajor(known), "/")
+	if len(a) < len(b) {
+		return false
+	}
+	a = a[:len(b)]
+
+	diff := -1
+	for i := range b {
+		if a[i] != b[i] {
+			if diff >= 0 {
+				return false
+			}
+			diff = i
+		}
+	}
+	if diff < 0 {
+		return false
+	}
+
+	x, y := a[diff], b[diff]
+	if strings.EqualFold(x, y) {
+		return true
+	}
+	limit := 1
+	if min(len(x), len(y)) > 6 {
+		limit = 2
+	}
+	return levenshtein(x, y) <= limit
+}
+
+// forkOf reports whether path is host/owner/repo with the same host and repo
+// name as known but a different owner.
+func forkOf(path, known string) bool {
+	a := strings.Split(path, "/")
+	b := strings.Split(known, "/")
+	if len(a) < 3 || len(b) < 3 {
+		return false
+	}
+	return a[0] == b[0] && strings.EqualFold(a[2], b[2]) && !strings.EqualFold(a[1], b[1])
+}
+
+// stripMajor removes a /vN major version suffix from a module path.
+func stripMajor(path string) string {
+	if prefix, _, ok := module.SplitPathVersion(path); ok {
+		return prefix
+	}
+	return path
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	return strings.HasPrefix(path, prefix+"/")
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
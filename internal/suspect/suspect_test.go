@@ -0,0 +1,77 @@
+package suspect
+
+import (
+	"testing"
+
+	"github.com/anthr76/nopher/pkg/lockfile"
+)
+
+func TestCheck(t *testing.T) {
+	prev := lockfile.New("1.22")
+	prev.Modules["github.com/acme/widgets"] = lockfile.Module{Version: "v1.0.0"}
+
+	lf := lockfile.New("1.22")
+	for path, version := range map[string]string{
+		"github.com/acme/widgets":            "v1.0.0",                             // already locked
+		"github.com/stretchr/testify":        "v1.9.0",                             // popular module itself
+		"github.com/sirupsen/logrus/hooks":   "v1.0.0",                             // subpackage of popular module
+		"github.com/stretchr/testfiy":        "v1.9.0",                             // transposition
+		"github.com/Sirupsen/logrus":         "v1.0.0",                             // case confusion
+		"github.com/acme/widgetz":            "v1.0.1",                             // near-miss of locked module
+		"github.com/someone/cobra":           "v0.0.0-20240101000000-abcdefabcdef", // fork at pseudo-version
+		"github.com/someone/viper":           "v1.18.0",                            // fork at release: not flagged
+		"github.com/onsi/ginkgo/v2":          "v2.1.0",                             // major version of popular module
+		"github.com/allowed/testifx":         "v1.0.0",                             // near-miss but allowlisted
+		"golang.org/x/vuln":                  "v1.0.0",                             // unrelated
+		"github.com/gorilla/mux/middlewares": "v0.1.0",                             // subpackage
+	} {
+		lf.Modules[path] = lockfile.Module{Version: version}
+	}
+	lf.Replace["github.com/spf13/pflag"] = lockfile.Replace{New: "github.com/someone/pflag", Version: "v0.0.0-20240101000000-abcdefabcdef"}
+
+	got := make(map[string]Finding)
+	for _, f := range Check(lf, prev, []string{"github.com/allowed/*"}) {
+		got[f.Module+" "+f.Similar] = f
+	}
+
+	want := map[string]Kind{
+		"github.com/stretchr/testfiy github.com/stretchr/testify": NearMiss,
+		"github.com/Sirupsen/logrus github.com/sirupsen/logrus":   NearMiss,
+		"github.com/acme/widgetz github.com/acme/widgets":         NearMiss,
+		"github.com/someone/cobra github.com/spf13/cobra":         Fork,
+		"github.com/someone/pflag github.com/spf13/pflag":         Fork,
+	}
+	for key, kind := range want {
+		f, ok := got[key]
+		if !ok {
+			t.Errorf("missing finding %q", key)
+			continue
+		}
+		if f.Kind != kind {
+			t.Errorf("finding %q kind = %s, want %s", key, f.Kind, kind)
+		}
+	}
+	for key := range got {
+		if _, ok := want[key]; !ok {
+			t.Errorf("unexpected finding %q", key)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "abc", 0},
+		{"testify", "testfiy", 2},
+		{"logrus", "logrs", 1},
+		{"net", "text", 2},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNilTracer(t *testing.T) {
+	var tr *Tracer
+	span := tr.Start("fetch")
+	child := span.Child("download")
+	child.SetAttr("k", "v")
+	child.SetError(errors.New("boom"))
+	child.End()
+	span.End()
+	if err := tr.Shutdown(); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+}
+
+func TestNewFromEnvDisabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	if tr := NewFromEnv(); tr != nil {
+		t.Error("NewFromEnv() returned tracer with no endpoint configured")
+	}
+}
+
+func TestShutdownExportsSpans(t *testing.T) {
+	var got map[string]any
+	var path, auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding body: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", srv.URL)
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer x")
+
+	tr := NewFromEnv()
+	if tr == nil {
+		t.Fatal("NewFromEnv() = nil, want tracer")
+	}
+
+	span := tr.Start("fetch")
+	span.SetAttr("module.path", "example.com/foo")
+	child := span.Child("download")
+	child.End()
+	span.End()
+
+	if err := tr.Shutdown(); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	if path != "/v1/traces" {
+		t.Errorf("path = %q, want /v1/traces", path)
+	}
+	if auth != "Bearer x" {
+		t.Errorf("Authorization = %q, want %q", auth, "Bearer x")
+	}
+
+	rs := got["resourceSpans"].([]any)[0].(map[string]any)
+	ss := rs["scopeSpans"].([]any)[0].(map[string]any)
+	spans := ss["spans"].([]any)
+	if len(spans) != 2 {
+		t.Fatalf("len(spans) = %d, want 2", len(spans))
+	}
+	first := spans[0].(map[string]any)
+	second := spans[1].(map[string]any)
+	if first["name"] != "download" || first["parentSpanId"] != second["spanId"] {
+		t.Errorf("child span not linked to parent: %v / %v", first, second)
+	}
+}
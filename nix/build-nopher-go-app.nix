@@ -12,6 +12,17 @@
 #     src = ./.;
 #     modules = ./nopher.lock.yaml;
 #   }
+#
+# For a go.work workspace, build from the workspace root with one lockfile
+# and select a member with subPackages:
+#
+#   buildNopherGoApp {
+#     pname = "api";
+#     version = "1.0.0";
+#     src = ./.;
+#     modules = ./nopher.lock.yaml;
+#     subPackages = [ "./services/api/..." ];
+#   }
 
 { lib
 , stdenv
@@ -29,7 +40,8 @@ in
 { pname
 , version
 , src
-, # Path to nopher.lock.yaml
+, # Path to nopher.lock.yaml (or nopher.lock.yaml.gz, nopher.lock.toml, or
+  # builder JSON from nopher export --to builder-json)
   modules
 , # Go compiler (optional override)
   go ? defaultGo
@@ -58,17 +70,28 @@ in
 let
   nopherLib = import ./lib.nix { inherit lib; };
 
-  # Convert YAML lockfile to JSON at eval time using IFD
-  # This is necessary because Nix doesn't natively parse YAML
-  lockfileJson = builtins.fromJSON (builtins.readFile (
-    stdenv.mkDerivation {
-      name = "lockfile-json";
-      nativeBuildInputs = [ yj ];
-      buildCommand = ''
-        yj -yj < ${modules} > $out
-      '';
-    }
-  ));
+  # Builder JSON (*.json, from nopher export --to builder-json) and TOML
+  # lockfiles (*.toml) are parsed natively. YAML lockfiles are converted to
+  # JSON at eval time using IFD, because Nix doesn't natively parse YAML;
+  # gzip-compressed ones (*.yaml.gz) are decompressed first
+  lockfileJson = nopherLib.checkSchema (
+    if lib.hasSuffix ".json" (toString modules) then
+      nopherLib.fromBuilderJSON (builtins.fromJSON (builtins.readFile modules))
+    else if lib.hasSuffix ".toml" (toString modules) then
+      builtins.fromTOML (builtins.readFile modules)
+    else
+      builtins.fromJSON (builtins.readFile (
+        stdenv.mkDerivation {
+          name = "lockfile-json";
+          nativeBuildInputs = [ yj ];
+          buildCommand =
+            if lib.hasSuffix ".gz" (toString modules) then ''
+              gzip -dc ${modules} | yj -yj > $out
+            '' else ''
+              yj -yj < ${modules} > $out
+            '';
+        }
+      )));
 
   # Fetch each module
   fetchedModules = lib.mapAttrs
@@ -79,23 +102,51 @@ let
         hash = info.hash;
       } // lib.optionalAttrs (info ? url) {
         url = info.url;
+      } // lib.optionalAttrs (info ? urls) {
+        urls = info.urls;
       } // lib.optionalAttrs (info ? rev) {
         rev = info.rev;
+      } // lib.optionalAttrs (info ? subdir) {
+        subdir = info.subdir;
+      } // lib.optionalAttrs (info ? hashType) {
+        hashType = info.hashType;
+      } // lib.optionalAttrs (info ? fetcher) {
+        fetcher = info.fetcher;
       }))
     (lockfileJson.modules or { });
 
+  # Remote replacements sharing a new@version target only record the hash
+  # on the first entry (in key order); index those entries by target.
+  replaceTargets = lib.foldl'
+    (acc: info:
+      let target = "${info.new}@${info.version}";
+      in if acc ? ${target} then acc else acc // { ${target} = info; })
+    { }
+    (lib.filter (info: !(info ? path) && info ? hash)
+      (lib.attrValues (lockfileJson.replace or { })));
+
+  # Whether a replacement applies to version of the original module: a
+  # version-specific directive (match) only replaces that version
+  replaceApplies = info: version: !(info ? match) || info.match == version;
+
   # Fetch replacement modules
   fetchedReplaces = lib.mapAttrs
     (path: info:
       if info ? path then
         # Local replacement - will be handled separately
         null
+      else if !(info ? oldVersion) then
+        # Unused replacement - nothing to vendor
+        null
       else
-        fetchGoModule {
+        let target = if info ? hash then info else replaceTargets."${info.new}@${info.version}";
+        in fetchGoModule ({
           modulePath = info.new;
           version = info.version;
-          hash = info.hash;
-        })
+          hash = target.hash;
+        } // lib.filterAttrs
+          (name: _: lib.elem name [ "hashType" "url" "urls" "rev" "subdir" "fetcher" ])
+          target))
     (lockfileJson.replace or { });
 
   # Determine which module paths have children
@@ -172,12 +223,22 @@ let
         #!/bin/bash
         set -e
         (
-      '' + lib.concatStringsSep "\n" (lib.mapAttrsToList (path: info:
-        if (lockfileJson.replace or {}) ? ${path} then
+      '' + lib.optionalString (lockfileJson ? workspace) (''
+        echo "## workspace"
+      '' + lib.concatMapStringsSep "\n" (req:
+        let parts = lib.splitString "@" req;
+        in ''
+          echo "# ${lib.head parts} ${lib.last parts}"
+          echo "## explicit"
+        ''
+      ) (lib.unique (lib.concatMap (member: member.requires or [ ]) (lib.attrValues lockfileJson.workspace)))) + lib.concatStringsSep "\n" (lib.mapAttrsToList (path: info:
+        if (lockfileJson.replace or {}) ? ${path} && replaceApplies lockfileJson.replace.${path} info.version then
           ""
         else ''
           echo "# ${path} ${info.version}"
+        '' + lib.optionalString (!(info ? via)) ''
           echo "## explicit; go ${lockfileJson.go}"
+        '' + ''
           find -L "$out/${path}" -name '*.go' -print0 2>/dev/null | xargs -0 -n1 dirname 2>/dev/null | sort -u | while read -r pkg_dir; do
             pkg_path="''${pkg_dir#$out/}"
             echo "$pkg_path"
@@ -187,17 +248,21 @@ let
         if replaceInfo ? path then ""
         else
           let
-            origVersion = replaceInfo.oldVersion or (lockfileJson.modules.${origPath} or {}).version or "v0.0.0";
-            needsMarker = origPath != replaceInfo.new;
-          in ''
-            echo "# ${origPath} ${origVersion} => ${replaceInfo.new} ${replaceInfo.version}"
+            # oldVersion is the required version being replaced; it is
+            # absent when the replacement is unused. Like go mod vendor,
+            # unused and all-version replacements are also recorded on
+            # their own line, which go checks against go.mod.
+            used = replaceInfo ? oldVersion;
+            old = if replaceInfo ? match then "${origPath} ${replaceInfo.match}" else origPath;
+          in lib.optionalString used ''
+            echo "# ${origPath} ${replaceInfo.oldVersion} => ${replaceInfo.new} ${replaceInfo.version}"
             echo "## explicit; go ${lockfileJson.go}"
             find -L "$out/${origPath}" -name '*.go' -print0 2>/dev/null | xargs -0 -n1 dirname 2>/dev/null | sort -u | while read -r pkg_dir; do
               pkg_path="''${pkg_dir#$out/}"
               echo "$pkg_path"
             done
-          '' + lib.optionalString needsMarker ''
-            echo "# ${origPath} => ${replaceInfo.new} ${replaceInfo.version}"
+          '' + lib.optionalString (!used || !(replaceInfo ? match)) ''
+            echo "# ${old} => ${replaceInfo.new} ${replaceInfo.version}"
           ''
       ) (lockfileJson.replace or {})) + ''
         ) > "$out/modules.txt"
@@ -205,8 +270,11 @@ let
     '';
   };
 
-  # Build local replace paths for linking
-  localReplaces = lib.filterAttrs (path: info: info ? path) (lockfileJson.replace or { });
+  # Build local replace paths for linking. Pattern keys ("prefix/...")
+  # cover whole module trees and are expanded at configure time.
+  isPattern = lib.hasSuffix "/...";
+  localReplaces = lib.filterAttrs (path: info: info ? path && !isPattern path) (lockfileJson.replace or { });
+  patternReplaces = lib.filterAttrs (path: info: info ? path && isPattern path) (lockfileJson.replace or { });
 
   # Use provided go compiler
   goCompiler = go;
@@ -278,6 +346,27 @@ stdenv.mkDerivation (extraArgs // {
       fi
     '') localReplaces)}
 
+    # Link pattern replacements: every module found under the pattern's
+    # directory replaces the vendored module at the same relative path,
+    # unless an exact replacement already covers it
+    ${lib.concatStringsSep "\n" (lib.mapAttrsToList (pattern: info:
+      let
+        prefix = lib.removeSuffix "/..." pattern;
+        root = lib.removeSuffix "/..." info.path;
+        exact = lib.concatStringsSep " " (lib.attrNames localReplaces);
+      in ''
+        if [ -d "${root}" ]; then
+          find "${root}" -name go.mod -printf '%h\n' | sort | while read -r moddir; do
+            rel="''${moddir#${root}}"
+            modpath="${prefix}$rel"
+            case " ${exact} " in *" $modpath "*) continue ;; esac
+            [ -d "vendor/$modpath" ] || continue
+            rm -rf "vendor/$modpath"
+            cp -r "$moddir" "vendor/$modpath"
+          done
+        fi
+      '') patternReplaces)}
+
     runHook postConfigure
   '';
 
@@ -1,9 +1,12 @@
 # fetchGoModule - Fetch a single Go module by path and version
 #
 # This function fetches a Go module using the appropriate method:
-# - GitHub repos: Uses builtins.fetchGit (supports netrc authentication)
+# - GitHub repos: Uses builtins.fetchGit (supports netrc authentication),
+#   or fetchzip when the lockfile has no full rev and a NAR hash
+# - GitLab repos: Uses fetchzip on the GitLab API archive URL
 # - BSR modules: Uses fetchurlBoot
-# - Other modules: Uses proxy.golang.org
+# - Other modules: Uses proxy.golang.org, falling back to any other proxy
+#   mirrors recorded in the lockfile's urls list
 #
 # Usage:
 #   fetchGoModule {
@@ -11,12 +14,14 @@
 #     version = "v1.9.3";
 #     hash = "sha256-E5GnOMrWPCJLof4UFRJ9sLQKLpALbstsrqHmnWpnn5w=";
 #     url = "https://github.com/sirupsen/logrus/archive/refs/tags/v1.9.3.zip";
+#     subdir = null;  # e.g. "otlp/otlptrace" for nested modules
 #   }
 
 { lib
 , stdenv
 , stdenvNoCC
 , fetchurl
+, fetchzip
 , unzip
 }:
 
@@ -25,10 +30,21 @@
 , hash
 , # Optional: explicit URL from lockfile (preferred)
   url ? null
+, # Optional: every source of the same zip from the lockfile, url first
+  urls ? [ ]
 , # Optional: git commit hash (for fetchGit)
   rev ? null
+, # Optional: module directory within the repository (from the lockfile);
+  # when unset it is guessed from the module path
+  subdir ? null
+, # Optional: "nar" when hash covers the unpacked GitHub archive (fetchzip);
+  # unset when it covers the downloaded bytes (fetchurl)
+  hashType ? null
 , # Optional: override the proxy URL (fallback)
   proxy ? "https://proxy.golang.org"
+, # Optional: the fetcher nopher chose ("fetchGit", "fetchzip", or
+  # "fetchurl", from builder JSON); when unset it is inferred from url and rev
+  fetcher ? null
 }:
 
 let
@@ -36,9 +52,19 @@ let
 
   # Check if we have a GitHub archive URL with a full 40-char rev
   # fetchGit requires either a ref or a full 40-character rev to work in pure mode
-  # If rev is missing or truncated, fall back to fetchurl
+  # If rev is missing or truncated, fall back to fetchzip, or for lockfiles
+  # that locked the archive's raw bytes, to fetchurl
   hasFullRev = rev != null && (builtins.stringLength rev) == 40;
-  isGitHubArchiveURL = url != null && hasFullRev && lib.hasPrefix "https://github.com/" url && lib.hasInfix "/archive/" url;
+  isArchiveURL = url != null && lib.hasPrefix "https://github.com/" url && lib.hasInfix "/archive/" url;
+  # GitLab archives are fetched through the API, which names the project
+  # by its full path, subgroups included
+  isGitLabArchiveURL = url != null && lib.hasInfix "/api/v4/projects/" url
+    && lib.hasInfix "/repository/archive.zip?" url;
+  isGitHubArchiveURL =
+    if fetcher != null then fetcher == "fetchGit" else isArchiveURL && hasFullRev;
+  isZipArchive =
+    if fetcher != null then fetcher == "fetchzip"
+    else (isArchiveURL || isGitLabArchiveURL) && hashType == "nar";
 
   # Parse GitHub URL to extract repo info and ref/rev
   # URL formats:
@@ -68,16 +94,13 @@ let
       repoUrl = "https://github.com/${owner}/${repo}";
     };
 
-  # For GitHub modules with archive URLs, use fetchGit which supports netrc
+  # For GitHub modules with archive URLs, use fetchGit which supports netrc,
+  # or fetchzip without a full rev when the hash is a NAR hash, as for
+  # GitLab archives
   githubSrc =
     if isGitHubArchiveURL then
       let
         parsed = parseGitHubURL url;
-        # Extract subdir if the module path has more than owner/repo
-        pathParts = lib.splitString "/" modulePath;
-        subdir = if (lib.length pathParts) > 3
-                 then lib.concatStringsSep "/" (lib.drop 3 pathParts)
-                 else null;
 
         # If rev is short (< 40 chars), it's truncated and we can't use it with fetchGit
         # In that case, fall back to using ref only
@@ -91,6 +114,14 @@ let
         // lib.optionalAttrs (parsed.ref != null) { ref = parsed.ref; }
         // lib.optionalAttrs useRev { inherit rev; }  # Use rev only if it's full 40-char hash
       )
+    else if isZipArchive then
+      # The lockfile records the NAR hash of the unpacked archive, which
+      # unlike the archive's bytes survives the forge regenerating it with
+      # new timestamps or entry order. GitLab API URLs end in a query, so
+      # the archive type is given explicitly.
+      fetchzip ({
+        inherit url hash;
+      } // lib.optionalAttrs isGitLabArchiveURL { extension = "zip"; })
     else null;
 
   # For non-GitHub modules, use fetchurlBoot
@@ -112,10 +143,16 @@ let
     else
       "${proxy}/${escapedPath}/@v/${version}.zip";
 
+  # Sources serving the exact bytes of downloadURL. Origin archives in urls
+  # only match the hash after canonicalization, so fetchurl can't use them.
+  fallbackURLs = [ downloadURL ] ++ lib.filter
+    (u: u != downloadURL && !(lib.hasPrefix "https://github.com/" u && lib.hasInfix "/archive/" u))
+    urls;
+
   # Create a valid derivation name
   pname = nopherLib.modulePathToName modulePath;
 in
-# For GitHub repos, extract the module from the git checkout
+# For GitHub and GitLab repos, extract the module from the git checkout or archive
 if githubSrc != null then
   stdenvNoCC.mkDerivation {
     name = "${pname}-${version}";
@@ -132,9 +169,15 @@ if githubSrc != null then
       mkdir -p $out
 
       # Extract subdir if needed
-      ${let
+      ${if subdir != null then ''
+        shopt -s dotglob
+        cp -r ${subdir}/* $out/
+        shopt -u dotglob
+      '' else let
         pathParts = lib.splitString "/" modulePath;
-        subdir = if (lib.length pathParts) > 3
+        # GitLab projects may sit in subgroups, so the path doesn't tell
+        # where the repository ends; nopher records subdir for them
+        subdir = if (lib.length pathParts) > 3 && !isGitLabArchiveURL
                  then lib.concatStringsSep "/" (lib.drop 3 pathParts)
                  else "";
         subdirWithoutVersion = if subdir != "" then
@@ -188,7 +231,7 @@ if githubSrc != null then
 else
   # For non-GitHub modules
   # Use builtins.fetchurl for BSR (supports netrc via netrc-file config)
-  # Use fetchurlBoot for others
+  # Use fetchurl when there are mirrors to fall back to, fetchurlBoot otherwise
   stdenvNoCC.mkDerivation {
     name = "${pname}-${version}";
     inherit pname version;
@@ -198,6 +241,11 @@ else
         url = downloadURL;
         sha256 = lib.removePrefix "sha256-" hash;
       }
+    else if lib.length fallbackURLs > 1 then
+      fetchurl {
+        urls = fallbackURLs;
+        inherit hash;
+      }
     else
       stdenv.fetchurlBoot {
         url = downloadURL;
@@ -227,7 +275,11 @@ else
         # Find the extracted directory and move its contents
         for dir in */; do
           if [ -d "$dir" ]; then
-            cp -r "$dir"* $out/ 2>/dev/null || mv "$dir" $out/
+            ${if subdir != null then ''
+              cp -r "$dir${subdir}/." $out/
+            '' else ''
+              cp -r "$dir"* $out/ 2>/dev/null || mv "$dir" $out/
+            ''}
             break
           fi
         done
@@ -34,6 +34,47 @@ rec {
   parseLockfile = lockfilePath:
     builtins.fromJSON (builtins.readFile lockfilePath);
 
+  # Newest lockfile schema the builder reads (SchemaVersion in
+  # pkg/lockfile). Older schemas only lack fields, so they read as-is
+  lockfileSchema = 2;
+
+  # Return lockfile, or fail if nopher wrote it with a newer schema whose
+  # fields the builder would misread
+  checkSchema = lockfile:
+    if (lockfile.schema or 1) > lockfileSchema then
+      throw "lockfile schema ${toString lockfile.schema} is newer than buildNopherGoApp supports (${toString lockfileSchema}); update nopher"
+    else lockfile;
+
+  # Convert builder JSON (nopher export --to builder-json) to the lockfile
+  # structure: modules keyed by path, replacements keyed by original path
+  fromBuilderJSON = builder:
+    let
+      isReplace = m: m ? replace || m ? local;
+      fetchAttrs = m: lib.filterAttrs
+        (name: _: lib.elem name [ "fetcher" "hash" "url" "urls" "rev" "subdir" ])
+        m;
+      matchAttrs = m: lib.optionalAttrs (m ? match) { inherit (m) match; };
+      replaceInfo = m:
+        if m ? local then
+          { path = m.local; } // matchAttrs m
+        else
+          fetchAttrs m // matchAttrs m // {
+            new = m.replace.path;
+            version = m.replace.version;
+          } // lib.optionalAttrs (m ? version) { oldVersion = m.version; };
+    in
+    if builder.builderSchema or null != 1 then
+      throw "unsupported builderSchema ${toString (builder.builderSchema or "missing")}; update nopher"
+    else {
+      inherit (builder) go;
+      modules = lib.listToAttrs (map
+        (m: lib.nameValuePair m.path (builtins.removeAttrs m [ "path" ]))
+        (lib.filter (m: !isReplace m) builder.modules));
+      replace = lib.listToAttrs (map
+        (m: lib.nameValuePair m.path (replaceInfo m))
+        (lib.filter isReplace builder.modules));
+    } // lib.optionalAttrs (builder ? workspace) { inherit (builder) workspace; };
+
   # Check if a module path matches a GOPRIVATE pattern
   matchesPrivate = pattern: path:
     if lib.hasSuffix "/*" pattern then
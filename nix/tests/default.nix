@@ -69,4 +69,26 @@ in
     expr = nopherLib.isPrivate ["github.com/myorg/*"] "github.com/other/repo";
     expected = false;
   };
+
+  testFromBuilderJSON = {
+    expr = nopherLib.fromBuilderJSON {
+      builderSchema = 1;
+      go = "1.22";
+      modules = [
+        { path = "golang.org/x/sys"; version = "v0.15.0"; fetcher = "fetchurl"; hash = "sha256-sys"; }
+        { path = "github.com/original/pkg"; version = "v1.0.0"; replace = { path = "github.com/myorg/pkg-fork"; version = "v1.0.1"; }; fetcher = "fetchurl"; hash = "sha256-fork"; }
+        { path = "github.com/myorg/shared"; local = "./libs/shared"; }
+      ];
+    };
+    expected = {
+      go = "1.22";
+      modules = {
+        "golang.org/x/sys" = { version = "v0.15.0"; fetcher = "fetchurl"; hash = "sha256-sys"; };
+      };
+      replace = {
+        "github.com/original/pkg" = { new = "github.com/myorg/pkg-fork"; version = "v1.0.1"; oldVersion = "v1.0.0"; fetcher = "fetchurl"; hash = "sha256-fork"; };
+        "github.com/myorg/shared" = { path = "./libs/shared"; };
+      };
+    };
+  };
 }
@@ -0,0 +1,6 @@
+// Package daemonapi holds the protobuf definition of the API served by
+// nopher daemon, and the Go message types and gRPC client and server
+// generated from it.
+package daemonapi
+
+//go:generate buf generate
@@ -0,0 +1,51 @@
+package generator
+
+import "sync"
+
+// Cache remembers fetch results in memory across Generate calls, so a
+// long-running process fetches and hashes each module version once. Only
+// successful results are kept. A Cache must only be shared between
+// generations with the same fetch settings, since those can change a
+// module's recorded URL.
+type Cache struct {
+	mu      sync.Mutex
+	results map[string]FetchResult
+}
+
+// NewCache creates an empty cache.
+func NewCache() *Cache {
+	return &Cache{results: make(map[string]FetchResult)}
+}
+
+// Len returns the number of cached module versions.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.results)
+}
+
+// wrap returns a FetchFunc that answers from c when it can, and otherwise
+// calls fetchModule and remembers its result. Cached results are reported
+// as cache hits without download statistics.
+func (c *Cache) wrap(fetchModule FetchFunc) FetchFunc {
+	return func(modulePath, version string) (*FetchResult, error) {
+		key := moduleKey(modulePath, version)
+
+		c.mu.Lock()
+		cached, ok := c.results[key]
+		c.mu.Unlock()
+		if ok {
+			cached.Bytes, cached.CacheHit, cached.Retries = 0, true, 0
+			return &cached, nil
+		}
+
+		result, err := fetchModule(modulePath, version)
+		if err != nil || result == nil {
+			return result, err
+		}
+		c.mu.Lock()
+		c.results[key] = *result
+		c.mu.Unlock()
+		return result, nil
+	}
+}
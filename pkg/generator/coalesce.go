@@ -0,0 +1,37 @@
+package generator
+
+import "sync"
+
+// fetchCall is one fetch of a module version, possibly still in flight.
+type fetchCall struct {
+	done   chan struct{}
+	result *FetchResult
+	err    error
+}
+
+// coalesce returns a FetchFunc that fetches each module version at most once
+// per generation. A module can be reached more than once, for example as
+// both a requirement and a replacement target; concurrent callers wait for
+// the in-flight fetch, and later callers get its result.
+func coalesce(fetchModule FetchFunc) FetchFunc {
+	var mu sync.Mutex
+	calls := make(map[string]*fetchCall)
+
+	return func(modulePath, version string) (*FetchResult, error) {
+		key := moduleKey(modulePath, version)
+
+		mu.Lock()
+		if c, ok := calls[key]; ok {
+			mu.Unlock()
+			<-c.done
+			return c.result, c.err
+		}
+		c := &fetchCall{done: make(chan struct{})}
+		calls[key] = c
+		mu.Unlock()
+
+		c.result, c.err = fetchModule(modulePath, version)
+		close(c.done)
+		return c.result, c.err
+	}
+}
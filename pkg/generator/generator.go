@@ -2,19 +2,51 @@
 package generator
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/anthr76/nopher/internal/fetch"
 	"github.com/anthr76/nopher/internal/mod"
 	"github.com/anthr76/nopher/pkg/lockfile"
+	"golang.org/x/mod/module"
 )
 
 // FetchResult contains the lockfile-relevant metadata for a fetched module.
 type FetchResult struct {
-	Hash string
-	URL  string
-	Rev  string
+	Hash     string
+	HashType string // lockfile.HashNAR when Hash covers an unpacked archive tree
+	URL      string
+	URLs     []string // Every known source of the zip, URL first; nil if URL is the only one
+	Rev      string
+	Subdir   string // Module directory within the repository, if not the root
+
+	// ModulePath is the path declared by the module's go.mod, if known.
+	// When set it is checked against the expected module path.
+	ModulePath string
+
+	// Dir is the extracted module directory, if available. It is required
+	// when PostFetch hooks are configured.
+	Dir string
+
+	// Replaces lists the replace directives in the module's own go.mod,
+	// as old => new. go ignores them outside the main module.
+	Replaces []string
+
+	// Size and Files describe the extracted module contents.
+	Size  int64
+	Files int
+
+	// Download statistics, used for metrics reporting.
+	Bytes    int64
+	CacheHit bool
+	Retries  int
 }
 
 // FetchFunc fetches metadata for a single module version.
@@ -26,80 +58,265 @@ type Options struct {
 	Verbose bool
 	// Fetch overrides module fetching. When nil, generator uses nopher's default fetcher.
 	Fetch FetchFunc
+	// Metrics collects per-module fetch statistics when non-nil.
+	Metrics *Metrics
+	// Progress, when non-nil, is called as each module is started,
+	// downloaded, hashed, or fails to fetch. It may be called from several
+	// goroutines at once.
+	Progress func(Event)
+	// Cache, when non-nil, answers repeated fetches of a module version
+	// from memory across Generate calls. PostFetch hooks still run for
+	// every module.
+	Cache *Cache
+	// Meta is recorded in the lockfile's meta block when non-nil.
+	Meta *lockfile.Meta
+	// Graph embeds module requirement edges from go mod graph (requires go).
+	Graph bool
+	// Profile selects the lockfile name (nopher.<profile>.lock.yaml).
+	// Empty uses nopher.lock.yaml.
+	Profile string
+	// TrustGoSum resolves hashes from the local Go module cache when the cached
+	// zip matches its go.sum h1 hash, skipping the download. Modules that need
+	// a git rev, or are missing from the cache, are fetched normally. Only
+	// applies to the default fetcher.
+	TrustGoSum bool
+	// GoproxyDump is a GOPROXY storage dump in the Athens disk layout that
+	// every module is taken from instead of the network, checked against
+	// its go.sum hashes. Modules missing from the dump, or without go.sum
+	// hashes, fail generation. The dump's zips are what the proxy serves,
+	// so the lockfile records proxy URLs. Only applies to the default
+	// fetcher.
+	GoproxyDump string
+	// Strict fails on modules whose source URL would be a heuristic guess.
+	// Only applies to the default fetcher.
+	Strict bool
+	// URLOverrides maps module paths or patterns to download URL templates.
+	// Only applies to the default fetcher.
+	URLOverrides map[string]string
+	// Signers sign requests to the given hosts (host or host:port), for
+	// proxies that require request signing. Only applies to the default
+	// fetcher.
+	Signers map[string]func(*http.Request) error
+	// GitHubApp authenticates GitHub requests, and the git and go commands
+	// run while fetching, as a GitHub App installation. Only applies to the
+	// default fetcher.
+	GitHubApp *fetch.GitHubApp
+	// GitLabHosts lists self-hosted GitLab hosts, which are sent
+	// GITLAB_TOKEN. Only applies to the default fetcher.
+	GitLabHosts []string
+	// Mirrors maps module paths or patterns to equivalent module proxies.
+	// The first proxy is recorded in lockfile URLs; downloads fail over to
+	// the others. Only applies to the default fetcher.
+	Mirrors map[string][]string
+	// CacheDir overrides the directory the default fetcher caches modules
+	// in. Empty uses the user cache directory.
+	CacheDir string
+	// CachePerm is the permission of the default fetcher's cache files.
+	// Zero uses fetch.DefaultCachePerm.
+	CachePerm os.FileMode
+	// CacheKey authenticates the default fetcher's cache entries with
+	// HMAC-SHA256, for caches shared between users.
+	CacheKey []byte
+	// ReadOnlyCache uses only modules already in the default fetcher's
+	// cache, writing nothing to it. Generate fails listing every module
+	// that would have had to be downloaded.
+	ReadOnlyCache bool
+	// Symlinks controls how symlinks in GitHub archives are extracted.
+	// Only applies to the default fetcher.
+	Symlinks fetch.SymlinkPolicy
+	// Network configures DNS overrides and dual-stack dialing. Only applies
+	// to the default fetcher.
+	Network fetch.Network
+	// FetchLog is the path of a JSONL log every downloaded artifact is
+	// appended to. Only applies to the default fetcher.
+	FetchLog string
+	// Direct fetches every module from its origin, ignoring GOPROXY and
+	// Mirrors. Only applies to the default fetcher.
+	Direct bool
+	// PostFetch hooks run for every fetched module, in order, before it is
+	// recorded. With the default fetcher, setting hooks disables the
+	// TrustGoSum fast path so every module is extracted.
+	PostFetch []PostFetchHook
+	// SkipMissingSums leaves requirements without any go.sum entry out of
+	// the lockfile. By default Generate fails on them, since go cannot build
+	// a module it has no checksum for; this usually means go mod tidy has
+	// not been run.
+	SkipMissingSums bool
+	// Permit is called for every module version that would be fetched,
+	// before anything is fetched. If it rejects any module, Generate fails
+	// listing every rejected module.
+	Permit func(modulePath, version string) error
+	// Check is called with the generated lockfile before GenerateAndSave
+	// writes it. Returning an error aborts the save.
+	Check func(*lockfile.Lockfile) error
+	// Compress writes the gzip lockfile variant (nopher.lock.yaml.gz) and
+	// removes any plaintext lockfile it replaces.
+	Compress bool
+	// Format is the encoding GenerateAndSave writes, removing a lockfile
+	// in the other encoding. Empty keeps the existing lockfile's encoding,
+	// and is YAML for a new lockfile.
+	Format lockfile.Format
+	// Only and Skip are comma-separated GOPRIVATE-style module path
+	// patterns limiting which modules are fetched: those matching Only, if
+	// set, and not matching Skip. Other modules keep their entry from the
+	// existing lockfile when it locks the same version, and are otherwise
+	// left out with a warning. Replacements are matched by original path.
+	Only string
+	Skip string
 }
 
 // Generate creates a lockfile from go.mod and go.sum in dir without writing it.
+// If dir is the root of a go.work workspace, the lockfile covers the merged
+// dependencies of every member. Review annotations and quarantine entries
+// from an existing lockfile in dir are carried forward.
 func Generate(dir string, opts Options) (*lockfile.Lockfile, error) {
 	if dir == "" {
 		dir = "."
 	}
 
-	goModPath := filepath.Join(dir, "go.mod")
-	modInfo, err := mod.ParseGoMod(goModPath)
+	set, err := loadModuleSet(dir)
 	if err != nil {
-		return nil, fmt.Errorf("parsing go.mod: %w", err)
+		return nil, err
 	}
+	modInfo := set.info
 
-	goSumPath := filepath.Join(dir, "go.sum")
-	sumEntriesList, err := mod.ParseGoSum(goSumPath)
-	if err != nil {
-		return nil, fmt.Errorf("parsing go.sum: %w", err)
-	}
+	sumEntries := set.sumEntries()
 
-	sumEntries := make(map[string]bool)
-	for _, entry := range sumEntriesList {
-		sumEntries[moduleKey(entry.Path, entry.Version)] = true
+	if !opts.SkipMissingSums {
+		if err := checkSums(modInfo, sumEntries); err != nil {
+			return nil, err
+		}
 	}
 
-	goModOnlyEntries, err := mod.ParseGoSumModOnly(goSumPath)
-	if err != nil {
-		return nil, fmt.Errorf("parsing go.sum for go.mod entries: %w", err)
+	if opts.Permit != nil {
+		if err := permitAll(modInfo, sumEntries, opts.Permit); err != nil {
+			return nil, err
+		}
 	}
-	for _, entry := range goModOnlyEntries {
-		sumEntries[moduleKey(entry.Path, entry.Version)] = true
+
+	sums := mod.SumMap(set.sums)
+
+	if opts.Progress != nil {
+		// coalesce fetches a module version reached twice only once.
+		fetched := make(map[string]bool)
+		for _, t := range fetchTargets(modInfo, sumEntries) {
+			if (t.Replaces != "" && selected(opts, t.Replaces)) || (t.Replaces == "" && selected(opts, t.Path)) {
+				fetched[moduleKey(t.Path, t.Version)] = true
+			}
+		}
+		opts.Progress = withTotal(opts.Progress, len(fetched))
 	}
 
-	fetchModule, err := fetchFunc(opts)
+	fetchModule, closeFetcher, err := fetchFunc(opts, sums)
 	if err != nil {
 		return nil, err
 	}
+	defer closeFetcher()
+
+	if opts.Cache != nil {
+		fetchModule = opts.Cache.wrap(fetchModule)
+	}
+	if len(opts.PostFetch) > 0 {
+		fetchModule = withHooks(fetchModule, opts.PostFetch)
+	}
+	if opts.Metrics != nil {
+		fetchModule = timedFetch(fetchModule, opts.Metrics)
+	}
+	if opts.Progress != nil {
+		fetchModule = withProgress(fetchModule, opts.Progress)
+	}
+	fetchModule = coalesce(fetchModule)
 
 	lf := lockfile.New(modInfo.GoVersion)
+	lf.Meta = opts.Meta
+	lf.Workspace = set.workspace
+
+	// The previous lockfile supplies the entries of unselected modules and
+	// the annotations carried over. Without --only or --skip nothing is lost
+	// by regenerating over an unreadable one, but with them every unselected
+	// module would be dropped.
+	prev, err := lockfile.Load(lockfile.Path(dir, opts.Profile))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		prev = lockfile.New("")
+	case err != nil && (opts.Only != "" || opts.Skip != ""):
+		return nil, fmt.Errorf("loading previous lockfile: %w", err)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "warning: regenerating over an unreadable lockfile; annotations are not carried over: %v\n", err)
+		prev = lockfile.New("")
+	}
+	var omitted []string
+	// Modules missing from a read-only cache are collected so they can
+	// all be reported, rather than failing on the first.
+	var uncached []string
 
 	requireMap := make(map[string]string)
 	for _, req := range modInfo.Requires {
 		requireMap[req.Path] = req.Version
 	}
 
+	replaced := make(map[string]mod.Replace)
 	for _, rep := range modInfo.Replaces {
+		replaced[rep.Old] = rep
 		if rep.IsLocal {
 			lf.Replace[rep.Old] = lockfile.Replace{
-				Path: rep.New,
+				Match: rep.OldVersion,
+				Path:  rep.New,
+			}
+			continue
+		}
+
+		// A replacement of a module go.mod does not require, or of a
+		// version other than the required one, is unused: go records it in
+		// vendor/modules.txt but the original module is not replaced.
+		var oldVersion string
+		if required, ok := requireMap[rep.Old]; ok && rep.Applies(required) {
+			oldVersion = required
+		}
+
+		if !selected(opts, rep.Old) {
+			r, ok := prev.Replace[rep.Old]
+			if !ok || r.Path != "" || r.New != rep.New || r.Version != rep.NewVersion || r.Hash == "" {
+				omitted = append(omitted, moduleKey(rep.New, rep.NewVersion))
+				continue
 			}
+			r.Old, r.OldVersion, r.Match = "", oldVersion, rep.OldVersion
+			r.Sum = sums[moduleKey(rep.New, rep.NewVersion)]
+			lf.Replace[rep.Old] = r
 			continue
 		}
 
 		result, err := fetchModule(rep.New, rep.NewVersion)
+		if errors.Is(err, fetch.ErrNotCached) {
+			uncached = append(uncached, moduleKey(rep.New, rep.NewVersion))
+			continue
+		}
 		if err != nil {
 			return nil, fmt.Errorf("fetching replacement %s@%s: %w", rep.New, rep.NewVersion, err)
 		}
 		if result == nil {
 			return nil, fmt.Errorf("fetching replacement %s@%s: no result", rep.New, rep.NewVersion)
 		}
-
-		oldVersion := rep.OldVersion
-		if oldVersion == "" {
-			oldVersion = requireMap[rep.Old]
+		if err := fetch.CheckModulePath(result.ModulePath, rep.Old, rep.New); err != nil {
+			return nil, fmt.Errorf("fetching replacement %s@%s: %w", rep.New, rep.NewVersion, err)
 		}
 
 		lf.Replace[rep.Old] = lockfile.Replace{
-			Old:        rep.Old,
 			OldVersion: oldVersion,
+			Match:      rep.OldVersion,
 			New:        rep.New,
 			Version:    rep.NewVersion,
 			Hash:       result.Hash,
+			HashType:   result.HashType,
 			URL:        result.URL,
+			URLs:       result.URLs,
 			Rev:        result.Rev,
+			Subdir:     result.Subdir,
+			Size:       result.Size,
+			Files:      result.Files,
+			Sum:        sums[moduleKey(rep.New, rep.NewVersion)],
+
+			GoModReplace: result.Replaces,
 		}
 	}
 
@@ -107,7 +324,7 @@ func Generate(dir string, opts Options) (*lockfile.Lockfile, error) {
 		modulePath := req.Path
 		moduleVersion := req.Version
 
-		if _, ok := lf.Replace[modulePath]; ok {
+		if r, ok := replaced[modulePath]; ok && r.Applies(moduleVersion) {
 			continue
 		}
 
@@ -115,66 +332,324 @@ func Generate(dir string, opts Options) (*lockfile.Lockfile, error) {
 			continue
 		}
 
+		if !selected(opts, modulePath) {
+			m, ok := prev.Modules[modulePath]
+			if !ok || m.Version != moduleVersion {
+				omitted = append(omitted, moduleKey(modulePath, moduleVersion))
+				continue
+			}
+			m.Sum = sums[moduleKey(modulePath, moduleVersion)]
+			m.Via = set.via[modulePath]
+			lf.Modules[modulePath] = m
+			continue
+		}
+
 		result, err := fetchModule(modulePath, moduleVersion)
+		if errors.Is(err, fetch.ErrNotCached) {
+			uncached = append(uncached, moduleKey(modulePath, moduleVersion))
+			continue
+		}
 		if err != nil {
 			return nil, fmt.Errorf("fetching %s@%s: %w", modulePath, moduleVersion, err)
 		}
 		if result == nil {
 			return nil, fmt.Errorf("fetching %s@%s: no result", modulePath, moduleVersion)
 		}
+		if err := fetch.CheckModulePath(result.ModulePath, modulePath); err != nil {
+			return nil, fmt.Errorf("fetching %s@%s: %w", modulePath, moduleVersion, err)
+		}
 
 		lf.Modules[modulePath] = lockfile.Module{
-			Version: moduleVersion,
-			Hash:    result.Hash,
-			URL:     result.URL,
-			Rev:     result.Rev,
+			Version:  moduleVersion,
+			Hash:     result.Hash,
+			HashType: result.HashType,
+			URL:      result.URL,
+			URLs:     result.URLs,
+			Rev:      result.Rev,
+			Subdir:   result.Subdir,
+			Size:     result.Size,
+			Files:    result.Files,
+			Sum:      sums[moduleKey(modulePath, moduleVersion)],
+			Via:      set.via[modulePath],
+
+			GoModReplace: result.Replaces,
+		}
+	}
+	if len(uncached) > 0 {
+		sort.Strings(uncached)
+		return nil, fmt.Errorf("%d module(s) would have to be fetched, but the cache is read-only:\n  %s", len(uncached), strings.Join(uncached, "\n  "))
+	}
+
+	if opts.Graph {
+		graph, err := mod.ModGraph(dir)
+		if err != nil {
+			return nil, fmt.Errorf("building module graph: %w", err)
 		}
+		lf.Graph = graph
+	}
+
+	lf.CarryAnnotations(prev)
+	lf.CarryPatterns(prev)
+	lf.CarryQuarantine(prev)
+
+	if len(omitted) > 0 {
+		sort.Strings(omitted)
+		fmt.Fprintf(os.Stderr, "warning: lockfile is incomplete; %d module(s) were not selected and have no entry to keep:\n  %s\n",
+			len(omitted), strings.Join(omitted, "\n  "))
 	}
 
 	return lf, nil
 }
 
+// selected reports whether modulePath is fetched under opts.Only and
+// opts.Skip.
+func selected(opts Options, modulePath string) bool {
+	if opts.Only != "" && !module.MatchPrefixPatterns(opts.Only, modulePath) {
+		return false
+	}
+	return opts.Skip == "" || !module.MatchPrefixPatterns(opts.Skip, modulePath)
+}
+
+// checkSums fails listing every requirement Generate would lock that has
+// no go.sum entry, not even for its go.mod.
+func checkSums(modInfo *mod.ModInfo, sumEntries map[string]bool) error {
+	replaced := make(map[string]mod.Replace)
+	for _, rep := range modInfo.Replaces {
+		replaced[rep.Old] = rep
+	}
+	var missing []string
+	for _, req := range modInfo.Requires {
+		if rep, ok := replaced[req.Path]; ok && rep.Applies(req.Version) {
+			continue
+		}
+		if !sumEntries[moduleKey(req.Path, req.Version)] {
+			missing = append(missing, moduleKey(req.Path, req.Version))
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("%d module(s) have no go.sum entry (run go mod tidy):\n  %s", len(missing), strings.Join(missing, "\n  "))
+}
+
+// permitAll calls permit for every module Generate would fetch.
+func permitAll(modInfo *mod.ModInfo, sumEntries map[string]bool, permit func(modulePath, version string) error) error {
+	var denied []string
+	for _, t := range fetchTargets(modInfo, sumEntries) {
+		err := permit(t.Path, t.Version)
+		switch {
+		case err == nil:
+		case t.Replaces != "":
+			denied = append(denied, fmt.Sprintf("%v (replacing %s)", err, t.Replaces))
+		default:
+			denied = append(denied, err.Error())
+		}
+	}
+	if len(denied) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d module(s) blocked by policy:\n  %s", len(denied), strings.Join(denied, "\n  "))
+}
+
+// target is a module Generate fetches.
+type target struct {
+	Path     string
+	Version  string
+	Replaces string // module path replaced by Path, for replacement targets
+}
+
+// fetchTargets lists the modules Generate would fetch, in order: non-local
+// replacement targets and the go.sum-listed requirements they don't replace.
+func fetchTargets(modInfo *mod.ModInfo, sumEntries map[string]bool) []target {
+	replaced := make(map[string]mod.Replace)
+	var targets []target
+	for _, rep := range modInfo.Replaces {
+		replaced[rep.Old] = rep
+		if !rep.IsLocal {
+			targets = append(targets, target{Path: rep.New, Version: rep.NewVersion, Replaces: rep.Old})
+		}
+	}
+	for _, req := range modInfo.Requires {
+		if rep, ok := replaced[req.Path]; ok && rep.Applies(req.Version) {
+			continue
+		}
+		if sumEntries[moduleKey(req.Path, req.Version)] {
+			targets = append(targets, target{Path: req.Path, Version: req.Version})
+		}
+	}
+	return targets
+}
+
 // GenerateAndSave creates a lockfile from go.mod and go.sum in dir and writes it
-// to nopher.lock.yaml.
+// to nopher.lock.yaml, or nopher.<profile>.lock.yaml when opts.Profile is set,
+// in the encoding opts.Format and opts.Compress select.
 func GenerateAndSave(dir string, opts Options) (*lockfile.Lockfile, error) {
+	if opts.Compress && opts.Format == lockfile.FormatTOML {
+		return nil, errors.New("compressed lockfiles must be YAML")
+	}
 	lf, err := Generate(dir, opts)
 	if err != nil {
 		return nil, err
 	}
+	if opts.Check != nil {
+		if err := opts.Check(lf); err != nil {
+			return nil, err
+		}
+	}
 
 	if dir == "" {
 		dir = "."
 	}
-	if err := lf.Save(dir); err != nil {
+	stale := lockfile.Path(dir, opts.Profile)
+	path := stale
+	switch {
+	case opts.Format == lockfile.FormatTOML:
+		path = filepath.Join(dir, lockfile.TOMLFilename(opts.Profile))
+	case opts.Format == lockfile.FormatYAML || opts.Compress:
+		if lockfile.FormatOf(path) == lockfile.FormatTOML {
+			path = filepath.Join(dir, lockfile.Filename(opts.Profile))
+		}
+	}
+	if opts.Compress && !strings.HasSuffix(path, lockfile.CompressedSuffix) {
+		path += lockfile.CompressedSuffix
+	}
+	if err := lf.SaveAs(path); err != nil {
 		return nil, fmt.Errorf("saving lockfile: %w", err)
 	}
+	if stale != path {
+		if err := os.Remove(stale); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("removing previous lockfile: %w", err)
+		}
+	}
 
 	return lf, nil
 }
 
-func fetchFunc(opts Options) (FetchFunc, error) {
+// FetchModule fetches a single module version the way Generate would,
+// with opts.Fetch or the default fetcher, through opts.Cache if set.
+// PostFetch hooks are not run.
+func FetchModule(opts Options, modulePath, version string) (*FetchResult, error) {
+	fetchModule, closeFetcher, err := fetchFunc(opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFetcher()
+
+	if opts.Cache != nil {
+		fetchModule = opts.Cache.wrap(fetchModule)
+	}
+	return fetchModule(modulePath, version)
+}
+
+// fetchFunc returns the module fetch function for opts along with a cleanup
+// function that flushes fetcher telemetry. sums maps path@version to go.sum
+// h1 hashes for the TrustGoSum fast path and GoproxyDump.
+func fetchFunc(opts Options, sums map[string]string) (FetchFunc, func(), error) {
 	if opts.Fetch != nil {
-		return opts.Fetch, nil
+		return opts.Fetch, func() {}, nil
 	}
 
-	fetcher, err := fetch.NewFetcher()
+	fetcher, err := newFetcher(opts)
 	if err != nil {
-		return nil, fmt.Errorf("creating fetcher: %w", err)
+		return nil, nil, err
 	}
-	fetcher.Verbose = opts.Verbose
 
-	return func(modulePath, version string) (*FetchResult, error) {
-		result, err := fetcher.Fetch(modulePath, version)
+	fetchModule := func(modulePath, version string) (*FetchResult, error) {
+		var result *fetch.FetchResult
+		var err error
+		if opts.GoproxyDump != "" {
+			result, err = fetcher.FetchFromDump(opts.GoproxyDump, modulePath, version, sums[moduleKey(modulePath, version)])
+			if err != nil {
+				return nil, err
+			}
+		} else if opts.TrustGoSum && len(opts.PostFetch) == 0 {
+			result, err = fetcher.FetchFromModCache(modulePath, version, sums[moduleKey(modulePath, version)])
+			if err != nil && !errors.Is(err, fetch.ErrNotInModCache) && opts.Verbose {
+				fmt.Fprintf(os.Stderr, "warning: %v; fetching instead\n", err)
+			}
+		}
+		if result == nil {
+			result, err = fetcher.Fetch(modulePath, version)
+		}
 		if err != nil {
 			return nil, err
 		}
 
 		return &FetchResult{
-			Hash: result.Hash,
-			URL:  result.URL,
-			Rev:  result.Rev,
+			Hash:       result.Hash,
+			HashType:   result.HashType,
+			URL:        result.URL,
+			URLs:       result.URLs,
+			Rev:        result.Rev,
+			Subdir:     result.Subdir,
+			ModulePath: result.ModFile,
+			Replaces:   result.Replaces,
+			Dir:        result.Dir,
+			Size:       result.Size,
+			Files:      result.Files,
+			Bytes:      result.Bytes,
+			CacheHit:   result.CacheHit,
+			Retries:    result.Retries,
 		}, nil
-	}, nil
+	}
+
+	closeFetcher := func() {
+		if err := fetcher.Close(); err != nil && opts.Verbose {
+			fmt.Fprintf(os.Stderr, "warning: failed to export traces: %v\n", err)
+		}
+	}
+
+	return fetchModule, closeFetcher, nil
+}
+
+// newFetcher returns a fetcher configured from opts.
+func newFetcher(opts Options) (*fetch.Fetcher, error) {
+	fetcher, err := fetch.NewFetcher()
+	if err != nil {
+		return nil, fmt.Errorf("creating fetcher: %w", err)
+	}
+	fetcher.Verbose = opts.Verbose
+	fetcher.Strict = opts.Strict
+	fetcher.URLOverrides = opts.URLOverrides
+	fetcher.Mirrors = opts.Mirrors
+	fetcher.Symlinks = opts.Symlinks
+	fetcher.Network = opts.Network
+	fetcher.FetchLog = opts.FetchLog
+	fetcher.GitHubApp = opts.GitHubApp
+	fetcher.GitLabHosts = opts.GitLabHosts
+	fetcher.CachePerm = opts.CachePerm
+	fetcher.CacheKey = opts.CacheKey
+	fetcher.ReadOnlyCache = opts.ReadOnlyCache
+	if opts.Progress != nil {
+		fetcher.OnDownload = func(modulePath, version, url string, size int64) {
+			opts.Progress(Event{Kind: EventDownloaded, Path: modulePath, Version: version, URL: url, Size: size})
+		}
+	}
+	if opts.CacheDir != "" {
+		fetcher.CacheDir = opts.CacheDir
+	}
+	if opts.Direct {
+		fetcher.Proxy = ""
+		fetcher.Mirrors = nil
+	}
+	if len(opts.Signers) > 0 {
+		fetcher.Signers = make(map[string]fetch.Signer, len(opts.Signers))
+		for host, sign := range opts.Signers {
+			fetcher.Signers[host] = fetch.SignerFunc(sign)
+		}
+	}
+	return fetcher, nil
+}
+
+// timedFetch wraps fetchModule so every call is recorded in metrics.
+func timedFetch(fetchModule FetchFunc, metrics *Metrics) FetchFunc {
+	return func(modulePath, version string) (*FetchResult, error) {
+		start := time.Now()
+		result, err := fetchModule(modulePath, version)
+		metrics.record(modulePath, version, time.Since(start), result, err)
+		return result, err
+	}
 }
 
 func moduleKey(path, version string) string {
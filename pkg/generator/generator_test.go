@@ -0,0 +1,655 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/anthr76/nopher/internal/fetch"
+	"github.com/anthr76/nopher/pkg/lockfile"
+)
+
+// writeSyntheticProject writes a go.mod and go.sum requiring n fake modules.
+func writeSyntheticProject(tb testing.TB, dir string, n int) {
+	tb.Helper()
+
+	var goMod, goSum strings.Builder
+	goMod.WriteString("module example.com/synthetic\n\ngo 1.22\n\nrequire (\n")
+	for i := 0; i < n; i++ {
+		path := fmt.Sprintf("example.com/dep%03d", i)
+		fmt.Fprintf(&goMod, "\t%s v1.0.%d\n", path, i)
+		fmt.Fprintf(&goSum, "%s v1.0.%d h1:abcd%d=\n", path, i, i)
+		fmt.Fprintf(&goSum, "%s v1.0.%d/go.mod h1:efgh%d=\n", path, i, i)
+	}
+	goMod.WriteString(")\n")
+
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod.String()), 0o644); err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.sum"), []byte(goSum.String()), 0o644); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func stubFetch(modulePath, version string) (*FetchResult, error) {
+	return &FetchResult{
+		Hash: "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+		URL:  "https://proxy.golang.org/" + modulePath + "/@v/" + version + ".zip",
+	}, nil
+}
+
+func TestGenerateSynthetic(t *testing.T) {
+	dir := t.TempDir()
+	writeSyntheticProject(t, dir, 10)
+
+	lf, err := Generate(dir, Options{Fetch: stubFetch})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(lf.Modules) != 10 {
+		t.Errorf("len(Modules) = %d, want 10", len(lf.Modules))
+	}
+	if lf.Go != "1.22" {
+		t.Errorf("Go = %q, want %q", lf.Go, "1.22")
+	}
+}
+
+func BenchmarkGenerate500(b *testing.B) {
+	dir := b.TempDir()
+	writeSyntheticProject(b, dir, 500)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := Generate(dir, Options{Fetch: stubFetch}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestGenerateMetrics(t *testing.T) {
+	dir := t.TempDir()
+	writeSyntheticProject(t, dir, 3)
+
+	metrics := NewMetrics()
+	fetch := func(modulePath, version string) (*FetchResult, error) {
+		r, _ := stubFetch(modulePath, version)
+		r.Bytes = 100
+		r.CacheHit = modulePath == "example.com/dep000"
+		return r, nil
+	}
+
+	if _, err := Generate(dir, Options{Fetch: fetch, Metrics: metrics}); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	out := filepath.Join(dir, "metrics.json")
+	if err := metrics.WriteFile(out); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report MetricsReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("unmarshaling report: %v", err)
+	}
+	if report.Modules != 3 {
+		t.Errorf("Modules = %d, want 3", report.Modules)
+	}
+	if report.Bytes != 300 {
+		t.Errorf("Bytes = %d, want 300", report.Bytes)
+	}
+	if report.CacheHits != 1 {
+		t.Errorf("CacheHits = %d, want 1", report.CacheHits)
+	}
+	if report.PerModule[0].Path != "example.com/dep000" {
+		t.Errorf("PerModule not sorted: first = %q", report.PerModule[0].Path)
+	}
+}
+
+func TestGeneratePostFetchHooks(t *testing.T) {
+	dir := t.TempDir()
+	writeSyntheticProject(t, dir, 3)
+
+	fetch := func(modulePath, version string) (*FetchResult, error) {
+		r, _ := stubFetch(modulePath, version)
+		r.Dir = "/modules/" + modulePath
+		return r, nil
+	}
+
+	var seen []string
+	record := func(modulePath, version, moduleDir string) error {
+		seen = append(seen, modulePath+"@"+version+" "+moduleDir)
+		return nil
+	}
+
+	if _, err := Generate(dir, Options{Fetch: fetch, PostFetch: []PostFetchHook{record}}); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(seen) != 3 {
+		t.Fatalf("hook called %d times, want 3: %v", len(seen), seen)
+	}
+	if want := "example.com/dep000@v1.0.0 /modules/example.com/dep000"; seen[0] != want {
+		t.Errorf("first hook call = %q, want %q", seen[0], want)
+	}
+
+	reject := func(modulePath, version, moduleDir string) error {
+		if modulePath == "example.com/dep001" {
+			return fmt.Errorf("flagged by scanner")
+		}
+		return nil
+	}
+	_, err := Generate(dir, Options{Fetch: fetch, PostFetch: []PostFetchHook{reject}})
+	if err == nil || !strings.Contains(err.Error(), "flagged by scanner") {
+		t.Errorf("Generate() error = %v, want hook failure", err)
+	}
+}
+
+func TestCommandHook(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	hook := CommandHook(`echo "$NOPHER_MODULE_PATH $NOPHER_MODULE_VERSION $NOPHER_MODULE_DIR" > ` + out)
+	if err := hook("example.com/a", "v1.0.0", "/tmp/a"); err != nil {
+		t.Fatalf("hook error = %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(string(data)), "example.com/a v1.0.0 /tmp/a"; got != want {
+		t.Errorf("hook output = %q, want %q", got, want)
+	}
+
+	if err := CommandHook("exit 3")("example.com/a", "v1.0.0", "/tmp/a"); err == nil {
+		t.Error("expected error for failing command")
+	}
+}
+
+func TestGenerateAndSaveCheck(t *testing.T) {
+	dir := t.TempDir()
+	writeSyntheticProject(t, dir, 2)
+
+	reject := func(lf *lockfile.Lockfile) error {
+		return fmt.Errorf("policy violation")
+	}
+	if _, err := GenerateAndSave(dir, Options{Fetch: stubFetch, Check: reject}); err == nil {
+		t.Fatal("GenerateAndSave() should fail when Check rejects the lockfile")
+	}
+	if _, err := os.Stat(filepath.Join(dir, lockfile.Filename(""))); !os.IsNotExist(err) {
+		t.Errorf("lockfile should not be written when Check fails, stat error = %v", err)
+	}
+}
+
+func TestGenerateAndSaveFormat(t *testing.T) {
+	dir := t.TempDir()
+	writeSyntheticProject(t, dir, 2)
+	yamlPath := filepath.Join(dir, lockfile.Filename(""))
+	tomlPath := filepath.Join(dir, lockfile.TOMLFilename(""))
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	if _, err := GenerateAndSave(dir, Options{Fetch: stubFetch}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GenerateAndSave(dir, Options{Fetch: stubFetch, Format: lockfile.FormatTOML}); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(tomlPath) || exists(yamlPath) {
+		t.Errorf("--format toml: toml exists = %v, yaml exists = %v; want the YAML lockfile replaced", exists(tomlPath), exists(yamlPath))
+	}
+
+	// Later runs keep the format.
+	lf, err := GenerateAndSave(dir, Options{Fetch: stubFetch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists(tomlPath) || exists(yamlPath) {
+		t.Errorf("regenerating: toml exists = %v, yaml exists = %v; want TOML kept", exists(tomlPath), exists(yamlPath))
+	}
+	if loaded, err := lockfile.Load(lockfile.Path(dir, "")); err != nil || len(loaded.Modules) != len(lf.Modules) {
+		t.Errorf("Load(TOML) = %v modules, %v; want %d", len(loaded.Modules), err, len(lf.Modules))
+	}
+
+	if _, err := GenerateAndSave(dir, Options{Fetch: stubFetch, Format: lockfile.FormatTOML, Compress: true}); err == nil {
+		t.Error("compressed TOML lockfiles should be rejected")
+	}
+	if _, err := GenerateAndSave(dir, Options{Fetch: stubFetch, Format: lockfile.FormatYAML}); err != nil {
+		t.Fatal(err)
+	}
+	if exists(tomlPath) || !exists(yamlPath) {
+		t.Errorf("--format yaml: toml exists = %v, yaml exists = %v; want the TOML lockfile replaced", exists(tomlPath), exists(yamlPath))
+	}
+}
+
+func TestGenerateWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.work":       "go 1.22\n\nuse (\n\t./api\n\t./worker\n)\n",
+		"go.work.sum":   "example.com/extra v1.0.0/go.mod h1:x=\n",
+		"api/go.mod":    "module example.com/api\n\ngo 1.22\n\nrequire (\n\texample.com/worker v0.0.0\n\texample.com/dep v1.1.0\n)\n",
+		"api/go.sum":    "example.com/dep v1.1.0 h1:a=\nexample.com/dep v1.1.0/go.mod h1:b=\n",
+		"worker/go.mod": "module example.com/worker\n\ngo 1.22\n\nrequire (\n\texample.com/dep v1.0.0\n\texample.com/other v0.2.0\n)\n",
+		"worker/go.sum": "example.com/dep v1.0.0/go.mod h1:c=\nexample.com/other v0.2.0 h1:d=\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lf, err := Generate(dir, Options{Fetch: stubFetch})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(lf.Modules) != 2 || lf.Modules["example.com/dep"].Version != "v1.1.0" {
+		t.Errorf("Modules = %+v, want dep@v1.1.0 and other", lf.Modules)
+	}
+	if _, ok := lf.Modules["example.com/worker"]; ok {
+		t.Error("workspace member should not be locked as a dependency")
+	}
+
+	worker, ok := lf.Workspace["example.com/worker"]
+	if !ok || worker.Dir != "./worker" {
+		t.Fatalf("Workspace = %+v", lf.Workspace)
+	}
+	if len(worker.Requires) != 1 || worker.Requires[0] != "example.com/dep@v1.0.0" {
+		t.Errorf("worker Requires = %v, want [example.com/dep@v1.0.0]", worker.Requires)
+	}
+	if api := lf.Workspace["example.com/api"]; len(api.Requires) != 0 {
+		t.Errorf("api Requires = %v, want none", api.Requires)
+	}
+}
+
+func TestGenerateFoldsLocalReplaceRequires(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":        "module example.com/app\n\ngo 1.16\n\nrequire (\n\texample.com/shared v0.0.0\n\texample.com/dep v1.2.0\n)\n\nreplace example.com/shared => ./shared\n",
+		"go.sum":        "example.com/dep v1.2.0 h1:a=\nexample.com/dep v1.2.0/go.mod h1:b=\n",
+		"shared/go.mod": "module example.com/shared\n\ngo 1.16\n\nrequire (\n\texample.com/dep v1.1.0\n\texample.com/only v0.3.0\n\texample.com/app v0.0.0\n)\n\nreplace example.com/only => ../elsewhere\n",
+		"shared/go.sum": "example.com/only v0.3.0 h1:c=\nexample.com/only v0.3.0/go.mod h1:d=\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lf, err := Generate(dir, Options{Fetch: stubFetch})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if dep := lf.Modules["example.com/dep"]; dep.Version != "v1.2.0" || dep.Via != "" {
+		t.Errorf("dep = %+v, want v1.2.0 required by go.mod", dep)
+	}
+	only, ok := lf.Modules["example.com/only"]
+	if !ok || only.Version != "v0.3.0" || only.Via != "example.com/shared" || only.Sum != "h1:c=" {
+		t.Errorf("only = %+v, %v; want v0.3.0 via example.com/shared", only, ok)
+	}
+	if _, ok := lf.Modules["example.com/app"]; ok {
+		t.Error("main module should not be locked as a dependency")
+	}
+	if len(lf.Modules) != 2 {
+		t.Errorf("Modules = %+v, want dep and only", lf.Modules)
+	}
+}
+
+func TestGenerateVersionedReplaces(t *testing.T) {
+	dir := t.TempDir()
+	goMod := `module example.com/app
+
+go 1.22
+
+require (
+	example.com/self v1.2.0
+	example.com/pinned v1.0.0
+	example.com/skipped v1.9.1
+)
+
+replace example.com/self => example.com/self v1.1.0
+
+replace example.com/pinned v1.0.0 => example.com/fork v1.0.1
+
+replace example.com/skipped v1.9.0 => example.com/fork v1.0.1
+
+replace example.com/unused => example.com/other v0.1.0
+`
+	goSum := "example.com/skipped v1.9.1 h1:a=\nexample.com/skipped v1.9.1/go.mod h1:b=\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.sum"), []byte(goSum), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	lf, err := Generate(dir, Options{Fetch: stubFetch})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	tests := map[string]struct{ oldVersion, match string }{
+		"example.com/self":    {"v1.2.0", ""},       // self-replace downgrading the required version
+		"example.com/pinned":  {"v1.0.0", "v1.0.0"}, // version-specific, applies
+		"example.com/skipped": {"", "v1.9.0"},       // version-specific, another version required
+		"example.com/unused":  {"", ""},             // not required
+	}
+	for path, want := range tests {
+		r := lf.Replace[path]
+		if r.OldVersion != want.oldVersion || r.Match != want.match {
+			t.Errorf("Replace[%s] oldVersion, match = %q, %q; want %q, %q", path, r.OldVersion, r.Match, want.oldVersion, want.match)
+		}
+	}
+	if self := lf.Replace["example.com/self"]; self.New != "example.com/self" || self.Version != "v1.1.0" {
+		t.Errorf("Replace[example.com/self] = %s@%s, want example.com/self@v1.1.0", self.New, self.Version)
+	}
+
+	// A replacement limited to another version leaves the module locked
+	if m, ok := lf.Modules["example.com/skipped"]; !ok || m.Version != "v1.9.1" {
+		t.Errorf("Modules[example.com/skipped] = %+v, %v; want v1.9.1", m, ok)
+	}
+	if len(lf.Modules) != 1 {
+		t.Errorf("Modules = %+v, want only example.com/skipped", lf.Modules)
+	}
+}
+
+func TestGenerateMissingSums(t *testing.T) {
+	dir := t.TempDir()
+	writeSyntheticProject(t, dir, 2)
+	goMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	goMod = append(goMod, "\nrequire example.com/nosum v1.0.0\n"...)
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), goMod, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var fetched []string
+	fetch := func(modulePath, version string) (*FetchResult, error) {
+		fetched = append(fetched, modulePath)
+		return stubFetch(modulePath, version)
+	}
+	_, err = Generate(dir, Options{Fetch: fetch})
+	if err == nil || !strings.Contains(err.Error(), "example.com/nosum@v1.0.0") {
+		t.Fatalf("Generate() error = %v, want missing go.sum entry for example.com/nosum", err)
+	}
+	if len(fetched) != 0 {
+		t.Errorf("fetched %v before failing, want nothing", fetched)
+	}
+
+	lf, err := Generate(dir, Options{Fetch: stubFetch, SkipMissingSums: true})
+	if err != nil {
+		t.Fatalf("Generate(SkipMissingSums) error = %v", err)
+	}
+	if _, ok := lf.Modules["example.com/nosum"]; ok || len(lf.Modules) != 2 {
+		t.Errorf("Modules = %v, want the two modules with go.sum entries", lf.Modules)
+	}
+}
+
+func TestGenerateOnlySkip(t *testing.T) {
+	dir := t.TempDir()
+	writeSyntheticProject(t, dir, 3)
+
+	if _, err := GenerateAndSave(dir, Options{Fetch: stubFetch}); err != nil {
+		t.Fatalf("GenerateAndSave() error = %v", err)
+	}
+
+	var fetched []string
+	refetch := func(modulePath, version string) (*FetchResult, error) {
+		fetched = append(fetched, modulePath)
+		return &FetchResult{Hash: "sha256-new", URL: "https://example.com/" + modulePath + ".zip"}, nil
+	}
+
+	lf, err := Generate(dir, Options{Fetch: refetch, Only: "example.com/dep00*", Skip: "example.com/dep000"})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if strings.Join(fetched, ",") != "example.com/dep001,example.com/dep002" {
+		t.Errorf("fetched %v, want dep001 and dep002 only", fetched)
+	}
+	if got := lf.Modules["example.com/dep000"]; got.Hash != "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=" || got.Sum != "h1:abcd0=" {
+		t.Errorf("skipped module = %+v, want the previous entry kept", got)
+	}
+	if got := lf.Modules["example.com/dep001"]; got.Hash != "sha256-new" {
+		t.Errorf("selected module = %+v, want it refetched", got)
+	}
+
+	// A skipped module whose version changed has nothing to keep.
+	writeSyntheticProject(t, dir, 4)
+	fetched = nil
+	lf, err = Generate(dir, Options{Fetch: refetch, Skip: "example.com"})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(fetched) != 0 {
+		t.Errorf("fetched %v, want nothing", fetched)
+	}
+	if _, ok := lf.Modules["example.com/dep003"]; ok || len(lf.Modules) != 3 {
+		t.Errorf("Modules = %v, want the 3 previously locked modules only", lf.Modules)
+	}
+
+	// An unreadable lockfile is not mistaken for a missing one.
+	if err := os.WriteFile(filepath.Join(dir, lockfile.DefaultLockfile), []byte("modules: [\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Generate(dir, Options{Fetch: refetch, Skip: "example.com"}); err == nil {
+		t.Error("Generate() with --skip over a corrupt lockfile should fail")
+	}
+	if _, err := Generate(dir, Options{Fetch: refetch}); err != nil {
+		t.Errorf("Generate() over a corrupt lockfile = %v, want it regenerated", err)
+	}
+}
+
+func TestGeneratePermit(t *testing.T) {
+	dir := t.TempDir()
+	writeSyntheticProject(t, dir, 3)
+
+	fetched := 0
+	fetch := func(modulePath, version string) (*FetchResult, error) {
+		fetched++
+		return stubFetch(modulePath, version)
+	}
+	permit := func(modulePath, version string) error {
+		if modulePath == "example.com/dep001" {
+			return fmt.Errorf("%s@%s is denied", modulePath, version)
+		}
+		return nil
+	}
+
+	_, err := Generate(dir, Options{Fetch: fetch, Permit: permit})
+	if err == nil || !strings.Contains(err.Error(), "example.com/dep001@v1.0.1") {
+		t.Fatalf("Generate() error = %v, want denial of dep001", err)
+	}
+	if fetched != 0 {
+		t.Errorf("fetched %d modules, want none before the policy check", fetched)
+	}
+}
+
+func TestGenerateReadOnlyCache(t *testing.T) {
+	dir := t.TempDir()
+	writeSyntheticProject(t, dir, 4)
+
+	cached := func(modulePath, version string) (*FetchResult, error) {
+		if modulePath == "example.com/dep001" || modulePath == "example.com/dep003" {
+			return nil, fmt.Errorf("%s@%s: %w", modulePath, version, fetch.ErrNotCached)
+		}
+		return stubFetch(modulePath, version)
+	}
+
+	_, err := Generate(dir, Options{Fetch: cached})
+	if err == nil || !strings.Contains(err.Error(), "2 module(s)") ||
+		!strings.Contains(err.Error(), "example.com/dep001@v1.0.1\n  example.com/dep003@v1.0.3") {
+		t.Fatalf("Generate() error = %v, want both uncached modules listed", err)
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	release := make(chan struct{})
+	fetch := coalesce(func(modulePath, version string) (*FetchResult, error) {
+		mu.Lock()
+		calls[modulePath+"@"+version]++
+		mu.Unlock()
+		<-release
+		return stubFetch(modulePath, version)
+	})
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := fetch("example.com/a", "v1.0.0"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	close(release)
+	wg.Wait()
+	if _, err := fetch("example.com/a", "v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fetch("example.com/a", "v1.1.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls["example.com/a@v1.0.0"] != 1 || calls["example.com/a@v1.1.0"] != 1 {
+		t.Errorf("calls = %v, want one fetch per version", calls)
+	}
+}
+
+func TestGenerateFetchesSharedModuleOnce(t *testing.T) {
+	dir := t.TempDir()
+	goMod := `module example.com/app
+
+go 1.22
+
+require (
+	example.com/fork v1.0.0
+	example.com/upstream v1.0.0
+)
+
+replace example.com/upstream => example.com/fork v1.0.0
+`
+	goSum := "example.com/fork v1.0.0 h1:abcd=\nexample.com/fork v1.0.0/go.mod h1:efgh=\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.sum"), []byte(goSum), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fetched := 0
+	fetch := func(modulePath, version string) (*FetchResult, error) {
+		fetched++
+		return stubFetch(modulePath, version)
+	}
+	lf, err := Generate(dir, Options{Fetch: fetch})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if fetched != 1 {
+		t.Errorf("fetched %d times, want 1", fetched)
+	}
+	if _, ok := lf.Modules["example.com/fork"]; !ok {
+		t.Error("example.com/fork missing from modules")
+	}
+	if lf.Replace["example.com/upstream"].New != "example.com/fork" {
+		t.Error("replacement missing")
+	}
+}
+
+func TestGenerateCache(t *testing.T) {
+	dir := t.TempDir()
+	writeSyntheticProject(t, dir, 3)
+
+	var mu sync.Mutex
+	fetched := 0
+	fetch := func(modulePath, version string) (*FetchResult, error) {
+		mu.Lock()
+		fetched++
+		mu.Unlock()
+		return stubFetch(modulePath, version)
+	}
+
+	cache := NewCache()
+	metrics := NewMetrics()
+	for range 2 {
+		if _, err := Generate(dir, Options{Fetch: fetch, Cache: cache, Metrics: metrics}); err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+	}
+	if fetched != 3 {
+		t.Errorf("fetched %d times, want 3", fetched)
+	}
+	if cache.Len() != 3 {
+		t.Errorf("cache.Len() = %d, want 3", cache.Len())
+	}
+	if r := metrics.Report(); r.CacheHits != 3 {
+		t.Errorf("CacheHits = %d, want 3 from the second generation", r.CacheHits)
+	}
+
+	result, err := FetchModule(Options{Fetch: fetch, Cache: cache}, "example.com/dep001", "v1.0.1")
+	if err != nil {
+		t.Fatalf("FetchModule() error = %v", err)
+	}
+	if fetched != 3 || !result.CacheHit {
+		t.Errorf("FetchModule() fetched again (%d fetches, CacheHit %v)", fetched, result.CacheHit)
+	}
+}
+
+func TestGenerateProgress(t *testing.T) {
+	dir := t.TempDir()
+	writeSyntheticProject(t, dir, 3)
+
+	var mu sync.Mutex
+	var events []Event
+	fetch := func(modulePath, version string) (*FetchResult, error) {
+		if modulePath == "example.com/dep002" {
+			return nil, fmt.Errorf("boom")
+		}
+		return stubFetch(modulePath, version)
+	}
+	_, err := Generate(dir, Options{Fetch: fetch, Progress: func(e Event) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	}})
+	if err == nil {
+		t.Fatal("Generate() succeeded, want the failed fetch")
+	}
+
+	var kinds []string
+	for _, e := range events {
+		if e.Total != 3 {
+			t.Errorf("event %+v: Total = %d, want 3", e, e.Total)
+		}
+		kinds = append(kinds, string(e.Kind)+" "+e.Path)
+	}
+	want := []string{
+		"started example.com/dep000", "hashed example.com/dep000",
+		"started example.com/dep001", "hashed example.com/dep001",
+		"started example.com/dep002", "failed example.com/dep002",
+	}
+	if strings.Join(kinds, ", ") != strings.Join(want, ", ") {
+		t.Errorf("events = %q, want %q", kinds, want)
+	}
+	if events[1].Hash == "" || events[5].Err == nil {
+		t.Errorf("events = %+v, want a hash on hashed and an error on failed", events)
+	}
+}
@@ -0,0 +1,52 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// PostFetchHook is invoked for every fetched module with the directory its
+// contents were extracted to. Returning an error aborts generation, which lets
+// scanners (malware, secrets, licenses) block a lockfile from being written.
+type PostFetchHook func(modulePath, version, dir string) error
+
+// CommandHook returns a PostFetchHook that runs command through sh -c. The
+// module is described to the command by the NOPHER_MODULE_PATH,
+// NOPHER_MODULE_VERSION, and NOPHER_MODULE_DIR environment variables; a
+// non-zero exit status fails the hook. Command output goes to stderr.
+func CommandHook(command string) PostFetchHook {
+	return func(modulePath, version, dir string) error {
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Env = append(os.Environ(),
+			"NOPHER_MODULE_PATH="+modulePath,
+			"NOPHER_MODULE_VERSION="+version,
+			"NOPHER_MODULE_DIR="+dir,
+		)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("post-fetch hook %q: %w", command, err)
+		}
+		return nil
+	}
+}
+
+// withHooks wraps fetchModule so hooks run after each successful fetch.
+func withHooks(fetchModule FetchFunc, hooks []PostFetchHook) FetchFunc {
+	return func(modulePath, version string) (*FetchResult, error) {
+		result, err := fetchModule(modulePath, version)
+		if err != nil || result == nil {
+			return result, err
+		}
+		if result.Dir == "" {
+			return nil, fmt.Errorf("post-fetch hooks need an extracted module directory")
+		}
+		for _, hook := range hooks {
+			if err := hook(modulePath, version, result.Dir); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+}
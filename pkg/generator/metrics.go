@@ -0,0 +1,118 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ModuleMetrics records fetch statistics for a single module.
+type ModuleMetrics struct {
+	Path       string  `json:"path"`
+	Version    string  `json:"version"`
+	DurationMS float64 `json:"durationMs"`
+	Bytes      int64   `json:"bytes"`
+	CacheHit   bool    `json:"cacheHit"`
+	Retries    int     `json:"retries"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Metrics collects per-module fetch statistics during generation.
+type Metrics struct {
+	mu      sync.Mutex
+	start   time.Time
+	end     time.Time
+	modules []ModuleMetrics
+}
+
+// MetricsReport is the machine-readable summary written by WriteFile.
+type MetricsReport struct {
+	DurationMS float64         `json:"durationMs"`
+	Modules    int             `json:"modules"`
+	Bytes      int64           `json:"bytes"`
+	CacheHits  int             `json:"cacheHits"`
+	Retries    int             `json:"retries"`
+	Failures   int             `json:"failures"`
+	PerModule  []ModuleMetrics `json:"perModule"`
+}
+
+// NewMetrics creates an empty metrics collector.
+func NewMetrics() *Metrics {
+	return &Metrics{start: time.Now()}
+}
+
+func (m *Metrics) record(path, version string, d time.Duration, result *FetchResult, err error) {
+	if m == nil {
+		return
+	}
+	mm := ModuleMetrics{
+		Path:       path,
+		Version:    version,
+		DurationMS: float64(d.Microseconds()) / 1000,
+	}
+	if result != nil {
+		mm.Bytes = result.Bytes
+		mm.CacheHit = result.CacheHit
+		mm.Retries = result.Retries
+	}
+	if err != nil {
+		mm.Error = err.Error()
+	}
+
+	m.mu.Lock()
+	m.modules = append(m.modules, mm)
+	m.end = time.Now()
+	m.mu.Unlock()
+}
+
+// Report summarizes the collected metrics.
+func (m *Metrics) Report() MetricsReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	end := m.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	r := MetricsReport{
+		DurationMS: float64(end.Sub(m.start).Microseconds()) / 1000,
+		Modules:    len(m.modules),
+		PerModule:  append([]ModuleMetrics(nil), m.modules...),
+	}
+	for _, mm := range m.modules {
+		r.Bytes += mm.Bytes
+		r.Retries += mm.Retries
+		if mm.CacheHit {
+			r.CacheHits++
+		}
+		if mm.Error != "" {
+			r.Failures++
+		}
+	}
+	sort.Slice(r.PerModule, func(i, j int) bool {
+		if r.PerModule[i].Path != r.PerModule[j].Path {
+			return r.PerModule[i].Path < r.PerModule[j].Path
+		}
+		return r.PerModule[i].Version < r.PerModule[j].Version
+	})
+
+	return r
+}
+
+// WriteFile writes the metrics report as JSON to path.
+func (m *Metrics) WriteFile(path string) error {
+	data, err := json.MarshalIndent(m.Report(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling metrics: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing metrics: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/anthr76/nopher/internal/fetch"
+)
+
+// PlannedFetch is a module Generate would fetch and how it would get it.
+type PlannedFetch struct {
+	Path    string
+	Version string
+	// Replaces is the module path replaced by Path, for replacement targets.
+	Replaces string
+	fetch.FetchPlan
+}
+
+// Plan lists the modules Generate would fetch for dir under opts, with the
+// source and URL each would be downloaded from and whether it is already
+// cached, without downloading anything. opts.Fetch is ignored: plans always
+// come from the fetcher opts configure.
+func Plan(dir string, opts Options) ([]PlannedFetch, error) {
+	if dir == "" {
+		dir = "."
+	}
+
+	set, err := loadModuleSet(dir)
+	if err != nil {
+		return nil, err
+	}
+	sumEntries := set.sumEntries()
+	if !opts.SkipMissingSums {
+		if err := checkSums(set.info, sumEntries); err != nil {
+			return nil, err
+		}
+	}
+
+	fetcher, err := newFetcher(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := fetcher.Close(); err != nil && opts.Verbose {
+			fmt.Fprintf(os.Stderr, "warning: failed to export traces: %v\n", err)
+		}
+	}()
+
+	var plan []PlannedFetch
+	for _, t := range fetchTargets(set.info, sumEntries) {
+		name := t.Path
+		if t.Replaces != "" {
+			name = t.Replaces
+		}
+		if !selected(opts, name) {
+			continue
+		}
+		plan = append(plan, PlannedFetch{
+			Path:      t.Path,
+			Version:   t.Version,
+			Replaces:  t.Replaces,
+			FetchPlan: *fetcher.Plan(t.Path, t.Version),
+		})
+	}
+	return plan, nil
+}
@@ -0,0 +1,56 @@
+package generator
+
+// EventKind identifies what happened to a module during generation.
+type EventKind string
+
+const (
+	// EventStarted is reported before a module is fetched.
+	EventStarted EventKind = "started"
+	// EventDownloaded is reported when the default fetcher has downloaded
+	// a module's archive from the network. Modules served from a cache are
+	// hashed without being downloaded.
+	EventDownloaded EventKind = "downloaded"
+	// EventHashed is reported once a module is fetched and hashed.
+	EventHashed EventKind = "hashed"
+	// EventFailed is reported when fetching a module fails.
+	EventFailed EventKind = "failed"
+)
+
+// Event reports the progress of one module during generation.
+type Event struct {
+	Kind    EventKind
+	Path    string
+	Version string
+	// Total is the number of modules the generation fetches.
+	Total int
+
+	URL      string // Set for EventDownloaded
+	Size     int64  // Set for EventDownloaded, in bytes
+	Hash     string // Set for EventHashed
+	CacheHit bool   // Set for EventHashed
+	Retries  int    // Set for EventHashed: download attempts beyond the first
+	Err      error  // Set for EventFailed
+}
+
+// withTotal returns progress with Total set on every event.
+func withTotal(progress func(Event), total int) func(Event) {
+	return func(e Event) {
+		e.Total = total
+		progress(e)
+	}
+}
+
+// withProgress wraps fetchModule so every fetch is reported to progress.
+func withProgress(fetchModule FetchFunc, progress func(Event)) FetchFunc {
+	return func(modulePath, version string) (*FetchResult, error) {
+		progress(Event{Kind: EventStarted, Path: modulePath, Version: version})
+		result, err := fetchModule(modulePath, version)
+		switch {
+		case err != nil:
+			progress(Event{Kind: EventFailed, Path: modulePath, Version: version, Err: err})
+		case result != nil:
+			progress(Event{Kind: EventHashed, Path: modulePath, Version: version, Hash: result.Hash, CacheHit: result.CacheHit, Retries: result.Retries})
+		}
+		return result, err
+	}
+}
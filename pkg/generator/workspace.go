@@ -0,0 +1,162 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/anthr76/nopher/internal/mod"
+	"github.com/anthr76/nopher/pkg/lockfile"
+	"golang.org/x/mod/semver"
+)
+
+// moduleSet is the requirement set a lockfile is generated from.
+type moduleSet struct {
+	info      *mod.ModInfo
+	sums      []mod.SumEntry // go.sum zip hashes
+	modOnly   []mod.SumEntry // go.sum entries with only a go.mod hash
+	workspace map[string]lockfile.WorkspaceMember
+	// via maps modules required only by a local replacement's go.mod to
+	// that replacement's module path.
+	via map[string]string
+}
+
+// sumEntries returns the path@version keys with any go.sum entry.
+func (s *moduleSet) sumEntries() map[string]bool {
+	entries := make(map[string]bool)
+	for _, entry := range s.sums {
+		entries[moduleKey(entry.Path, entry.Version)] = true
+	}
+	for _, entry := range s.modOnly {
+		entries[moduleKey(entry.Path, entry.Version)] = true
+	}
+	return entries
+}
+
+// loadModuleSet reads go.mod and go.sum in dir. When dir holds a go.work file
+// (and GOWORK is not "off"), the members' requirements are merged into one
+// set and checksums are read from go.work.sum and every member's go.sum.
+func loadModuleSet(dir string) (*moduleSet, error) {
+	workPath := filepath.Join(dir, "go.work")
+	if _, err := os.Stat(workPath); err != nil || os.Getenv("GOWORK") == "off" {
+		info, err := mod.ParseGoMod(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			return nil, fmt.Errorf("parsing go.mod: %w", err)
+		}
+		set := &moduleSet{info: info}
+		if err := set.readSums(filepath.Join(dir, "go.sum"), false); err != nil {
+			return nil, err
+		}
+		if err := set.foldLocalReplaces(dir); err != nil {
+			return nil, err
+		}
+		return set, nil
+	}
+
+	work, err := mod.ParseGoWork(workPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := work.ModInfo()
+	if err != nil {
+		return nil, fmt.Errorf("merging workspace: %w", err)
+	}
+
+	selected := make(map[string]string, len(info.Requires))
+	for _, req := range info.Requires {
+		selected[req.Path] = req.Version
+	}
+
+	set := &moduleSet{info: info, workspace: make(map[string]lockfile.WorkspaceMember)}
+	for _, m := range work.Members {
+		member := lockfile.WorkspaceMember{Dir: m.Dir}
+		for _, req := range m.Requires {
+			if v, ok := selected[req.Path]; ok && semver.Compare(req.Version, v) < 0 {
+				member.Requires = append(member.Requires, req.Path+"@"+req.Version)
+			}
+		}
+		sort.Strings(member.Requires)
+		set.workspace[m.ModulePath] = member
+	}
+	for _, path := range work.SumFiles(dir) {
+		if err := set.readSums(path, true); err != nil {
+			return nil, err
+		}
+	}
+	if err := set.foldLocalReplaces(dir); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+func (s *moduleSet) readSums(path string, optional bool) error {
+	entries, err := mod.ParseGoSum(path)
+	if err != nil {
+		if optional && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("parsing %s: %w", filepath.Base(path), err)
+	}
+	modOnly, err := mod.ParseGoSumModOnly(path)
+	if err != nil {
+		return fmt.Errorf("parsing %s for go.mod entries: %w", filepath.Base(path), err)
+	}
+	s.sums = append(s.sums, entries...)
+	s.modOnly = append(s.modOnly, modOnly...)
+	return nil
+}
+
+// foldLocalReplaces adds the requirements of local replacement targets that
+// the module set does not already require, so modules only a replaced
+// module depends on are locked too. Go resolves these through the replaced
+// module's go.mod, but go mod tidy records them in the main go.mod only for
+// go 1.17 and later. Requirements already present keep their version, and
+// replacements within the replaced module's go.mod are ignored, as go does.
+// Checksums are also read from the replaced module's go.sum, if any.
+func (s *moduleSet) foldLocalReplaces(dir string) error {
+	required := make(map[string]bool, len(s.info.Requires))
+	for _, req := range s.info.Requires {
+		required[req.Path] = true
+	}
+	local := make(map[string]bool)
+	for _, rep := range s.info.Replaces {
+		if rep.IsLocal {
+			local[rep.Old] = true
+		}
+	}
+
+	for _, rep := range s.info.Replaces {
+		if !rep.IsLocal {
+			continue
+		}
+		root := rep.New
+		if !filepath.IsAbs(root) {
+			root = filepath.Join(dir, root)
+		}
+		info, err := mod.ParseGoMod(filepath.Join(root, "go.mod"))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("parsing go.mod of local replacement %s: %w", rep.Old, err)
+		}
+		for _, req := range info.Requires {
+			if _, member := s.workspace[req.Path]; member || required[req.Path] || local[req.Path] || req.Path == s.info.ModulePath {
+				continue
+			}
+			required[req.Path] = true
+			s.info.Requires = append(s.info.Requires, mod.Require{Path: req.Path, Version: req.Version, Indirect: true})
+			if s.via == nil {
+				s.via = make(map[string]string)
+			}
+			s.via[req.Path] = rep.Old
+		}
+		if err := s.readSums(filepath.Join(root, "go.sum"), true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+// Package lockcheck checks that a nopher lockfile is in sync with go.mod
+// and go.sum, as nopher verify does. It lets linters and other static
+// analysis pipelines assert lockfile freshness as part of their own runs,
+// without invoking the CLI.
+package lockcheck
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+
+	"github.com/anthr76/nopher/internal/mod"
+	"github.com/anthr76/nopher/pkg/lockfile"
+)
+
+// Kind classifies a Finding.
+type Kind string
+
+const (
+	GoVersion   Kind = "go-version"  // The lockfile and go.mod name different Go versions
+	Missing     Kind = "missing"     // Required or replaced in go.mod but not locked
+	Extra       Kind = "extra"       // Locked but no longer required or replaced
+	Mismatch    Kind = "mismatch"    // Locked at another version or replacement target
+	GoSum       Kind = "gosum"       // No go.sum entry, or a different h1 hash in go.sum
+	Quarantined Kind = "quarantined" // Locked but quarantined pending review
+)
+
+// Finding is one way the lockfile is out of sync with go.mod and go.sum.
+type Finding struct {
+	Kind Kind
+	// Message describes the finding, such as
+	// "golang.org/x/mod: lockfile=v0.31.0, go.mod=v0.32.0".
+	Message string
+}
+
+func (f Finding) String() string {
+	return string(f.Kind) + ": " + f.Message
+}
+
+// Options adjusts CheckWith.
+type Options struct {
+	// Profile selects the lockfile profile, as nopher's --profile flag
+	// does; empty means the default lockfile.
+	Profile string
+	// InScope limits the check to the modules, by path (the original path
+	// for replacements), for which it returns true. Nil checks every
+	// module.
+	InScope func(modulePath string) bool
+}
+
+// Check checks the lockfile in dir against the go.mod and go.sum next to
+// it. It returns no findings when the lockfile is fresh; quarantined
+// modules are reported but leave it fresh otherwise, so callers decide
+// whether they fail a run. Findings are ordered by kind, in the order of
+// the Kind constants, then by message.
+func Check(dir string) ([]Finding, error) {
+	return CheckWith(dir, Options{})
+}
+
+// CheckWith is Check with options.
+func CheckWith(dir string, opts Options) ([]Finding, error) {
+	lf, err := lockfile.Load(lockfile.Path(dir, opts.Profile))
+	if err != nil {
+		return nil, fmt.Errorf("loading lockfile: %w", err)
+	}
+	info, err := mod.ParseGoMod(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing go.mod: %w", err)
+	}
+	inScope := opts.InScope
+	if inScope == nil {
+		inScope = func(string) bool { return true }
+	}
+
+	var findings []Finding
+	add := func(kind Kind, messages []string) {
+		for _, m := range messages {
+			findings = append(findings, Finding{Kind: kind, Message: m})
+		}
+	}
+	if lf.Go != info.GoVersion {
+		add(GoVersion, []string{fmt.Sprintf("lockfile has %s, go.mod has %s", lf.Go, info.GoVersion)})
+	}
+	missing, extra, mismatch := compareModules(lf, info, inScope)
+	add(Missing, missing)
+	add(Extra, extra)
+	add(Mismatch, mismatch)
+	sums, err := checkGoSum(dir, lf, inScope)
+	if err != nil {
+		return nil, err
+	}
+	add(GoSum, sums)
+	add(Quarantined, quarantinedModules(lf, inScope))
+	return findings, nil
+}
+
+// Fresh reports whether findings leave the lockfile in sync, that is,
+// whether every finding is a quarantined module.
+func Fresh(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Kind != Quarantined {
+			return false
+		}
+	}
+	return true
+}
+
+// Messages returns the messages of the findings of the given kind.
+func Messages(findings []Finding, kind Kind) []string {
+	var out []string
+	for _, f := range findings {
+		if f.Kind == kind {
+			out = append(out, f.Message)
+		}
+	}
+	return out
+}
+
+// quarantinedModules returns sorted descriptions of the lockfile's
+// quarantined modules for which inScope returns true.
+func quarantinedModules(lf *lockfile.Lockfile, inScope func(string) bool) []string {
+	var out []string
+	for path, q := range lf.Quarantine {
+		if !inScope(path) {
+			continue
+		}
+		name := path
+		if q.Version != "" {
+			name += "@" + q.Version
+		}
+		if q.Reason != "" {
+			name += ": " + q.Reason
+		}
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// compareModules compares the lockfile's modules and replacements with
+// go.mod, returning sorted descriptions of what is missing from the
+// lockfile, extra in it, and mismatched. Only modules for which inScope
+// returns true are compared.
+//
+// A required module replaced in go.mod is not locked under modules; its
+// replace entry is checked instead, whether or not a require line remains.
+// Modules required only through a local replacement's go.mod (see
+// Module.Via), and modules covered by a pattern replacement, are accepted.
+func compareModules(lf *lockfile.Lockfile, info *mod.ModInfo, inScope func(string) bool) (missing, extra, mismatch []string) {
+	required := make(map[string]string, len(info.Requires))
+	for _, req := range info.Requires {
+		required[req.Path] = req.Version
+	}
+	replaced := make(map[string]mod.Replace, len(info.Replaces))
+	for _, rep := range info.Replaces {
+		replaced[rep.Old] = rep
+	}
+
+	// Replace directives, in both directions
+	for old, rep := range replaced {
+		if !inScope(old) {
+			continue
+		}
+		r, ok := lf.Replace[old]
+		switch {
+		case !ok && rep.IsLocal:
+			missing = append(missing, fmt.Sprintf("replace %s => %s", old, rep.New))
+		case !ok:
+			missing = append(missing, fmt.Sprintf("replace %s => %s@%s", old, rep.New, rep.NewVersion))
+		case rep.IsLocal && r.Path != rep.New:
+			mismatch = append(mismatch, fmt.Sprintf("replace %s: lockfile=%s, go.mod=%s", old, r.Target(), rep.New))
+		case !rep.IsLocal && (r.New != rep.New || r.Version != rep.NewVersion):
+			mismatch = append(mismatch, fmt.Sprintf("replace %s: lockfile=%s, go.mod=%s@%s", old, r.Target(), rep.New, rep.NewVersion))
+		case r.Match != rep.OldVersion:
+			mismatch = append(mismatch, fmt.Sprintf("replace %s: lockfile replaces %s, go.mod replaces %s", old, replacedVersions(r.Match), replacedVersions(rep.OldVersion)))
+		}
+	}
+	for old := range lf.Replace {
+		if _, ok := replaced[old]; ok || lockfile.IsPattern(old) || !inScope(old) {
+			continue
+		}
+		extra = append(extra, "replace "+old)
+	}
+
+	// Requirements not replaced in go.mod are locked under modules
+	for path, version := range required {
+		if rep, ok := replaced[path]; (ok && rep.Applies(version)) || !inScope(path) {
+			continue
+		}
+		m, ok := lf.Modules[path]
+		switch {
+		case !ok:
+			if r, ok := lf.ReplaceFor(path); ok && r.Path != "" {
+				continue // Covered by a pattern replacement
+			}
+			missing = append(missing, fmt.Sprintf("%s@%s", path, version))
+		case m.Version != version:
+			mismatch = append(mismatch, fmt.Sprintf("%s: lockfile=%s, go.mod=%s", path, m.Version, version))
+		}
+	}
+	for path, m := range lf.Modules {
+		if !inScope(path) {
+			continue
+		}
+		if rep, ok := replaced[path]; ok && rep.Applies(m.Version) {
+			extra = append(extra, path+" (replaced in go.mod)")
+			continue
+		}
+		if _, ok := required[path]; ok {
+			continue
+		}
+		// Required through a local replacement's go.mod
+		if rep, ok := replaced[m.Via]; ok && rep.IsLocal {
+			continue
+		}
+		extra = append(extra, path)
+	}
+
+	sort.Strings(missing)
+	sort.Strings(extra)
+	sort.Strings(mismatch)
+	return missing, extra, mismatch
+}
+
+// replacedVersions describes the versions a replace directive limited to
+// version applies to.
+func replacedVersions(version string) string {
+	if version == "" {
+		return "all versions"
+	}
+	return version
+}
+
+// checkGoSum reports lockfile modules that have no go.sum entry, or whose
+// recorded h1 sum differs from go.sum. Only modules for which inScope returns
+// true are checked. The check is skipped when go.sum does not exist.
+func checkGoSum(dir string, lf *lockfile.Lockfile, inScope func(string) bool) ([]string, error) {
+	goSumPath := filepath.Join(dir, "go.sum")
+	entries, err := mod.ParseGoSum(goSumPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing go.sum: %w", err)
+	}
+	modOnly, err := mod.ParseGoSumModOnly(goSumPath)
+	if err != nil {
+		return nil, fmt.Errorf("parsing go.sum for go.mod entries: %w", err)
+	}
+
+	sums := mod.SumMap(entries)
+	known := make(map[string]bool, len(sums)+len(modOnly))
+	for key := range sums {
+		known[key] = true
+	}
+	for _, e := range modOnly {
+		known[e.Path+"@"+e.Version] = true
+	}
+
+	var problems []string
+	check := func(name, key, recorded string) {
+		if !known[key] {
+			problems = append(problems, fmt.Sprintf("%s: no go.sum entry for %s", name, key))
+			return
+		}
+		if recorded != "" && sums[key] != recorded {
+			problems = append(problems, fmt.Sprintf("%s: lockfile sum=%s, go.sum=%s", name, recorded, sums[key]))
+		}
+	}
+
+	for path, m := range lf.Modules {
+		if !inScope(path) {
+			continue
+		}
+		// Checksums of modules required through a local replacement may
+		// only be in that replacement's go.sum.
+		if m.Via != "" && !known[path+"@"+m.Version] {
+			continue
+		}
+		check(path, path+"@"+m.Version, m.Sum)
+	}
+	for path, r := range lf.Replace {
+		if r.Path != "" || r.New == "" || !inScope(path) {
+			continue
+		}
+		check(path, r.New+"@"+r.Version, r.Sum)
+	}
+
+	sort.Strings(problems)
+	return problems, nil
+}
@@ -0,0 +1,219 @@
+package lockcheck
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/anthr76/nopher/internal/mod"
+	"github.com/anthr76/nopher/pkg/lockfile"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	goMod := "module example.com/app\n\ngo 1.22\n\nrequire (\n\tgolang.org/x/mod v0.32.0\n\tgolang.org/x/sync v0.10.0\n)\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	goSum := "golang.org/x/mod v0.32.0 h1:current=\ngolang.org/x/sync v0.10.0 h1:sync=\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.sum"), []byte(goSum), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	lf := lockfile.New("1.22")
+	lf.Modules["golang.org/x/mod"] = lockfile.Module{Version: "v0.32.0", Sum: "h1:current="}
+	lf.Modules["golang.org/x/sync"] = lockfile.Module{Version: "v0.10.0"}
+	lf.Quarantine = map[string]lockfile.Quarantined{"golang.org/x/sync": {Version: "v0.10.0", Reason: "new"}}
+	if err := lf.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	findings, err := Check(dir)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	want := []Finding{{Kind: Quarantined, Message: "golang.org/x/sync@v0.10.0: new"}}
+	if !reflect.DeepEqual(findings, want) || !Fresh(findings) {
+		t.Errorf("Check() = %v, want %v", findings, want)
+	}
+
+	lf.Go = "1.21"
+	lf.Quarantine = nil
+	delete(lf.Modules, "golang.org/x/sync")
+	lf.Modules["golang.org/x/mod"] = lockfile.Module{Version: "v0.31.0"}
+	lf.Modules["golang.org/x/old"] = lockfile.Module{Version: "v1.0.0"}
+	if err := lf.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	findings, err = Check(dir)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+	wantStrings := []string{
+		"go-version: lockfile has 1.21, go.mod has 1.22",
+		"missing: golang.org/x/sync@v0.10.0",
+		"extra: golang.org/x/old",
+		"mismatch: golang.org/x/mod: lockfile=v0.31.0, go.mod=v0.32.0",
+		"gosum: golang.org/x/mod: no go.sum entry for golang.org/x/mod@v0.31.0",
+		"gosum: golang.org/x/old: no go.sum entry for golang.org/x/old@v1.0.0",
+	}
+	if !reflect.DeepEqual(got, wantStrings) || Fresh(findings) {
+		t.Errorf("Check() = %q, want %q", got, wantStrings)
+	}
+
+	scoped, err := CheckWith(dir, Options{InScope: func(path string) bool { return path == "golang.org/x/sync" }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Messages(scoped, Missing); len(scoped) != 2 || !reflect.DeepEqual(got, []string{"golang.org/x/sync@v0.10.0"}) {
+		t.Errorf("CheckWith(InScope) = %v", scoped)
+	}
+
+	if _, err := Check(t.TempDir()); err == nil || !strings.Contains(err.Error(), "loading lockfile") {
+		t.Errorf("Check() without a lockfile error = %v", err)
+	}
+}
+
+func TestCheckGoSum(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	goSum := `golang.org/x/mod v0.32.0 h1:current=
+golang.org/x/mod v0.32.0/go.mod h1:xyz=
+github.com/only/gomod v1.0.0/go.mod h1:abc=
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.sum"), []byte(goSum), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lf := lockfile.New("1.21")
+	lf.Modules["golang.org/x/mod"] = lockfile.Module{Version: "v0.32.0", Sum: "h1:stale="}
+	lf.Modules["github.com/only/gomod"] = lockfile.Module{Version: "v1.0.0"}
+	lf.Modules["github.com/gone/pkg"] = lockfile.Module{Version: "v1.0.0"}
+
+	problems, err := checkGoSum(tmpDir, lf, func(string) bool { return true })
+	if err != nil {
+		t.Fatalf("checkGoSum() error = %v", err)
+	}
+	if len(problems) != 2 {
+		t.Fatalf("checkGoSum() = %v, want 2 problems", problems)
+	}
+	if !strings.Contains(problems[0], "github.com/gone/pkg") {
+		t.Errorf("problems[0] = %q, want missing go.sum entry for github.com/gone/pkg", problems[0])
+	}
+	if !strings.Contains(problems[1], "h1:stale=") {
+		t.Errorf("problems[1] = %q, want sum mismatch", problems[1])
+	}
+
+	// No go.sum: check is skipped
+	if problems, err := checkGoSum(t.TempDir(), lf, func(string) bool { return true }); err != nil || problems != nil {
+		t.Errorf("checkGoSum() without go.sum = %v, %v; want nil, nil", problems, err)
+	}
+}
+
+func TestCompareModules(t *testing.T) {
+	all := func(string) bool { return true }
+	tests := []struct {
+		name                    string
+		goMod                   string
+		lf                      *lockfile.Lockfile
+		missing, extra, differs []string
+	}{
+		{
+			name:  "local replace with require",
+			goMod: "require example.com/a v1.0.0\nreplace example.com/a => ../a\n",
+			lf:    &lockfile.Lockfile{Replace: map[string]lockfile.Replace{"example.com/a": {Path: "../a"}}},
+		},
+		{
+			name:  "local replace without require",
+			goMod: "replace example.com/a => ../a\n",
+			lf:    &lockfile.Lockfile{Replace: map[string]lockfile.Replace{"example.com/a": {Path: "../a"}}},
+		},
+		{
+			name:  "remote replace with require",
+			goMod: "require example.com/a v1.0.0\nreplace example.com/a => example.com/fork v1.0.1\n",
+			lf:    &lockfile.Lockfile{Replace: map[string]lockfile.Replace{"example.com/a": {New: "example.com/fork", Version: "v1.0.1"}}},
+		},
+		{
+			name:    "replace missing from lockfile",
+			goMod:   "require example.com/a v1.0.0\nreplace example.com/a => ../a\n",
+			lf:      &lockfile.Lockfile{},
+			missing: []string{"replace example.com/a => ../a"},
+		},
+		{
+			name:  "stale replace in lockfile",
+			goMod: "require example.com/a v1.0.0\n",
+			lf: &lockfile.Lockfile{
+				Modules: map[string]lockfile.Module{"example.com/a": {Version: "v1.0.0"}},
+				Replace: map[string]lockfile.Replace{"example.com/a": {Path: "../a"}},
+			},
+			extra: []string{"replace example.com/a"},
+		},
+		{
+			name:  "module locked although replaced",
+			goMod: "require example.com/a v1.0.0\nreplace example.com/a => ../a\n",
+			lf: &lockfile.Lockfile{
+				Modules: map[string]lockfile.Module{"example.com/a": {Version: "v1.0.0"}},
+				Replace: map[string]lockfile.Replace{"example.com/a": {Path: "../a"}},
+			},
+			extra: []string{"example.com/a (replaced in go.mod)"},
+		},
+		{
+			name:    "replace target changed",
+			goMod:   "require example.com/a v1.0.0\nreplace example.com/a => example.com/fork v1.0.2\n",
+			lf:      &lockfile.Lockfile{Replace: map[string]lockfile.Replace{"example.com/a": {New: "example.com/fork", Version: "v1.0.1"}}},
+			differs: []string{"replace example.com/a: lockfile=example.com/fork@v1.0.1, go.mod=example.com/fork@v1.0.2"},
+		},
+		{
+			name:    "local replace became remote",
+			goMod:   "replace example.com/a => example.com/fork v1.0.1\n",
+			lf:      &lockfile.Lockfile{Replace: map[string]lockfile.Replace{"example.com/a": {Path: "../a"}}},
+			differs: []string{"replace example.com/a: lockfile=../a, go.mod=example.com/fork@v1.0.1"},
+		},
+		{
+			name:  "module required via local replace",
+			goMod: "require example.com/a v1.0.0\nreplace example.com/a => ../a\n",
+			lf: &lockfile.Lockfile{
+				Modules: map[string]lockfile.Module{"example.com/b": {Version: "v1.0.0", Via: "example.com/a"}},
+				Replace: map[string]lockfile.Replace{"example.com/a": {Path: "../a"}},
+			},
+		},
+		{
+			name:  "version-specific replace of another version",
+			goMod: "require example.com/a v1.1.0\nreplace example.com/a v1.0.0 => example.com/fork v1.0.1\n",
+			lf: &lockfile.Lockfile{
+				Modules: map[string]lockfile.Module{"example.com/a": {Version: "v1.1.0"}},
+				Replace: map[string]lockfile.Replace{"example.com/a": {Match: "v1.0.0", New: "example.com/fork", Version: "v1.0.1"}},
+			},
+		},
+		{
+			name:    "replace became version-specific",
+			goMod:   "require example.com/a v1.0.0\nreplace example.com/a v1.0.0 => example.com/fork v1.0.1\n",
+			lf:      &lockfile.Lockfile{Replace: map[string]lockfile.Replace{"example.com/a": {OldVersion: "v1.0.0", New: "example.com/fork", Version: "v1.0.1"}}},
+			differs: []string{"replace example.com/a: lockfile replaces all versions, go.mod replaces v1.0.0"},
+		},
+		{
+			name:  "pattern replace",
+			goMod: "require example.com/org/a v1.0.0\n",
+			lf:    &lockfile.Lockfile{Replace: map[string]lockfile.Replace{"example.com/org/...": {Path: "./org/..."}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := mod.ParseGoModData("go.mod", []byte("module example.com/app\n\ngo 1.21\n\n"+tt.goMod))
+			if err != nil {
+				t.Fatal(err)
+			}
+			missing, extra, differs := compareModules(tt.lf, info, all)
+			if !reflect.DeepEqual(missing, tt.missing) || !reflect.DeepEqual(extra, tt.extra) || !reflect.DeepEqual(differs, tt.differs) {
+				t.Errorf("compareModules() = %q, %q, %q; want %q, %q, %q", missing, extra, differs, tt.missing, tt.extra, tt.differs)
+			}
+		})
+	}
+}
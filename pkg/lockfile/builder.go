@@ -0,0 +1,129 @@
+package lockfile
+
+import "strings"
+
+// BuilderSchema versions the builder JSON interface. It changes only when
+// buildNopherGoApp needs a different structure, independently of the
+// lockfile's SchemaVersion.
+const BuilderSchema = 1
+
+// Fetcher names the Nix fetcher fetchGoModule uses for a locked module.
+type Fetcher string
+
+const (
+	// FetchGit checks out a GitHub archive's full rev with builtins.fetchGit.
+	FetchGit Fetcher = "fetchGit"
+	// FetchZip unpacks a GitHub or GitLab archive with fetchzip; the hash
+	// covers the unpacked tree.
+	FetchZip Fetcher = "fetchzip"
+	// FetchURL downloads the module zip with fetchurl.
+	FetchURL Fetcher = "fetchurl"
+)
+
+// FetcherFor returns the fetcher for a module locked with url, rev and
+// hashType: builtins.fetchGit for a GitHub archive with a full rev, fetchzip
+// for one without, or a GitLab API archive, whose hash is a HashNAR, and
+// fetchurl for everything else, including archives locked by their raw
+// bytes.
+func FetcherFor(url, rev, hashType string) Fetcher {
+	if strings.Contains(url, "/api/v4/projects/") && strings.Contains(url, "/repository/archive.zip?") {
+		if hashType == HashNAR {
+			return FetchZip
+		}
+		return FetchURL
+	}
+	if !strings.HasPrefix(url, "https://github.com/") || !strings.Contains(url, "/archive/") {
+		return FetchURL
+	}
+	if len(rev) == 40 {
+		return FetchGit
+	}
+	if hashType == HashNAR {
+		return FetchZip
+	}
+	return FetchURL
+}
+
+// Builder is the lockfile in the structure buildNopherGoApp consumes: one
+// flat list of modules to vendor, each with the fetcher that fetches it.
+// It is the machine interface to the Nix builder, so the lockfile schema
+// can change without breaking it.
+type Builder struct {
+	Schema    int                        `json:"builderSchema"`
+	Go        string                     `json:"go"`
+	Modules   []BuilderModule            `json:"modules"`
+	Workspace map[string]WorkspaceMember `json:"workspace,omitempty"`
+}
+
+// BuilderModule is a module to vendor: a locked module, or a replacement.
+type BuilderModule struct {
+	// Path is the module path, or for a replacement the original module
+	// path or pattern.
+	Path string `json:"path"`
+	// Version is the locked version, or for a replacement the required
+	// version it replaces; empty for an unused replacement.
+	Version string `json:"version,omitempty"`
+	// Match is the version a version-specific replacement is limited to.
+	Match string `json:"match,omitempty"`
+	// Replace is the module a remote replacement fetches instead.
+	Replace *BuilderTarget `json:"replace,omitempty"`
+	// Local is the directory of a local replacement, which is not fetched.
+	Local string `json:"local,omitempty"`
+
+	Fetcher Fetcher  `json:"fetcher,omitempty"`
+	Hash    string   `json:"hash,omitempty"`
+	URL     string   `json:"url,omitempty"`
+	URLs    []string `json:"urls,omitempty"`
+	Rev     string   `json:"rev,omitempty"`
+	Subdir  string   `json:"subdir,omitempty"`
+	// Via names the local replacement requiring the module, which is then
+	// not marked explicit in vendor/modules.txt.
+	Via string `json:"via,omitempty"`
+}
+
+// BuilderTarget is the module a replacement fetches.
+type BuilderTarget struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+}
+
+// Builder returns the builder form of the lockfile: its modules sorted by
+// path, followed by its replacements sorted by original path.
+func (lf *Lockfile) Builder() *Builder {
+	b := &Builder{Schema: BuilderSchema, Go: lf.Go, Modules: []BuilderModule{}, Workspace: lf.Workspace}
+
+	for _, path := range sortedKeys(lf.Modules) {
+		m := lf.Modules[path]
+		b.Modules = append(b.Modules, BuilderModule{
+			Path:    path,
+			Version: m.Version,
+			Fetcher: FetcherFor(m.URL, m.Rev, m.HashType),
+			Hash:    m.Hash,
+			URL:     m.URL,
+			URLs:    m.URLs,
+			Rev:     m.Rev,
+			Subdir:  m.Subdir,
+			Via:     m.Via,
+		})
+	}
+	for _, path := range sortedKeys(lf.Replace) {
+		r := lf.Replace[path]
+		if r.Path != "" {
+			b.Modules = append(b.Modules, BuilderModule{Path: path, Match: r.Match, Local: r.Path})
+			continue
+		}
+		b.Modules = append(b.Modules, BuilderModule{
+			Path:    path,
+			Version: r.OldVersion,
+			Match:   r.Match,
+			Replace: &BuilderTarget{Path: r.New, Version: r.Version},
+			Fetcher: FetcherFor(r.URL, r.Rev, r.HashType),
+			Hash:    r.Hash,
+			URL:     r.URL,
+			URLs:    r.URLs,
+			Rev:     r.Rev,
+			Subdir:  r.Subdir,
+		})
+	}
+	return b
+}
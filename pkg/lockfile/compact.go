@@ -0,0 +1,68 @@
+package lockfile
+
+import "sort"
+
+// compact returns a copy of lf in its on-disk form. Redundant Old fields
+// (equal to the map key) are dropped, and remote replacements that share the
+// same New@Version target store hash and source data only on the first entry
+// in key order.
+func (lf *Lockfile) compact() *Lockfile {
+	if len(lf.Replace) == 0 {
+		return lf
+	}
+
+	out := *lf
+	out.Replace = make(map[string]Replace, len(lf.Replace))
+
+	seen := make(map[string]bool)
+	for _, key := range sortedKeys(lf.Replace) {
+		r := lf.Replace[key]
+		if r.Old == key {
+			r.Old = ""
+		}
+		if r.Path == "" && r.New != "" {
+			target := r.New + "@" + r.Version
+			if seen[target] {
+				r.Hash, r.HashType, r.URL, r.URLs, r.Rev, r.Subdir, r.Size, r.Files, r.Sum = "", "", "", nil, "", "", 0, 0, ""
+			}
+			seen[target] = true
+		}
+		out.Replace[key] = r
+	}
+
+	return &out
+}
+
+// expand restores replacement data removed by compact, so every remote
+// replacement carries its own hash and source fields in memory.
+func (lf *Lockfile) expand() {
+	targets := make(map[string]Replace)
+	for _, key := range sortedKeys(lf.Replace) {
+		r := lf.Replace[key]
+		if r.Path == "" && r.New != "" && r.Hash != "" {
+			target := r.New + "@" + r.Version
+			if _, ok := targets[target]; !ok {
+				targets[target] = r
+			}
+		}
+	}
+
+	for key, r := range lf.Replace {
+		if r.Path != "" || r.New == "" || r.Hash != "" {
+			continue
+		}
+		if src, ok := targets[r.New+"@"+r.Version]; ok {
+			r.Hash, r.HashType, r.URL, r.URLs, r.Rev, r.Subdir, r.Size, r.Files, r.Sum = src.Hash, src.HashType, src.URL, src.URLs, src.Rev, src.Subdir, src.Size, src.Files, src.Sum
+			lf.Replace[key] = r
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
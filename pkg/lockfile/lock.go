@@ -0,0 +1,29 @@
+package lockfile
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLocked is returned by Lock when another process holds the lock.
+var ErrLocked = errors.New("another nopher process is updating this project")
+
+// Lock takes an advisory lock on the project directory dir for a
+// read-modify-write of its lockfile (and go.mod, for commands that change
+// it), so concurrent writers cannot interleave and lose each other's
+// changes. It fails fast with ErrLocked rather than waiting. The lock is
+// held until the returned function is called, or the process exits.
+//
+// Locking the directory rather than the lockfile covers every profile and
+// works before the lockfile exists, without leaving a lock file behind. On
+// platforms without flock, Lock does nothing.
+func Lock(dir string) (unlock func(), err error) {
+	unlock, err = lockDir(dir)
+	if err != nil {
+		if errors.Is(err, ErrLocked) {
+			return nil, fmt.Errorf("%w (%s); try again when it finishes", ErrLocked, dir)
+		}
+		return nil, fmt.Errorf("locking %s: %w", dir, err)
+	}
+	return unlock, nil
+}
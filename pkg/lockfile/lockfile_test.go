@@ -1,8 +1,12 @@
 package lockfile
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -29,7 +33,7 @@ func TestLoadAndSave(t *testing.T) {
 
 	// Create a lockfile
 	original := &Lockfile{
-		Schema: 1,
+		Schema: SchemaVersion,
 		Go:     "1.21",
 		Modules: map[string]Module{
 			"github.com/example/repo": {
@@ -104,6 +108,138 @@ func TestLoadAndSave(t *testing.T) {
 	}
 }
 
+func TestFilename(t *testing.T) {
+	tests := []struct {
+		profile string
+		want    string
+	}{
+		{"", "nopher.lock.yaml"},
+		{"minimal", "nopher.minimal.lock.yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			if got := Filename(tt.profile); got != tt.want {
+				t.Errorf("Filename(%q) = %q, want %q", tt.profile, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompressedRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	lf := New("1.21")
+	lf.Modules["github.com/example/repo"] = Module{Version: "v1.2.3", Hash: "sha256-abcd1234"}
+
+	path := filepath.Join(tmpDir, DefaultLockfile+CompressedSuffix)
+	if err := lf.SaveYAML(path); err != nil {
+		t.Fatalf("SaveYAML() error = %v", err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) < 2 || raw[0] != 0x1f || raw[1] != 0x8b {
+		t.Error("compressed lockfile does not start with gzip magic")
+	}
+
+	if got := Path(tmpDir, ""); got != path {
+		t.Errorf("Path() = %q, want compressed variant %q", got, path)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if loaded.Modules["github.com/example/repo"].Hash != "sha256-abcd1234" {
+		t.Errorf("loaded module = %+v", loaded.Modules["github.com/example/repo"])
+	}
+}
+
+func TestTOMLRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	lf := New("1.22")
+	lf.Meta = &Meta{Generator: "nopher test", Proxy: "https://proxy.golang.org"}
+	lf.Modules["github.com/example/repo"] = Module{
+		Version:     "v1.2.3",
+		Hash:        "sha256-abcd1234",
+		URL:         "https://proxy.golang.org/github.com/example/repo/@v/v1.2.3.zip",
+		URLs:        []string{"https://proxy.golang.org/github.com/example/repo/@v/v1.2.3.zip", "https://github.com/example/repo/archive/v1.2.3.zip"},
+		Rev:         "0123456789abcdef0123456789abcdef01234567",
+		Size:        1234,
+		Files:       5,
+		Sum:         "h1:abc=",
+		Annotations: Annotations{Notes: "vendored fork", ReviewedBy: "sec-team"},
+	}
+	lf.Modules["golang.org/x/mod"] = Module{Version: "v0.32.0", Hash: "sha256-efgh5678"}
+	lf.Replace["example.com/a"] = Replace{New: "example.com/fork", Version: "v1.0.0", Hash: "sha256-fork", URL: "https://proxy.golang.org/example.com/fork/@v/v1.0.0.zip"}
+	lf.Replace["example.com/b"] = Replace{New: "example.com/fork", Version: "v1.0.0", Hash: "sha256-fork", URL: "https://proxy.golang.org/example.com/fork/@v/v1.0.0.zip"}
+	lf.Replace["example.com/local"] = Replace{Path: "./local"}
+	lf.Graph = map[string][]string{"example.com/main": {"github.com/example/repo@v1.2.3"}}
+	lf.Quarantine = map[string]Quarantined{"golang.org/x/mod": {Version: "v0.32.0", Reason: "new"}}
+
+	path := filepath.Join(tmpDir, TOMLFilename(""))
+	if err := lf.SaveAs(path); err != nil {
+		t.Fatalf("SaveAs() error = %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`[modules."github.com/example/repo"]`, `reviewedBy = "sec-team"`, "schema = 2"} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("TOML lockfile lacks %q:\n%s", want, raw)
+		}
+	}
+
+	if got := Path(tmpDir, ""); got != path {
+		t.Errorf("Path() = %q, want TOML variant %q", got, path)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, lf) {
+		t.Errorf("TOML round trip:\ngot  %+v\nwant %+v", loaded, lf)
+	}
+
+	// Re-encoding is stable, and matches the YAML lockfile's contents.
+	if err := loaded.SaveTOML(path); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := os.ReadFile(path); string(again) != string(raw) {
+		t.Errorf("re-encoded TOML differs:\n%s\nwant:\n%s", again, raw)
+	}
+	yamlPath := filepath.Join(tmpDir, DefaultLockfile)
+	if err := lf.SaveYAML(yamlPath); err != nil {
+		t.Fatal(err)
+	}
+	fromYAML, err := Load(yamlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromYAML, loaded) {
+		t.Errorf("YAML and TOML lockfiles differ:\nyaml %+v\ntoml %+v", fromYAML, loaded)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]Format{"yaml": FormatYAML, "TOML": FormatTOML} {
+		if got, err := ParseFormat(in); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("json"); err == nil {
+		t.Error("ParseFormat(json) should fail")
+	}
+	if got := TOMLFilename("full"); got != "nopher.full.lock.toml" {
+		t.Errorf("TOMLFilename(full) = %q", got)
+	}
+}
+
 func TestLoadNonExistent(t *testing.T) {
 	_, err := Load("/nonexistent/path/to/lockfile.yaml")
 	if err == nil {
@@ -119,6 +255,38 @@ func TestSaveInvalidDirectory(t *testing.T) {
 	}
 }
 
+func TestSaveKeepsMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, DefaultLockfile)
+	lf := New("1.21")
+	if err := lf.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	mode := func() os.FileMode {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Mode().Perm()
+	}
+	if got := mode(); got != 0o644 {
+		t.Fatalf("new lockfile mode = %04o, want 0644", got)
+	}
+
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := lf.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := mode(); got != 0o600 {
+		t.Errorf("rewritten lockfile mode = %04o, want 0600", got)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want only the lockfile", len(entries))
+	}
+}
+
 func TestLocalReplacement(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -205,6 +373,109 @@ func TestYAMLOmitEmpty(t *testing.T) {
 	}
 }
 
+func TestCarryAnnotations(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	prev := New("1.21")
+	prev.Modules["github.com/example/repo"] = Module{
+		Version:     "v1.0.0",
+		Hash:        "sha256-old",
+		Annotations: Annotations{Notes: "audited", ReviewedBy: "alice"},
+	}
+	prev.Replace["github.com/old/pkg"] = Replace{
+		New:         "github.com/new/pkg",
+		Annotations: Annotations{Notes: "fork with fix"},
+	}
+	if err := prev.Save(tmpDir); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := Load(filepath.Join(tmpDir, DefaultLockfile))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	next := New("1.21")
+	next.Modules["github.com/example/repo"] = Module{Version: "v1.1.0", Hash: "sha256-new"}
+	next.Modules["github.com/other/repo"] = Module{Version: "v1.0.0", Hash: "sha256-other"}
+	next.Replace["github.com/old/pkg"] = Replace{New: "github.com/new/pkg"}
+	next.CarryAnnotations(loaded)
+
+	m := next.Modules["github.com/example/repo"]
+	if m.Notes != "audited" || m.ReviewedBy != "alice" {
+		t.Errorf("Module annotations = %+v, want notes and reviewedBy carried", m.Annotations)
+	}
+	if m.Hash != "sha256-new" {
+		t.Errorf("Module.Hash = %q, want new hash", m.Hash)
+	}
+	if other := next.Modules["github.com/other/repo"]; other.Notes != "" {
+		t.Errorf("unrelated module got notes %q", other.Notes)
+	}
+	if r := next.Replace["github.com/old/pkg"]; r.Notes != "fork with fix" {
+		t.Errorf("Replace.Notes = %q, want %q", r.Notes, "fork with fix")
+	}
+}
+
+func TestCompactReplaceRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	lf := New("1.21")
+	for _, key := range []string{"github.com/a/pkg", "github.com/b/pkg"} {
+		lf.Replace[key] = Replace{
+			Old:     key,
+			New:     "github.com/fork/pkg",
+			Version: "v1.0.0",
+			Hash:    "sha256-shared",
+			URL:     "https://example.com/fork.zip",
+			URLs:    []string{"https://example.com/fork.zip", "https://mirror.example.com/fork.zip"},
+		}
+	}
+
+	if err := lf.Save(tmpDir); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, DefaultLockfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	yamlStr := string(content)
+	if containsString(yamlStr, "old:") {
+		t.Error("YAML contains redundant 'old:' field")
+	}
+	if n := countString(yamlStr, "sha256-shared"); n != 1 {
+		t.Errorf("shared hash written %d times, want 1", n)
+	}
+	if n := countString(yamlStr, "mirror.example.com"); n != 1 {
+		t.Errorf("shared URLs written %d times, want 1", n)
+	}
+
+	loaded, err := Load(filepath.Join(tmpDir, DefaultLockfile))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	for key, r := range loaded.Replace {
+		if r.Hash != "sha256-shared" || r.URL != "https://example.com/fork.zip" || len(r.URLs) != 2 {
+			t.Errorf("Replace[%s] = %+v, want shared hash and URL restored", key, r)
+		}
+	}
+
+	// Saving must not mutate the in-memory lockfile.
+	if lf.Replace["github.com/b/pkg"].Hash != "sha256-shared" {
+		t.Error("Save() modified in-memory replacement")
+	}
+}
+
+func countString(s, substr string) int {
+	n := 0
+	for i := 0; i <= len(s)-len(substr); i++ {
+		if s[i:i+len(substr)] == substr {
+			n++
+		}
+	}
+	return n
+}
+
 func containsString(s, substr string) bool {
 	return len(s) > 0 && len(substr) > 0 && contains(s, substr)
 }
@@ -217,3 +488,264 @@ func contains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestLock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Lock is a no-op without flock")
+	}
+	dir := t.TempDir()
+
+	unlock, err := Lock(dir)
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	if _, err := Lock(dir); !errors.Is(err, ErrLocked) {
+		t.Errorf("second Lock() error = %v, want ErrLocked", err)
+	}
+
+	unlock()
+	unlock, err = Lock(dir)
+	if err != nil {
+		t.Fatalf("Lock() after unlock error = %v", err)
+	}
+	unlock()
+
+	if _, err := Lock(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Lock() of a missing directory succeeded")
+	}
+}
+
+func TestReplaceFor(t *testing.T) {
+	lf := New("1.21")
+	lf.Replace["example.com/org/..."] = Replace{Path: "./org/..."}
+	lf.Replace["example.com/org/tools/..."] = Replace{Path: "../tools/..."}
+	lf.Replace["example.com/org/api"] = Replace{Path: "../api-fork"}
+
+	tests := []struct {
+		path, want string
+		ok         bool
+	}{
+		{"example.com/org", "./org", true},
+		{"example.com/org/lib", "./org/lib", true},
+		{"example.com/org/lib/v2", "./org/lib/v2", true},
+		{"example.com/org/api", "../api-fork", true},        // exact beats pattern
+		{"example.com/org/tools/gen", "../tools/gen", true}, // longer pattern wins
+		{"example.com/org/tools", "../tools", true},
+		{"example.com/orgx", "", false},
+		{"example.com/other", "", false},
+	}
+	for _, tt := range tests {
+		r, ok := lf.ReplaceFor(tt.path)
+		if ok != tt.ok || r.Path != tt.want {
+			t.Errorf("ReplaceFor(%q) = %q, %v; want %q, %v", tt.path, r.Path, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLoadValidatesPatterns(t *testing.T) {
+	tests := map[string]string{
+		"remote": "example.com/org/...:\n    new: example.com/fork\n    version: v1.0.0\n",
+		"path":   "example.com/org/...:\n    path: ./org\n",
+		"prefix": "/...:\n    path: ./...\n",
+	}
+	for name, entry := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), DefaultLockfile)
+			data := "schema: 1\ngo: \"1.21\"\nreplace:\n  " + entry
+			if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Error("Load() succeeded, want invalid pattern error")
+			}
+		})
+	}
+}
+
+func TestCarryPatterns(t *testing.T) {
+	prev := New("1.21")
+	prev.Replace["example.com/org/..."] = Replace{Path: "./org/..."}
+	prev.Replace["example.com/old"] = Replace{Path: "../old"}
+
+	lf := New("1.21")
+	lf.CarryPatterns(prev)
+	if _, ok := lf.Replace["example.com/org/..."]; !ok {
+		t.Error("pattern replacement not carried forward")
+	}
+	if _, ok := lf.Replace["example.com/old"]; ok {
+		t.Error("exact replacement carried forward, want only patterns")
+	}
+}
+
+func TestCarryQuarantine(t *testing.T) {
+	prev := New("1.21")
+	prev.Quarantine = map[string]Quarantined{
+		"github.com/new/dep":  {Version: "v1.0.0", Reason: "new dependency"},
+		"github.com/gone/dep": {Version: "v1.0.0"},
+		"github.com/old/pkg":  {Version: "v1.0.0"},
+	}
+
+	next := New("1.21")
+	next.Modules["github.com/new/dep"] = Module{Version: "v1.1.0", Hash: "sha256-new"}
+	next.Replace["github.com/old/pkg"] = Replace{New: "github.com/fork/pkg", Version: "v2.0.0"}
+	next.CarryQuarantine(prev)
+
+	want := map[string]Quarantined{
+		"github.com/new/dep": {Version: "v1.1.0", Reason: "new dependency"},
+		"github.com/old/pkg": {Version: "v2.0.0"},
+	}
+	if !reflect.DeepEqual(next.Quarantine, want) {
+		t.Errorf("Quarantine = %+v, want %+v", next.Quarantine, want)
+	}
+}
+
+func TestFetcherFor(t *testing.T) {
+	rev := "0123456789abcdef0123456789abcdef01234567"
+	for _, tt := range []struct {
+		url, rev, hashType string
+		want               Fetcher
+	}{
+		{"https://proxy.golang.org/golang.org/x/sys/@v/v0.15.0.zip", "", "", FetchURL},
+		{"https://proxy.golang.org/github.com/a/b/@v/v1.0.0.zip", rev, "", FetchURL},
+		{"https://github.com/a/b/archive/" + rev + ".zip", rev, "", FetchGit},
+		{"https://github.com/a/b/archive/refs/tags/v1.0.0.zip", "0123456", HashNAR, FetchZip},
+		// Archives locked before NAR hashes hash the download itself.
+		{"https://github.com/a/b/archive/refs/tags/v1.0.0.zip", "0123456", "", FetchURL},
+		{"https://gitlab.com/api/v4/projects/a%2Fsub%2Fb/repository/archive.zip?sha=v1.0.0", "", HashNAR, FetchZip},
+		{"", "", "", FetchURL},
+	} {
+		if got := FetcherFor(tt.url, tt.rev, tt.hashType); got != tt.want {
+			t.Errorf("FetcherFor(%q, %q, %q) = %s, want %s", tt.url, tt.rev, tt.hashType, got, tt.want)
+		}
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	_, err := Parse("nopher.lock.yaml", []byte(`schema: 99
+go: "1.22"
+meta:
+  generator: nopher 0.9.0
+modules:
+  example.com/a: [renamed, fields]
+`))
+	var se *SchemaError
+	if !errors.As(err, &se) || se.Schema != 99 {
+		t.Fatalf("Parse() of a newer schema = %v, want a SchemaError", err)
+	}
+	if !strings.Contains(err.Error(), "upgrade nopher to >= 0.9.0") {
+		t.Errorf("error = %q, want the version to upgrade to", err)
+	}
+	if _, err := Parse("nopher.lock.toml", []byte("schema = 99\n")); !errors.As(err, &se) || !strings.HasSuffix(err.Error(), "; upgrade nopher") {
+		t.Errorf("Parse() of a newer TOML schema = %v", err)
+	}
+
+	lf, err := Parse("nopher.lock.yaml", []byte(`schema: 1
+go: "1.22"
+modules:
+  example.com/a:
+    version: v1.0.0
+    hash: sha256-a
+`))
+	if err != nil {
+		t.Fatalf("Parse() of schema 1 = %v", err)
+	}
+	if lf.Schema != SchemaVersion || lf.Modules["example.com/a"].Hash != "sha256-a" {
+		t.Errorf("schema 1 lockfile = %+v, want it upgraded to schema %d", lf, SchemaVersion)
+	}
+	for v := 1; v < SchemaVersion; v++ {
+		if upgrades[v] == nil {
+			t.Errorf("no upgrade from schema %d", v)
+		}
+	}
+}
+
+func TestLegacyArchiveHash(t *testing.T) {
+	// Before hashType, archives without a full rev locked the download's
+	// bytes, which fetchzip would check against the wrong hash.
+	lf, err := Parse("nopher.lock.yaml", []byte(`schema: 1
+go: "1.22"
+modules:
+  github.com/a/old:
+    version: v1.0.0
+    hash: sha256-archive
+    url: https://github.com/a/old/archive/refs/tags/v1.0.0.zip
+    rev: "0123456"
+  github.com/a/new:
+    version: v1.0.0
+    hash: sha256-tree
+    hashType: nar
+    url: https://github.com/a/new/archive/refs/tags/v1.0.0.zip
+    rev: "0123456"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := lf.Modules["github.com/a/old"]; m.HashType != "" || FetcherFor(m.URL, m.Rev, m.HashType) != FetchURL {
+		t.Errorf("legacy archive entry = %+v, want it fetched with fetchurl", m)
+	}
+	b := lf.Builder()
+	if m := b.Modules[0]; m.Path != "github.com/a/new" || m.Fetcher != FetchZip {
+		t.Errorf("NAR-hashed archive = %+v, want fetchzip", m)
+	}
+	if m := b.Modules[1]; m.Path != "github.com/a/old" || m.Fetcher != FetchURL {
+		t.Errorf("legacy archive = %+v, want fetchurl", m)
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	lf, err := Parse("nopher.lock.yaml", []byte(`schema: 1
+go: "1.22"
+modules:
+  golang.org/x/sys:
+    version: v0.15.0
+    hash: sha256-sys
+    url: https://proxy.golang.org/golang.org/x/sys/@v/v0.15.0.zip
+    size: 100
+  example.com/indirect:
+    version: v1.0.0
+    hash: sha256-ind
+    via: example.com/shared
+replace:
+  github.com/a/pkg:
+    oldVersion: v1.0.0
+    new: github.com/fork/pkg
+    version: v1.0.1
+    hash: sha256-fork
+  github.com/b/pkg:
+    new: github.com/fork/pkg
+    version: v1.0.1
+  example.com/shared:
+    path: ./shared
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := lf.Builder()
+	if b.Schema != BuilderSchema || b.Go != "1.22" {
+		t.Errorf("Builder() schema, go = %d, %q", b.Schema, b.Go)
+	}
+	var paths []string
+	for _, m := range b.Modules {
+		paths = append(paths, m.Path)
+	}
+	want := []string{"example.com/indirect", "golang.org/x/sys", "example.com/shared", "github.com/a/pkg", "github.com/b/pkg"}
+	if strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Fatalf("Builder() paths = %q, want modules then replacements, sorted: %q", paths, want)
+	}
+
+	if m := b.Modules[0]; m.Via != "example.com/shared" || m.Fetcher != FetchURL {
+		t.Errorf("indirect module = %+v", m)
+	}
+	if m := b.Modules[2]; m.Local != "./shared" || m.Replace != nil || m.Fetcher != "" {
+		t.Errorf("local replacement = %+v", m)
+	}
+	// Replacements sharing a target each carry its hash, unlike the
+	// compacted lockfile.
+	if m := b.Modules[4]; m.Replace == nil || m.Replace.Path != "github.com/fork/pkg" || m.Version != "" || m.Hash != "sha256-fork" {
+		t.Errorf("unused replacement = %+v", m)
+	}
+	if m := b.Modules[3]; m.Version != "v1.0.0" || m.Hash != "sha256-fork" {
+		t.Errorf("replacement = %+v", m)
+	}
+}
@@ -0,0 +1,90 @@
+package lockfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PatternSuffix marks a Replace key that covers a whole module tree: the key
+// "example.com/org/..." matches example.com/org and every module below it.
+// Pattern replacements must be local, with a Path that also ends in
+// PatternSuffix; the part of the module path below the pattern's prefix is
+// substituted for it, so "example.com/org/..." => "./org/..." replaces
+// example.com/org/api with ./org/api.
+//
+// go.mod cannot express such replacements, so generation never produces
+// them; they are written by hand and carried forward from the previous
+// lockfile. Modules they cover are still locked and fetched as usual, which
+// keeps vendoring consistent with go.mod; the pattern only overrides their
+// vendored source with the local directories.
+const PatternSuffix = "/..."
+
+// IsPattern reports whether a Replace key is a pattern.
+func IsPattern(key string) bool {
+	return strings.HasSuffix(key, PatternSuffix)
+}
+
+// ReplaceFor returns the replacement applying to modulePath. An exact key
+// takes precedence over any pattern, and a longer pattern over a shorter
+// one. For a pattern match, the returned Replace has its Path resolved for
+// modulePath.
+func (lf *Lockfile) ReplaceFor(modulePath string) (Replace, bool) {
+	if r, ok := lf.Replace[modulePath]; ok {
+		return r, true
+	}
+
+	var best string
+	for key := range lf.Replace {
+		prefix, ok := strings.CutSuffix(key, PatternSuffix)
+		if !ok || len(prefix) <= len(best) {
+			continue
+		}
+		if modulePath == prefix || strings.HasPrefix(modulePath, prefix+"/") {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return Replace{}, false
+	}
+
+	r := lf.Replace[best+PatternSuffix]
+	r.Path = strings.TrimSuffix(r.Path, PatternSuffix) + strings.TrimPrefix(modulePath, best)
+	return r, true
+}
+
+// CarryPatterns copies pattern replacements from prev into lf, since
+// generation cannot derive them from go.mod.
+func (lf *Lockfile) CarryPatterns(prev *Lockfile) {
+	if prev == nil {
+		return
+	}
+	for key, r := range prev.Replace {
+		if !IsPattern(key) {
+			continue
+		}
+		if lf.Replace == nil {
+			lf.Replace = make(map[string]Replace)
+		}
+		lf.Replace[key] = r
+	}
+}
+
+// validatePatterns checks that pattern replacements are local and map a tree
+// onto a tree.
+func (lf *Lockfile) validatePatterns() error {
+	for _, key := range sortedKeys(lf.Replace) {
+		if !IsPattern(key) {
+			continue
+		}
+		r := lf.Replace[key]
+		switch {
+		case key == PatternSuffix || strings.HasPrefix(key, "/"):
+			return fmt.Errorf("replace %s: pattern needs a module path prefix", key)
+		case r.Path == "":
+			return fmt.Errorf("replace %s: pattern replacements must be local (set path, not new)", key)
+		case !IsPattern(r.Path):
+			return fmt.Errorf("replace %s: path %q must end in %s", key, r.Path, PatternSuffix)
+		}
+	}
+	return nil
+}
@@ -1,38 +1,134 @@
 // Package lockfile provides types and functions for working with nopher lockfiles.
 package lockfile
 
-// Schema version for the lockfile format.
-const SchemaVersion = 1
+// Schema version for the lockfile format. Lockfiles with an older schema
+// are upgraded when loaded; see upgrades.
+const SchemaVersion = 2
+
+// HashNAR is the HashType of an entry whose hash is the NAR hash of an
+// unpacked GitHub archive, as fetchzip checks it. Entries without a
+// HashType hash the downloaded bytes; older lockfiles locked archives
+// without a full rev that way, and still build with fetchurl.
+const HashNAR = "nar"
 
 // Lockfile represents the nopher.lock.yaml file structure.
 type Lockfile struct {
-	Schema  int                `json:"schema" yaml:"schema"`
-	Go      string             `json:"go" yaml:"go"`
-	Modules map[string]Module  `json:"modules,omitempty" yaml:"modules,omitempty"`
-	Replace map[string]Replace `json:"replace,omitempty" yaml:"replace,omitempty"`
+	Schema  int                `json:"schema" yaml:"schema" toml:"schema"`
+	Go      string             `json:"go" yaml:"go" toml:"go"`
+	Meta    *Meta              `json:"meta,omitempty" yaml:"meta,omitempty" toml:"meta,omitempty"`
+	Modules map[string]Module  `json:"modules,omitempty" yaml:"modules,omitempty" toml:"modules,omitempty"`
+	Replace map[string]Replace `json:"replace,omitempty" yaml:"replace,omitempty" toml:"replace,omitempty"`
+	// Graph maps each module (path@version, or the bare main module path) to
+	// its requirements, as reported by go mod graph.
+	Graph map[string][]string `json:"graph,omitempty" yaml:"graph,omitempty" toml:"graph,omitempty"`
+	// Workspace lists go.work members by module path when the lockfile was
+	// generated for a workspace. Modules and Replace then hold the dependency
+	// set shared by all members.
+	Workspace map[string]WorkspaceMember `json:"workspace,omitempty" yaml:"workspace,omitempty" toml:"workspace,omitempty"`
+	// Quarantine lists locked modules, by module path or replaced path, that
+	// were fetched but have not been approved for use yet. verify fails while
+	// any remain.
+	Quarantine map[string]Quarantined `json:"quarantine,omitempty" yaml:"quarantine,omitempty" toml:"quarantine,omitempty"`
+}
+
+// Quarantined is a module awaiting review.
+type Quarantined struct {
+	Version string `json:"version,omitempty" yaml:"version,omitempty" toml:"version,omitempty"` // Locked version; empty for local replacements
+	Reason  string `json:"reason,omitempty" yaml:"reason,omitempty" toml:"reason,omitempty"`
+}
+
+// WorkspaceMember is a module listed in go.work.
+type WorkspaceMember struct {
+	Dir string `json:"dir" yaml:"dir" toml:"dir"` // Relative to the workspace root, e.g. "./services/api"
+	// Requires lists path@version requirements of this member that are older
+	// than the locked version. Vendoring marks them explicit so go's
+	// consistency check accepts the member's go.mod.
+	Requires []string `json:"requires,omitempty" yaml:"requires,omitempty" toml:"requires,omitempty"`
+}
+
+// Meta records provenance information about how the lockfile was generated.
+type Meta struct {
+	Generator   string `json:"generator,omitempty" yaml:"generator,omitempty" toml:"generator,omitempty"`       // e.g. "nopher 0.1.0"
+	Proxy       string `json:"proxy,omitempty" yaml:"proxy,omitempty" toml:"proxy,omitempty"`                   // GOPROXY used, or "direct"
+	GeneratedAt string `json:"generatedAt,omitempty" yaml:"generatedAt,omitempty" toml:"generatedAt,omitempty"` // RFC 3339, omitted in reproducible mode
 }
 
 // Module represents a single Go module dependency.
 type Module struct {
-	Version string `json:"version" yaml:"version"`
-	Hash    string `json:"hash" yaml:"hash"`
-	URL     string `json:"url,omitempty" yaml:"url,omitempty"`
-	Rev     string `json:"rev,omitempty" yaml:"rev,omitempty"`
+	Version string `json:"version" yaml:"version" toml:"version"`
+	Hash    string `json:"hash" yaml:"hash" toml:"hash"`
+	// HashType is HashNAR when Hash covers the unpacked tree of a GitHub
+	// archive rather than a zip's bytes.
+	HashType string `json:"hashType,omitempty" yaml:"hashType,omitempty" toml:"hashType,omitempty"`
+	URL      string `json:"url,omitempty" yaml:"url,omitempty" toml:"url,omitempty"`
+	// URLs lists every known source of the zip, URL first, so fetchers can
+	// fall back if it disappears. Omitted when URL is the only one.
+	URLs   []string `json:"urls,omitempty" yaml:"urls,omitempty" toml:"urls,omitempty"`
+	Rev    string   `json:"rev,omitempty" yaml:"rev,omitempty" toml:"rev,omitempty"`
+	Subdir string   `json:"subdir,omitempty" yaml:"subdir,omitempty" toml:"subdir,omitempty"` // Module directory within the repository
+	Size   int64    `json:"size,omitempty" yaml:"size,omitempty" toml:"size,omitzero"`        // Uncompressed size in bytes
+	Files  int      `json:"files,omitempty" yaml:"files,omitempty" toml:"files,omitzero"`     // Number of regular files
+	Sum    string   `json:"sum,omitempty" yaml:"sum,omitempty" toml:"sum,omitempty"`          // h1: hash from go.sum
+	// Via names the local replacement whose go.mod requires this module,
+	// when go.mod itself does not. Vendoring then does not mark it explicit.
+	Via string `json:"via,omitempty" yaml:"via,omitempty" toml:"via,omitempty"`
+	// GoModReplace lists the replace directives in the module's own go.mod,
+	// as old => new. go ignores them outside the main module, so the build
+	// compiles the versions this lockfile selects, not the ones the
+	// module's authors replaced in, such as their forks.
+	GoModReplace []string `json:"goModReplace,omitempty" yaml:"goModReplace,omitempty" toml:"goModReplace,omitempty"`
+
+	Annotations `yaml:",inline"`
+}
+
+// Annotations holds human-maintained review metadata. Generation never
+// produces these fields; they are carried forward from the previous lockfile.
+type Annotations struct {
+	Notes      string `json:"notes,omitempty" yaml:"notes,omitempty" toml:"notes,omitempty"`
+	ReviewedBy string `json:"reviewedBy,omitempty" yaml:"reviewedBy,omitempty" toml:"reviewedBy,omitempty"`
 }
 
 // Replace represents a module replacement directive.
 type Replace struct {
 	// For remote replacements
-	Old        string `json:"old,omitempty" yaml:"old,omitempty"`               // Original module path (usually same as key)
-	OldVersion string `json:"oldVersion,omitempty" yaml:"oldVersion,omitempty"` // Original version from go.mod
-	New        string `json:"new,omitempty" yaml:"new,omitempty"`
-	Version    string `json:"version,omitempty" yaml:"version,omitempty"` // New version
-	Hash       string `json:"hash,omitempty" yaml:"hash,omitempty"`
-	URL        string `json:"url,omitempty" yaml:"url,omitempty"`
-	Rev        string `json:"rev,omitempty" yaml:"rev,omitempty"`
+	Old        string   `json:"old,omitempty" yaml:"old,omitempty" toml:"old,omitempty"`                      // Original module path; omitted when same as key
+	OldVersion string   `json:"oldVersion,omitempty" yaml:"oldVersion,omitempty" toml:"oldVersion,omitempty"` // Required version being replaced; empty if unused
+	Match      string   `json:"match,omitempty" yaml:"match,omitempty" toml:"match,omitempty"`                // Version a version-specific directive is limited to
+	New        string   `json:"new,omitempty" yaml:"new,omitempty" toml:"new,omitempty"`
+	Version    string   `json:"version,omitempty" yaml:"version,omitempty" toml:"version,omitempty"` // New version
+	Hash       string   `json:"hash,omitempty" yaml:"hash,omitempty" toml:"hash,omitempty"`
+	HashType   string   `json:"hashType,omitempty" yaml:"hashType,omitempty" toml:"hashType,omitempty"` // HashNAR for an unpacked archive tree
+	URL        string   `json:"url,omitempty" yaml:"url,omitempty" toml:"url,omitempty"`
+	URLs       []string `json:"urls,omitempty" yaml:"urls,omitempty" toml:"urls,omitempty"`
+	Rev        string   `json:"rev,omitempty" yaml:"rev,omitempty" toml:"rev,omitempty"`
+	Subdir     string   `json:"subdir,omitempty" yaml:"subdir,omitempty" toml:"subdir,omitempty"`
+	Size       int64    `json:"size,omitempty" yaml:"size,omitempty" toml:"size,omitzero"`
+	Files      int      `json:"files,omitempty" yaml:"files,omitempty" toml:"files,omitzero"`
+	Sum        string   `json:"sum,omitempty" yaml:"sum,omitempty" toml:"sum,omitempty"` // h1: hash from go.sum
+	// GoModReplace lists the replace directives in the replacement's own
+	// go.mod, which go ignores; see Module.GoModReplace.
+	GoModReplace []string `json:"goModReplace,omitempty" yaml:"goModReplace,omitempty" toml:"goModReplace,omitempty"`
 
 	// For local replacements
-	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	Path string `json:"path,omitempty" yaml:"path,omitempty" toml:"path,omitempty"`
+
+	Annotations `yaml:",inline"`
+}
+
+// Target describes where the replacement points: the directory of a local
+// replacement, or new@version.
+func (r Replace) Target() string {
+	if r.Path != "" {
+		return r.Path
+	}
+	return r.New + "@" + r.Version
+}
+
+// Applies reports whether the replacement applies to version of the
+// original module: always for a directive without a version on its left
+// side, and otherwise only to the version it names.
+func (r Replace) Applies(version string) bool {
+	return r.Match == "" || r.Match == version
 }
 
 // New creates a new Lockfile with the given Go version.
@@ -44,3 +140,56 @@ func New(goVersion string) *Lockfile {
 		Replace: make(map[string]Replace),
 	}
 }
+
+// CarryAnnotations copies review annotations from prev into lf for every
+// module and replacement that exists in both.
+func (lf *Lockfile) CarryAnnotations(prev *Lockfile) {
+	if prev == nil {
+		return
+	}
+	for path, m := range lf.Modules {
+		if old, ok := prev.Modules[path]; ok {
+			m.Annotations = old.Annotations
+			lf.Modules[path] = m
+		}
+	}
+	for path, r := range lf.Replace {
+		if old, ok := prev.Replace[path]; ok {
+			r.Annotations = old.Annotations
+			lf.Replace[path] = r
+		}
+	}
+}
+
+// LockedVersion returns the version locked for path, as a module or as the
+// target of a replacement, and whether path is locked at all. Local
+// replacements have no version.
+func (lf *Lockfile) LockedVersion(path string) (string, bool) {
+	if m, ok := lf.Modules[path]; ok {
+		return m.Version, true
+	}
+	if r, ok := lf.Replace[path]; ok && !IsPattern(path) {
+		return r.Version, true
+	}
+	return "", false
+}
+
+// CarryQuarantine copies quarantine entries from prev into lf for every
+// module lf still locks. An entry stays until it is released by hand, even
+// when the module moves to another version, which is recorded instead.
+func (lf *Lockfile) CarryQuarantine(prev *Lockfile) {
+	if prev == nil {
+		return
+	}
+	for path, q := range prev.Quarantine {
+		version, ok := lf.LockedVersion(path)
+		if !ok {
+			continue
+		}
+		if lf.Quarantine == nil {
+			lf.Quarantine = make(map[string]Quarantined)
+		}
+		q.Version = version
+		lf.Quarantine[path] = q
+	}
+}
@@ -0,0 +1,70 @@
+package lockfile
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// TOMLSuffix ends the name of a TOML lockfile, such as nopher.lock.toml. Nix
+// reads TOML lockfiles with builtins.fromTOML, without the import from
+// derivation YAML needs.
+const TOMLSuffix = ".toml"
+
+// Format is a lockfile encoding.
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
+)
+
+// ParseFormat parses a --format value.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatYAML, FormatTOML:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown lockfile format %q (want yaml or toml)", s)
+}
+
+// FormatOf returns the format the lockfile name selects: TOML for names
+// ending in TOMLSuffix, and YAML, possibly gzip-compressed, otherwise.
+func FormatOf(name string) Format {
+	if strings.HasSuffix(name, TOMLSuffix) {
+		return FormatTOML
+	}
+	return FormatYAML
+}
+
+// TOMLFilename returns the TOML lockfile name for profile, such as
+// nopher.lock.toml.
+func TOMLFilename(profile string) string {
+	return strings.TrimSuffix(Filename(profile), ".yaml") + TOMLSuffix
+}
+
+// SaveTOML writes the lockfile in TOML format, with replacement entries in
+// compact form like SaveYAML.
+func (lf *Lockfile) SaveTOML(path string) error {
+	var buf bytes.Buffer
+	enc := toml.NewEncoder(&buf)
+	enc.Indent = ""
+	if err := enc.Encode(lf.compact()); err != nil {
+		return fmt.Errorf("marshaling TOML: %w", err)
+	}
+	if err := writeFile(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("writing lockfile: %w", err)
+	}
+	return nil
+}
+
+// SaveAs writes the lockfile to path in the format its name selects (see
+// FormatOf).
+func (lf *Lockfile) SaveAs(path string) error {
+	if FormatOf(path) == FormatTOML {
+		return lf.SaveTOML(path)
+	}
+	return lf.SaveYAML(path)
+}
@@ -0,0 +1,91 @@
+package lockfile
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// upgrades maps each schema version older than SchemaVersion to the
+// function that upgrades a lockfile decoded from it to the next version.
+// Fields are only ever added with a new version, so decoding an older
+// lockfile into the current types is safe; the functions fill in what the
+// newer schema means differently.
+var upgrades = map[int]func(*Lockfile){
+	// Schema 2 added hashType. Schema 1 lockfiles locked GitHub archives
+	// without a full rev by their bytes, which an entry without hashType
+	// still means, so there is nothing to rewrite.
+	1: func(*Lockfile) {},
+}
+
+// SchemaError is returned for a lockfile written with a newer schema than
+// this version of nopher supports. Decoding it would silently drop or
+// misread whatever the newer schema changed.
+type SchemaError struct {
+	Schema    int
+	Generator string // Meta.Generator of the lockfile, e.g. "nopher 0.2.0"
+}
+
+func (e *SchemaError) Error() string {
+	msg := fmt.Sprintf("lockfile schema %d is newer than this nopher supports (schema %d)", e.Schema, SchemaVersion)
+	if name, version, ok := strings.Cut(e.Generator, " "); ok && name == "nopher" && version != "" {
+		return msg + "; upgrade nopher to >= " + version
+	}
+	return msg + "; upgrade nopher"
+}
+
+// schemaHeader is the part of a lockfile decoded before the rest, to pick
+// how the rest is read.
+type schemaHeader struct {
+	Schema int `yaml:"schema" toml:"schema"`
+	Meta   *struct {
+		Generator string `yaml:"generator" toml:"generator"`
+	} `yaml:"meta" toml:"meta"`
+}
+
+// checkSchema decodes the schema version of a lockfile in format and fails
+// with a SchemaError if it is newer than SchemaVersion. A lockfile without
+// a schema is read as schema 1.
+func checkSchema(format Format, data []byte) (int, error) {
+	var header schemaHeader
+	unmarshal := yaml.Unmarshal
+	if format == FormatTOML {
+		unmarshal = toml.Unmarshal
+	}
+	if err := unmarshal(data, &header); err != nil {
+		return 0, fmt.Errorf("parsing lockfile: %w", err)
+	}
+	switch {
+	case header.Schema > SchemaVersion:
+		err := &SchemaError{Schema: header.Schema}
+		if header.Meta != nil {
+			err.Generator = header.Meta.Generator
+		}
+		return 0, err
+	case header.Schema < 1:
+		return 1, nil
+	}
+	return header.Schema, nil
+}
+
+// warnedUpgrade records the lockfiles already warned about, so commands
+// that load one several times warn once.
+var warnedUpgrade sync.Map
+
+// upgrade brings lf, decoded from schema version from, up to SchemaVersion
+// in memory. Saving it then writes the current schema.
+func (lf *Lockfile) upgrade(name string, from int) {
+	for v := from; v < SchemaVersion; v++ {
+		upgrades[v](lf)
+	}
+	lf.Schema = SchemaVersion
+	if from < SchemaVersion {
+		if _, warned := warnedUpgrade.LoadOrStore(name, true); !warned {
+			fmt.Fprintf(os.Stderr, "warning: %s uses lockfile schema %d; read as schema %d, which it is written as when saved\n", name, from, SchemaVersion)
+		}
+	}
+}
@@ -1,48 +1,166 @@
 package lockfile
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/BurntSushi/toml"
 	"gopkg.in/yaml.v3"
 )
 
 const (
 	// DefaultLockfile is the default lockfile name.
 	DefaultLockfile = "nopher.lock.yaml"
+
+	// CompressedSuffix is appended to a lockfile name for the gzip variant.
+	CompressedSuffix = ".gz"
 )
 
 // Load reads a lockfile from the given path.
+// Paths ending in CompressedSuffix are decompressed transparently, and
+// paths ending in TOMLSuffix are read as TOML.
 func Load(path string) (*Lockfile, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("reading lockfile: %w", err)
 	}
+	return Parse(path, data)
+}
+
+// Parse parses lockfile contents read from elsewhere, such as a git
+// revision. name is used to detect the encoding, and in warnings: data is
+// decompressed when name ends in CompressedSuffix, and parsed as TOML when
+// it ends in TOMLSuffix. A lockfile with a newer schema than SchemaVersion
+// fails with a SchemaError; an older one is upgraded in memory.
+func Parse(name string, data []byte) (*Lockfile, error) {
+	if strings.HasSuffix(name, CompressedSuffix) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decompressing lockfile: %w", err)
+		}
+		data, err = io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing lockfile: %w", err)
+		}
+	}
+
+	schema, err := checkSchema(FormatOf(name), data)
+	if err != nil {
+		return nil, err
+	}
 
 	var lf Lockfile
-	if err := yaml.Unmarshal(data, &lf); err != nil {
+	unmarshal := yaml.Unmarshal
+	if FormatOf(name) == FormatTOML {
+		unmarshal = toml.Unmarshal
+	}
+	if err := unmarshal(data, &lf); err != nil {
+		return nil, fmt.Errorf("parsing lockfile: %w", err)
+	}
+	lf.upgrade(name, schema)
+	lf.expand()
+	if err := lf.validatePatterns(); err != nil {
 		return nil, fmt.Errorf("parsing lockfile: %w", err)
 	}
 
 	return &lf, nil
 }
 
+// Filename returns the lockfile name for profile. An empty profile selects
+// DefaultLockfile; otherwise the name is nopher.<profile>.lock.yaml.
+func Filename(profile string) string {
+	if profile == "" {
+		return DefaultLockfile
+	}
+	return "nopher." + profile + ".lock.yaml"
+}
+
+// Path returns the lockfile path for profile within dir. The plaintext YAML
+// name is preferred; the gzip or TOML variant is returned only when it
+// exists and the plaintext one does not, so lockfiles keep their encoding
+// when rewritten.
+func Path(dir, profile string) string {
+	plain := filepath.Join(dir, Filename(profile))
+	if _, err := os.Stat(plain); err != nil {
+		for _, alt := range []string{plain + CompressedSuffix, filepath.Join(dir, TOMLFilename(profile))} {
+			if _, err := os.Stat(alt); err == nil {
+				return alt
+			}
+		}
+	}
+	return plain
+}
+
 // Save writes the lockfile in YAML format.
 func (lf *Lockfile) Save(dir string) error {
 	return lf.SaveYAML(filepath.Join(dir, DefaultLockfile))
 }
 
 // SaveYAML writes the lockfile in YAML format.
+// Replacement entries are written in compact form (see compact). Paths ending
+// in CompressedSuffix are gzip-compressed.
 func (lf *Lockfile) SaveYAML(path string) error {
-	data, err := yaml.Marshal(lf)
+	data, err := yaml.Marshal(lf.compact())
 	if err != nil {
 		return fmt.Errorf("marshaling YAML: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if strings.HasSuffix(path, CompressedSuffix) {
+		// The default gzip header has no name or mtime, keeping output deterministic.
+		var buf bytes.Buffer
+		zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if err != nil {
+			return fmt.Errorf("compressing lockfile: %w", err)
+		}
+		if _, err := zw.Write(data); err != nil {
+			return fmt.Errorf("compressing lockfile: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("compressing lockfile: %w", err)
+		}
+		data = buf.Bytes()
+	}
+
+	if err := writeFile(path, data); err != nil {
 		return fmt.Errorf("writing lockfile: %w", err)
 	}
 
 	return nil
 }
+
+// writeFile atomically replaces path with data, so readers never see a
+// partial lockfile. An existing file keeps its permissions; new lockfiles,
+// which are meant to be committed, get 0644.
+func writeFile(path string, data []byte) error {
+	perm := os.FileMode(0o644)
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		// Replace the target of a symlinked lockfile, not the link.
+		path = resolved
+		if info, err := os.Stat(path); err == nil {
+			perm = info.Mode().Perm()
+		}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".nopher-lock-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}